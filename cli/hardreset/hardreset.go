@@ -58,6 +58,11 @@ func hardReset(c *config.Config) error {
 		return err
 	}
 
+	if c.SessionStore != "" && c.SessionStore != "redis" {
+		log.Println("Reset complete")
+		return nil
+	}
+
 	conn, err := redis.Dial("tcp", c.RedisAddress)
 	if err != nil {
 		return err
@@ -15,6 +15,7 @@ import (
 	"github.com/discuitnet/discuit/config"
 	"github.com/discuitnet/discuit/core"
 	"github.com/discuitnet/discuit/internal/images"
+	"github.com/discuitnet/discuit/internal/mail"
 	"github.com/discuitnet/discuit/internal/uid"
 	"github.com/discuitnet/discuit/server"
 	"github.com/urfave/cli/v2"
@@ -59,6 +60,52 @@ func serve(ctx *cli.Context) error {
 	}
 	images.SetImagesRootFolder(p)
 
+	exportsFolder, err := filepath.Abs(conf.DataExportsFolderPath)
+	if err != nil {
+		log.Fatalf("Error attempting to set the data exports folder location (%s): %v", conf.DataExportsFolderPath, err)
+	}
+	core.SetDataExportOptions(core.DataExportOptions{
+		Folder:  exportsFolder,
+		HMACKey: []byte(conf.HMACSecret),
+		TTL:     time.Hour * 24 * time.Duration(conf.DataExportExpiryDays),
+	})
+
+	core.SetIPHistoryOptions(core.IPHistoryOptions{
+		Salt:      conf.HMACSecret,
+		Retention: time.Hour * 24 * time.Duration(conf.IPHistoryRetentionDays),
+	})
+	if err := core.HashStoredIPs(context.Background(), db); err != nil {
+		log.Fatalf("Error hashing stored IP addresses: %v", err)
+	}
+
+	core.SetSpamOptions(core.SpamOptions{
+		FlagScore:        conf.SpamFlagScore,
+		HoldScore:        conf.SpamHoldScore,
+		ShadowLimitScore: conf.SpamShadowLimitScore,
+	})
+
+	var mailSender mail.Sender
+	switch conf.MailSender {
+	case "smtp":
+		mailSender = &mail.SMTPSender{Addr: conf.SMTPAddr, Username: conf.SMTPUsername, Password: conf.SMTPPassword}
+	case "file":
+		mailFolder, err := filepath.Abs(conf.MailFolderPath)
+		if err != nil {
+			log.Fatalf("Error attempting to set the mail folder location (%s): %v", conf.MailFolderPath, err)
+		}
+		mailSender = &mail.FileSender{Folder: mailFolder}
+	}
+	if mailSender != nil && conf.SiteURL == "" {
+		log.Fatal("siteURL is required for sending emails")
+	}
+	core.SetEmailDigestOptions(core.EmailDigestOptions{
+		Sender:   mailSender,
+		From:     conf.MailFrom,
+		SiteName: conf.SiteName,
+		SiteURL:  conf.SiteURL,
+		HMACKey:  []byte(conf.HMACSecret),
+	})
+
 	// Create default badges.
 	if err := core.NewBadgeType(db, "supporter"); err != nil {
 		log.Fatalf("Error creating 'supporter' user badge: %v\n", err)
@@ -82,10 +129,76 @@ func serve(ctx *cli.Context) error {
 			} else {
 				log.Printf("Removed %d temp images\n", n)
 			}
+			if n, err := core.UpdateSitemaps(context.TODO(), db); err != nil {
+				log.Printf("Failed to update sitemaps: %v\n", err)
+			} else {
+				log.Printf("Regenerated %d sitemap shards\n", n)
+			}
+			if n, err := core.PurgeContentHashes(context.TODO(), db); err != nil {
+				log.Printf("Failed to purge content hashes: %v\n", err)
+			} else {
+				log.Printf("Purged %d old content hashes\n", n)
+			}
+			if n, err := core.FailInterruptedDataExports(context.TODO(), db); err != nil {
+				log.Printf("Failed to mark interrupted data exports as failed: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Marked %d interrupted data exports as failed\n", n)
+			}
+			if n, err := core.PurgeExpiredDataExports(context.TODO(), db); err != nil {
+				log.Printf("Failed to purge expired data exports: %v\n", err)
+			} else {
+				log.Printf("Purged %d expired data exports\n", n)
+			}
+			if n, err := core.PurgeViewedPosts(context.TODO(), db); err != nil {
+				log.Printf("Failed to purge viewed posts: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Purged %d old viewed posts\n", n)
+			}
+			if n, err := core.PurgeUserIPs(context.TODO(), db); err != nil {
+				log.Printf("Failed to purge user IP history: %v\n", err)
+			} else {
+				log.Printf("Purged %d old user IP addresses\n", n)
+			}
+			if n, err := core.LiftExpiredBans(context.TODO(), db); err != nil {
+				log.Printf("Failed to lift expired site bans: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Lifted %d expired site bans\n", n)
+			}
+			if n, err := site.DeleteScheduledUsers(context.TODO()); err != nil {
+				log.Printf("Failed to delete users scheduled for deletion: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Deleted %d users scheduled for deletion\n", n)
+			}
+			if conf.LiveThreadIdleHours > 0 {
+				if n, err := core.LockIdleLiveThreads(context.TODO(), db, time.Hour*time.Duration(conf.LiveThreadIdleHours)); err != nil {
+					log.Printf("Failed to lock idle live threads: %v\n", err)
+				} else if n > 0 {
+					log.Printf("Locked %d idle live threads\n", n)
+				}
+			}
+			if n, err := core.SendEmailDigests(context.TODO(), db); err != nil {
+				log.Printf("Failed to send email digests: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Sent %d email digests\n", n)
+			}
+			if err := site.PurgeExpiredSessions(); err != nil {
+				log.Printf("Failed to purge expired sessions: %v\n", err)
+			}
 			time.Sleep(time.Hour)
 		}
 	}()
 
+	go func() {
+		// The rising scores of posts decay as they stop getting votes, so they
+		// are recalculated more often than the hourly jobs.
+		for {
+			if _, err := core.UpdatePostsRising(context.TODO(), db); err != nil {
+				log.Printf("Failed to update rising scores: %v\n", err)
+			}
+			time.Sleep(time.Minute * 10)
+		}
+	}()
+
 	if !config.AddressValid(conf.Addr) {
 		log.Fatal("Address needs to be a valid address of the form 'host:port' (host can be empty)")
 	}
@@ -30,6 +30,10 @@ type Config struct {
 
 	RedisAddress string `yaml:"redisAddress"`
 
+	// SessionStore is where sessions, OTPs, and rate limits are stored. It's
+	// one of redis (the default), memory, or sql.
+	SessionStore string `yaml:"sessionStore"`
+
 	HMACSecret string `yaml:"hmacSecret"`
 
 	CSRFOff bool `yaml:"csrfOff"`
@@ -53,6 +57,9 @@ type Config struct {
 	// where value is AdminAPIKey, rate limits are disabled.
 	AdminAPIKey string `yaml:"adminAPIKey"`
 
+	// DisableImagePosts, ForumCreationReqPoints, and MaxForumsPerUser are only
+	// the initial values of the site settings (see core.SiteSettings). Once an
+	// admin saves the site settings, the saved values override these.
 	DisableImagePosts bool `yaml:"disableImagePosts"`
 
 	DisableForumCreation   bool `yaml:"disableForumCreation"`   // If true, only admins can create communities.
@@ -64,6 +71,15 @@ type Config struct {
 
 	MaxImagesPerPost int `yaml:"maxImagesPerPost"`
 
+	// The location where the data exports (takeouts) of users are saved, and
+	// the number of days they can be downloaded for.
+	DataExportsFolderPath string `yaml:"dataExportsFolderPath"`
+	DataExportExpiryDays  int    `yaml:"dataExportExpiryDays"`
+
+	// Live threads with no activity for this many hours are locked (0
+	// disables).
+	LiveThreadIdleHours int `yaml:"liveThreadIdleHours"`
+
 	// For the front-end:
 	CaptchaSiteKey string `yaml:"captchaSiteKey"`
 	EmailContact   string `yaml:"emailContact"`
@@ -80,6 +96,38 @@ type Config struct {
 	// novu
 	NovuApiKey string `yaml:"novuApiKey"`
 	NovuApiUrl string `yaml:"novuApiUrl"`
+
+	// Spam scores (0-100) at or above which new content is listed for admins
+	// to review, held for review, or gets its author shadow limited. A value
+	// of 0 disables the action.
+	SpamFlagScore        int `yaml:"spamFlagScore"`
+	SpamHoldScore        int `yaml:"spamHoldScore"`
+	SpamShadowLimitScore int `yaml:"spamShadowLimitScore"`
+
+	// The number of days after which an account that's scheduled for deletion
+	// is deleted. Logging in during this period cancels the deletion.
+	AccountDeletionGraceDays int `yaml:"accountDeletionGraceDays"`
+
+	// The number of days the IP addresses of users (which are only stored
+	// hashed), and the IP ban matches, are kept for detecting ban evasion.
+	IPHistoryRetentionDays int `yaml:"ipHistoryRetentionDays"`
+
+	// If true, admins and mods have to enable two-factor authentication
+	// before they can do anything else after logging in.
+	RequireTwoFactorForMods bool `yaml:"requireTwoFactorForMods"`
+
+	// The URL of the site (like https://discuit.net), used for links in
+	// emails and in sitemaps. Sitemaps are not served if it's not set.
+	SiteURL string `yaml:"siteURL"`
+
+	// How emails (like email digests) are sent: "smtp", "file" (saved as .eml
+	// files in MailFolderPath, for development), or empty to not send any.
+	MailSender     string `yaml:"mailSender"`
+	MailFrom       string `yaml:"mailFrom"`
+	MailFolderPath string `yaml:"mailFolderPath"`
+	SMTPAddr       string `yaml:"smtpAddr"` // host:port
+	SMTPUsername   string `yaml:"smtpUsername"`
+	SMTPPassword   string `yaml:"smtpPassword"`
 }
 
 // Parse parses the yaml file at path and returns a Config.
@@ -90,12 +138,27 @@ func Parse(path string) (*Config, error) {
 		DBUser:             "discuit",
 		SessionCookieName:  "SID",
 		RedisAddress:       ":6381",
+		SessionStore:       "redis",
 		PaginationLimit:    10,
 		PaginationLimitMax: 50,
 		DefaultFeedSort:    core.FeedSortHot,
 		MaxImageSize:       25 * (1 << 20),
 		MaxImagesPerPost:   10,
 
+		DataExportsFolderPath: "exports",
+		DataExportExpiryDays:  3,
+
+		LiveThreadIdleHours: 6,
+
+		AccountDeletionGraceDays: 30,
+		IPHistoryRetentionDays:   90,
+
+		MailFolderPath: "mails",
+
+		SpamFlagScore:        40,
+		SpamHoldScore:        60,
+		SpamShadowLimitScore: 90,
+
 		// Required fields:
 		ForumCreationReqPoints: -1,
 		MaxForumsPerUser:       -1,
@@ -119,6 +182,7 @@ func Parse(path string) (*Config, error) {
 		"DISCUIT_SESSION_COOKIE_NAME": &c.SessionCookieName,
 
 		"DISCUIT_REDIS_ADDRESS": &c.RedisAddress,
+		"DISCUIT_SESSION_STORE": &c.SessionStore,
 
 		"DISCUIT_HMAC_SECRET": &c.HMACSecret,
 
@@ -151,6 +215,28 @@ func Parse(path string) (*Config, error) {
 		// The location where images are saved on disk.
 		"DISCUIT_IMAGES_FOLDER_PATH": &c.ImagesFolderPath,
 
+		"DISCUIT_DATA_EXPORTS_FOLDER_PATH": &c.DataExportsFolderPath,
+		"DISCUIT_DATA_EXPORT_EXPIRY_DAYS":  &c.DataExportExpiryDays,
+
+		"DISCUIT_LIVE_THREAD_IDLE_HOURS": &c.LiveThreadIdleHours,
+
+		"DISCUIT_ACCOUNT_DELETION_GRACE_DAYS": &c.AccountDeletionGraceDays,
+		"DISCUIT_IP_HISTORY_RETENTION_DAYS":   &c.IPHistoryRetentionDays,
+
+		"DISCUIT_SPAM_FLAG_SCORE":         &c.SpamFlagScore,
+		"DISCUIT_SPAM_HOLD_SCORE":         &c.SpamHoldScore,
+		"DISCUIT_SPAM_SHADOW_LIMIT_SCORE": &c.SpamShadowLimitScore,
+
+		"DISCUIT_REQUIRE_TWO_FACTOR_FOR_MODS": &c.RequireTwoFactorForMods,
+
+		"DISCUIT_SITE_URL":         &c.SiteURL,
+		"DISCUIT_MAIL_SENDER":      &c.MailSender,
+		"DISCUIT_MAIL_FROM":        &c.MailFrom,
+		"DISCUIT_MAIL_FOLDER_PATH": &c.MailFolderPath,
+		"DISCUIT_SMTP_ADDR":        &c.SMTPAddr,
+		"DISCUIT_SMTP_USERNAME":    &c.SMTPUsername,
+		"DISCUIT_SMTP_PASSWORD":    &c.SMTPPassword,
+
 		// For the front-end:
 		"DISCUIT_CAPTCHA_SITEKEY": &c.CaptchaSiteKey,
 		"DISCUIT_EMAIL_CONTACT":   &c.EmailContact,
@@ -207,6 +293,11 @@ func Parse(path string) (*Config, error) {
 	if c.MaxForumsPerUser == -1 {
 		return nil, errors.New("MaxForumsPerUser cannot be (-1)")
 	}
+	switch c.MailSender {
+	case "", "smtp", "file":
+	default:
+		return nil, errors.New("mailSender must be one of smtp, file, or empty")
+	}
 
 	return c, nil
 }
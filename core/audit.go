@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// AuditEntry is a record of an action taken by an admin (through the admin
+// API).
+type AuditEntry struct {
+	ID            int             `json:"id"`
+	AdminID       uid.ID          `json:"adminId"`
+	AdminUsername string          `json:"adminUsername"`
+	Action        string          `json:"action"`
+	TargetType    msql.NullString `json:"targetType"`
+	Target        msql.NullString `json:"target"`
+	Details       json.RawMessage `json:"details"`
+	IP            msql.NullString `json:"ip"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
+
+// CreateAuditEntry records the action taken by admin. The target of the action
+// (a username or a community name, for instance) is optional, and so is
+// details, which, if not nil, is stored as JSON.
+func CreateAuditEntry(ctx context.Context, db *sql.DB, admin uid.ID, action, targetType, target string, details any, ip string) error {
+	var detailsJSON msql.NullString
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		detailsJSON = msql.NewNullString(string(data))
+	}
+	query, args := msql.BuildInsertQuery("admin_audit_log", []msql.ColumnValue{
+		{Name: "admin_id", Value: admin},
+		{Name: "action", Value: action},
+		{Name: "target_type", Value: nullString(targetType)},
+		{Name: "target", Value: nullString(target)},
+		{Name: "details", Value: detailsJSON},
+		{Name: "ip", Value: nullString(ip)},
+	})
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetAuditEntries returns a page of audit log entries (newest first) and the
+// total number of entries. If admin is not nil, only the actions taken by that
+// admin are returned.
+func GetAuditEntries(ctx context.Context, db *sql.DB, admin *uid.ID, limit, page int) (int, []*AuditEntry, error) {
+	where, args := "", []any{}
+	if admin != nil {
+		where = "WHERE admin_audit_log.admin_id = ?"
+		args = append(args, *admin)
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM admin_audit_log "+where, args...).Scan(&count); err != nil {
+		return 0, nil, err
+	}
+
+	query := msql.BuildSelectQuery("admin_audit_log", []string{
+		"admin_audit_log.id",
+		"admin_audit_log.admin_id",
+		"users.username",
+		"admin_audit_log.action",
+		"admin_audit_log.target_type",
+		"admin_audit_log.target",
+		"admin_audit_log.details",
+		"admin_audit_log.ip",
+		"admin_audit_log.created_at",
+	}, []string{
+		"INNER JOIN users ON users.id = admin_audit_log.admin_id",
+	}, where+" ORDER BY admin_audit_log.id DESC LIMIT ? OFFSET ?")
+	args = append(args, limit, limit*(page-1))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		e := &AuditEntry{}
+		var details msql.NullString
+		if err := rows.Scan(
+			&e.ID,
+			&e.AdminID,
+			&e.AdminUsername,
+			&e.Action,
+			&e.TargetType,
+			&e.Target,
+			&details,
+			&e.IP,
+			&e.CreatedAt); err != nil {
+			return 0, nil, err
+		}
+		if details.Valid {
+			e.Details = json.RawMessage(details.String)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	return count, entries, nil
+}
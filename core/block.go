@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// A block goes further than a mute. Besides hiding the content of the blocked
+// user from the blocker (as a mute does), the blocked user cannot reply to the
+// posts and comments of the blocker, or mention the blocker, and no
+// notifications are sent between the two users (in either direction).
+
+// Block is a user blocked by another user.
+type Block struct {
+	ID            int       `json:"-"`
+	User          uid.ID    `json:"-"`
+	BlockedUserID uid.ID    `json:"blockedUserId"`
+	CreatedAt     time.Time `json:"createdAt"`
+
+	BlockedUser *User `json:"blockedUser,omitempty"`
+}
+
+// BlockUser makes user block blockedUser. Blocking an already blocked user is
+// a no-op.
+func BlockUser(ctx context.Context, db *sql.DB, user, blockedUser uid.ID) error {
+	if user == blockedUser {
+		return errCannotBlockSelf
+	}
+	if is, err := UserDeleted(db, blockedUser); err != nil {
+		return err
+	} else if is {
+		return ErrUserDeleted
+	}
+
+	_, err := db.ExecContext(ctx, "INSERT INTO blocked_users (user_id, blocked_user_id) VALUES (?, ?)", user, blockedUser)
+	if err != nil && msql.IsErrDuplicateErr(err) {
+		return nil
+	}
+	return err
+}
+
+// UnblockUser lifts the block of blockedUser by user.
+func UnblockUser(ctx context.Context, db *sql.DB, user, blockedUser uid.ID) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM blocked_users WHERE user_id = ? AND blocked_user_id = ?", user, blockedUser)
+	return err
+}
+
+// GetBlockedUsers returns the users blocked by user (oldest first). If
+// fillUsers is true, the BlockedUser field of each block is populated.
+func GetBlockedUsers(ctx context.Context, db *sql.DB, user uid.ID, fillUsers bool) ([]*Block, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, blocked_user_id, created_at FROM blocked_users WHERE user_id = ? ORDER BY id", user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []*Block{}
+	var ids []uid.ID
+	for rows.Next() {
+		block := &Block{User: user}
+		if err := rows.Scan(&block.ID, &block.BlockedUserID, &block.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+		ids = append(ids, block.BlockedUserID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if fillUsers && len(ids) > 0 {
+		users, err := GetUsersByIDs(ctx, db, ids, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			for _, block := range blocks {
+				if block.BlockedUserID == user.ID {
+					block.BlockedUser = user
+					break
+				}
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// UserBlocked reports whether blocker has blocked blocked.
+func UserBlocked(ctx context.Context, db *sql.DB, blocker, blocked uid.ID) (bool, error) {
+	var rowID int
+	if err := db.QueryRowContext(ctx, "SELECT id FROM blocked_users WHERE user_id = ? AND blocked_user_id = ?", blocker, blocked).Scan(&rowID); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("UserBlocked db error: %w", err)
+	}
+	return true, nil
+}
+
+// usersBlocked reports whether either of the two users has blocked the other.
+func usersBlocked(ctx context.Context, db *sql.DB, a, b uid.ID) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM blocked_users
+		WHERE (user_id = ? AND blocked_user_id = ?) OR (user_id = ? AND blocked_user_id = ?)`, a, b, b, a).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
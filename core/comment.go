@@ -95,6 +95,12 @@ type Comment struct {
 	DeletedBy uid.NullID    `json:"-"`
 	DeletedAs UserGroup     `json:"deletedAs,omitempty"`
 
+	// The content of held comments is only shown to their authors, mods, and
+	// admins until the comment is approved.
+	Held       bool            `json:"held"`
+	HeldAt     msql.NullTime   `json:"heldAt,omitempty"`
+	HeldReason msql.NullString `json:"heldReason,omitempty"`
+
 	Author *User `json:"author,omitempty"`
 
 	// Reports whether the author of this comment is muted by the viewer.
@@ -132,6 +138,8 @@ func buildSelectCommentsQuery(loggedIn bool, where string) string {
 		"comments.edited_at",
 		"comments.deleted_at",
 		"comments.deleted_as",
+		"comments.held_at",
+		"comments.held_reason",
 	}
 	var joins []string
 	if loggedIn {
@@ -219,6 +227,8 @@ func scanComments(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.I
 			&comment.EditedAt,
 			&comment.DeletedAt,
 			&comment.DeletedAs,
+			&comment.HeldAt,
+			&comment.HeldReason,
 		}
 		if loggedIn {
 			dest = append(dest, &comment.ViewerVoted, &comment.ViewerVotedUp)
@@ -229,6 +239,7 @@ func scanComments(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.I
 		}
 
 		comment.Deleted = comment.DeletedAt.Valid
+		comment.Held = comment.HeldAt.Valid
 		if comment.Deleted {
 			comment.setStrippedContent(false)
 		}
@@ -298,6 +309,34 @@ func scanComments(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.I
 		}
 	}
 
+	// Strip held comments, unless the viewer is the author, a mod, or an admin.
+	if !viewerAdmin {
+		viewerModOf := make(map[uid.ID]bool) // keys are community ids
+		for _, comment := range comments {
+			if !comment.Held || comment.Deleted {
+				continue
+			}
+			if viewer != nil {
+				if comment.AuthorID == *viewer {
+					continue
+				}
+				viewerMod, ok := viewerModOf[comment.CommunityID]
+				if !ok {
+					var err error
+					viewerMod, err = UserMod(ctx, db, comment.CommunityID, *viewer)
+					if err != nil {
+						return nil, err
+					}
+					viewerModOf[comment.CommunityID] = viewerMod
+				}
+				if viewerMod {
+					continue
+				}
+			}
+			comment.stripHeldContent()
+		}
+	}
+
 	// Strip deleted author information, unless the viewer is an admin.
 	for _, comment := range comments {
 		if comment.AuthorDeleted {
@@ -321,6 +360,14 @@ func addComment(ctx context.Context, db *sql.DB, post *Post, author *User, paren
 		ancestors []uid.ID
 	)
 
+	verdict, err := checkContent(ctx, db, post.CommunityID, commentBody)
+	if err != nil {
+		return nil, err
+	}
+	if err := verdict.scoreSpam(ctx, db, post.CommunityID, author.ID, commentBody, nil); err != nil {
+		return nil, err
+	}
+
 	if parentID != nil {
 		parent, err = GetComment(ctx, db, *parentID, nil)
 		if err != nil {
@@ -336,6 +383,21 @@ func addComment(ctx context.Context, db *sql.DB, post *Post, author *User, paren
 		ancestors = append(ancestors, parent.ID)
 	}
 
+	// Users cannot reply to the posts and comments of users who have blocked
+	// them.
+	if blocked, err := UserBlocked(ctx, db, post.AuthorID, author.ID); err != nil {
+		return nil, err
+	} else if blocked {
+		return nil, errBlocked
+	}
+	if parent != nil {
+		if blocked, err := UserBlocked(ctx, db, parent.AuthorID, author.ID); err != nil {
+			return nil, err
+		} else if blocked {
+			return nil, errBlocked
+		}
+	}
+
 	id := uid.New()
 	f := func(tx *sql.Tx) error {
 		depth, newParentID := 0, uid.NullID{}
@@ -384,8 +446,19 @@ func addComment(ctx context.Context, db *sql.DB, post *Post, author *User, paren
 		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
 			return err
 		}
+		if verdict.hold {
+			if _, err = tx.ExecContext(ctx, "UPDATE comments SET held_at = ?, held_reason = ? WHERE id = ?", now, verdict.holdReason, id); err != nil {
+				return err
+			}
+		}
 
-		if _, err = tx.ExecContext(ctx, "UPDATE posts SET no_comments = no_comments + 1, last_activity_at = ? WHERE id = ?", now, post.ID); err != nil {
+		// The comments of shadow banned users are not counted, and held
+		// comments are counted once approved.
+		if _, err = tx.ExecContext(ctx, `
+			UPDATE posts SET
+				no_comments = no_comments + IF(? OR (SELECT shadow_banned_at IS NOT NULL FROM users WHERE id = ?), 0, 1),
+				last_activity_at = ?
+			WHERE id = ?`, verdict.hold, author.ID, now, post.ID); err != nil {
 			return err
 		}
 
@@ -403,9 +476,11 @@ func addComment(ctx context.Context, db *sql.DB, post *Post, author *User, paren
 			}
 		}
 
-		// For the user profile.
-		if _, err := tx.ExecContext(ctx, "INSERT INTO posts_comments (target_id, user_id, target_type) VALUES (?, ?, ?)", id, author.ID, ContentTypeComment); err != nil {
-			return err
+		// For the user profile (held comments are added once approved).
+		if !verdict.hold {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO posts_comments (target_id, user_id, target_type) VALUES (?, ?, ?)", id, author.ID, ContentTypeComment); err != nil {
+				return err
+			}
 		}
 
 		for _, v := range ancestors {
@@ -425,7 +500,43 @@ func addComment(ctx context.Context, db *sql.DB, post *Post, author *User, paren
 		return nil, err
 	}
 
+	verdict.record(db, post.CommunityID, uid.NullID{Valid: true, ID: post.ID}, ReportTypeComment, id)
+	if verdict.hold {
+		// No notifications are sent for held comments.
+		return GetComment(ctx, db, id, &author.ID)
+	}
+	if shadowBanned, err := userShadowBanned(db, author.ID); err != nil {
+		return nil, err
+	} else if shadowBanned {
+		// Nor for the comments of shadow banned users.
+		return GetComment(ctx, db, id, &author.ID)
+	}
+
+	announceComment(db, post, id, parent, ancestors, author, commentBody)
+	return GetComment(ctx, db, id, nil)
+}
+
+// announceComment publishes the new comment id, on post, to the post's
+// real-time subscribers and sends the notifications about it. parent is nil
+// for top-level comments.
+func announceComment(db *sql.DB, post *Post, id uid.ID, parent *Comment, ancestors []uid.ID, author *User, body string) {
+	var parentID *uid.ID
+	if parent != nil {
+		parentID = &parent.ID
+	}
+	publishNewComment(post.ID, id, parentID)
+
 	// Send notifications.
+	mentionUsers(db, author.ID, post.ID, post.CommunityID, &id, body)
+	go func() {
+		var parentAuthor *uid.ID
+		if parent != nil {
+			parentAuthor = &parent.AuthorID
+		}
+		if err := notifyThreadSubscribers(context.Background(), db, post, id, ancestors, author, parentAuthor); err != nil {
+			log.Printf("Notifying thread subscribers failed: %v\n", err)
+		}
+	}()
 	if parent != nil && !parent.AuthorID.EqualsTo(author.ID) {
 		go func() {
 			if err := CreateCommentReplyNotification(context.Background(), db, parent.AuthorID, parent.ID, id, author, post); err != nil {
@@ -441,8 +552,6 @@ func addComment(ctx context.Context, db *sql.DB, post *Post, author *User, paren
 			}
 		}()
 	}
-
-	return GetComment(ctx, db, id, nil)
 }
 
 // Save updates comment's body.
@@ -456,16 +565,181 @@ func (c *Comment) Save(ctx context.Context, user uid.ID) error {
 
 	c.Body = utils.TruncateUnicodeString(c.Body, maxCommentBodyLength)
 
+	verdict, err := checkContent(ctx, c.db, c.CommunityID, c.Body)
+	if err != nil {
+		return err
+	}
+
 	now := time.Now()
 	query := "UPDATE comments SET body = ?, edited_at = ? WHERE id = ? AND deleted_at IS NULL"
-	_, err := c.db.ExecContext(ctx, query, c.Body, now, c.ID)
-	if err == nil {
-		c.EditedAt.Valid = true
-		c.EditedAt.Time = now
+	if _, err = c.db.ExecContext(ctx, query, c.Body, now, c.ID); err != nil {
+		return err
+	}
+	c.EditedAt.Valid = true
+	c.EditedAt.Time = now
+	if verdict.hold {
+		if err := c.hold(ctx, verdict.holdReason); err != nil {
+			return err
+		}
+	}
+	verdict.record(c.db, c.CommunityID, uid.NullID{Valid: true, ID: c.PostID}, ReportTypeComment, c.ID)
+	if !c.Held {
+		mentionUsers(c.db, c.AuthorID, c.PostID, c.CommunityID, &c.ID, c.Body)
+		if shadowBanned, err := userShadowBanned(c.db, c.AuthorID); err == nil && !shadowBanned {
+			publishCommentEdited(c.PostID, c.ID)
+		}
+	}
+	return nil
+}
+
+// hold holds the comment for review by the mods. If the comment is already
+// held, only the reason is updated.
+func (c *Comment) hold(ctx context.Context, reason string) error {
+	if c.Held {
+		if _, err := c.db.ExecContext(ctx, "UPDATE comments SET held_reason = ? WHERE id = ?", reason, c.ID); err != nil {
+			return err
+		}
+		c.HeldReason = msql.NewNullString(reason)
+		return nil
+	}
+
+	now := time.Now()
+	err := msql.Transact(ctx, c.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE comments SET held_at = ?, held_reason = ? WHERE id = ? AND held_at IS NULL", now, reason, c.ID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err // Held concurrently.
+		}
+		if err := c.updatePostCommentsCount(ctx, tx, -1); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM posts_comments WHERE target_id = ? AND user_id = ?", c.ID, c.AuthorID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	c.Held = true
+	c.HeldAt = msql.NewNullTime(now)
+	c.HeldReason = msql.NewNullString(reason)
+	return nil
+}
+
+// Approve releases a held comment. Only mods of the community and admins can
+// approve comments.
+func (c *Comment) Approve(ctx context.Context, mod uid.ID) error {
+	if !c.Held {
+		return errNotHeld
 	}
+	if ok, err := UserModOrAdmin(ctx, c.db, c.CommunityID, mod); err != nil {
+		return err
+	} else if !ok {
+		return errNotMod
+	}
+
+	err := msql.Transact(ctx, c.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE comments SET held_at = NULL, held_reason = NULL WHERE id = ? AND held_at IS NOT NULL", c.ID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return errNotHeld // Approved concurrently.
+		}
+		if err := c.updatePostCommentsCount(ctx, tx, 1); err != nil {
+			return err
+		}
+		if c.Deleted {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, "INSERT IGNORE INTO posts_comments (target_id, user_id, target_type) VALUES (?, ?, ?)", c.ID, c.AuthorID, ContentTypeComment)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	heldAt := c.HeldAt.Time
+	c.Held = false
+	c.HeldAt = msql.NullTime{}
+	c.HeldReason = msql.NullString{}
+	if !c.Deleted {
+		if err := c.announceApproved(ctx, heldAt); err != nil {
+			log.Printf("Announcing approved comment %v failed: %v\n", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// announceApproved does what was held back while c was held, which it was
+// since heldAt. Comments held when they were made are announced as new
+// comments, and comments held on an edit as edited ones.
+func (c *Comment) announceApproved(ctx context.Context, heldAt time.Time) error {
+	if shadowBanned, err := userShadowBanned(c.db, c.AuthorID); err != nil || shadowBanned {
+		return err
+	}
+	if !heldAt.Equal(c.CreatedAt) {
+		mentionUsers(c.db, c.AuthorID, c.PostID, c.CommunityID, &c.ID, c.Body)
+		publishCommentEdited(c.PostID, c.ID)
+		return nil
+	}
+
+	post, err := GetPost(ctx, c.db, &c.PostID, "", nil, true)
+	if err != nil || post.Deleted {
+		return err
+	}
+	author, err := GetUser(ctx, c.db, c.AuthorID, nil)
+	if err != nil {
+		return err
+	}
+	var parent *Comment
+	if c.ParentID.Valid {
+		if parent, err = GetComment(ctx, c.db, c.ParentID.ID, nil); err != nil {
+			return err
+		}
+	}
+	announceComment(c.db, post, c.ID, parent, c.Ancestors, author, c.Body)
+	return nil
+}
+
+// updatePostCommentsCount adds delta to the comments count of the post of c,
+// unless the author of c is shadow banned (whose comments are not counted).
+func (c *Comment) updatePostCommentsCount(ctx context.Context, tx *sql.Tx, delta int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE posts SET no_comments = no_comments + ?
+		WHERE id = ? AND NOT (SELECT shadow_banned_at IS NOT NULL FROM users WHERE id = ?)`, delta, c.PostID, c.AuthorID)
 	return err
 }
 
+// GetCommentsHeld returns a slice of comments that are held for review (sorted
+// by ID) and the number of such comments in community.
+func GetCommentsHeld(ctx context.Context, db *sql.DB, community uid.ID, viewer uid.ID, limit, page int) (int, []*Comment, error) {
+	count := 0
+	row := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM comments WHERE community_id = ? AND deleted_at IS NULL AND held_at IS NOT NULL", community)
+	if err := row.Scan(&count); err != nil {
+		return 0, nil, err
+	}
+	if count == 0 {
+		return 0, []*Comment{}, nil
+	}
+
+	query := buildSelectCommentsQuery(true, "WHERE comments.community_id = ? AND comments.deleted_at IS NULL AND comments.held_at IS NOT NULL ORDER BY comments.id DESC LIMIT ? OFFSET ?")
+	rows, err := db.QueryContext(ctx, query, viewer, community, limit, limit*(page-1))
+	if err != nil {
+		return 0, nil, err
+	}
+	comments, err := scanComments(ctx, db, rows, &viewer)
+	if err != nil {
+		if err == errCommentNotFound {
+			return count, []*Comment{}, nil
+		}
+		return 0, nil, err
+	}
+	return count, comments, nil
+}
+
 // Delete returns an error if user, who's deleting the comment, has no
 // permissions in his capacity as g to delete this comment.
 func (c *Comment) Delete(ctx context.Context, user uid.ID, g UserGroup) error {
@@ -542,6 +816,20 @@ func (c *Comment) setStrippedContent(v bool) {
 	*c.ContentStripped = v
 }
 
+// stripHeldContent strips the content of a held comment for viewers who are
+// not allowed to see it.
+func (c *Comment) stripHeldContent() {
+	c.setStrippedContent(true)
+	c.AuthorID.Clear()
+	c.AuthorUsername = "[Hidden]"
+	c.PostedAs = UserGroupNaN
+	c.Body = "[Comment held for review]"
+	c.HeldReason = msql.NullString{}
+	c.ViewerVoted.Valid = false
+	c.ViewerVotedUp.Valid = false
+	c.Author = nil
+}
+
 // StripAuthorInfo should be called if the author account of the comment is
 // deleted and the viewer is not an admin.
 func (c *Comment) StripAuthorInfo() {
@@ -625,8 +913,9 @@ func (c *Comment) Vote(ctx context.Context, user uid.ID, up bool) error {
 		incrementUserPoints(ctx, c.db, c.AuthorID, 1)
 	}
 
-	// Attempt to create a notification (only for upvotes).
-	if !c.AuthorID.EqualsTo(user) && up {
+	// Attempt to create a notification (only for upvotes, and not for the votes
+	// of shadow banned users).
+	if shadowBanned, _ := userShadowBanned(c.db, user); !c.AuthorID.EqualsTo(user) && up && !shadowBanned {
 		go func() {
 			if err := CreateNewVotesNotification(context.Background(), c.db, c.AuthorID, c.CommunityName, false, c.ID); err != nil {
 				log.Printf("Failed creating new_votes notification: %v\n", err)
@@ -634,6 +923,7 @@ func (c *Comment) Vote(ctx context.Context, user uid.ID, up bool) error {
 		}()
 	}
 
+	c.publishVotes()
 	return nil
 }
 
@@ -692,6 +982,7 @@ func (c *Comment) DeleteVote(ctx context.Context, user uid.ID) error {
 		incrementUserPoints(ctx, c.db, c.AuthorID, -1)
 	}
 
+	c.publishVotes()
 	return nil
 }
 
@@ -759,6 +1050,7 @@ func (c *Comment) ChangeVote(ctx context.Context, user uid.ID, up bool) error {
 		incrementUserPoints(ctx, c.db, c.AuthorID, points)
 	}
 
+	c.publishVotes()
 	return nil
 }
 
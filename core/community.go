@@ -37,6 +37,13 @@ type Community struct {
 	DeletedAt     msql.NullTime   `json:"deletedAt"`
 	DeletedBy     uid.NullID      `json:"-"`
 
+	// A quarantined community is left out of the site-wide feed, community
+	// listings, and search. Clients are expected to show a warning before its
+	// content (see quarantine.go).
+	Quarantined      bool            `json:"quarantined"`
+	QuarantinedAt    msql.NullTime   `json:"quarantinedAt"`
+	QuarantineReason msql.NullString `json:"quarantineReason"`
+
 	// IsDefault is nil until Default is called.
 	IsDefault *bool `json:"isDefault,omitempty"`
 
@@ -60,6 +67,8 @@ func buildSelectCommunityQuery(where string) string {
 		"communities.no_members",
 		"communities.created_at",
 		"communities.deleted_at",
+		"communities.quarantined_at",
+		"communities.quarantine_reason",
 	}
 	cols = append(cols, images.ImageColumns("pro_pic")...)
 	cols = append(cols, images.ImageColumns("banner")...)
@@ -167,6 +176,8 @@ func scanCommunities(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *ui
 			&c.NumMembers,
 			&c.CreatedAt,
 			&c.DeletedAt,
+			&c.QuarantinedAt,
+			&c.QuarantineReason,
 		}
 
 		proPic, bannerImage := &images.Image{}, &images.Image{}
@@ -177,6 +188,8 @@ func scanCommunities(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *ui
 			return nil, err
 		}
 
+		c.Quarantined = c.QuarantinedAt.Valid
+
 		if proPic.ID != nil {
 			proPic.PostScan()
 			setCommunityProPicCopies(proPic)
@@ -225,9 +238,6 @@ var communityCreationAdminOnly = true
 // created more communities than maxPerUser.
 func CreateCommunity(ctx context.Context, db *sql.DB, creator uid.ID, reqPoints, maxPerUser int, name, about string) (*Community, error) {
 	about = utils.TruncateUnicodeString(about, maxCommunityAboutLength)
-	if err := IsUsernameValid(name); err != nil {
-		return nil, httperr.NewBadRequest("invalid-community-name", fmt.Sprintf("Community name invalid. It %s.", err.Error()))
-	}
 
 	user, err := GetUser(ctx, db, creator, nil)
 	if err != nil {
@@ -251,21 +261,10 @@ func CreateCommunity(ctx context.Context, db *sql.DB, creator uid.ID, reqPoints,
 		}
 	}
 
-	// Check for duplicates first.
-	if exists, _, err := CommunityExists(ctx, db, name); err != nil {
-		return nil, err
-	} else if exists {
-		return nil, &httperr.Error{HTTPStatus: http.StatusConflict, Code: "community-exists", Message: fmt.Sprintf("A community with name %s already exists.", name)}
-	}
-
-	query := "INSERT INTO communities (id, name, name_lc, user_id, about) VALUES (?, ?, ?, ?, ?)"
 	id := uid.New()
-	var about_ any
-	if about != "" {
-		about_ = about
-	}
-
-	if _, err = db.ExecContext(ctx, query, id, name, strings.ToLower(name), creator, about_); err != nil {
+	if err := msql.Transact(ctx, db, func(tx *sql.Tx) error {
+		return insertCommunity(ctx, tx, id, creator, name, about)
+	}); err != nil {
 		return nil, err
 	}
 
@@ -285,6 +284,33 @@ func CreateCommunity(ctx context.Context, db *sql.DB, creator uid.ID, reqPoints,
 	return comm, nil
 }
 
+// insertCommunity inserts a community row, without any members, after checking
+// that the name is valid and not taken. It doesn't check whether creator is
+// allowed to create communities.
+func insertCommunity(ctx context.Context, tx *sql.Tx, id, creator uid.ID, name, about string) error {
+	if err := IsUsernameValid(name); err != nil {
+		return httperr.NewBadRequest("invalid-community-name", fmt.Sprintf("Community name invalid. It %s.", err.Error()))
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM communities WHERE name_lc = ?)", strings.ToLower(name)).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return &httperr.Error{HTTPStatus: http.StatusConflict, Code: "community-exists", Message: fmt.Sprintf("A community with name %s already exists.", name)}
+	}
+
+	var about_ any
+	if about != "" {
+		about_ = about
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO communities (id, name, name_lc, user_id, about) VALUES (?, ?, ?, ?, ?)", id, name, strings.ToLower(name), creator, about_)
+	if err != nil && msql.IsErrDuplicateErr(err) {
+		return &httperr.Error{HTTPStatus: http.StatusConflict, Code: "community-exists", Message: fmt.Sprintf("A community with name %s already exists.", name)}
+	}
+	return err
+}
+
 func CommunityExists(ctx context.Context, db *sql.DB, name string) (bool, *Community, error) {
 	comm, err := GetCommunityByName(ctx, db, name, nil)
 	if err != nil {
@@ -332,6 +358,9 @@ func GetCommunities(ctx context.Context, db *sql.DB, sort CommunitiesSort, set s
 
 	var args []any
 	where := "WHERE communities.deleted_at IS NULL "
+	if set == CommunitiesSetAll {
+		where += "AND communities.quarantined_at IS NULL "
+	}
 	if set == CommunitiesSetDefault {
 		where += "AND communities.id IN (SELECT community_id FROM default_communities) "
 	} else if set == CommunitiesSetSubscribed {
@@ -370,7 +399,7 @@ func GetCommunities(ctx context.Context, db *sql.DB, sort CommunitiesSort, set s
 // GetCommunitiesPrefix returns all communities with name prefix s sorted by created at.
 func GetCommunitiesPrefix(ctx context.Context, db *sql.DB, s string) ([]*Community, error) {
 	const limit = 10
-	query := buildSelectCommunityQuery("WHERE communities.name LIKE ? AND communities.deleted_at IS NULL LIMIT ?")
+	query := buildSelectCommunityQuery("WHERE communities.name LIKE ? AND communities.deleted_at IS NULL AND communities.quarantined_at IS NULL LIMIT ?")
 	rows, err := db.QueryContext(ctx, query, "%"+s+"%", limit)
 	if err != nil {
 		return nil, err
@@ -380,7 +409,7 @@ func GetCommunitiesPrefix(ctx context.Context, db *sql.DB, s string) ([]*Communi
 		return nil, err
 	}
 
-	query = buildSelectCommunityQuery("WHERE communities.name = ?")
+	query = buildSelectCommunityQuery("WHERE communities.name = ? AND communities.quarantined_at IS NULL")
 	rows, err = db.QueryContext(ctx, query, s)
 	if err != nil {
 		return nil, err
@@ -1252,55 +1281,3 @@ func AddAllUsersToCommunity(ctx context.Context, db *sql.DB, community string) e
 		return nil
 	})
 }
-
-type CommunityRequest struct {
-	ID            int        `json:"id"`
-	ByUser        string     `json:"byUser"`
-	CommunityName string     `json:"communityName"`
-	Note          string     `json:"note"`
-	CreatedAt     time.Time  `json:"createdAt"`
-	DeletedAt     *time.Time `json:"deletedAt"`
-}
-
-func CreateCommunityRequest(ctx context.Context, db *sql.DB, byUser, name, note string) error {
-	_, err := db.ExecContext(ctx, "INSERT INTO community_requests (by_user, community_name, community_name_lc, note) VALUES (?, ?, ?, ?)",
-		byUser, name, strings.ToLower(name), note)
-	if err != nil && msql.IsErrDuplicateErr(err) {
-		return nil
-	}
-	return err
-}
-
-func GetCommunityRequests(ctx context.Context, db *sql.DB) ([]*CommunityRequest, error) {
-	rows, err := db.QueryContext(ctx, "SELECT id, by_user, community_name, note, created_at, deleted_at FROM community_requests WHERE deleted_at IS NULL ORDER BY created_at")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var items []*CommunityRequest
-	for rows.Next() {
-		r := &CommunityRequest{}
-		err := rows.Scan(&r.ID,
-			&r.ByUser,
-			&r.CommunityName,
-			&r.Note,
-			&r.CreatedAt,
-			&r.DeletedAt)
-
-		if err != nil {
-			return nil, err
-		}
-		items = append(items, r)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
-}
-
-func DeleteCommunityRequest(ctx context.Context, db *sql.DB, id int) error {
-	_, err := db.ExecContext(ctx, "UPDATE community_requests SET deleted_at = ? WHERE id = ?", time.Now(), id)
-	return err
-}
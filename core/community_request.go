@@ -0,0 +1,298 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// CommunityRequestStatus is the status of a community request. A request is
+// pending until an admin either approves it (which creates the community) or
+// rejects it.
+type CommunityRequestStatus int
+
+const (
+	CommunityRequestPending = CommunityRequestStatus(iota)
+	CommunityRequestApproved
+	CommunityRequestRejected
+)
+
+func (s CommunityRequestStatus) MarshalText() ([]byte, error) {
+	switch s {
+	case CommunityRequestPending:
+		return []byte("pending"), nil
+	case CommunityRequestApproved:
+		return []byte("approved"), nil
+	case CommunityRequestRejected:
+		return []byte("rejected"), nil
+	}
+	return nil, errors.New("unsupported community request status")
+}
+
+func (s *CommunityRequestStatus) UnmarshalText(data []byte) error {
+	switch string(data) {
+	case "pending":
+		*s = CommunityRequestPending
+	case "approved":
+		*s = CommunityRequestApproved
+	case "rejected":
+		*s = CommunityRequestRejected
+	default:
+		return errors.New("unsupported community request status")
+	}
+	return nil
+}
+
+type CommunityRequest struct {
+	db *sql.DB
+
+	ID            int                    `json:"id"`
+	ByUser        string                 `json:"byUser"`
+	CommunityName string                 `json:"communityName"`
+	Note          string                 `json:"note"`
+	Status        CommunityRequestStatus `json:"status"`
+	RejectReason  msql.NullString        `json:"rejectReason"`
+	ResolvedBy    uid.NullID             `json:"resolvedBy"`
+	ResolvedAt    msql.NullTime          `json:"resolvedAt"`
+	CommunityID   uid.NullID             `json:"communityId"` // Set once approved.
+	CreatedAt     time.Time              `json:"createdAt"`
+	DeletedAt     *time.Time             `json:"deletedAt"`
+}
+
+// CreateCommunityRequest creates a request by byUser for a community named
+// name. If byUser has requested the community before, and that request was
+// rejected or deleted, it's reopened as a new request. Requesting a community
+// again while the request is pending does nothing.
+func CreateCommunityRequest(ctx context.Context, db *sql.DB, byUser, name, note string) error {
+	nameLC := strings.ToLower(name)
+	return msql.Transact(ctx, db, func(tx *sql.Tx) error {
+		var (
+			id      int
+			status  CommunityRequestStatus
+			deleted bool
+		)
+		row := tx.QueryRowContext(ctx, "SELECT id, status, deleted_at IS NOT NULL FROM community_requests WHERE by_user = ? AND community_name_lc = ? FOR UPDATE", byUser, nameLC)
+		if err := row.Scan(&id, &status, &deleted); err != nil {
+			if err != sql.ErrNoRows {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO community_requests (by_user, community_name, community_name_lc, note) VALUES (?, ?, ?, ?)",
+				byUser, name, nameLC, note)
+			if err != nil && msql.IsErrDuplicateErr(err) {
+				return nil
+			}
+			return err
+		}
+
+		switch {
+		case status == CommunityRequestApproved:
+			return errCommunityRequestApproved
+		case status == CommunityRequestPending && !deleted:
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE community_requests SET community_name = ?, note = ?, status = ?, reject_reason = NULL,
+			resolved_by = NULL, resolved_at = NULL, created_at = ?, deleted_at = NULL WHERE id = ?`,
+			name, note, CommunityRequestPending, time.Now(), id)
+		return err
+	})
+}
+
+var selectCommunityRequestCols = []string{
+	"id",
+	"by_user",
+	"community_name",
+	"note",
+	"status",
+	"reject_reason",
+	"resolved_by",
+	"resolved_at",
+	"community_id",
+	"created_at",
+	"deleted_at",
+}
+
+func scanCommunityRequests(db *sql.DB, rows *sql.Rows) ([]*CommunityRequest, error) {
+	defer rows.Close()
+
+	var items []*CommunityRequest
+	for rows.Next() {
+		r := &CommunityRequest{db: db}
+		var note msql.NullString
+		err := rows.Scan(&r.ID,
+			&r.ByUser,
+			&r.CommunityName,
+			&note,
+			&r.Status,
+			&r.RejectReason,
+			&r.ResolvedBy,
+			&r.ResolvedAt,
+			&r.CommunityID,
+			&r.CreatedAt,
+			&r.DeletedAt)
+
+		if err != nil {
+			return nil, err
+		}
+		r.Note = note.String
+		items = append(items, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetCommunityRequests returns the community requests (oldest first) that are
+// not deleted. If byUser is not empty, only the requests of that user are
+// returned, and if status is not nil, only the requests with that status.
+func GetCommunityRequests(ctx context.Context, db *sql.DB, byUser string, status *CommunityRequestStatus) ([]*CommunityRequest, error) {
+	where, args := "WHERE deleted_at IS NULL ", []any{}
+	if byUser != "" {
+		where += "AND by_user = ? "
+		args = append(args, byUser)
+	}
+	if status != nil {
+		where += "AND status = ? "
+		args = append(args, *status)
+	}
+	query := msql.BuildSelectQuery("community_requests", selectCommunityRequestCols, nil, where+"ORDER BY created_at")
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanCommunityRequests(db, rows)
+}
+
+// GetCommunityRequest returns the community request with the given id (even if
+// it's deleted).
+func GetCommunityRequest(ctx context.Context, db *sql.DB, id int) (*CommunityRequest, error) {
+	query := msql.BuildSelectQuery("community_requests", selectCommunityRequestCols, nil, "WHERE id = ?")
+	rows, err := db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	items, err := scanCommunityRequests(db, rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errCommunityRequestNotFound
+	}
+	return items[0], nil
+}
+
+func DeleteCommunityRequest(ctx context.Context, db *sql.DB, id int) error {
+	_, err := db.ExecContext(ctx, "UPDATE community_requests SET deleted_at = ? WHERE id = ?", time.Now(), id)
+	return err
+}
+
+// Approve creates the requested community, with the requester as its only
+// moderator, and notifies the requester. Admin is the admin approving the
+// request. Approving a request bypasses the limits on who can create
+// communities.
+func (r *CommunityRequest) Approve(ctx context.Context, admin uid.ID) (*Community, error) {
+	if r.Status != CommunityRequestPending || r.DeletedAt != nil {
+		return nil, errCommunityRequestResolved
+	}
+
+	requester, err := GetUserByUsername(ctx, r.db, r.ByUser, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The community is recorded as created by the admin, with the requester as
+	// its only member and moderator.
+	id := uid.New()
+	now := time.Now()
+	err = msql.Transact(ctx, r.db, func(tx *sql.Tx) error {
+		if err := r.resolve(ctx, tx, CommunityRequestApproved, admin, "", uid.NullID{Valid: true, ID: id}, now); err != nil {
+			return err
+		}
+		if err := insertCommunity(ctx, tx, id, admin, r.CommunityName, ""); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO community_members (community_id, user_id, is_mod) VALUES (?, ?, TRUE)", id, requester.ID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO community_mods (community_id, user_id, position) VALUES (?, ?, 0)", id, requester.ID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE communities SET no_members = 1 WHERE id = ?", id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.setResolved(CommunityRequestApproved, admin, "", uid.NullID{Valid: true, ID: id}, now)
+	r.notify(requester.ID)
+	return GetCommunityByID(ctx, r.db, id, nil)
+}
+
+// Reject rejects the request and notifies the requester. The reason, if not
+// empty, is shown to the requester.
+func (r *CommunityRequest) Reject(ctx context.Context, admin uid.ID, reason string) error {
+	if r.Status != CommunityRequestPending || r.DeletedAt != nil {
+		return errCommunityRequestResolved
+	}
+
+	requester, err := GetUserByUsername(ctx, r.db, r.ByUser, nil)
+	if err != nil && err != errUserNotFound {
+		return err
+	}
+
+	now := time.Now()
+	if err := msql.Transact(ctx, r.db, func(tx *sql.Tx) error {
+		return r.resolve(ctx, tx, CommunityRequestRejected, admin, reason, uid.NullID{}, now)
+	}); err != nil {
+		return err
+	}
+
+	r.setResolved(CommunityRequestRejected, admin, reason, uid.NullID{}, now)
+	if requester != nil {
+		r.notify(requester.ID)
+	}
+	return nil
+}
+
+// resolve marks the request as resolved in the database. It returns
+// errCommunityRequestResolved if the request is no longer pending (if, for
+// instance, another admin resolved it in the meantime).
+func (r *CommunityRequest) resolve(ctx context.Context, tx *sql.Tx, status CommunityRequestStatus, admin uid.ID, reason string, community uid.NullID, now time.Time) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE community_requests SET status = ?, reject_reason = ?, resolved_by = ?, resolved_at = ?, community_id = ?
+		WHERE id = ? AND status = ? AND deleted_at IS NULL`,
+		status, nullString(reason), admin, now, community, r.ID, CommunityRequestPending)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errCommunityRequestResolved
+	}
+	return nil
+}
+
+func (r *CommunityRequest) setResolved(status CommunityRequestStatus, admin uid.ID, reason string, community uid.NullID, now time.Time) {
+	r.Status = status
+	r.RejectReason = nullString(reason)
+	r.ResolvedBy = uid.NullID{Valid: true, ID: admin}
+	r.ResolvedAt = msql.NewNullTime(now)
+	r.CommunityID = community
+}
+
+func (r *CommunityRequest) notify(requester uid.ID) {
+	go func() {
+		if err := CreateCommunityRequestNotification(context.Background(), r.db, requester, r.ID); err != nil {
+			log.Printf("Failed creating community_request notification: %v\n", err)
+		}
+	}()
+}
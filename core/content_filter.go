@@ -0,0 +1,325 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/discuitnet/discuit/internal/httperr"
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+const maxContentFilterPatternLength = 255 // in bytes
+
+// ContentFilterType is the kind of thing a ContentFilter matches.
+type ContentFilterType int
+
+const (
+	// ContentFilterTypeWord filters match words (or phrases) in the text of
+	// posts and comments. A '*' in the pattern matches any number of word
+	// characters.
+	ContentFilterTypeWord = ContentFilterType(iota)
+
+	// ContentFilterTypeDomain filters match links to a domain (and its
+	// subdomains), both in the text of posts and comments and in link posts.
+	ContentFilterTypeDomain
+)
+
+func (t ContentFilterType) MarshalText() ([]byte, error) {
+	switch t {
+	case ContentFilterTypeWord:
+		return []byte("word"), nil
+	case ContentFilterTypeDomain:
+		return []byte("domain"), nil
+	}
+	return nil, errors.New("unsupported content filter type")
+}
+
+func (t *ContentFilterType) UnmarshalText(data []byte) error {
+	switch string(data) {
+	case "word":
+		*t = ContentFilterTypeWord
+	case "domain":
+		*t = ContentFilterTypeDomain
+	default:
+		return httperr.NewBadRequest("invalid_filter_type", "Unsupported content filter type.")
+	}
+	return nil
+}
+
+// ContentFilterAction is what's done with content that matches a
+// ContentFilter. Actions are ordered from the least severe to the most.
+type ContentFilterAction int
+
+const (
+	// ContentFilterActionReport reports the content to the mods of the
+	// community (on behalf of the creator of the filter).
+	ContentFilterActionReport = ContentFilterAction(iota)
+
+	// ContentFilterActionHold holds the content for review by the mods. Held
+	// content is not shown to anyone other than its author and the mods (and
+	// admins) until it's approved.
+	ContentFilterActionHold
+
+	// ContentFilterActionReject rejects the content outright.
+	ContentFilterActionReject
+)
+
+func (a ContentFilterAction) MarshalText() ([]byte, error) {
+	switch a {
+	case ContentFilterActionReport:
+		return []byte("report"), nil
+	case ContentFilterActionHold:
+		return []byte("hold"), nil
+	case ContentFilterActionReject:
+		return []byte("reject"), nil
+	}
+	return nil, errors.New("unsupported content filter action")
+}
+
+func (a *ContentFilterAction) UnmarshalText(data []byte) error {
+	switch string(data) {
+	case "report":
+		*a = ContentFilterActionReport
+	case "hold":
+		*a = ContentFilterActionHold
+	case "reject":
+		*a = ContentFilterActionReject
+	default:
+		return httperr.NewBadRequest("invalid_filter_action", "Unsupported content filter action.")
+	}
+	return nil
+}
+
+// ContentFilter is a banned word or domain, either of a community or, if
+// CommunityID is null, of the whole site.
+type ContentFilter struct {
+	ID          uid.ID              `json:"id"`
+	CommunityID uid.NullID          `json:"communityId"`
+	Type        ContentFilterType   `json:"type"`
+	Pattern     string              `json:"pattern"`
+	Action      ContentFilterAction `json:"action"`
+	CreatedBy   uid.ID              `json:"createdBy"`
+	CreatedAt   time.Time           `json:"createdAt"`
+
+	re *regexp.Regexp // For word filters.
+}
+
+var selectContentFilterCols = []string{
+	"content_filters.id",
+	"content_filters.community_id",
+	"content_filters.filter_type",
+	"content_filters.pattern",
+	"content_filters.action",
+	"content_filters.created_by",
+	"content_filters.created_at",
+}
+
+func getContentFilters(ctx context.Context, db *sql.DB, where string, args ...any) ([]*ContentFilter, error) {
+	query := msql.BuildSelectQuery("content_filters", selectContentFilterCols, nil, where)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var filters []*ContentFilter
+	for rows.Next() {
+		f := &ContentFilter{}
+		if err := rows.Scan(&f.ID, &f.CommunityID, &f.Type, &f.Pattern, &f.Action, &f.CreatedBy, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		f.compile()
+		filters = append(filters, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// GetContentFilters returns the content filters of community, or, if community
+// is nil, the site-wide content filters.
+func GetContentFilters(ctx context.Context, db *sql.DB, community *uid.ID) ([]*ContentFilter, error) {
+	if community == nil {
+		return getContentFilters(ctx, db, "WHERE content_filters.community_id IS NULL ORDER BY content_filters.created_at")
+	}
+	return getContentFilters(ctx, db, "WHERE content_filters.community_id = ? ORDER BY content_filters.created_at", *community)
+}
+
+// GetContentFilter returns the content filter with the given id.
+func GetContentFilter(ctx context.Context, db *sql.DB, id uid.ID) (*ContentFilter, error) {
+	filters, err := getContentFilters(ctx, db, "WHERE content_filters.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(filters) == 0 {
+		return nil, errContentFilterNotFound
+	}
+	return filters[0], nil
+}
+
+// normalizeContentFilterPattern returns the pattern in the form it's stored in.
+func normalizeContentFilterPattern(t ContentFilterType, pattern string) (string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return "", httperr.NewBadRequest("empty_filter_pattern", "Filter pattern cannot be empty.")
+	}
+	if len(pattern) > maxContentFilterPatternLength {
+		return "", httperr.NewBadRequest("filter_pattern_too_long", "Filter pattern is too long.")
+	}
+	if t == ContentFilterTypeDomain {
+		if strings.Contains(pattern, "://") {
+			u, err := url.Parse(pattern)
+			if err != nil {
+				return "", httperr.NewBadRequest("invalid_filter_domain", "Invalid domain.")
+			}
+			pattern = u.Hostname()
+		}
+		pattern = strings.TrimPrefix(pattern, "*.")
+		pattern = strings.TrimPrefix(pattern, "www.")
+		pattern = strings.Trim(pattern, ".")
+		if !strings.Contains(pattern, ".") || strings.ContainsAny(pattern, " /\t\n") {
+			return "", httperr.NewBadRequest("invalid_filter_domain", "Invalid domain.")
+		}
+	}
+	return pattern, nil
+}
+
+// CreateContentFilter adds a content filter to community, or, if community is
+// nil, a site-wide content filter.
+func CreateContentFilter(ctx context.Context, db *sql.DB, community *uid.ID, t ContentFilterType, pattern string, action ContentFilterAction, createdBy uid.ID) (*ContentFilter, error) {
+	pattern, err := normalizeContentFilterPattern(t, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := GetContentFilters(ctx, db, community)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range existing {
+		if f.Type == t && f.Pattern == pattern {
+			return nil, &httperr.Error{
+				HTTPStatus: http.StatusConflict,
+				Code:       "filter_exists",
+				Message:    "A filter with the same pattern already exists.",
+			}
+		}
+	}
+
+	f := &ContentFilter{
+		ID:        uid.New(),
+		Type:      t,
+		Pattern:   pattern,
+		Action:    action,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if community != nil {
+		f.CommunityID = uid.NullID{Valid: true, ID: *community}
+	}
+	query, args := msql.BuildInsertQuery("content_filters", []msql.ColumnValue{
+		{Name: "id", Value: f.ID},
+		{Name: "community_id", Value: f.CommunityID},
+		{Name: "filter_type", Value: f.Type},
+		{Name: "pattern", Value: f.Pattern},
+		{Name: "action", Value: f.Action},
+		{Name: "created_by", Value: f.CreatedBy},
+		{Name: "created_at", Value: f.CreatedAt},
+	})
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+	f.compile()
+	return f, nil
+}
+
+// Delete deletes the content filter.
+func (f *ContentFilter) Delete(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM content_filters WHERE id = ?", f.ID)
+	return err
+}
+
+const wordChars = `\p{L}\p{N}_`
+
+func (f *ContentFilter) compile() {
+	if f.Type != ContentFilterTypeWord {
+		return
+	}
+	parts := strings.Split(f.Pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	expr := `(?i)(?:^|[^` + wordChars + `])` + strings.Join(parts, `[`+wordChars+`]*`) + `(?:$|[^` + wordChars + `])`
+	f.re, _ = regexp.Compile(expr) // The expression is always valid.
+}
+
+// matchText reports whether text contains the word (or words) of f.
+func (f *ContentFilter) matchText(text string) bool {
+	return f.re != nil && f.re.MatchString(text)
+}
+
+// matchHostname reports whether hostname is the domain of f or one of its
+// subdomains.
+func (f *ContentFilter) matchHostname(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	return hostname == f.Pattern || strings.HasSuffix(hostname, "."+f.Pattern)
+}
+
+var textLinkRegexp = regexp.MustCompile(`(?i)https?://[^\s<>()\[\]"']+`)
+
+// linkHostnames returns the hostnames of links, and of the links found in
+// text.
+func linkHostnames(text string, links []string) []string {
+	var hostnames []string
+	for _, link := range append(textLinkRegexp.FindAllString(text, -1), links...) {
+		if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+			hostnames = append(hostnames, u.Hostname())
+		}
+	}
+	return hostnames
+}
+
+// matchContentFilters returns the matching content filter of community (or
+// site-wide content filter) with the most severe action. If no filter matches,
+// it returns nil.
+func matchContentFilters(ctx context.Context, db *sql.DB, community uid.ID, text string, links []string) (*ContentFilter, error) {
+	filters, err := getContentFilters(ctx, db, "WHERE content_filters.community_id IS NULL OR content_filters.community_id = ?", community)
+	if err != nil {
+		return nil, err
+	}
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	hostnames := linkHostnames(text, links)
+	var match *ContentFilter
+	for _, f := range filters {
+		if match != nil && match.Action >= f.Action {
+			continue
+		}
+		matched := false
+		switch f.Type {
+		case ContentFilterTypeWord:
+			matched = f.matchText(text)
+		case ContentFilterTypeDomain:
+			for _, h := range hostnames {
+				if f.matchHostname(h) {
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			match = f
+		}
+	}
+	return match, nil
+}
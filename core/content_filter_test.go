@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestContentFilterMatchText(t *testing.T) {
+	cases := []struct {
+		pattern string
+		text    string
+		want    bool
+	}{
+		{"spam", "this is spam", true},
+		{"spam", "SPAM!", true},
+		{"spam", "spammer", false},
+		{"spam*", "spammer here", true},
+		{"*coin", "buy bitcoin now", true},
+		{"free money", "get free money today", true},
+		{"free money", "get free moneys", false},
+		{"café", "le café", true},
+		{"a.b", "axb", false},
+	}
+	for _, item := range cases {
+		f := &ContentFilter{Type: ContentFilterTypeWord, Pattern: item.pattern}
+		f.compile()
+		if got := f.matchText(item.text); got != item.want {
+			t.Errorf("pattern %q on %q: got %v, want %v", item.pattern, item.text, got, item.want)
+		}
+	}
+}
+
+func TestContentFilterMatchHostname(t *testing.T) {
+	f := &ContentFilter{Type: ContentFilterTypeDomain, Pattern: "example.com"}
+	cases := []struct {
+		hostname string
+		want     bool
+	}{
+		{"example.com", true},
+		{"www.Example.com", true},
+		{"notexample.com", false},
+		{"example.com.evil.org", false},
+	}
+	for _, item := range cases {
+		if got := f.matchHostname(item.hostname); got != item.want {
+			t.Errorf("%s: got %v, want %v", item.hostname, got, item.want)
+		}
+	}
+}
+
+func TestNormalizeContentFilterPattern(t *testing.T) {
+	cases := []struct {
+		t       ContentFilterType
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{ContentFilterTypeWord, "  Spam ", "spam", false},
+		{ContentFilterTypeWord, "   ", "", true},
+		{ContentFilterTypeDomain, "https://www.example.com/path", "example.com", false},
+		{ContentFilterTypeDomain, "*.example.com", "example.com", false},
+		{ContentFilterTypeDomain, "localhost", "", true},
+	}
+	for _, item := range cases {
+		got, err := normalizeContentFilterPattern(item.t, item.pattern)
+		if got != item.want || (err != nil) != item.wantErr {
+			t.Errorf("%q: got (%q, %v), want %q", item.pattern, got, err, item.want)
+		}
+	}
+}
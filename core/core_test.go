@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"testing"
+)
+
+// The helpers below create the data that the tests that need a database (see
+// testdb.Open) work on. Names are random, so that tests don't clash with each
+// other or with earlier runs.
+
+func randomName(t *testing.T, prefix string) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+func newTestUser(t *testing.T, db *sql.DB) *User {
+	t.Helper()
+	u, err := RegisterUser(context.Background(), db, randomName(t, "u"), "", "password", "", "", "")
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return u
+}
+
+func newTestAdmin(t *testing.T, db *sql.DB) *User {
+	t.Helper()
+	u, err := MakeAdmin(context.Background(), db, newTestUser(t, db).Username, true)
+	if err != nil {
+		t.Fatalf("making admin: %v", err)
+	}
+	return u
+}
+
+func newTestCommunity(t *testing.T, db *sql.DB) *Community {
+	t.Helper()
+	c, err := CreateCommunity(context.Background(), db, newTestAdmin(t, db).ID, 0, 1000, randomName(t, "c"), "")
+	if err != nil {
+		t.Fatalf("creating community: %v", err)
+	}
+	return c
+}
+
+func newTestPost(t *testing.T, db *sql.DB, author *User, community *Community) *Post {
+	t.Helper()
+	p, err := CreateTextPost(context.Background(), db, author.ID, community.ID, randomName(t, "Post "), "")
+	if err != nil {
+		t.Fatalf("creating post: %v", err)
+	}
+	return p
+}
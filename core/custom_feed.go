@@ -0,0 +1,288 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/discuitnet/discuit/internal/httperr"
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+	"github.com/discuitnet/discuit/internal/utils"
+)
+
+// MaxCustomFeedCommunities is the maximum number of communities in a custom
+// feed.
+const MaxCustomFeedCommunities = 100
+
+var errCustomFeedNotFound = httperr.NewNotFound("custom-feed-not-found", "Custom feed not found.")
+
+// CustomFeed is a named feed of a user that combines the posts of a selection
+// of communities. Public custom feeds can be viewed by anyone with the link.
+// Posts of custom feeds are got through GetFeed (see FeedOptions.CustomFeed).
+type CustomFeed struct {
+	ID             int             `json:"id"`
+	UserID         uid.ID          `json:"userId"`
+	Username       string          `json:"username"`
+	Name           string          `json:"name"`
+	DisplayName    string          `json:"displayName"`
+	Description    msql.NullString `json:"description"`
+	Public         bool            `json:"public"`
+	NumCommunities int             `json:"numCommunities"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	LastUpdatedAt  time.Time       `json:"lastUpdatedAt"`
+}
+
+func getCustomFeeds(ctx context.Context, db *sql.DB, where string, args ...any) ([]*CustomFeed, error) {
+	query := msql.BuildSelectQuery("custom_feeds", []string{
+		"custom_feeds.id",
+		"custom_feeds.user_id",
+		"users.username",
+		"custom_feeds.name",
+		"custom_feeds.display_name",
+		"custom_feeds.description",
+		"custom_feeds.public",
+		"custom_feeds.num_communities",
+		"custom_feeds.created_at",
+		"custom_feeds.last_updated_at",
+	}, []string{
+		"INNER JOIN users ON custom_feeds.user_id = users.id",
+	}, where)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []*CustomFeed{}
+	for rows.Next() {
+		feed := &CustomFeed{}
+		if err = rows.Scan(
+			&feed.ID,
+			&feed.UserID,
+			&feed.Username,
+			&feed.Name,
+			&feed.DisplayName,
+			&feed.Description,
+			&feed.Public,
+			&feed.NumCommunities,
+			&feed.CreatedAt,
+			&feed.LastUpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return feeds, nil
+}
+
+func GetCustomFeed(ctx context.Context, db *sql.DB, id int) (*CustomFeed, error) {
+	feeds, err := getCustomFeeds(ctx, db, "WHERE custom_feeds.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(feeds) == 0 {
+		return nil, errCustomFeedNotFound
+	}
+	return feeds[0], nil
+}
+
+func GetCustomFeedByName(ctx context.Context, db *sql.DB, user uid.ID, name string) (*CustomFeed, error) {
+	feeds, err := getCustomFeeds(ctx, db, "WHERE custom_feeds.user_id = ? AND custom_feeds.name = ?", user, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(feeds) == 0 {
+		return nil, errCustomFeedNotFound
+	}
+	return feeds[0], nil
+}
+
+// GetUsersCustomFeeds returns the custom feeds of user, sorted by name. If
+// publicOnly is true, private feeds are left out.
+func GetUsersCustomFeeds(ctx context.Context, db *sql.DB, user uid.ID, publicOnly bool) ([]*CustomFeed, error) {
+	where := "WHERE custom_feeds.user_id = ? "
+	if publicOnly {
+		where += "AND custom_feeds.public = TRUE "
+	}
+	where += "ORDER BY custom_feeds.name ASC"
+	return getCustomFeeds(ctx, db, where, user)
+}
+
+// customFeedNameValid always returns an httperr.Error.
+func customFeedNameValid(name string) error {
+	if err := IsUsernameValid(name); err != nil {
+		return httperr.NewBadRequest("invalid-custom-feed-name", fmt.Sprintf("custom feed name %v", err))
+	}
+	return nil
+}
+
+func truncateCustomFeedDisplayName(s string) string {
+	return utils.TruncateUnicodeString(s, 50)
+}
+
+var errDuplicateCustomFeed = &httperr.Error{
+	HTTPStatus: http.StatusConflict,
+	Code:       "duplicate-custom-feed",
+	Message:    "A custom feed with that name already exists.",
+}
+
+func CreateCustomFeed(ctx context.Context, db *sql.DB, user uid.ID, name, displayName string, description msql.NullString, public bool) (*CustomFeed, error) {
+	if description.String == "" {
+		description.Valid = false
+	}
+	if err := customFeedNameValid(name); err != nil {
+		return nil, err
+	}
+
+	displayName = truncateCustomFeedDisplayName(displayName)
+	description.String = utils.TruncateUnicodeString(description.String, maxUserProfileAboutLength)
+
+	query, args := msql.BuildInsertQuery("custom_feeds", []msql.ColumnValue{
+		{Name: "user_id", Value: user},
+		{Name: "name", Value: name},
+		{Name: "display_name", Value: displayName},
+		{Name: "description", Value: description},
+		{Name: "public", Value: public},
+	})
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		if msql.IsErrDuplicateErr(err) {
+			return nil, errDuplicateCustomFeed
+		}
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetCustomFeed(ctx, db, int(id))
+}
+
+// ViewableBy reports whether viewer (which could be nil) can view the feed.
+func (f *CustomFeed) ViewableBy(viewer *uid.ID) bool {
+	return f.Public || (viewer != nil && *viewer == f.UserID)
+}
+
+// Update updates the feed's updatable fields.
+func (f *CustomFeed) Update(ctx context.Context, db *sql.DB) error {
+	if err := customFeedNameValid(f.Name); err != nil {
+		return err
+	}
+
+	f.Description.String = utils.TruncateUnicodeString(f.Description.String, maxUserProfileAboutLength)
+	f.DisplayName = truncateCustomFeedDisplayName(f.DisplayName)
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE custom_feeds SET
+			name = ?,
+			display_name = ?,
+			description = ?,
+			public = ?,
+			last_updated_at = ?
+		WHERE id = ?`,
+		f.Name,
+		f.DisplayName,
+		f.Description,
+		f.Public,
+		time.Now(),
+		f.ID)
+	if err != nil && msql.IsErrDuplicateErr(err) {
+		return errDuplicateCustomFeed
+	}
+	return err
+}
+
+// UnmarshalUpdatableFieldsJSON extracts the updatable values of the feed from
+// the encoded JSON string.
+func (f *CustomFeed) UnmarshalUpdatableFieldsJSON(data []byte) error {
+	temp := *f // shallow copy
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+	f.Name = temp.Name
+	f.DisplayName = temp.DisplayName
+	f.Description = temp.Description
+	if f.Description.String == "" {
+		f.Description.Valid = false
+	}
+	f.Public = temp.Public
+	return nil
+}
+
+func (f *CustomFeed) Delete(ctx context.Context, db *sql.DB) error {
+	// The rows of custom_feed_communities are deleted by ON DELETE CASCADE.
+	_, err := db.ExecContext(ctx, "DELETE FROM custom_feeds WHERE id = ?", f.ID)
+	return err
+}
+
+// GetCommunities returns the communities of the feed, sorted by name.
+func (f *CustomFeed) GetCommunities(ctx context.Context, db *sql.DB, viewer *uid.ID) ([]*Community, error) {
+	return getCommunities(ctx, db, viewer, `
+		WHERE communities.id IN (SELECT community_id FROM custom_feed_communities WHERE feed_id = ?)
+		ORDER BY communities.name_lc`, f.ID)
+}
+
+// AddCommunity adds community to the feed. It's not an error if community is
+// already in the feed.
+func (f *CustomFeed) AddCommunity(ctx context.Context, db *sql.DB, community uid.ID) error {
+	if _, err := GetCommunityByID(ctx, db, community, nil); err != nil {
+		return err
+	}
+
+	err := msql.Transact(ctx, db, func(tx *sql.Tx) error {
+		// The feed row is locked so that concurrent additions can't go over the
+		// limit.
+		var n int
+		if err := tx.QueryRowContext(ctx, "SELECT num_communities FROM custom_feeds WHERE id = ? FOR UPDATE", f.ID).Scan(&n); err != nil {
+			if err == sql.ErrNoRows {
+				return errCustomFeedNotFound
+			}
+			return err
+		}
+		f.NumCommunities = n
+		if n >= MaxCustomFeedCommunities {
+			return httperr.NewForbidden("custom-feed-full", fmt.Sprintf("A custom feed cannot have more than %d communities.", MaxCustomFeedCommunities))
+		}
+
+		if _, err := tx.ExecContext(ctx, "INSERT INTO custom_feed_communities (feed_id, community_id) VALUES (?, ?)", f.ID, community); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE custom_feeds SET num_communities = num_communities + 1, last_updated_at = ? WHERE id = ?", time.Now(), f.ID)
+		return err
+	})
+	if err != nil {
+		if msql.IsErrDuplicateErr(err) {
+			return nil
+		}
+		return err
+	}
+	f.NumCommunities++
+	return nil
+}
+
+// RemoveCommunity removes community from the feed.
+func (f *CustomFeed) RemoveCommunity(ctx context.Context, db *sql.DB, community uid.ID) error {
+	return msql.Transact(ctx, db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM custom_feed_communities WHERE feed_id = ? AND community_id = ?", f.ID, community)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE custom_feeds SET num_communities = num_communities - 1, last_updated_at = ? WHERE id = ?", time.Now(), f.ID); err != nil {
+			return err
+		}
+		f.NumCommunities--
+		return nil
+	})
+}
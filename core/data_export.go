@@ -0,0 +1,678 @@
+package core
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/discuitnet/discuit/internal/images"
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// dataExportInterval is the minimum time between two data exports of a user.
+const dataExportInterval = time.Hour * 24
+
+// dataExportBuildTimeout is the maximum time it takes to build a data export.
+// Exports that are still pending after this long were interrupted (by a
+// restart of the server, say) and are marked as failed by
+// FailInterruptedDataExports.
+const dataExportBuildTimeout = time.Hour
+
+// DataExportOptions configure the data exports (takeouts) of users.
+type DataExportOptions struct {
+	// Folder is where the zip files of the exports are saved.
+	Folder string
+
+	// HMACKey is the key used to sign download links.
+	HMACKey []byte
+
+	// TTL is how long an export can be downloaded after it's created.
+	TTL time.Duration
+}
+
+var dataExportOptions = struct {
+	sync.RWMutex
+	DataExportOptions
+}{
+	DataExportOptions: DataExportOptions{
+		Folder: "exports",
+		TTL:    time.Hour * 24 * 3,
+	},
+}
+
+// SetDataExportOptions sets the options of data exports. Call it before the
+// server is started.
+func SetDataExportOptions(opts DataExportOptions) {
+	dataExportOptions.Lock()
+	defer dataExportOptions.Unlock()
+	dataExportOptions.DataExportOptions = opts
+}
+
+func getDataExportOptions() DataExportOptions {
+	dataExportOptions.RLock()
+	defer dataExportOptions.RUnlock()
+	return dataExportOptions.DataExportOptions
+}
+
+type DataExportStatus int
+
+const (
+	DataExportStatusPending = DataExportStatus(iota)
+	DataExportStatusReady
+	DataExportStatusFailed
+)
+
+func (s DataExportStatus) MarshalText() ([]byte, error) {
+	switch s {
+	case DataExportStatusPending:
+		return []byte("pending"), nil
+	case DataExportStatusReady:
+		return []byte("ready"), nil
+	case DataExportStatusFailed:
+		return []byte("failed"), nil
+	}
+	return nil, errors.New("unsupported data export status")
+}
+
+// DataExport is a zip file of all the data of a user, which is built in the
+// background after it's requested.
+type DataExport struct {
+	ID          uid.ID           `json:"id"`
+	UserID      uid.ID           `json:"-"`
+	Status      DataExportStatus `json:"status"`
+	Size        int64            `json:"size"` // In bytes.
+	CreatedAt   time.Time        `json:"createdAt"`
+	CompletedAt msql.NullTime    `json:"completedAt"`
+	ExpiresAt   msql.NullTime    `json:"expiresAt"`
+
+	// DownloadURL is a signed link to the zip file, valid until ExpiresAt. It's
+	// set only for ready exports.
+	DownloadURL string `json:"downloadUrl,omitempty"`
+}
+
+var selectDataExportCols = []string{
+	"data_exports.id",
+	"data_exports.user_id",
+	"data_exports.status",
+	"data_exports.size",
+	"data_exports.created_at",
+	"data_exports.completed_at",
+	"data_exports.expires_at",
+}
+
+func getDataExports(ctx context.Context, db *sql.DB, where string, args ...any) ([]*DataExport, error) {
+	query := msql.BuildSelectQuery("data_exports", selectDataExportCols, nil, where)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []*DataExport{}
+	for rows.Next() {
+		e := &DataExport{}
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Status, &e.Size, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt); err != nil {
+			return nil, err
+		}
+		e.setDownloadURL()
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// GetDataExport returns the (unexpired) data export with the given ID.
+func GetDataExport(ctx context.Context, db *sql.DB, id uid.ID) (*DataExport, error) {
+	exports, err := getDataExports(ctx, db, "WHERE data_exports.id = ? AND (data_exports.expires_at IS NULL OR data_exports.expires_at > ?)", id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(exports) == 0 {
+		return nil, errDataExportNotFound
+	}
+	return exports[0], nil
+}
+
+// GetUserDataExports returns the unexpired data exports of user, the latest
+// first.
+func GetUserDataExports(ctx context.Context, db *sql.DB, user uid.ID) ([]*DataExport, error) {
+	return getDataExports(ctx, db, "WHERE data_exports.user_id = ? AND (data_exports.expires_at IS NULL OR data_exports.expires_at > ?) ORDER BY data_exports.created_at DESC", user, time.Now())
+}
+
+// RequestDataExport starts building a data export of user in the background.
+// The user is notified when it's ready. A user can request only one export
+// every 24 hours.
+func RequestDataExport(ctx context.Context, db *sql.DB, user uid.ID) (*DataExport, error) {
+	e := &DataExport{
+		ID:        uid.New(),
+		UserID:    user,
+		Status:    DataExportStatusPending,
+		CreatedAt: time.Now(),
+	}
+	err := msql.Transact(ctx, db, func(tx *sql.Tx) error {
+		// The user row is locked so that concurrent requests of the same user
+		// are serialized.
+		var id uid.ID
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? FOR UPDATE", user).Scan(&id); err != nil {
+			if err == sql.ErrNoRows {
+				return errUserNotFound
+			}
+			return err
+		}
+		var n int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM data_exports WHERE user_id = ? AND status <> ? AND created_at > ?",
+			user, DataExportStatusFailed, e.CreatedAt.Add(-dataExportInterval)).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return errDataExportTooSoon
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO data_exports (id, user_id, status, created_at) VALUES (?, ?, ?, ?)",
+			e.ID, e.UserID, e.Status, e.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), dataExportBuildTimeout)
+		defer cancel()
+		if err := e.build(ctx, db); err != nil {
+			log.Printf("Data export %v of user %v failed: %v\n", e.ID, e.UserID, err)
+		}
+	}()
+	return e, nil
+}
+
+// FailInterruptedDataExports marks as failed the data exports that have been
+// pending for longer than it takes to build one, which happens if the server
+// is stopped while an export is being built. The user can then request a new
+// export. It returns the number of exports marked as failed.
+func FailInterruptedDataExports(ctx context.Context, db *sql.DB) (int, error) {
+	now := time.Now()
+	res, err := db.ExecContext(ctx, "UPDATE data_exports SET status = ?, error = ?, completed_at = ? WHERE status = ? AND created_at < ?",
+		DataExportStatusFailed, "interrupted", now, DataExportStatusPending, now.Add(-dataExportBuildTimeout))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Filepath returns the location of the zip file of e.
+func (e *DataExport) Filepath() string {
+	return dataExportFilepath(getDataExportOptions().Folder, e.ID)
+}
+
+func dataExportFilepath(folder string, id uid.ID) string {
+	return filepath.Join(folder, id.String()+".zip")
+}
+
+func signDataExport(key []byte, id uid.ID, expires int64) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(id.String() + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (e *DataExport) setDownloadURL() {
+	e.DownloadURL = ""
+	if e.Status != DataExportStatusReady || !e.ExpiresAt.Valid {
+		return
+	}
+	expires := e.ExpiresAt.Time.Unix()
+	v := url.Values{}
+	v.Set("expires", strconv.FormatInt(expires, 10))
+	v.Set("sig", signDataExport(getDataExportOptions().HMACKey, e.ID, expires))
+	e.DownloadURL = "/api/_export/" + e.ID.String() + "/download?" + v.Encode()
+}
+
+// ValidDataExportSignature reports whether sig is a valid signature of the
+// download link of the data export with the ID id, and whether the link has
+// not expired.
+func ValidDataExportSignature(id uid.ID, expires int64, sig string) bool {
+	if time.Now().Unix() >= expires {
+		return false
+	}
+	want := signDataExport(getDataExportOptions().HMACKey, id, expires)
+	return hmac.Equal([]byte(want), []byte(sig))
+}
+
+// build writes the zip file of e and notifies the user.
+func (e *DataExport) build(ctx context.Context, db *sql.DB) (err error) {
+	defer func() {
+		if err != nil {
+			e.Status = DataExportStatusFailed
+			// Not ctx, which might be the reason for the failure.
+			if _, err2 := db.ExecContext(context.Background(), "UPDATE data_exports SET status = ?, error = ?, completed_at = ? WHERE id = ?",
+				e.Status, err.Error(), time.Now(), e.ID); err2 != nil {
+				log.Printf("Failed to set data export %v as failed: %v\n", e.ID, err2)
+			}
+		}
+	}()
+
+	opts := getDataExportOptions()
+	if err := os.MkdirAll(opts.Folder, 0755); err != nil {
+		return err
+	}
+	path := dataExportFilepath(opts.Folder, e.ID)
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeDataExport(ctx, db, e.UserID, file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	e.Status = DataExportStatusReady
+	e.Size = info.Size()
+	e.CompletedAt = msql.NewNullTime(now)
+	e.ExpiresAt = msql.NewNullTime(now.Add(opts.TTL))
+	if _, err := db.ExecContext(ctx, "UPDATE data_exports SET status = ?, size = ?, completed_at = ?, expires_at = ? WHERE id = ?",
+		e.Status, e.Size, e.CompletedAt, e.ExpiresAt, e.ID); err != nil {
+		return err
+	}
+
+	return CreateDataExportNotification(ctx, db, e.UserID, e.ID)
+}
+
+// PurgeExpiredDataExports deletes the expired data exports and their files.
+// It returns the number of exports deleted.
+func PurgeExpiredDataExports(ctx context.Context, db *sql.DB) (int, error) {
+	exports, err := getDataExports(ctx, db, "WHERE data_exports.expires_at <= ? OR (data_exports.status = ? AND data_exports.created_at <= ?)",
+		time.Now(), DataExportStatusFailed, time.Now().Add(-dataExportInterval))
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range exports {
+		if err := e.delete(ctx, db); err != nil {
+			return 0, err
+		}
+	}
+	return len(exports), nil
+}
+
+func (e *DataExport) delete(ctx context.Context, db *sql.DB) error {
+	if err := os.Remove(e.Filepath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err := db.ExecContext(ctx, "DELETE FROM data_exports WHERE id = ?", e.ID)
+	return err
+}
+
+// deleteUserDataExportsTx deletes all the data exports of user.
+func deleteUserDataExportsTx(ctx context.Context, tx *sql.Tx, user uid.ID) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM data_exports WHERE user_id = ?", user)
+	if err != nil {
+		return err
+	}
+	var ids []uid.ID
+	for rows.Next() {
+		var id uid.ID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	folder := getDataExportOptions().Folder
+	for _, id := range ids {
+		if err := os.Remove(dataExportFilepath(folder, id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM data_exports WHERE user_id = ?", user)
+	return err
+}
+
+// exportVote is a vote of the user in a data export.
+type exportVote struct {
+	TargetType ContentType `json:"targetType"`
+	TargetID   uid.ID      `json:"targetId"`
+	Up         bool        `json:"up"`
+	CreatedAt  time.Time   `json:"createdAt"`
+}
+
+// exportList is a list of the user, with its items, in a data export.
+type exportList struct {
+	*List
+	Items []*ListItem `json:"items"`
+}
+
+type exportCustomFeed struct {
+	*CustomFeed
+	Communities []string `json:"communities"` // Names.
+}
+
+// exportNotification is a notification of the user in a data export. The
+// notification is exported as it's stored, since the content it refers to may
+// no longer exist.
+type exportNotification struct {
+	Type      NotificationType `json:"type"`
+	Notif     json.RawMessage  `json:"notif"`
+	Seen      bool             `json:"seen"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// dataExport is all the data of a user that goes into a data export.
+type dataExport struct {
+	User          *User                 `json:"user"`
+	Posts         []*Post               `json:"posts"`
+	Comments      []*Comment            `json:"comments"`
+	Votes         []*exportVote         `json:"votes"`
+	Lists         []*exportList         `json:"lists"`
+	CustomFeeds   []*exportCustomFeed   `json:"customFeeds"`
+	Mutes         []*Mute               `json:"mutes"`
+	Blocks        []*Block              `json:"blocks"`
+	Notifications []*exportNotification `json:"notifications"`
+	Images        []string              `json:"images"` // Paths in the zip file.
+
+	imageRecords []*images.ImageRecord
+}
+
+func gatherDataExport(ctx context.Context, db *sql.DB, user uid.ID) (*dataExport, error) {
+	var err error
+	d := &dataExport{}
+
+	if d.User, err = GetUser(ctx, db, user, &user); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, buildSelectPostQuery(true, "WHERE posts.user_id = ? ORDER BY posts.created_at"), user, user)
+	if err != nil {
+		return nil, err
+	}
+	if d.Posts, err = scanPosts(ctx, db, rows, &user); err != nil && err != errPostNotFound {
+		return nil, err
+	}
+
+	if d.Comments, err = getComments(ctx, db, &user, "WHERE comments.user_id = ? ORDER BY comments.created_at", user); err != nil {
+		return nil, err
+	}
+
+	if d.Votes, err = exportVotes(ctx, db, user); err != nil {
+		return nil, err
+	}
+
+	lists, err := GetUsersLists(ctx, db, user, "name", "all")
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		rows, err := db.QueryContext(ctx, buildSelectListItemsQuery("WHERE list_id = ? ORDER BY created_at"), list.ID)
+		if err != nil {
+			return nil, err
+		}
+		items, err := scanListItems(rows, list.ID)
+		if err != nil {
+			return nil, err
+		}
+		if items == nil {
+			items = []*ListItem{}
+		}
+		d.Lists = append(d.Lists, &exportList{List: list, Items: items})
+	}
+
+	feeds, err := GetUsersCustomFeeds(ctx, db, user, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, feed := range feeds {
+		comms, err := feed.GetCommunities(ctx, db, nil)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for _, comm := range comms {
+			names = append(names, comm.Name)
+		}
+		d.CustomFeeds = append(d.CustomFeeds, &exportCustomFeed{CustomFeed: feed, Communities: names})
+	}
+
+	if d.Mutes, err = GetMutes(ctx, db, user); err != nil {
+		return nil, err
+	}
+	if d.Blocks, err = GetBlockedUsers(ctx, db, user, false); err != nil {
+		return nil, err
+	}
+
+	if d.Notifications, err = exportNotifications(ctx, db, user); err != nil {
+		return nil, err
+	}
+
+	if d.imageRecords, err = exportImageRecords(ctx, db, user); err != nil {
+		return nil, err
+	}
+	for _, record := range d.imageRecords {
+		d.Images = append(d.Images, "images/"+record.ID.String()+record.Format.Extension())
+	}
+
+	return d, nil
+}
+
+func exportVotes(ctx context.Context, db *sql.DB, user uid.ID) ([]*exportVote, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT ?, post_id, up, created_at FROM post_votes WHERE user_id = ?
+		UNION ALL
+		SELECT ?, comment_id, up, created_at FROM comment_votes WHERE user_id = ?
+		ORDER BY created_at`, ContentTypePost, user, ContentTypeComment, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	votes := []*exportVote{}
+	for rows.Next() {
+		v := &exportVote{}
+		if err := rows.Scan(&v.TargetType, &v.TargetID, &v.Up, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+func exportNotifications(ctx context.Context, db *sql.DB, user uid.ID) ([]*exportNotification, error) {
+	rows, err := db.QueryContext(ctx, "SELECT type, notif, seen, created_at FROM notifications WHERE user_id = ? ORDER BY id", user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifs := []*exportNotification{}
+	for rows.Next() {
+		n := &exportNotification{}
+		if err := rows.Scan(&n.Type, &n.Notif, &n.Seen, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifs = append(notifs, n)
+	}
+	return notifs, rows.Err()
+}
+
+// exportImageRecords returns the images uploaded by user (the profile picture
+// and the images of image posts) that are not deleted.
+func exportImageRecords(ctx context.Context, db *sql.DB, user uid.ID) ([]*images.ImageRecord, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT pro_pic FROM users WHERE id = ? AND pro_pic IS NOT NULL
+		UNION
+		SELECT post_images.image_id FROM post_images
+		INNER JOIN posts ON posts.id = post_images.post_id
+		WHERE posts.user_id = ?`, user, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uid.ID
+	for rows.Next() {
+		var id uid.ID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	records, err := images.GetImageRecords(ctx, db, ids...)
+	if err != nil {
+		if err == images.ErrImageNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []*images.ImageRecord
+	for _, record := range records {
+		if record.DeletedAt == nil {
+			out = append(out, record)
+		}
+	}
+	return out, nil
+}
+
+var dataExportHTML = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Data of @{{.User.Username}}</title>
+<style>
+body { font-family: sans-serif; max-width: 800px; margin: 2em auto; padding: 0 1em; }
+.item { border-bottom: 1px solid #ddd; padding: 0.5em 0; }
+.meta { color: #666; font-size: 0.9em; }
+pre { white-space: pre-wrap; font-family: inherit; }
+</style>
+</head>
+<body>
+<h1>@{{.User.Username}}</h1>
+<p class="meta">Joined on {{.User.CreatedAt.Format "2006-01-02"}}. Exported on {{.ExportedAt.Format "2006-01-02 15:04 MST"}}.</p>
+{{with .User.EmailPublic}}<p>Email: {{.}}</p>{{end}}
+{{with .User.About}}<pre>{{.String}}</pre>{{end}}
+<p>The same data, and more, is in the JSON files in this archive.</p>
+
+<h2>Posts ({{len .Posts}})</h2>
+{{range .Posts}}<div class="item">
+<h3>{{.Title}}</h3>
+<p class="meta">In {{.CommunityName}} on {{.CreatedAt.Format "2006-01-02 15:04"}}{{if .Deleted}} (deleted){{end}}</p>
+{{with .Body}}<pre>{{.String}}</pre>{{end}}
+{{with .Link}}<p><a href="{{.URL}}">{{.URL}}</a></p>{{end}}
+</div>{{end}}
+
+<h2>Comments ({{len .Comments}})</h2>
+{{range .Comments}}<div class="item">
+<p class="meta">In {{.CommunityName}} on {{.CreatedAt.Format "2006-01-02 15:04"}}{{if .Deleted}} (deleted){{end}}</p>
+<pre>{{.Body}}</pre>
+</div>{{end}}
+
+<h2>Lists ({{len .Lists}})</h2>
+{{range .Lists}}<div class="item">
+<h3>{{.DisplayName}}</h3>
+<p class="meta">{{len .Items}} items</p>
+</div>{{end}}
+
+<h2>Images ({{len .Images}})</h2>
+{{range .Images}}<p><a href="{{.}}">{{.}}</a></p>{{end}}
+</body>
+</html>
+`))
+
+// writeDataExport writes a zip file with all the data of user to w.
+func writeDataExport(ctx context.Context, db *sql.DB, user uid.ID, w io.Writer) error {
+	d, err := gatherDataExport(ctx, db, user)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	addJSON := func(name string, v any) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", d.User},
+		{"posts.json", d.Posts},
+		{"comments.json", d.Comments},
+		{"votes.json", d.Votes},
+		{"lists.json", d.Lists},
+		{"custom_feeds.json", d.CustomFeeds},
+		{"mutes.json", d.Mutes},
+		{"blocks.json", d.Blocks},
+		{"notifications.json", d.Notifications},
+	}
+	for _, file := range files {
+		if err := addJSON(file.name, file.v); err != nil {
+			return fmt.Errorf("writing %s: %w", file.name, err)
+		}
+	}
+
+	f, err := zw.Create("index.html")
+	if err != nil {
+		return err
+	}
+	if err := dataExportHTML.Execute(f, struct {
+		*dataExport
+		ExportedAt time.Time
+	}{d, time.Now()}); err != nil {
+		return err
+	}
+
+	for i, record := range d.imageRecords {
+		data, err := record.Data()
+		if err != nil {
+			return fmt.Errorf("reading image %v: %w", record.ID, err)
+		}
+		f, err := zw.Create(d.Images[i])
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+func TestDataExportDownloadURL(t *testing.T) {
+	SetDataExportOptions(DataExportOptions{Folder: t.TempDir(), HMACKey: []byte("secret"), TTL: time.Hour})
+
+	e := &DataExport{
+		ID:        uid.New(),
+		Status:    DataExportStatusReady,
+		ExpiresAt: msql.NewNullTime(time.Now().Add(time.Hour)),
+	}
+	e.setDownloadURL()
+
+	u, err := url.Parse(e.DownloadURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(u.Path, "/api/_export/"+e.ID.String()) {
+		t.Errorf("unexpected download path %s", u.Path)
+	}
+	expires, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	sig := u.Query().Get("sig")
+
+	if !ValidDataExportSignature(e.ID, expires, sig) {
+		t.Errorf("signature of the download link is not valid")
+	}
+	if ValidDataExportSignature(uid.New(), expires, sig) {
+		t.Errorf("signature is valid for another export")
+	}
+	if ValidDataExportSignature(e.ID, expires+60, sig) {
+		t.Errorf("signature is valid for a later expiry")
+	}
+	past := time.Now().Add(-time.Minute).Unix()
+	if ValidDataExportSignature(e.ID, past, signDataExport([]byte("secret"), e.ID, past)) {
+		t.Errorf("expired link is valid")
+	}
+
+	e.Status = DataExportStatusPending
+	e.setDownloadURL()
+	if e.DownloadURL != "" {
+		t.Errorf("pending exports should not have a download link")
+	}
+}
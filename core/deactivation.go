@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// Deactivate hides the profile of the user (their posts and comments are kept).
+// The user who deactivated the account (the user themself or an admin) is by.
+// If it's the user themself, the account is reactivated when they log in again
+// (see DeactivatedBySelf), at which point Reactivate should be called.
+func (u *User) Deactivate(ctx context.Context, by uid.ID) error {
+	if u.Deleted {
+		return ErrUserDeleted
+	}
+	if u.Deactivated {
+		return errUserDeactivated
+	}
+	now := time.Now()
+	if _, err := u.db.ExecContext(ctx, "UPDATE users SET deactivated_at = ?, deactivated_by = ? WHERE id = ?", now, by, u.ID); err != nil {
+		return err
+	}
+	u.Deactivated = true
+	u.DeactivatedAt = msql.NewNullTime(now)
+	u.DeactivatedBy = uid.NullID{Valid: true, ID: by}
+	return nil
+}
+
+// DeactivatedBySelf reports whether the user has deactivated their own account,
+// in which case logging in reactivates it.
+func (u *User) DeactivatedBySelf() bool {
+	return u.Deactivated && u.DeactivatedBy.Valid && u.DeactivatedBy.ID == u.ID
+}
+
+// ScheduleDeletion deactivates the account and schedules its deletion after
+// days number of days. If deleteContent is true, the posts and comments of the
+// user are deleted as well. The user who scheduled the deletion (the user
+// themself or an admin) is by.
+func (u *User) ScheduleDeletion(ctx context.Context, days int, deleteContent bool, by uid.ID) error {
+	if u.Deleted {
+		return ErrUserDeleted
+	}
+	if days < 0 {
+		days = 0
+	}
+	now := time.Now()
+	deleteAt := now.Add(time.Hour * 24 * time.Duration(days))
+	deactivatedAt, deactivatedBy := now, by
+	if u.Deactivated {
+		deactivatedAt, deactivatedBy = u.DeactivatedAt.Time, u.DeactivatedBy.ID
+	}
+	if _, err := u.db.ExecContext(ctx, "UPDATE users SET deactivated_at = ?, deactivated_by = ?, delete_at = ?, delete_scheduled_by = ?, delete_content = ? WHERE id = ?",
+		deactivatedAt, deactivatedBy, deleteAt, by, deleteContent, u.ID); err != nil {
+		return err
+	}
+	u.Deactivated = true
+	u.DeactivatedAt = msql.NewNullTime(deactivatedAt)
+	u.DeactivatedBy = uid.NullID{Valid: true, ID: deactivatedBy}
+	u.DeleteAt = msql.NewNullTime(deleteAt)
+	u.DeleteAtPublic = &deleteAt
+	u.DeleteScheduledBy = uid.NullID{Valid: true, ID: by}
+	u.DeleteWithContent = deleteContent
+	return nil
+}
+
+// DeletionScheduledBySelf reports whether the user has scheduled their own
+// account for deletion, in which case logging in cancels it.
+func (u *User) DeletionScheduledBySelf() bool {
+	return u.DeleteAt.Valid && u.DeleteScheduledBy.Valid && u.DeleteScheduledBy.ID == u.ID
+}
+
+// CancelDeletion cancels the scheduled deletion of the account (the account
+// remains deactivated, if it is).
+func (u *User) CancelDeletion(ctx context.Context) error {
+	if !u.DeleteAt.Valid {
+		return errDeletionNotScheduled
+	}
+	if _, err := u.db.ExecContext(ctx, "UPDATE users SET delete_at = NULL, delete_scheduled_by = NULL, delete_content = false WHERE id = ?", u.ID); err != nil {
+		return err
+	}
+	u.DeleteAt = msql.NullTime{}
+	u.DeleteAtPublic = nil
+	u.DeleteScheduledBy = uid.NullID{}
+	u.DeleteWithContent = false
+	return nil
+}
+
+// Reactivate undoes Deactivate and cancels the scheduled deletion of the
+// account, if any.
+func (u *User) Reactivate(ctx context.Context) error {
+	if _, err := u.db.ExecContext(ctx, "UPDATE users SET deactivated_at = NULL, deactivated_by = NULL, delete_at = NULL, delete_scheduled_by = NULL, delete_content = false WHERE id = ?", u.ID); err != nil {
+		return err
+	}
+	u.Deactivated = false
+	u.DeactivatedAt = msql.NullTime{}
+	u.DeactivatedBy = uid.NullID{}
+	u.DeleteAt = msql.NullTime{}
+	u.DeleteAtPublic = nil
+	u.DeleteScheduledBy = uid.NullID{}
+	u.DeleteWithContent = false
+	return nil
+}
+
+// GetUsersDueForDeletion returns the users whose scheduled deletion time has
+// passed. Banned users are skipped, since they cannot be deleted (see
+// User.Delete).
+func GetUsersDueForDeletion(ctx context.Context, db *sql.DB) ([]*User, error) {
+	rows, err := db.QueryContext(ctx, buildSelectUserQuery("WHERE users.delete_at <= ? AND users.deleted_at IS NULL AND users.banned_at IS NULL"), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	users, err := scanUsers(ctx, db, rows, nil)
+	if err != nil && err != errUserNotFound {
+		return nil, err
+	}
+	return users, nil
+}
+
+// DeleteScheduled deletes the account (and, if the deletion was scheduled so,
+// its posts and comments). The user should be logged out of all sessions
+// before calling this method.
+func (u *User) DeleteScheduled(ctx context.Context) error {
+	if !u.DeleteAt.Valid || u.DeleteAt.Time.After(time.Now()) {
+		return errDeletionNotScheduled
+	}
+	if u.DeleteWithContent {
+		by := u.ID
+		if u.DeleteScheduledBy.Valid {
+			by = u.DeleteScheduledBy.ID
+		}
+		if err := u.DeleteContent(ctx, 0, by); err != nil {
+			return err
+		}
+	}
+	return u.Delete(ctx)
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/discuitnet/discuit/internal/testdb"
+)
+
+func TestDeactivateAndReactivate(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	user, admin := newTestUser(t, db), newTestAdmin(t, db)
+	tests := []struct {
+		name   string
+		by     *User
+		bySelf bool
+	}{
+		{"by self", user, true},
+		{"by admin", admin, false},
+	}
+	for _, test := range tests {
+		if err := user.Deactivate(ctx, test.by.ID); err != nil {
+			t.Fatalf("%s: deactivating: %v", test.name, err)
+		}
+		if err := user.Deactivate(ctx, test.by.ID); err != errUserDeactivated {
+			t.Errorf("%s: deactivating twice: got error %v, want %v", test.name, err, errUserDeactivated)
+		}
+
+		got, err := GetUser(ctx, db, user.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Deactivated || got.DeactivatedBy.ID != test.by.ID {
+			t.Errorf("%s: got deactivated %v by %v, want deactivated by %v", test.name, got.Deactivated, got.DeactivatedBy, test.by.ID)
+		}
+		if got.DeactivatedBySelf() != test.bySelf {
+			t.Errorf("%s: DeactivatedBySelf is %v, want %v", test.name, got.DeactivatedBySelf(), test.bySelf)
+		}
+
+		if err := got.Reactivate(ctx); err != nil {
+			t.Fatalf("%s: reactivating: %v", test.name, err)
+		}
+		if got, err = GetUser(ctx, db, user.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got.Deactivated || got.DeactivatedBy.Valid {
+			t.Errorf("%s: still deactivated after reactivating", test.name)
+		}
+		user = got
+	}
+}
+
+func TestScheduleDeletion(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	// An account deactivated by an admin stays so (as far as logging in goes)
+	// if the user then schedules its deletion.
+	user, admin := newTestUser(t, db), newTestAdmin(t, db)
+	if err := user.Deactivate(ctx, admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.ScheduleDeletion(ctx, 30, false, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetUser(ctx, db, user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DeactivatedBySelf() || !got.DeletionScheduledBySelf() {
+		t.Errorf("got DeactivatedBySelf %v and DeletionScheduledBySelf %v, want false and true", got.DeactivatedBySelf(), got.DeletionScheduledBySelf())
+	}
+
+	due, err := GetUsersDueForDeletion(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range due {
+		if u.ID == user.ID {
+			t.Error("user due for deletion before the grace period is over")
+		}
+	}
+	if err := got.DeleteScheduled(ctx); err != errDeletionNotScheduled {
+		t.Errorf("DeleteScheduled before the grace period: got error %v, want %v", err, errDeletionNotScheduled)
+	}
+
+	if err := got.CancelDeletion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = GetUser(ctx, db, user.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.DeleteAt.Valid || !got.Deactivated {
+		t.Errorf("after cancelling the deletion: got delete at %v and deactivated %v, want no deletion and deactivated", got.DeleteAt, got.Deactivated)
+	}
+}
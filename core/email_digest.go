@@ -0,0 +1,443 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/discuitnet/discuit/internal/mail"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+const (
+	// The maximum number of notifications and posts in a digest.
+	maxDigestNotifications = 20
+	maxDigestPosts         = 10
+)
+
+// EmailDigestFrequency is how often a user receives an email digest of the
+// user's unread notifications and of the top posts of the user's communities.
+// Digests are opt-in.
+type EmailDigestFrequency int
+
+const (
+	EmailDigestOff = EmailDigestFrequency(iota)
+	EmailDigestDaily
+	EmailDigestWeekly
+)
+
+func (f EmailDigestFrequency) MarshalText() ([]byte, error) {
+	switch f {
+	case EmailDigestOff:
+		return []byte("off"), nil
+	case EmailDigestDaily:
+		return []byte("daily"), nil
+	case EmailDigestWeekly:
+		return []byte("weekly"), nil
+	}
+	return nil, errors.New("unsupported email digest frequency")
+}
+
+func (f *EmailDigestFrequency) UnmarshalText(data []byte) error {
+	switch string(data) {
+	case "off":
+		*f = EmailDigestOff
+	case "daily":
+		*f = EmailDigestDaily
+	case "weekly":
+		*f = EmailDigestWeekly
+	default:
+		return errors.New("unsupported email digest frequency")
+	}
+	return nil
+}
+
+// interval returns the minimum time between two digests. It's a little less
+// than a day (or a week) so that digests, which are sent by an hourly job,
+// don't drift later each time.
+func (f EmailDigestFrequency) interval() time.Duration {
+	if f == EmailDigestWeekly {
+		return time.Hour * (24*7 - 1)
+	}
+	return time.Hour * 23
+}
+
+func (f EmailDigestFrequency) feedSort() FeedSort {
+	if f == EmailDigestWeekly {
+		return FeedSortTopWeek
+	}
+	return FeedSortTopDay
+}
+
+// EmailDigestOptions configure the email digests.
+type EmailDigestOptions struct {
+	// Sender sends the emails. If it's nil, no digests are sent.
+	Sender mail.Sender
+
+	// From is the From address of the emails.
+	From string
+
+	SiteName string
+
+	// SiteURL is the URL of the site (like "https://discuit.net"), which is
+	// used to make the links in the emails.
+	SiteURL string
+
+	// HMACKey is the key used to sign unsubscribe links.
+	HMACKey []byte
+}
+
+var emailDigestOptions = struct {
+	sync.RWMutex
+	EmailDigestOptions
+}{}
+
+// SetEmailDigestOptions sets the options of email digests. Call it before the
+// server is started.
+func SetEmailDigestOptions(opts EmailDigestOptions) {
+	emailDigestOptions.Lock()
+	defer emailDigestOptions.Unlock()
+	opts.SiteURL = strings.TrimSuffix(opts.SiteURL, "/")
+	emailDigestOptions.EmailDigestOptions = opts
+}
+
+func getEmailDigestOptions() EmailDigestOptions {
+	emailDigestOptions.RLock()
+	defer emailDigestOptions.RUnlock()
+	return emailDigestOptions.EmailDigestOptions
+}
+
+func signEmailDigestUnsubscribe(key []byte, user uid.ID) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("email_digest_unsubscribe:" + user.String()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// EmailDigestUnsubscribeURL returns the signed link with which user can turn
+// off email digests without logging in.
+func EmailDigestUnsubscribeURL(user uid.ID) string {
+	opts := getEmailDigestOptions()
+	v := url.Values{}
+	v.Set("user", user.String())
+	v.Set("sig", signEmailDigestUnsubscribe(opts.HMACKey, user))
+	return opts.SiteURL + "/api/email_digest/unsubscribe?" + v.Encode()
+}
+
+// UnsubscribeFromEmailDigest turns off the email digests of user, if sig is a
+// valid signature of the unsubscribe link of user.
+func UnsubscribeFromEmailDigest(ctx context.Context, db *sql.DB, user uid.ID, sig string) error {
+	want := signEmailDigestUnsubscribe(getEmailDigestOptions().HMACKey, user)
+	if !hmac.Equal([]byte(want), []byte(sig)) {
+		return ErrInvalidUnsubscribeLink
+	}
+	_, err := db.ExecContext(ctx, "UPDATE users SET email_digest = ? WHERE id = ?", EmailDigestOff, user)
+	return err
+}
+
+// SendEmailDigests sends the email digests that are due, and returns the
+// number of emails sent. It's meant to be called periodically (every hour).
+func SendEmailDigests(ctx context.Context, db *sql.DB) (int, error) {
+	opts := getEmailDigestOptions()
+	if opts.Sender == nil {
+		return 0, nil
+	}
+
+	now := time.Now()
+	rows, err := db.QueryContext(ctx, `
+		SELECT id FROM users
+		WHERE email_digest <> ? AND email IS NOT NULL AND email <> ''
+			AND deleted_at IS NULL AND banned_at IS NULL AND deactivated_at IS NULL
+			AND (email_digest_sent_at IS NULL OR email_digest_sent_at < (CASE email_digest WHEN ? THEN ? ELSE ? END))`,
+		EmailDigestOff, EmailDigestWeekly, now.Add(-EmailDigestWeekly.interval()), now.Add(-EmailDigestDaily.interval()))
+	if err != nil {
+		return 0, err
+	}
+	users, err := scanIDs(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range users {
+		sent, err := sendEmailDigest(ctx, db, opts, id, now)
+		if err != nil {
+			log.Printf("Failed sending email digest to user %v: %v\n", id, err)
+			continue
+		}
+		if sent {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// sendEmailDigest sends the digest of user, unless it's empty, and records
+// that it's done. It reports whether an email was sent.
+func sendEmailDigest(ctx context.Context, db *sql.DB, opts EmailDigestOptions, user uid.ID, now time.Time) (bool, error) {
+	u, err := GetUser(ctx, db, user, nil)
+	if err != nil {
+		return false, err
+	}
+
+	since := now.Add(-u.EmailDigest.interval())
+	if u.EmailDigestSentAt.Valid && u.EmailDigestSentAt.Time.After(since) {
+		since = u.EmailDigestSentAt.Time
+	}
+	d, err := buildEmailDigest(ctx, db, opts, u, since)
+	if err != nil {
+		return false, err
+	}
+
+	sent := false
+	if len(d.Notifications) > 0 || len(d.Posts) > 0 {
+		msg, err := d.message(opts, u.Email.String)
+		if err != nil {
+			return false, err
+		}
+		if err := opts.Sender.Send(ctx, msg); err != nil {
+			return false, err
+		}
+		sent = true
+	}
+	_, err = db.ExecContext(ctx, "UPDATE users SET email_digest_sent_at = ? WHERE id = ?", now, user)
+	return sent, err
+}
+
+type emailDigestItem struct {
+	Text string
+	URL  string
+}
+
+type emailDigest struct {
+	SiteName       string
+	SiteURL        string
+	Username       string
+	Frequency      EmailDigestFrequency
+	Notifications  []emailDigestItem
+	Posts          []emailDigestItem
+	SettingsURL    string
+	UnsubscribeURL string
+}
+
+// buildEmailDigest gathers the unread notifications of u (created after since,
+// and of the types for which u has turned on emails) and the top posts of the
+// communities u has joined.
+func buildEmailDigest(ctx context.Context, db *sql.DB, opts EmailDigestOptions, u *User, since time.Time) (*emailDigest, error) {
+	d := &emailDigest{
+		SiteName:       opts.SiteName,
+		SiteURL:        opts.SiteURL,
+		Username:       u.Username,
+		Frequency:      u.EmailDigest,
+		SettingsURL:    opts.SiteURL + "/settings",
+		UnsubscribeURL: EmailDigestUnsubscribeURL(u.ID),
+	}
+
+	notifs, _, err := GetNotifications(ctx, db, u.ID, MaxNotificationsPerUser, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notifs {
+		if len(d.Notifications) == maxDigestNotifications {
+			break
+		}
+		if n.Seen || n.CreatedAt.Before(since) || !u.NotificationPreferences.Channels(n.Type).Email {
+			continue
+		}
+		text, path, err := n.digestText(ctx, db)
+		if err != nil {
+			if err == errPostNotFound || err == errCommentNotFound {
+				continue
+			}
+			return nil, err
+		}
+		d.Notifications = append(d.Notifications, emailDigestItem{Text: text, URL: opts.SiteURL + path})
+	}
+
+	set, err := GetFeed(ctx, db, &FeedOptions{
+		Sort:     u.EmailDigest.feedSort(),
+		Viewer:   &u.ID,
+		Homefeed: true,
+		Limit:    maxDigestPosts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range set.Posts {
+		d.Posts = append(d.Posts, emailDigestItem{
+			Text: fmt.Sprintf("%s (%s, %d comments)", post.Title, post.CommunityName, post.NumComments),
+			URL:  opts.SiteURL + postPath(post),
+		})
+	}
+	return d, nil
+}
+
+func postPath(post *Post) string {
+	return "/" + post.CommunityName + "/post/" + post.PublicID
+}
+
+// digestText returns a short description of n, and the path of the page it
+// links to.
+func (n *Notification) digestText(ctx context.Context, db *sql.DB) (string, string, error) {
+	getPost := func(id uid.ID) (*Post, error) {
+		return GetPost(ctx, db, &id, "", nil, true)
+	}
+	getCommentPost := func(id uid.ID) (*Post, error) {
+		comment, err := GetComment(ctx, db, id, nil)
+		if err != nil {
+			return nil, err
+		}
+		return getPost(comment.PostID)
+	}
+	plural := func(n int, s string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", s)
+		}
+		return fmt.Sprintf("%d %ss", n, s)
+	}
+
+	switch notif := n.Notif.(type) {
+	case *NotificationNewComment:
+		post, err := getPost(notif.PostID)
+		if err != nil {
+			return "", "", err
+		}
+		if notif.NumComments > 1 {
+			return fmt.Sprintf("%s on your post %q", plural(notif.NumComments, "new comment"), post.Title), postPath(post), nil
+		}
+		return fmt.Sprintf("@%s commented on your post %q", notif.CommentAuthor, post.Title), postPath(post) + "/" + notif.CommentID.String(), nil
+	case *NotificationCommentReply:
+		post, err := getPost(notif.PostID)
+		if err != nil {
+			return "", "", err
+		}
+		if notif.NumComments > 1 {
+			return fmt.Sprintf("%s to your comment on %q", plural(notif.NumComments, "new reply"), post.Title), postPath(post) + "/" + notif.ParentCommentID.String(), nil
+		}
+		return fmt.Sprintf("@%s replied to your comment on %q", notif.CommentAuthor, post.Title), postPath(post) + "/" + notif.CommentID.String(), nil
+	case *NotificationThreadReply:
+		post, err := getPost(notif.PostID)
+		if err != nil {
+			return "", "", err
+		}
+		return fmt.Sprintf("%s in a thread you follow on %q", plural(notif.NumComments, "new comment"), post.Title), postPath(post) + "/" + notif.CommentID.String(), nil
+	case *NotificationMention:
+		post, err := getPost(notif.PostID)
+		if err != nil {
+			return "", "", err
+		}
+		author, err := GetUser(ctx, db, notif.AuthorID, nil)
+		if err != nil {
+			return "", "", err
+		}
+		path := postPath(post)
+		if notif.CommentID != nil {
+			path += "/" + notif.CommentID.String()
+		}
+		return fmt.Sprintf("@%s mentioned you in %q", author.Username, post.Title), path, nil
+	case *NotificationNewVotes:
+		var post *Post
+		var err error
+		if notif.TargetType == "post" {
+			post, err = getPost(notif.TargetID)
+		} else {
+			post, err = getCommentPost(notif.TargetID)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return fmt.Sprintf("%s on your %s in %q", plural(notif.NoVotes, "new upvote"), notif.TargetType, post.Title), postPath(post), nil
+	case *NotificationPostDeleted:
+		return fmt.Sprintf("Your %s was removed", notif.TargetType), "/notifications", nil
+	case *NotificationModAdd:
+		return fmt.Sprintf("You were made a moderator of %s", notif.CommunityName), "/" + notif.CommunityName, nil
+	case *NotificationNewBadge:
+		return "You received a new badge", "/notifications", nil
+	case *NotificationDataExport:
+		return "Your data export is ready to download", "/settings", nil
+	case *NotificationCommunityRequest:
+		return "Your community request has been reviewed", "/notifications", nil
+	}
+	return "You have a new notification", "/notifications", nil
+}
+
+func (d *emailDigest) subject() string {
+	when := "daily"
+	if d.Frequency == EmailDigestWeekly {
+		when = "weekly"
+	}
+	return fmt.Sprintf("Your %s %s digest", when, d.SiteName)
+}
+
+// message renders d into an email to the address to.
+func (d *emailDigest) message(opts EmailDigestOptions, to string) (*mail.Message, error) {
+	var html, text bytes.Buffer
+	if err := emailDigestHTML.Execute(&html, d); err != nil {
+		return nil, err
+	}
+	if err := emailDigestText.Execute(&text, d); err != nil {
+		return nil, err
+	}
+	return &mail.Message{
+		From:    opts.From,
+		To:      to,
+		Subject: d.subject(),
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			// One-click unsubscribe (RFC 8058).
+			"List-Unsubscribe":      "<" + d.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
+
+var emailDigestHTML = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.SiteName}}</title>
+</head>
+<body style="font-family: sans-serif; max-width: 600px; margin: 0 auto; padding: 1em; color: #222;">
+<p>Hi @{{.Username}}, here's what you missed on <a href="{{.SiteURL}}">{{.SiteName}}</a>.</p>
+{{if .Notifications}}<h2 style="font-size: 1.2em;">Unread notifications</h2>
+<ul>
+{{range .Notifications}}<li><a href="{{.URL}}">{{.Text}}</a></li>
+{{end}}</ul>
+{{end}}{{if .Posts}}<h2 style="font-size: 1.2em;">Top posts in your communities</h2>
+<ul>
+{{range .Posts}}<li><a href="{{.URL}}">{{.Text}}</a></li>
+{{end}}</ul>
+{{end}}<p style="color: #666; font-size: 0.9em;">You're receiving this email because you turned on email digests. <a href="{{.UnsubscribeURL}}">Unsubscribe</a> or <a href="{{.SettingsURL}}">change your settings</a>.</p>
+</body>
+</html>
+`))
+
+var emailDigestText = texttemplate.Must(texttemplate.New("digest").Parse(`Hi @{{.Username}}, here's what you missed on {{.SiteName}} ({{.SiteURL}}).
+{{if .Notifications}}
+Unread notifications:
+{{range .Notifications}}
+- {{.Text}}
+  {{.URL}}
+{{end}}{{end}}{{if .Posts}}
+Top posts in your communities:
+{{range .Posts}}
+- {{.Text}}
+  {{.URL}}
+{{end}}{{end}}
+--
+You're receiving this email because you turned on email digests.
+Unsubscribe: {{.UnsubscribeURL}}
+Change your settings: {{.SettingsURL}}
+`))
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+func TestEmailDigestMessage(t *testing.T) {
+	SetEmailDigestOptions(EmailDigestOptions{SiteName: "Discuit", SiteURL: "https://example.com/", HMACKey: []byte("key")})
+	defer SetEmailDigestOptions(EmailDigestOptions{})
+
+	user := uid.New()
+	opts := getEmailDigestOptions()
+	d := &emailDigest{
+		SiteName:       opts.SiteName,
+		SiteURL:        opts.SiteURL,
+		Username:       "alice",
+		Frequency:      EmailDigestWeekly,
+		Notifications:  []emailDigestItem{{Text: `@bob replied to your comment on "<Hello>"`, URL: opts.SiteURL + "/general/post/abc"}},
+		SettingsURL:    opts.SiteURL + "/settings",
+		UnsubscribeURL: EmailDigestUnsubscribeURL(user),
+	}
+	m, err := d.message(opts, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Subject != "Your weekly Discuit digest" {
+		t.Errorf("subject: got %q", m.Subject)
+	}
+	if !strings.HasPrefix(d.UnsubscribeURL, "https://example.com/api/email_digest/unsubscribe?") {
+		t.Errorf("unsubscribe URL: got %q", d.UnsubscribeURL)
+	}
+	if !strings.Contains(d.UnsubscribeURL, signEmailDigestUnsubscribe([]byte("key"), user)) {
+		t.Error("unsubscribe URL is not signed")
+	}
+	if m.Headers["List-Unsubscribe"] != "<"+d.UnsubscribeURL+">" {
+		t.Errorf("List-Unsubscribe: got %q", m.Headers["List-Unsubscribe"])
+	}
+	if !strings.Contains(m.Text, `@bob replied to your comment on "<Hello>"`) {
+		t.Error("text body is missing the notification")
+	}
+	if strings.Contains(m.HTML, "<Hello>") || !strings.Contains(m.HTML, "&lt;Hello&gt;") {
+		t.Error("html body is not escaped")
+	}
+	if strings.Contains(m.Text, "Top posts") || strings.Contains(m.HTML, "Top posts") {
+		t.Error("empty posts section is rendered")
+	}
+}
@@ -12,6 +12,14 @@ var (
 	ErrWrongPassword = &httperr.Error{HTTPStatus: http.StatusUnauthorized, Code: "wrong-password", Message: "Username and password do not match."}
 
 	ErrUserDeleted = httperr.NewForbidden("user-deleted", "Cannot continue because the user is deleted.")
+
+	// ErrWrongTwoFactorCode is returned if a two-factor authentication code (or
+	// recovery code) is invalid.
+	ErrWrongTwoFactorCode = &httperr.Error{HTTPStatus: http.StatusUnauthorized, Code: "wrong_2fa_code", Message: "Invalid authentication code."}
+
+	// ErrInvalidUnsubscribeLink is returned by UnsubscribeFromEmailDigest if
+	// the signature of the link is invalid.
+	ErrInvalidUnsubscribeLink = httperr.NewForbidden("invalid_unsubscribe_link", "Unsubscribe link is invalid.")
 )
 
 var (
@@ -34,4 +42,32 @@ var (
 	errPostTypeUnsupported = httperr.NewBadRequest("post-type/unsupported", "Unsupported post type.")
 
 	errInvalidUserGroup = httperr.NewBadRequest("user/invalid-group", "Invalid user-group.")
+
+	errSitemapNotFound = httperr.NewNotFound("sitemap_not_found", "Sitemap not found.")
+
+	errContentFilterNotFound = httperr.NewNotFound("filter_not_found", "Content filter not found.")
+	errSpamFlagNotFound      = httperr.NewNotFound("spam_flag_not_found", "Spam flag not found.")
+	errContentFiltered       = httperr.NewForbidden("content_filtered", "Your post or comment contains words or links that are not allowed here.")
+	errNotHeld               = httperr.NewBadRequest("not_held", "Content is not held for review.")
+
+	err2FAAlreadyEnabled = httperr.NewBadRequest("2fa_already_enabled", "Two-factor authentication is already enabled.")
+	err2FANotEnabled     = httperr.NewBadRequest("2fa_not_enabled", "Two-factor authentication is not enabled.")
+	err2FANotEnrolling   = httperr.NewBadRequest("2fa_not_enrolling", "Two-factor authentication enrolment is not started.")
+
+	errUserDeactivated      = httperr.NewBadRequest("user_deactivated", "Account is already deactivated.")
+	errDeletionNotScheduled = httperr.NewBadRequest("deletion_not_scheduled", "Account deletion is not scheduled.")
+
+	errDataExportNotFound = httperr.NewNotFound("export_not_found", "Data export not found.")
+	errDataExportTooSoon  = &httperr.Error{HTTPStatus: http.StatusTooManyRequests, Code: "export_too_soon", Message: "A data export can be requested only once a day."}
+
+	errIPBanNotFound = httperr.NewNotFound("ip_ban_not_found", "IP ban not found.")
+	errIPBanExists   = &httperr.Error{HTTPStatus: http.StatusConflict, Code: "ip_ban_exists", Message: "The IP address or range is already banned."}
+	errInvalidCIDR   = httperr.NewBadRequest("invalid_cidr", "Invalid IP address or CIDR range.")
+
+	errCannotBlockSelf = httperr.NewBadRequest("cannot_block_self", "You cannot block yourself.")
+	errBlocked         = httperr.NewForbidden("blocked", "You cannot reply to this user.")
+
+	errCommunityRequestNotFound = httperr.NewNotFound("community_request_not_found", "Community request not found.")
+	errCommunityRequestResolved = &httperr.Error{HTTPStatus: http.StatusConflict, Code: "community_request_resolved", Message: "Community request is already approved or rejected."}
+	errCommunityRequestApproved = &httperr.Error{HTTPStatus: http.StatusConflict, Code: "community_request_approved", Message: "You've already requested this community, and it was approved."}
 )
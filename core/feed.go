@@ -26,6 +26,8 @@ const (
 	FeedSortTopMonth
 	FeedSortTopYear
 	FeedSortTopAll
+	FeedSortRising
+	FeedSortControversial
 )
 
 // Valid reports whether f is a valid FeedSort.
@@ -53,6 +55,10 @@ func (s FeedSort) MarshalText() ([]byte, error) {
 		return []byte("hot"), nil
 	case FeedSortActivity:
 		return []byte("activity"), nil
+	case FeedSortRising:
+		return []byte("rising"), nil
+	case FeedSortControversial:
+		return []byte("controversial"), nil
 	}
 	return nil, fmt.Errorf("cannot marshal unsupported FeedSort (%v)", int(s))
 }
@@ -81,6 +87,10 @@ func (s *FeedSort) UnmarshalText(text []byte) error {
 		*s = FeedSortHot
 	case "activity":
 		*s = FeedSortActivity
+	case "rising":
+		*s = FeedSortRising
+	case "controversial":
+		*s = FeedSortControversial
 	default:
 		return fmt.Errorf("cannot unmarshal unsupported FeedSort: %v", t)
 	}
@@ -149,6 +159,10 @@ func newFeedResultSet(posts []*Post, limit int, sort FeedSort) *FeedResultSet {
 			nextnext = strconv.Itoa(posts[limit].Hotness) + "." + posts[limit].ID.String()
 		case FeedSortActivity:
 			nextnext = posts[limit].LastActivityAt.UnixNano()
+		case FeedSortRising:
+			nextnext = strconv.Itoa(posts[limit].Rising) + "." + posts[limit].ID.String()
+		case FeedSortControversial:
+			nextnext = strconv.Itoa(posts[limit].Controversy) + "." + posts[limit].ID.String()
 		default:
 			// Shouldn't happen, ever.
 			panic("invalid feed sort")
@@ -179,14 +193,38 @@ func NextPointsIDCursor(text string) (p int, id *uid.ID, err error) {
 
 const whereSelectUserComms = "community_id IN (SELECT community_members.community_id FROM community_members WHERE community_members.user_id = ?) "
 
+const whereSelectCustomFeedComms = "community_id IN (SELECT custom_feed_communities.community_id FROM custom_feed_communities WHERE custom_feed_communities.feed_id = ?) "
+
+// whereFeedCommunities returns the condition, to be added to a where clause,
+// that limits the posts of a feed to its communities: the communities the
+// viewer has joined for the home feed, the communities of the custom feed for
+// custom feeds, and opts.Community for community feeds. It returns an empty
+// string for the site-wide feed.
+func whereFeedCommunities(opts *FeedOptions) (string, []any) {
+	if opts.Homefeed {
+		return whereSelectUserComms, []any{*opts.Viewer}
+	} else if opts.CustomFeed != nil {
+		return whereSelectCustomFeedComms, []any{*opts.CustomFeed}
+	} else if opts.Community != nil {
+		return "community_id = ? ", []any{*opts.Community}
+	}
+	return "", nil
+}
+
 type FeedOptions struct {
 	Sort        FeedSort
 	DefaultSort bool
 	Viewer      *uid.ID
 	Community   *uid.ID // Community should be nil if Homefeed is true.
 	Homefeed    bool
+	CustomFeed  *int // The ID of a custom feed. Community should be nil and Homefeed false if it's set.
 	Limit       int
 	Next        string // The pagination cursor, taken from previous API response.
+
+	// If true, the posts that Viewer has hidden, or has viewed, are left out
+	// (see hidden_post.go).
+	HideHidden bool
+	HideViewed bool
 }
 
 var (
@@ -236,15 +274,17 @@ func GetFeed(ctx context.Context, db *sql.DB, opts *FeedOptions) (_ *FeedResultS
 		set, err = getPostsHot(ctx, db, opts)
 	} else if opts.Sort == FeedSortActivity {
 		set, err = getPostsActivity(ctx, db, opts)
+	} else if opts.Sort == FeedSortRising || opts.Sort == FeedSortControversial {
+		set, err = getPostsByScore(ctx, db, opts)
 	} else {
 		set, err = getPostsTop(ctx, db, opts)
 	}
 	if err != nil {
 		return nil, err
 	}
-	if opts.DefaultSort {
+	if opts.DefaultSort && opts.CustomFeed == nil {
 		// Merge pinned posts.
-		return mergePinnedPosts(ctx, db, opts.Viewer, opts.Community, opts.Next, set)
+		return mergePinnedPosts(ctx, db, opts, set)
 	}
 	return set, err
 }
@@ -258,19 +298,18 @@ func getPostsLatest(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedRe
 	if loggedIn {
 		args = append(args, opts.Viewer)
 	}
-	where := "WHERE posts.deleted = FALSE "
-	if opts.Homefeed {
-		where += "AND " + whereSelectUserComms
-		args = append(args, *opts.Viewer)
-	} else {
-		if opts.Community != nil {
-			where += "AND community_id = ? "
-			args = append(args, *opts.Community)
-		}
+	where := "WHERE posts.deleted = FALSE AND posts.held_at IS NULL "
+	if cond, condArgs := whereFeedCommunities(opts); cond != "" {
+		where += "AND " + cond
+		args = append(args, condArgs...)
 	}
 	if loggedIn {
 		where, args = whereMuted(where, "posts", args, *opts.Viewer, opts.Community == nil && !opts.Homefeed)
 	}
+	where, args, err := whereHidden(db, where, "posts", args, opts)
+	if err != nil {
+		return nil, err
+	}
 	if opts.Next != "" {
 		next, err := opts.nextID()
 		if err != nil {
@@ -283,7 +322,6 @@ func getPostsLatest(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedRe
 	query := buildSelectPostQuery(loggedIn, where)
 
 	var rows *sql.Rows
-	var err error
 	args = append(args, opts.Limit+1)
 	rows, err = db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -332,17 +370,22 @@ func scanIDs(rows *sql.Rows) ([]uid.ID, error) {
 	return ids, nil
 }
 
-// mergePinnedPosts fetches site-wide pinned posts, if community is nil, or
-// community-wide pinned posts, if not, and merges them to rs.
-func mergePinnedPosts(ctx context.Context, db *sql.DB, viewer, community *uid.ID, next string, rs *FeedResultSet) (*FeedResultSet, error) {
-	if next != "" {
+// mergePinnedPosts fetches site-wide pinned posts, if opts.Community is nil, or
+// community-wide pinned posts, if not, and merges them to rs. Pinned posts
+// that the viewer has hidden (or viewed, if opts says so) are left out.
+func mergePinnedPosts(ctx context.Context, db *sql.DB, opts *FeedOptions, rs *FeedResultSet) (*FeedResultSet, error) {
+	if opts.Next != "" {
 		return rs, nil
 	}
+	community := opts.Community
 
-	pinned, err := getPinnedPosts(ctx, db, viewer, community)
+	pinned, err := getPinnedPosts(ctx, db, opts.Viewer, community)
 	if err != nil {
 		return nil, err
 	}
+	if pinned, err = filterHiddenByViewer(ctx, db, pinned, opts); err != nil {
+		return nil, err
+	}
 
 	var notPinned []*Post
 	for _, post := range rs.Posts {
@@ -368,11 +411,54 @@ func whereMuted(where, postsTable string, args []any, viewer uid.ID, muteCommuni
 		where += "community_id NOT IN (SELECT community_id FROM muted_communities WHERE user_id = ?) AND "
 		args = append(args, viewer)
 	}
-	where += postsTable + ".user_id NOT IN (SELECT muted_user_id FROM muted_users WHERE user_id = ?)"
-	args = append(args, viewer)
+	where += postsTable + ".user_id NOT IN (SELECT muted_user_id FROM muted_users WHERE user_id = ?) AND "
+	where += postsTable + ".user_id NOT IN (SELECT blocked_user_id FROM blocked_users WHERE user_id = ?)"
+	args = append(args, viewer, viewer)
 	return where, args
 }
 
+// whereHidden adds to where the conditions that leave out the posts that are
+// hidden from the viewer: posts of shadow banned users, in the site-wide feed
+// and in custom feeds, posts of quarantined communities, and, if opts says so,
+// posts hidden or viewed by the viewer. The conditions don't depend on the
+// sort order of the feed, so the pagination cursors are unaffected.
+func whereHidden(db *sql.DB, where, postsTable string, args []any, opts *FeedOptions) (string, []any, error) {
+	cond, condArgs, err := whereNotShadowBanned(db, postsTable, opts.Viewer)
+	if err != nil {
+		return "", nil, err
+	}
+	and := func(c string) {
+		if cond != "" {
+			cond += " AND "
+		}
+		cond += c
+	}
+	if opts.Community == nil && !opts.Homefeed {
+		and(postsTable + ".community_id NOT IN (SELECT id FROM communities WHERE quarantined_at IS NOT NULL)")
+	}
+	if opts.Viewer != nil {
+		postID := postsTable + ".post_id" // posts_today, posts_week, and so on
+		if postsTable == "posts" {
+			postID = "posts.id"
+		}
+		if opts.HideHidden {
+			and(postID + " NOT IN (SELECT post_id FROM hidden_posts WHERE user_id = ?)")
+			condArgs = append(condArgs, *opts.Viewer)
+		}
+		if opts.HideViewed {
+			and(postID + " NOT IN (SELECT post_id FROM viewed_posts WHERE user_id = ?)")
+			condArgs = append(condArgs, *opts.Viewer)
+		}
+	}
+	if cond == "" {
+		return where, args, nil
+	}
+	if !(where == "" || strings.TrimSpace(strings.ToUpper(where)) == "WHERE") {
+		where += " AND "
+	}
+	return where + cond + " ", append(args, condArgs...), nil
+}
+
 // getPostsHot returns site wide hot posts, if opts.Community is nil, or hot
 // posts in opts.Community, if not.
 func getPostsHot(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedResultSet, error) {
@@ -382,19 +468,18 @@ func getPostsHot(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedResul
 	if loggedIn {
 		args = append(args, opts.Viewer)
 	}
-	where := "WHERE posts.deleted = FALSE "
-	if opts.Homefeed {
-		where += "AND " + whereSelectUserComms
-		args = append(args, *opts.Viewer)
-	} else {
-		if opts.Community != nil {
-			where += "AND community_id = ? "
-			args = append(args, *opts.Community)
-		}
+	where := "WHERE posts.deleted = FALSE AND posts.held_at IS NULL "
+	if cond, condArgs := whereFeedCommunities(opts); cond != "" {
+		where += "AND " + cond
+		args = append(args, condArgs...)
 	}
 	if loggedIn {
 		where, args = whereMuted(where, "posts", args, *opts.Viewer, opts.Community == nil && !opts.Homefeed)
 	}
+	where, args, err := whereHidden(db, where, "posts", args, opts)
+	if err != nil {
+		return nil, err
+	}
 	if opts.Next != "" {
 		nextHotness, nextID, err := opts.nextPointsID()
 		if err != nil {
@@ -408,7 +493,6 @@ func getPostsHot(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedResul
 	query := buildSelectPostQuery(loggedIn, where)
 
 	var rows *sql.Rows
-	var err error
 	args = append(args, opts.Limit+1)
 	rows, err = db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -425,6 +509,66 @@ func getPostsHot(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedResul
 	return newFeedResultSet(posts, opts.Limit, FeedSortHot), nil
 }
 
+// getPostsByScore returns site wide posts, if opts.Community is nil, or posts
+// in opts.Community, if not, sorted by either their rising or their
+// controversy score (see postRising and PostControversy).
+func getPostsByScore(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedResultSet, error) {
+	var args []any
+	loggedIn := opts.Viewer != nil
+
+	col := "posts.controversy"
+	if opts.Sort == FeedSortRising {
+		col = "posts.rising"
+	}
+
+	if loggedIn {
+		args = append(args, opts.Viewer)
+	}
+	where := "WHERE posts.deleted = FALSE AND posts.held_at IS NULL "
+	if opts.Sort == FeedSortRising {
+		where += "AND posts.created_at > ? AND posts.rising > 0 "
+		args = append(args, time.Now().Add(-risingMaxAge))
+	} else {
+		where += "AND posts.controversy > 0 "
+	}
+	if cond, condArgs := whereFeedCommunities(opts); cond != "" {
+		where += "AND " + cond
+		args = append(args, condArgs...)
+	}
+	if loggedIn {
+		where, args = whereMuted(where, "posts", args, *opts.Viewer, opts.Community == nil && !opts.Homefeed)
+	}
+	where, args, err := whereHidden(db, where, "posts", args, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Next != "" {
+		nextScore, nextID, err := opts.nextPointsID()
+		if err != nil {
+			return nil, err
+		}
+		where += "AND (" + col + ", posts.id) <= (?, ?) "
+		args = append(args, nextScore, nextID)
+	}
+	where += "ORDER BY " + col + " DESC, posts.id DESC LIMIT ?"
+	query := buildSelectPostQuery(loggedIn, where)
+
+	args = append(args, opts.Limit+1)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := scanPosts(ctx, db, rows, opts.Viewer)
+	if err != nil {
+		if err == errPostNotFound {
+			return &FeedResultSet{}, nil
+		}
+		return nil, err
+	}
+	return newFeedResultSet(posts, opts.Limit, opts.Sort), nil
+}
+
 // getPostsTopAll returns site wide all time top posts, if opts.Community is
 // nil, or all time top posts in opts.Community, if not.
 func getPostsTopAll(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedResultSet, error) {
@@ -434,19 +578,18 @@ func getPostsTopAll(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedRe
 		args = append(args, *opts.Viewer)
 	}
 
-	where := "WHERE deleted = FALSE "
-	if opts.Homefeed {
-		where += "AND " + whereSelectUserComms
-		args = append(args, *opts.Viewer)
-	} else {
-		if opts.Community != nil {
-			where += "AND community_id = ? "
-			args = append(args, *opts.Community)
-		}
+	where := "WHERE deleted = FALSE AND posts.held_at IS NULL "
+	if cond, condArgs := whereFeedCommunities(opts); cond != "" {
+		where += "AND " + cond
+		args = append(args, condArgs...)
 	}
 	if loggedIn {
 		where, args = whereMuted(where, "posts", args, *opts.Viewer, opts.Community == nil && !opts.Homefeed)
 	}
+	where, args, err := whereHidden(db, where, "posts", args, opts)
+	if err != nil {
+		return nil, err
+	}
 	if opts.Next != "" {
 		nextPoints, nextID, err := opts.nextPointsID()
 		if err != nil {
@@ -487,18 +630,17 @@ func getPostsTop(ctx context.Context, db *sql.DB, opts *FeedOptions) (*FeedResul
 	var args []any
 	query := fmt.Sprintf("SELECT post_id FROM %s ", table)
 	where := ""
-	if opts.Homefeed {
-		where += whereSelectUserComms
-		args = append(args, *opts.Viewer)
-	} else {
-		if opts.Community != nil {
-			where += "community_id = ? "
-			args = append(args, *opts.Community)
-		}
+	if cond, condArgs := whereFeedCommunities(opts); cond != "" {
+		where += cond
+		args = append(args, condArgs...)
 	}
 	if opts.Viewer != nil {
 		where, args = whereMuted(where, table, args, *opts.Viewer, opts.Community == nil && !opts.Homefeed)
 	}
+	where, args, err := whereHidden(db, where, table, args, opts)
+	if err != nil {
+		return nil, err
+	}
 	if opts.Next != "" {
 		nextPoints, nextID, err := opts.nextPointsID()
 		if err != nil {
@@ -547,19 +689,18 @@ func getPostsActivity(ctx context.Context, db *sql.DB, opts *FeedOptions) (*Feed
 	if loggedIn {
 		args = append(args, opts.Viewer)
 	}
-	where := "WHERE posts.deleted = FALSE "
-	if opts.Homefeed {
-		where += "AND " + whereSelectUserComms
-		args = append(args, *opts.Viewer)
-	} else {
-		if opts.Community != nil {
-			where += "AND community_id = ? "
-			args = append(args, *opts.Community)
-		}
+	where := "WHERE posts.deleted = FALSE AND posts.held_at IS NULL "
+	if cond, condArgs := whereFeedCommunities(opts); cond != "" {
+		where += "AND " + cond
+		args = append(args, condArgs...)
 	}
 	if loggedIn {
 		where, args = whereMuted(where, "posts", args, *opts.Viewer, opts.Community == nil && !opts.Homefeed)
 	}
+	where, args, err := whereHidden(db, where, "posts", args, opts)
+	if err != nil {
+		return nil, err
+	}
 	if opts.Next != "" {
 		next, err := opts.nextInt64()
 		if err != nil {
@@ -574,7 +715,6 @@ func getPostsActivity(ctx context.Context, db *sql.DB, opts *FeedOptions) (*Feed
 	query := buildSelectPostQuery(loggedIn, where)
 
 	var rows *sql.Rows
-	var err error
 	args = append(args, opts.Limit+1)
 	rows, err = db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -656,6 +796,28 @@ func GetPostsLocked(ctx context.Context, db *sql.DB, community uid.ID, limit, pa
 	return count, posts, nil
 }
 
+// GetPostsHeld returns a slice of posts that are held for review (sorted by
+// ID) and the number of such posts in community.
+func GetPostsHeld(ctx context.Context, db *sql.DB, community uid.ID, limit, page int) (int, []*Post, error) {
+	count := 0
+	row := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM posts WHERE community_id = ? AND posts.deleted = FALSE AND held_at IS NOT NULL", community)
+	if err := row.Scan(&count); err != nil {
+		return 0, nil, err
+	}
+
+	query := buildSelectPostQuery(false, "WHERE community_id = ? AND posts.deleted = FALSE AND posts.held_at IS NOT NULL ORDER BY posts.id DESC LIMIT ? OFFSET ?")
+	rows, err := db.QueryContext(ctx, query, community, limit, limit*(page-1))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	posts, err := scanPosts(ctx, db, rows, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, posts, nil
+}
+
 // UserFeedItem is an item in a user page's feed.
 type UserFeedItem struct {
 	// Item is either a post or a comment.
@@ -675,6 +837,12 @@ func GetUserFeed(ctx context.Context, db *sql.DB, viewer *uid.ID, userID uid.ID,
 		return nil, httperr.NewBadRequest("invalid-filter", "filter must be one of 'posts' or 'comments' or it must be empty")
 	}
 
+	if hidden, err := ContentHidden(db, userID, viewer); err != nil {
+		return nil, err
+	} else if hidden {
+		return &UserFeedResultSet{Items: []UserFeedItem{}}, nil
+	}
+
 	query := "SELECT target_id, target_type FROM posts_comments WHERE user_id = ? "
 	args := []any{userID}
 
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/testdb"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+func TestFeedSortText(t *testing.T) {
+	for _, text := range []string{"hot", "latest", "activity", "day", "week", "month", "year", "all", "rising", "controversial"} {
+		var s FeedSort
+		if err := s.UnmarshalText([]byte(text)); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		got, err := s.MarshalText()
+		if err != nil || string(got) != text {
+			t.Errorf("%s: round trip got %q (err: %v)", text, got, err)
+		}
+	}
+}
+
+func TestPostControversy(t *testing.T) {
+	if got := PostControversy(10, 0); got != 0 {
+		t.Errorf("no downvotes: got %d, want 0", got)
+	}
+	if got := PostControversy(10, 10); got != 20000 {
+		t.Errorf("10 up, 10 down: got %d, want 20000", got)
+	}
+	// The more evenly split the votes, the more controversial.
+	if even, uneven := PostControversy(10, 10), PostControversy(15, 5); even <= uneven {
+		t.Errorf("even split (%d) not more controversial than uneven split (%d)", even, uneven)
+	}
+	// And, for the same split, the more votes, the more controversial.
+	if many, few := PostControversy(50, 50), PostControversy(10, 10); many <= few {
+		t.Errorf("many votes (%d) not more controversial than few votes (%d)", many, few)
+	}
+}
+
+func TestPostRising(t *testing.T) {
+	now := time.Now()
+	votes := func(n int, up bool, at time.Time) []risingVote {
+		v := make([]risingVote, n)
+		for i := range v {
+			v[i] = risingVote{up: up, at: at}
+		}
+		return v
+	}
+
+	if got := postRising(votes(10, true, now), now); got != 10000 {
+		t.Errorf("10 fresh upvotes: got %d, want 10000", got)
+	}
+	if got := postRising(append(votes(10, true, now), votes(4, false, now)...), now); got != 6000 {
+		t.Errorf("10 fresh upvotes and 4 downvotes: got %d, want 6000", got)
+	}
+	if got := postRising(votes(10, true, now.Add(-risingWindow/2)), now); got != 5000 {
+		t.Errorf("10 upvotes half a window ago: got %d, want 5000", got)
+	}
+	if got := postRising(votes(10, true, now.Add(-risingWindow)), now); got != 0 {
+		t.Errorf("10 upvotes a window ago: got %d, want 0", got)
+	}
+}
+
+func TestPostRisingDecays(t *testing.T) {
+	start := time.Now()
+
+	// Post a got 10 votes at the start and none since. Post b gets a vote every
+	// 15 minutes.
+	a := make([]risingVote, 10)
+	for i := range a {
+		a[i] = risingVote{up: true, at: start}
+	}
+	var b []risingVote
+
+	if postRising(a, start) <= postRising(b, start) {
+		t.Fatal("post a should start out ahead of post b")
+	}
+	for now := start; now.Sub(start) <= risingWindow; now = now.Add(time.Minute * 15) {
+		b = append(b, risingVote{up: true, at: now})
+	}
+	now := start.Add(risingWindow)
+	if ra, rb := postRising(a, now), postRising(b, now); ra >= rb {
+		t.Errorf("post a (%d), which stopped getting votes, is not below post b (%d)", ra, rb)
+	}
+}
+
+// TestFeedPaginationWithHiddenPosts pages through a feed with hidden posts in
+// it. The hidden posts are left out by a condition in the where clause (see
+// whereHidden), which is modelled here by query, alongside the cursor condition
+// and the ordering of the hot feed.
+// collectFeed pages through the feed with opts and returns the IDs of all the
+// posts in it, in order.
+func collectFeed(t *testing.T, db *sql.DB, opts FeedOptions) []uid.ID {
+	t.Helper()
+	var ids []uid.ID
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("pagination does not end")
+		}
+		set, err := GetFeed(context.Background(), db, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, post := range set.Posts {
+			ids = append(ids, post.ID)
+		}
+		if set.Next == nil {
+			break
+		}
+		opts.Next = fmt.Sprint(set.Next) // As the API sends it.
+	}
+	return ids
+}
+
+func TestFeedPaginationWithHiddenPosts(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	community := newTestCommunity(t, db)
+	// The author is an admin, so that the spam checks (which new users posting
+	// in quick succession would trip) don't hold any of the posts.
+	author, viewer := newTestAdmin(t, db), newTestUser(t, db)
+
+	want := make(map[uid.ID]bool)
+	for i := 0; i < 12; i++ {
+		post := newTestPost(t, db, author, community)
+		// With ties in hotness.
+		if _, err := db.Exec("UPDATE posts SET hotness = ? WHERE id = ?", (i%3)*100, post.ID); err != nil {
+			t.Fatal(err)
+		}
+		switch i % 4 {
+		case 0:
+			if err := HidePost(ctx, db, viewer.ID, post.ID); err != nil {
+				t.Fatal(err)
+			}
+		case 1:
+			if err := MarkPostViewed(ctx, db, viewer.ID, post.ID); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			want[post.ID] = true
+		}
+	}
+
+	for _, sort := range []string{"latest", "hot"} {
+		opts := FeedOptions{
+			Viewer:     &viewer.ID,
+			Community:  &community.ID,
+			Limit:      4,
+			HideHidden: true,
+			HideViewed: true,
+		}
+		if err := opts.Sort.UnmarshalText([]byte(sort)); err != nil {
+			t.Fatal(err)
+		}
+		got := collectFeed(t, db, opts)
+		seen := make(map[uid.ID]bool)
+		for _, id := range got {
+			if !want[id] {
+				t.Errorf("%s: hidden or viewed post %v in feed", sort, id)
+			}
+			if seen[id] {
+				t.Errorf("%s: post %v on more than one page", sort, id)
+			}
+			seen[id] = true
+		}
+		if len(seen) != len(want) {
+			t.Errorf("%s: got %d posts, want %d", sort, len(seen), len(want))
+		}
+	}
+}
+
+func TestCustomFeed(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	viewer := newTestUser(t, db)
+	author, mutedAuthor := newTestAdmin(t, db), newTestAdmin(t, db)
+	inFeed, mutedInFeed, notInFeed := newTestCommunity(t, db), newTestCommunity(t, db), newTestCommunity(t, db)
+
+	feed, err := CreateCustomFeed(ctx, db, viewer.ID, randomName(t, "f"), "Feed", msql.NullString{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*Community{inFeed, mutedInFeed} {
+		if err := feed.AddCommunity(ctx, db, c.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MuteCommunity(ctx, db, viewer.ID, mutedInFeed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := MuteUser(ctx, db, viewer.ID, mutedAuthor.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	post := newTestPost(t, db, author, inFeed)
+	mutedAuthorPost := newTestPost(t, db, mutedAuthor, inFeed)
+	mutedCommunityPost := newTestPost(t, db, author, mutedInFeed)
+	newTestPost(t, db, author, notInFeed)
+
+	tests := []struct {
+		name   string
+		viewer *uid.ID
+		want   []uid.ID // Latest first.
+	}{
+		{"logged in", &viewer.ID, []uid.ID{post.ID}},
+		{"logged out", nil, []uid.ID{mutedCommunityPost.ID, mutedAuthorPost.ID, post.ID}},
+	}
+	for _, test := range tests {
+		got := collectFeed(t, db, FeedOptions{
+			Sort:       FeedSortLatest,
+			Viewer:     test.viewer,
+			CustomFeed: &feed.ID,
+			Limit:      2,
+		})
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: got posts %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// Users can hide posts from their feeds, and, if User.HideViewedPosts is set,
+// the posts that they've opened are hidden from their feeds too. Both are left
+// out of feeds through FeedOptions.HideHidden and FeedOptions.HideViewed.
+
+// viewedPostsMaxAge is how long a viewed post is remembered for.
+const viewedPostsMaxAge = time.Hour * 24 * 90
+
+// HidePost hides post from the feeds of user. It's not an error if the post is
+// already hidden.
+func HidePost(ctx context.Context, db *sql.DB, user, post uid.ID) error {
+	_, err := db.ExecContext(ctx, "INSERT IGNORE INTO hidden_posts (user_id, post_id) VALUES (?, ?)", user, post)
+	return err
+}
+
+// UnhidePost undoes HidePost.
+func UnhidePost(ctx context.Context, db *sql.DB, user, post uid.ID) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM hidden_posts WHERE user_id = ? AND post_id = ?", user, post)
+	return err
+}
+
+// PostHiddenBy reports whether user has hidden post.
+func PostHiddenBy(ctx context.Context, db *sql.DB, user, post uid.ID) (bool, error) {
+	var hidden bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM hidden_posts WHERE user_id = ? AND post_id = ?)", user, post).Scan(&hidden)
+	return hidden, err
+}
+
+// GetHiddenPosts returns the posts hidden by user, latest posts first. The
+// cursor next is the one returned in a previous FeedResultSet (or empty).
+func GetHiddenPosts(ctx context.Context, db *sql.DB, user uid.ID, limit int, next string) (*FeedResultSet, error) {
+	where := "WHERE posts.id IN (SELECT post_id FROM hidden_posts WHERE user_id = ?) "
+	args := []any{user, user}
+	if next != "" {
+		var nextID uid.ID
+		if err := nextID.UnmarshalText([]byte(next)); err != nil {
+			return nil, ErrInvalidFeedCursor
+		}
+		where += "AND posts.id <= ? "
+		args = append(args, nextID)
+	}
+	where += "ORDER BY posts.id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := db.QueryContext(ctx, buildSelectPostQuery(true, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := scanPosts(ctx, db, rows, &user)
+	if err != nil {
+		if err == errPostNotFound {
+			return &FeedResultSet{}, nil
+		}
+		return nil, err
+	}
+	return newFeedResultSet(posts, limit, FeedSortLatest), nil
+}
+
+// MarkPostViewed records that user has opened post, so that it's left out of
+// their feeds. Call it only for users that have User.HideViewedPosts set (see
+// UserHidesViewedPosts), so that posts are not needlessly recorded.
+func MarkPostViewed(ctx context.Context, db *sql.DB, user, post uid.ID) error {
+	now := time.Now()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO viewed_posts (user_id, post_id, viewed_at) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE viewed_at = ?`, user, post, now, now)
+	return err
+}
+
+// ClearViewedPosts forgets all the posts that user has viewed.
+func ClearViewedPosts(ctx context.Context, db *sql.DB, user uid.ID) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM viewed_posts WHERE user_id = ?", user)
+	return err
+}
+
+// UserHidesViewedPosts returns the User.HideViewedPosts setting of user.
+func UserHidesViewedPosts(ctx context.Context, db *sql.DB, user uid.ID) (bool, error) {
+	var hide bool
+	if err := db.QueryRowContext(ctx, "SELECT hide_viewed_posts FROM users WHERE id = ?", user).Scan(&hide); err != nil {
+		if err == sql.ErrNoRows {
+			return false, errUserNotFound
+		}
+		return false, err
+	}
+	return hide, nil
+}
+
+// PurgeViewedPosts forgets the posts viewed before viewedPostsMaxAge. It
+// returns the number of rows deleted.
+func PurgeViewedPosts(ctx context.Context, db *sql.DB) (int, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM viewed_posts WHERE viewed_at < ?", time.Now().Add(-viewedPostsMaxAge))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// filterHiddenByViewer removes from posts the ones that are hidden from the
+// feeds of opts.Viewer, as set by opts.HideHidden and opts.HideViewed. It's for
+// posts that are not got through a query with whereHidden (like pinned posts).
+func filterHiddenByViewer(ctx context.Context, db *sql.DB, posts []*Post, opts *FeedOptions) ([]*Post, error) {
+	if opts.Viewer == nil || len(posts) == 0 || !(opts.HideHidden || opts.HideViewed) {
+		return posts, nil
+	}
+
+	var queries []string
+	var args []any
+	ids := make([]any, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	in := msql.InClauseQuestionMarks(len(ids))
+	if opts.HideHidden {
+		queries = append(queries, "SELECT post_id FROM hidden_posts WHERE user_id = ? AND post_id IN "+in)
+		args = append(append(args, *opts.Viewer), ids...)
+	}
+	if opts.HideViewed {
+		queries = append(queries, "SELECT post_id FROM viewed_posts WHERE user_id = ? AND post_id IN "+in)
+		args = append(append(args, *opts.Viewer), ids...)
+	}
+	rows, err := db.QueryContext(ctx, strings.Join(queries, " UNION "), args...)
+	if err != nil {
+		return nil, err
+	}
+	hidden, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := posts[:0]
+	for _, post := range posts {
+		if !slices.Contains(hidden, post.ID) {
+			filtered = append(filtered, post)
+		}
+	}
+	return filtered, nil
+}
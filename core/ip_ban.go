@@ -0,0 +1,448 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// IPHistoryOptions are the options of the IP address history of users (the
+// user_ips table), which is used to find accounts that share IP addresses.
+type IPHistoryOptions struct {
+	// IP addresses are stored as salted SHA-256 hashes. Changing the salt
+	// makes the existing history useless.
+	Salt string
+
+	// The duration after which an IP address that's no longer used by a user
+	// is removed from the history.
+	Retention time.Duration
+}
+
+var ipHistoryOptions = struct {
+	sync.RWMutex
+	IPHistoryOptions
+}{
+	IPHistoryOptions: IPHistoryOptions{
+		Retention: time.Hour * 24 * 90,
+	},
+}
+
+// SetIPHistoryOptions sets the options of the IP address history. Call it
+// before the server is started.
+func SetIPHistoryOptions(opts IPHistoryOptions) {
+	ipHistoryOptions.Lock()
+	defer ipHistoryOptions.Unlock()
+	ipHistoryOptions.IPHistoryOptions = opts
+}
+
+func getIPHistoryOptions() IPHistoryOptions {
+	ipHistoryOptions.RLock()
+	defer ipHistoryOptions.RUnlock()
+	return ipHistoryOptions.IPHistoryOptions
+}
+
+// hashIP returns the hex encoded hash of ip that's stored in the user_ips
+// table and in users.last_seen_ip. It must match the hash computed in
+// HashStoredIPs.
+func hashIP(ip string) string {
+	sum := sha256.Sum256([]byte(getIPHistoryOptions().Salt + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+const storedIPsHashedDBKey = "stored_ips_hashed" // for the key column of the application_data table
+
+// HashStoredIPs hashes the IP addresses, in the IP history and in
+// users.last_seen_ip, that were stored before hashing was introduced. IP
+// addresses are hashed when they are written, so this only needs to be done
+// once: it's recorded in the application_data table when it's done, and later
+// calls do nothing. Call it when the server starts (before it handles any
+// requests).
+func HashStoredIPs(ctx context.Context, db *sql.DB) error {
+	var done bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM application_data WHERE `key` = ?)", storedIPsHashedDBKey).Scan(&done); err != nil {
+		return err
+	}
+	if done {
+		return nil
+	}
+
+	salt := getIPHistoryOptions().Salt
+	return msql.Transact(ctx, db, func(tx *sql.Tx) error {
+		// Hashes are 64 characters long, IP addresses are at most 45.
+		if _, err := tx.ExecContext(ctx, "UPDATE IGNORE user_ips SET ip_hash = SHA2(CONCAT(?, ip_hash), 256) WHERE LENGTH(ip_hash) < 64", salt); err != nil {
+			return err
+		}
+		// The rows left are duplicates of already hashed rows.
+		if _, err := tx.ExecContext(ctx, "DELETE FROM user_ips WHERE LENGTH(ip_hash) < 64"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET last_seen_ip = SHA2(CONCAT(?, last_seen_ip), 256) WHERE LENGTH(last_seen_ip) < 64", salt); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO application_data (`key`, `value`) VALUES (?, ?)", storedIPsHashedDBKey, "true")
+		return err
+	})
+}
+
+// PurgeUserIPs removes the IP addresses in the IP history, and the IP ban
+// matches (which are derived from IP addresses), that are older than the
+// retention period. It returns the number of addresses removed.
+func PurgeUserIPs(ctx context.Context, db *sql.DB) (int, error) {
+	cutoff := time.Now().Add(-getIPHistoryOptions().Retention)
+	if _, err := db.ExecContext(ctx, "DELETE FROM ip_ban_matches WHERE created_at < ?", cutoff); err != nil {
+		return 0, err
+	}
+	res, err := db.ExecContext(ctx, "DELETE FROM user_ips WHERE last_seen_at < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// SharedIPUser is a user who shares IP addresses with another user.
+type SharedIPUser struct {
+	User      *User `json:"user"`
+	SharedIPs int   `json:"sharedIps"` // The number of IP addresses shared.
+
+	// The most recent time at which both users used one of the shared
+	// addresses.
+	LastSharedAt time.Time `json:"lastSharedAt"`
+}
+
+// GetUsersSharingIPs returns the users who have used any of the IP addresses
+// that user has used (within the retention period), the ones sharing the most
+// addresses first.
+func GetUsersSharingIPs(ctx context.Context, db *sql.DB, user uid.ID, viewer *uid.ID) ([]*SharedIPUser, error) {
+	rows, err := db.QueryContext(ctx, `SELECT others.user_id, COUNT(*), MAX(LEAST(others.last_seen_at, mine.last_seen_at))
+		FROM user_ips AS mine
+		INNER JOIN user_ips AS others ON others.ip_hash = mine.ip_hash AND others.user_id <> mine.user_id
+		WHERE mine.user_id = ?
+		GROUP BY others.user_id
+		ORDER BY COUNT(*) DESC
+		LIMIT 100`, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		shared []*SharedIPUser
+		ids    []uid.ID
+	)
+	for rows.Next() {
+		var (
+			id uid.ID
+			s  = &SharedIPUser{}
+		)
+		if err := rows.Scan(&id, &s.SharedIPs, &s.LastSharedAt); err != nil {
+			return nil, err
+		}
+		shared = append(shared, s)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []*SharedIPUser{}, nil
+	}
+
+	users, err := GetUsersByIDs(ctx, db, ids, viewer)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		for _, u := range users {
+			if u.ID == id {
+				shared[i].User = u
+				break
+			}
+		}
+	}
+	return shared, nil
+}
+
+// IPBanAction is what happens when someone signs up or logs in from a banned IP
+// address.
+type IPBanAction int
+
+const (
+	IPBanActionBlock = IPBanAction(iota) // The signup or login is refused.
+	IPBanActionFlag                      // It's allowed, but listed for admins to review.
+)
+
+func (a IPBanAction) MarshalText() ([]byte, error) {
+	switch a {
+	case IPBanActionBlock:
+		return []byte("block"), nil
+	case IPBanActionFlag:
+		return []byte("flag"), nil
+	}
+	return nil, errors.New("unsupported ip ban action")
+}
+
+func (a *IPBanAction) UnmarshalText(data []byte) error {
+	switch string(data) {
+	case "block":
+		*a = IPBanActionBlock
+	case "flag":
+		*a = IPBanActionFlag
+	default:
+		return errors.New("unsupported ip ban action")
+	}
+	return nil
+}
+
+// IPBan is a ban of an IP address or a range of IP addresses.
+type IPBan struct {
+	ID        int             `json:"id"`
+	CIDR      string          `json:"cidr"`
+	Action    IPBanAction     `json:"action"`
+	Reason    msql.NullString `json:"reason"`
+	CreatedBy uid.ID          `json:"createdBy"`
+	CreatedAt time.Time       `json:"createdAt"`
+	ExpiresAt msql.NullTime   `json:"expiresAt"`
+
+	network *net.IPNet
+}
+
+// ParseIPBanCIDR parses s, which is either an IP address or a CIDR range, and
+// returns the range in its canonical form (an IP address becomes a range of
+// one address).
+func ParseIPBanCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, errInvalidCIDR
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, errInvalidCIDR
+	}
+	return network, nil
+}
+
+// Active reports whether the ban has not expired.
+func (b *IPBan) Active() bool {
+	return !b.ExpiresAt.Valid || b.ExpiresAt.Time.After(time.Now())
+}
+
+// CreateIPBan bans the IP address or CIDR range cidr. If expires is not nil,
+// the ban stops applying at that time.
+func CreateIPBan(ctx context.Context, db *sql.DB, cidr string, action IPBanAction, reason string, by uid.ID, expires *time.Time) (*IPBan, error) {
+	network, err := ParseIPBanCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := msql.NullTime{}
+	if expires != nil {
+		expiresAt = msql.NewNullTime(*expires)
+	}
+
+	query, args := msql.BuildInsertQuery("ip_bans", []msql.ColumnValue{
+		{Name: "cidr", Value: network.String()},
+		{Name: "action", Value: action},
+		{Name: "reason", Value: nullString(reason)},
+		{Name: "created_by", Value: by},
+		{Name: "expires_at", Value: expiresAt},
+	})
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		if msql.IsErrDuplicateErr(err) {
+			return nil, errIPBanExists
+		}
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetIPBan(ctx, db, int(id))
+}
+
+func getIPBans(ctx context.Context, db *sql.DB, where string, args ...any) ([]*IPBan, error) {
+	query := msql.BuildSelectQuery("ip_bans", []string{
+		"ip_bans.id",
+		"ip_bans.cidr",
+		"ip_bans.action",
+		"ip_bans.reason",
+		"ip_bans.created_by",
+		"ip_bans.created_at",
+		"ip_bans.expires_at",
+	}, nil, where)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []*IPBan{}
+	for rows.Next() {
+		b := &IPBan{}
+		if err := rows.Scan(
+			&b.ID,
+			&b.CIDR,
+			&b.Action,
+			&b.Reason,
+			&b.CreatedBy,
+			&b.CreatedAt,
+			&b.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if _, b.network, err = net.ParseCIDR(b.CIDR); err != nil {
+			return nil, err
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
+
+// GetIPBans returns all IP bans (newest first). If activeOnly is true, expired
+// bans are skipped.
+func GetIPBans(ctx context.Context, db *sql.DB, activeOnly bool) ([]*IPBan, error) {
+	if activeOnly {
+		return getIPBans(ctx, db, "WHERE ip_bans.expires_at IS NULL OR ip_bans.expires_at > ? ORDER BY ip_bans.id DESC", time.Now())
+	}
+	return getIPBans(ctx, db, "ORDER BY ip_bans.id DESC")
+}
+
+func GetIPBan(ctx context.Context, db *sql.DB, id int) (*IPBan, error) {
+	bans, err := getIPBans(ctx, db, "WHERE ip_bans.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(bans) == 0 {
+		return nil, errIPBanNotFound
+	}
+	return bans[0], nil
+}
+
+// Delete lifts the ban (the record of its matches is deleted as well).
+func (b *IPBan) Delete(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM ip_bans WHERE id = ?", b.ID)
+	return err
+}
+
+// MatchIPBan returns the active ban that applies to the IP address ip, or nil
+// if there's none.
+func MatchIPBan(ctx context.Context, db *sql.DB, ip string) (*IPBan, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, nil
+	}
+	bans, err := GetIPBans(ctx, db, true)
+	if err != nil {
+		return nil, err
+	}
+	return matchIPBan(bans, addr), nil
+}
+
+// matchIPBan returns the ban out of bans that contains ip. A ban that blocks is
+// preferred over one that only flags.
+func matchIPBan(bans []*IPBan, ip net.IP) *IPBan {
+	var match *IPBan
+	for _, b := range bans {
+		if !b.network.Contains(ip) {
+			continue
+		}
+		if match == nil || (match.Action == IPBanActionFlag && b.Action == IPBanActionBlock) {
+			match = b
+		}
+	}
+	return match
+}
+
+// RecordMatch records that someone tried to sign up or log in (event) from an
+// address covered by the ban. User is the account involved, if known.
+func (b *IPBan) RecordMatch(ctx context.Context, db *sql.DB, user *uid.ID, event string, blocked bool) error {
+	userID := uid.NullID{}
+	if user != nil {
+		userID = uid.NullID{Valid: true, ID: *user}
+	}
+	query, args := msql.BuildInsertQuery("ip_ban_matches", []msql.ColumnValue{
+		{Name: "ip_ban_id", Value: b.ID},
+		{Name: "user_id", Value: userID},
+		{Name: "event", Value: event},
+		{Name: "blocked", Value: blocked},
+	})
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// IPBanMatch is a signup or login attempt from an address covered by an IP
+// ban.
+type IPBanMatch struct {
+	ID        int             `json:"id"`
+	IPBanID   int             `json:"ipBanId"`
+	CIDR      string          `json:"cidr"`
+	UserID    uid.NullID      `json:"userId"`
+	Username  msql.NullString `json:"username"`
+	Event     string          `json:"event"`
+	Blocked   bool            `json:"blocked"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// GetIPBanMatches returns a page of the recorded IP ban matches (newest first)
+// and the total number of matches.
+func GetIPBanMatches(ctx context.Context, db *sql.DB, limit, page int) (int, []*IPBanMatch, error) {
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ip_ban_matches").Scan(&count); err != nil {
+		return 0, nil, err
+	}
+
+	query := msql.BuildSelectQuery("ip_ban_matches", []string{
+		"ip_ban_matches.id",
+		"ip_ban_matches.ip_ban_id",
+		"ip_bans.cidr",
+		"ip_ban_matches.user_id",
+		"users.username",
+		"ip_ban_matches.event",
+		"ip_ban_matches.blocked",
+		"ip_ban_matches.created_at",
+	}, []string{
+		"INNER JOIN ip_bans ON ip_bans.id = ip_ban_matches.ip_ban_id",
+		"LEFT JOIN users ON users.id = ip_ban_matches.user_id",
+	}, "ORDER BY ip_ban_matches.id DESC LIMIT ? OFFSET ?")
+	rows, err := db.QueryContext(ctx, query, limit, limit*(page-1))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	matches := []*IPBanMatch{}
+	for rows.Next() {
+		m := &IPBanMatch{}
+		if err := rows.Scan(
+			&m.ID,
+			&m.IPBanID,
+			&m.CIDR,
+			&m.UserID,
+			&m.Username,
+			&m.Event,
+			&m.Blocked,
+			&m.CreatedAt); err != nil {
+			return 0, nil, err
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	return count, matches, nil
+}
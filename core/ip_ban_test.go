@@ -0,0 +1,71 @@
+package core
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseIPBanCIDR(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      bool
+	}{
+		{"203.0.113.7", "203.0.113.7/32", false},
+		{" 203.0.113.7 ", "203.0.113.7/32", false},
+		{"203.0.113.7/24", "203.0.113.0/24", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8::1/32", "2001:db8::/32", false},
+		{"203.0.113", "", true},
+		{"203.0.113.7/33", "", true},
+		{"", "", true},
+	}
+	for _, test := range tests {
+		network, err := ParseIPBanCIDR(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseIPBanCIDR(%q) expected an error, got %v", test.in, network)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseIPBanCIDR(%q) returned error: %v", test.in, err)
+		} else if network.String() != test.want {
+			t.Errorf("ParseIPBanCIDR(%q) expected %s, got %s", test.in, test.want, network)
+		}
+	}
+}
+
+func TestMatchIPBan(t *testing.T) {
+	newBan := func(id int, cidr string, action IPBanAction) *IPBan {
+		network, err := ParseIPBanCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &IPBan{ID: id, CIDR: network.String(), Action: action, network: network}
+	}
+	bans := []*IPBan{
+		newBan(1, "198.51.100.0/24", IPBanActionFlag),
+		newBan(2, "198.51.100.9", IPBanActionBlock),
+		newBan(3, "2001:db8::/32", IPBanActionFlag),
+	}
+
+	tests := []struct {
+		ip   string
+		want int // ban ID, 0 for no match
+	}{
+		{"198.51.100.1", 1},
+		{"198.51.100.9", 2}, // blocking bans take precedence
+		{"198.51.101.1", 0},
+		{"2001:db8::5", 3},
+		{"2001:db9::5", 0},
+	}
+	for _, test := range tests {
+		got := 0
+		if ban := matchIPBan(bans, net.ParseIP(test.ip)); ban != nil {
+			got = ban.ID
+		}
+		if got != test.want {
+			t.Errorf("matchIPBan(%s) expected ban %d, got %d", test.ip, test.want, got)
+		}
+	}
+}
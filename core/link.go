@@ -0,0 +1,341 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/discuitnet/discuit/internal/httperr"
+	"github.com/discuitnet/discuit/internal/httputil"
+	"github.com/discuitnet/discuit/internal/utils"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	linkInfoCacheTTL     = time.Hour
+	linkInfoCacheMaxSize = 5000
+
+	maxLinkDescriptionLength = 500 // in runes
+	maxEmbedHTMLLength       = 10000
+)
+
+var errInvalidURL = httperr.NewBadRequest("invalid-url", "Invalid URL.")
+
+// LinkEmbed is the oEmbed data of a link from an embeddable provider.
+type LinkEmbed struct {
+	Provider     string `json:"provider"`
+	Type         string `json:"type"` // One of video, rich, photo, or link.
+	HTML         string `json:"html,omitempty"`
+	URL          string `json:"url,omitempty"` // For the photo type.
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+	AuthorName   string `json:"authorName,omitempty"`
+}
+
+// LinkInfo is the preview data of a link, as found by unfurling it.
+type LinkInfo struct {
+	URL         string     `json:"url"`
+	Hostname    string     `json:"hostname"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	SiteName    string     `json:"siteName,omitempty"`
+	Favicon     string     `json:"favicon,omitempty"`
+	Image       string     `json:"image,omitempty"` // The og:image, or the link itself if it's an image.
+	Embed       *LinkEmbed `json:"embed,omitempty"`
+}
+
+// embedProvider is an oEmbed provider whose embeds are allowed on the site.
+type embedProvider struct {
+	name     string
+	hosts    []string // Subdomains of these hosts match as well.
+	endpoint string
+}
+
+// embedProviders is the allowlist of providers whose embeds are shown.
+// Embed HTML is only ever fetched from one of these endpoints.
+var embedProviders = []*embedProvider{
+	{
+		name:     "YouTube",
+		hosts:    []string{"youtube.com", "youtu.be"},
+		endpoint: "https://www.youtube.com/oembed",
+	},
+	{
+		name:     "Vimeo",
+		hosts:    []string{"vimeo.com"},
+		endpoint: "https://vimeo.com/api/oembed.json",
+	},
+	{
+		name:     "Twitter",
+		hosts:    []string{"twitter.com", "x.com"},
+		endpoint: "https://publish.twitter.com/oembed",
+	},
+}
+
+func findEmbedProvider(hostname string) *embedProvider {
+	hostname = strings.ToLower(hostname)
+	for _, p := range embedProviders {
+		for _, host := range p.hosts {
+			if hostname == host || strings.HasSuffix(hostname, "."+host) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+var linkInfoCache = struct {
+	sync.Mutex
+	entries map[string]linkInfoCacheEntry
+}{entries: make(map[string]linkInfoCacheEntry)}
+
+type linkInfoCacheEntry struct {
+	info    *LinkInfo
+	expires time.Time
+}
+
+func getCachedLinkInfo(link string) *LinkInfo {
+	linkInfoCache.Lock()
+	defer linkInfoCache.Unlock()
+	entry, ok := linkInfoCache.entries[link]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(linkInfoCache.entries, link)
+		return nil
+	}
+	info := *entry.info
+	return &info
+}
+
+func cacheLinkInfo(link string, info *LinkInfo) {
+	linkInfoCache.Lock()
+	defer linkInfoCache.Unlock()
+	if len(linkInfoCache.entries) >= linkInfoCacheMaxSize {
+		now := time.Now()
+		for key, entry := range linkInfoCache.entries {
+			if now.After(entry.expires) {
+				delete(linkInfoCache.entries, key)
+			}
+		}
+		// If still full, evict arbitrary entries.
+		for key := range linkInfoCache.entries {
+			if len(linkInfoCache.entries) < linkInfoCacheMaxSize {
+				break
+			}
+			delete(linkInfoCache.entries, key)
+		}
+	}
+	c := *info
+	linkInfoCache.entries[link] = linkInfoCacheEntry{info: &c, expires: time.Now().Add(linkInfoCacheTTL)}
+}
+
+// parseLinkURL parses a user submitted link. Links without a scheme are
+// assumed to be http links.
+func parseLinkURL(link string) (*url.URL, error) {
+	if len(link) > maxPostLinkLength {
+		link = link[:maxPostLinkLength]
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, errInvalidURL
+	}
+	if !u.IsAbs() {
+		// Reparse so that a link like "example.com/path" doesn't end up
+		// entirely in the path.
+		if u, err = url.Parse("http://" + link); err != nil {
+			return nil, errInvalidURL
+		}
+	}
+	if u.Hostname() == "" {
+		return nil, errInvalidURL
+	}
+	return u, nil
+}
+
+// GetLinkInfo unfurls link and returns its preview data. Results are cached
+// for a while. If the link could not be fetched, the returned LinkInfo only
+// has the URL and Hostname fields set, and the error is non-nil.
+func GetLinkInfo(ctx context.Context, link string) (*LinkInfo, error) {
+	u, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+	return getLinkInfo(ctx, u)
+}
+
+func getLinkInfo(ctx context.Context, u *url.URL) (*LinkInfo, error) {
+	key := u.String()
+	if info := getCachedLinkInfo(key); info != nil {
+		return info, nil
+	}
+
+	info, err := unfurlLink(ctx, u)
+	if err != nil {
+		return info, err
+	}
+	cacheLinkInfo(key, info)
+	return info, nil
+}
+
+func unfurlLink(ctx context.Context, u *url.URL) (*LinkInfo, error) {
+	info := &LinkInfo{
+		URL:      u.String(),
+		Hostname: u.Hostname(),
+	}
+
+	res, err := httputil.Fetch(ctx, info.URL, &httputil.FetchOptions{
+		MaxBodySize:  5 << 20,
+		ContentTypes: []string{"text/html", "application/xhtml+xml", "image/*"},
+	})
+	if err != nil {
+		return info, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return info, fmt.Errorf("unfurling %s: status code %d", info.URL, res.StatusCode)
+	}
+
+	base := u
+	if res.Request != nil && res.Request.URL != nil {
+		base = res.Request.URL // In case of redirects.
+	}
+	resolve := func(ref string) string {
+		if ref == "" {
+			return ""
+		}
+		r, err := base.Parse(ref)
+		if err != nil || (r.Scheme != "http" && r.Scheme != "https") {
+			return ""
+		}
+		return r.String()
+	}
+
+	contentType := strings.ToLower(res.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		meta, err := httputil.ExtractPageMetadata(res.Body)
+		if err != nil {
+			return info, err
+		}
+		info.Title = meta.Title
+		info.Description = meta.Description
+		info.SiteName = meta.SiteName
+		info.Image = resolve(meta.Image)
+		info.Favicon = resolve(meta.Favicon)
+		if info.Favicon == "" {
+			info.Favicon = resolve("/favicon.ico")
+		}
+	}
+	if info.Image == "" && linkProbablyAnImage(u, contentType) {
+		info.Image = info.URL
+	}
+	info.truncate()
+
+	if p := findEmbedProvider(u.Hostname()); p != nil {
+		embed, err := fetchOEmbed(ctx, p, info.URL)
+		if err != nil {
+			log.Printf("Failed fetching oEmbed data of %s from %s: %v\n", info.URL, p.name, err)
+		} else {
+			info.Embed = embed
+		}
+	}
+
+	return info, nil
+}
+
+func linkProbablyAnImage(u *url.URL, contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	if slices.Contains([]string{"image/jpeg", "image/png", "image/webp"}, strings.TrimSpace(mediaType)) {
+		return true
+	}
+	path := strings.ToLower(u.Path)
+	for _, ext := range []string{".jpg", ".jpeg", ".png", ".webp"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func (info *LinkInfo) truncate() {
+	info.Title = utils.TruncateUnicodeString(info.Title, maxPostTitleLength)
+	info.Description = utils.TruncateUnicodeString(info.Description, maxLinkDescriptionLength)
+	info.SiteName = utils.TruncateUnicodeString(info.SiteName, maxPostTitleLength)
+	if len(info.Favicon) > maxPostLinkLength {
+		info.Favicon = ""
+	}
+	if len(info.Image) > maxPostLinkLength {
+		info.Image = ""
+	}
+}
+
+// fetchOEmbed fetches the oEmbed data of link from the provider p.
+func fetchOEmbed(ctx context.Context, p *embedProvider, link string) (*LinkEmbed, error) {
+	endpoint, err := url.Parse(p.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := endpoint.Query()
+	q.Set("url", link)
+	q.Set("format", "json")
+	endpoint.RawQuery = q.Encode()
+
+	res, err := httputil.Fetch(ctx, endpoint.String(), &httputil.FetchOptions{
+		MaxBodySize:  1 << 20,
+		ContentTypes: []string{"application/json"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("status code %d", res.StatusCode)
+	}
+
+	data := struct {
+		Type         string `json:"type"`
+		HTML         string `json:"html"`
+		URL          string `json:"url"`
+		Width        any    `json:"width"`
+		Height       any    `json:"height"`
+		ThumbnailURL string `json:"thumbnail_url"`
+		AuthorName   string `json:"author_name"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Type == "" || len(data.HTML) > maxEmbedHTMLLength {
+		return nil, fmt.Errorf("invalid oEmbed response")
+	}
+
+	return &LinkEmbed{
+		Provider:     p.name,
+		Type:         data.Type,
+		HTML:         data.HTML,
+		URL:          data.URL,
+		Width:        oEmbedDimension(data.Width),
+		Height:       oEmbedDimension(data.Height),
+		ThumbnailURL: data.ThumbnailURL,
+		AuthorName:   data.AuthorName,
+	}, nil
+}
+
+// oEmbedDimension returns the integer value of an oEmbed width or height
+// field, which some providers send as numbers and others as strings (or null).
+func oEmbedDimension(v any) int {
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case string:
+		var n int
+		fmt.Sscanf(v, "%d", &n)
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+const (
+	// Only the first few users mentioned in a post or a comment are notified.
+	maxMentionsPerItem = 10
+
+	// The maximum number of mention notifications sent for a post (including
+	// its comments) in an hour, to stop mention storms.
+	maxMentionsPerPostPerHour = 50
+)
+
+// mentionRegexp matches @username and u/username mentions that are not part of
+// a word, an email address, or a URL path.
+var mentionRegexp = regexp.MustCompile(`(?:^|[^\w/@.])(?:@|u/)(\w{3,21})\b`)
+
+// parseMentions returns the usernames mentioned in text (in lower case and
+// without duplicates), in the order they first appear.
+func parseMentions(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range mentionRegexp.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(match[1])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// mentionUsers notifies, in the background, the users mentioned in text (the
+// body of a post or a comment). A comment is nil for posts. Users are notified
+// only the first time they're mentioned in a post or a comment, so it's okay to
+// call this function again after an edit.
+func mentionUsers(db *sql.DB, author, post, community uid.ID, comment *uid.ID, text string) {
+	go func() {
+		if err := createMentions(context.Background(), db, author, post, community, comment, text); err != nil {
+			log.Printf("Failed creating mentions: %v\n", err)
+		}
+	}()
+}
+
+func createMentions(ctx context.Context, db *sql.DB, author, post, community uid.ID, comment *uid.ID, text string) error {
+	names := parseMentions(text)
+	if len(names) == 0 {
+		return nil
+	}
+	if len(names) > maxMentionsPerItem {
+		names = names[:maxMentionsPerItem]
+	}
+
+	// Shadow banned users don't get to notify anyone.
+	if banned, err := userShadowBanned(db, author); err != nil || banned {
+		return err
+	}
+
+	var recent int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mentions WHERE post_id = ? AND created_at > ?", post, time.Now().Add(-time.Hour)).Scan(&recent); err != nil {
+		return err
+	}
+	if recent >= maxMentionsPerPostPerHour {
+		return nil
+	}
+	if left := maxMentionsPerPostPerHour - recent; len(names) > left {
+		names = names[:left]
+	}
+
+	args := make([]any, len(names))
+	for i := range names {
+		args[i] = names[i]
+	}
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users WHERE username_lc IN "+msql.InClauseQuestionMarks(len(names))+" AND deleted_at IS NULL AND banned_at IS NULL", args...)
+	if err != nil {
+		return err
+	}
+	users, err := scanIDs(rows)
+	if err != nil {
+		return err
+	}
+
+	target, targetType := post, ContentTypePost
+	if comment != nil {
+		target, targetType = *comment, ContentTypeComment
+	}
+
+	for _, user := range users {
+		if user == author {
+			continue
+		}
+		if ok, err := canMention(ctx, db, author, user, community); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		query, args := msql.BuildInsertQuery("mentions", []msql.ColumnValue{
+			{Name: "user_id", Value: user},
+			{Name: "by_user_id", Value: author},
+			{Name: "post_id", Value: post},
+			{Name: "target_id", Value: target},
+			{Name: "target_type", Value: targetType},
+		})
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			if msql.IsErrDuplicateErr(err) {
+				continue // already notified
+			}
+			return err
+		}
+		if err := CreateMentionNotification(ctx, db, user, author, post, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canMention reports whether author's mention of user should notify user. It
+// doesn't if user has muted author, if either of them has blocked the other,
+// or if user is banned from the community.
+func canMention(ctx context.Context, db *sql.DB, author, user, community uid.ID) (bool, error) {
+	if muted, err := UserMuted(ctx, db, user, author); err != nil || muted {
+		return false, err
+	}
+	if blocked, err := usersBlocked(ctx, db, user, author); err != nil || blocked {
+		return false, err
+	}
+	banned, err := IsUserBannedFromCommunity(ctx, db, community, user)
+	return !banned, err
+}
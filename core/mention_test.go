@@ -0,0 +1,27 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"hello @alice", []string{"alice"}},
+		{"@Alice and u/bob, and @alice again", []string{"alice", "bob"}},
+		{"(@carol) @dave.", []string{"carol", "dave"}},
+		{"mail me at alice@example.com", nil},
+		{"see https://discuit.net/u/alice", nil},
+		{"@ab is too short", nil},
+		{"@abcdefghijklmnopqrstuvwxyz is too long", nil},
+		{"no mentions here", nil},
+	}
+	for _, item := range cases {
+		if got := parseMentions(item.text); !slices.Equal(got, item.want) {
+			t.Errorf("parseMentions(%q) = %v, want %v", item.text, got, item.want)
+		}
+	}
+}
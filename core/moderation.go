@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// contentFilterReportReason is the title of the report reason used for reports
+// made by content filters.
+const contentFilterReportReason = "Content filter"
+
+// contentVerdict is the outcome of the automated checks run on new (or
+// edited) posts and comments.
+type contentVerdict struct {
+	hold       bool   // Whether the content should be held for review.
+	holdReason string // Shown to the mods.
+
+	// If non-nil, the content is to be reported (on behalf of the creator of
+	// the filter).
+	reportFilter *ContentFilter
+
+	// Set for new content that's scored for spam (see scoreSpam).
+	newContent bool
+	author     uid.ID
+	text       string
+	hostnames  []string
+	spamScore  *SpamScore // Non-nil if the content is to be flagged.
+	spamAction SpamAction
+}
+
+// checkContent runs the automated checks on the text (and links) of a post or
+// a comment that's about to be saved to community. If the content is to be
+// rejected, it returns an error.
+func checkContent(ctx context.Context, db *sql.DB, community uid.ID, text string, links ...string) (*contentVerdict, error) {
+	v := &contentVerdict{}
+	f, err := matchContentFilters(ctx, db, community, text, links)
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		switch f.Action {
+		case ContentFilterActionReject:
+			return nil, errContentFiltered
+		case ContentFilterActionHold:
+			typ, _ := f.Type.MarshalText()
+			v.hold = true
+			v.holdReason = fmt.Sprintf("Matched the %s filter %q.", typ, f.Pattern)
+		case ContentFilterActionReport:
+			v.reportFilter = f
+		}
+	}
+	return v, nil
+}
+
+// record is to be called once the content that v is about is saved as target.
+// It files the reports of v, flags the content as spam, and records its hashes
+// for later duplicate checks. Errors are logged.
+func (v *contentVerdict) record(db *sql.DB, community uid.ID, post uid.NullID, t ReportType, target uid.ID) {
+	if v.newContent {
+		go v.recordSpam(db, community, t, target)
+	}
+	if v.reportFilter == nil {
+		return
+	}
+	filter := v.reportFilter
+	go func() {
+		ctx := context.Background()
+		var reason int
+		if err := db.QueryRowContext(ctx, "SELECT id FROM report_reasons WHERE title = ?", contentFilterReportReason).Scan(&reason); err != nil {
+			log.Printf("Failed to find the content filter report reason: %v\n", err)
+			return
+		}
+		if _, err := NewReport(ctx, db, community, post, t, reason, target, filter.CreatedBy); err != nil {
+			log.Printf("Failed to report content (%v) matching filter %v: %v\n", target, filter.ID, err)
+		}
+	}()
+}
+
+func (v *contentVerdict) recordSpam(db *sql.DB, community uid.ID, t ReportType, target uid.ID) {
+	ctx := context.Background()
+	if err := recordContentHashes(ctx, db, v.author, v.text, v.hostnames); err != nil {
+		log.Printf("Failed to record content hashes of %v: %v\n", target, err)
+	}
+	if v.spamScore == nil {
+		return
+	}
+	contentType := ContentTypePost
+	if t == ReportTypeComment {
+		contentType = ContentTypeComment
+	}
+	if err := createSpamFlag(ctx, db, contentType, target, community, v.author, v.spamScore, v.spamAction); err != nil {
+		log.Printf("Failed to flag %v as spam: %v\n", target, err)
+	}
+	if v.spamAction == SpamActionShadowLimit {
+		if err := SetUserShadowLimited(ctx, db, v.author, true); err != nil {
+			log.Printf("Failed to shadow limit user %v: %v\n", v.author, err)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/SherClockHolmes/webpush-go"
+	"github.com/discuitnet/discuit/internal/httputil"
 	msql "github.com/discuitnet/discuit/internal/sql"
 	"github.com/discuitnet/discuit/internal/uid"
 )
@@ -46,17 +47,28 @@ const (
 	NotificationTypeDeletePost   = NotificationType("deleted_post")
 	NotificationTypeModAdd       = NotificationType("mod_add")
 	NotificationTypeNewBadge     = NotificationType("new_badge")
+	NotificationTypeDataExport   = NotificationType("data_export")
+	NotificationTypeCommRequest  = NotificationType("community_request")
+	NotificationTypeMention      = NotificationType("mention")
+	NotificationTypeThreadReply  = NotificationType("thread_reply")
 )
 
+// notificationTypes is the list of all notification types.
+var notificationTypes = []NotificationType{
+	NotificationTypeNewComment,
+	NotificationTypeCommentReply,
+	NotificationTypeUpvote,
+	NotificationTypeDeletePost,
+	NotificationTypeModAdd,
+	NotificationTypeNewBadge,
+	NotificationTypeDataExport,
+	NotificationTypeCommRequest,
+	NotificationTypeMention,
+	NotificationTypeThreadReply,
+}
+
 func (t NotificationType) Valid() bool {
-	return slices.Contains([]NotificationType{
-		NotificationTypeNewComment,
-		NotificationTypeCommentReply,
-		NotificationTypeUpvote,
-		NotificationTypeDeletePost,
-		NotificationTypeModAdd,
-		NotificationTypeNewBadge,
-	}, t)
+	return slices.Contains(notificationTypes, t)
 }
 
 type notification interface {
@@ -177,6 +189,30 @@ func scanNotifications(db *sql.DB, rows *sql.Rows) ([]*Notification, error) {
 				return nil, err
 			}
 			notif.Notif = nc
+		case NotificationTypeDataExport:
+			nc := &NotificationDataExport{}
+			if err := json.Unmarshal(notif.notifRawJSON, nc); err != nil {
+				return nil, err
+			}
+			notif.Notif = nc
+		case NotificationTypeCommRequest:
+			nc := &NotificationCommunityRequest{}
+			if err := json.Unmarshal(notif.notifRawJSON, nc); err != nil {
+				return nil, err
+			}
+			notif.Notif = nc
+		case NotificationTypeMention:
+			nc := &NotificationMention{}
+			if err := json.Unmarshal(notif.notifRawJSON, nc); err != nil {
+				return nil, err
+			}
+			notif.Notif = nc
+		case NotificationTypeThreadReply:
+			nc := &NotificationThreadReply{}
+			if err := json.Unmarshal(notif.notifRawJSON, nc); err != nil {
+				return nil, err
+			}
+			notif.Notif = nc
 		default:
 			return nil, fmt.Errorf("unknown notification type: %s", string(notif.Type))
 		}
@@ -214,12 +250,16 @@ func removeExcessNotifications(ctx context.Context, db *sql.DB, user uid.ID) (n
 	return
 }
 
-// CreateNotification adds a new notification to user's notifications stack.
+// CreateNotification adds a new notification to user's notifications stack,
+// unless user has turned off notifications of the type.
 func CreateNotification(ctx context.Context, db *sql.DB, user uid.ID, Type NotificationType, notif notification) error {
-	if is, err := UserDeleted(db, user); err != nil {
+	prefs, deleted, err := getNotificationPreferences(ctx, db, user)
+	if err != nil {
 		return err
-	} else if is {
-		// Exit silently if the user is deleted.
+	}
+	if deleted || !prefs.Channels(Type).InApp {
+		// Exit silently if the user is deleted or doesn't want the
+		// notification.
 		return nil
 	}
 
@@ -381,10 +421,16 @@ func (n *Notification) Update(ctx context.Context) error {
 	return nil
 }
 
-// SendPushNotification sends the notification to all matching sessions. Call
-// EnablePushNotifications before any calls to this method.
+// SendPushNotification sends the notification to all matching sessions,
+// unless the user has turned off push notifications of the type, or it's the
+// user's quiet hours. Call EnablePushNotifications before any calls to this
+// method.
 func (n *Notification) SendPushNotification(ctx context.Context) error {
-	if n.Type == NotificationTypeUpvote { // no push notifications for upvotes, for the moment
+	prefs, _, err := getNotificationPreferences(ctx, n.db, n.UserID)
+	if err != nil {
+		return err
+	}
+	if !prefs.pushAllowed(n.Type, time.Now()) {
 		return nil
 	}
 
@@ -413,6 +459,7 @@ func (n *Notification) SendPushNotification(ctx context.Context) error {
 		VAPIDPrivateKey: keys.Private,
 		TTL:             30,
 		Topic:           topic, // For collapsing comments
+		HTTPClient:      httputil.SafeClient,
 	})
 }
 
@@ -464,7 +511,7 @@ func CreateNewCommentNotification(ctx context.Context, db *sql.DB, post *Post, c
 	if err != nil {
 		return err
 	}
-	if user.ReplyNotificationsOff {
+	if !user.NotificationPreferences.Channels(NotificationTypeNewComment).InApp {
 		return nil
 	}
 
@@ -473,6 +520,16 @@ func CreateNewCommentNotification(ctx context.Context, db *sql.DB, post *Post, c
 	} else if muted {
 		return nil
 	}
+	if blocked, err := usersBlocked(ctx, db, user.ID, author.ID); err != nil {
+		return err
+	} else if blocked {
+		return nil
+	}
+	if unsubscribed, err := threadUnsubscribed(ctx, db, user.ID, post.ID); err != nil {
+		return err
+	} else if unsubscribed {
+		return nil
+	}
 
 	// Select last 10 notifications to see if an identical notification exists.
 	notifs, _, err := GetNotifications(ctx, db, post.AuthorID, 10, "")
@@ -544,7 +601,7 @@ func CreateCommentReplyNotification(ctx context.Context, db *sql.DB, receiver ui
 	if err != nil {
 		return err
 	}
-	if user.ReplyNotificationsOff {
+	if !user.NotificationPreferences.Channels(NotificationTypeCommentReply).InApp {
 		return nil
 	}
 
@@ -553,6 +610,16 @@ func CreateCommentReplyNotification(ctx context.Context, db *sql.DB, receiver ui
 	} else if muted {
 		return nil
 	}
+	if blocked, err := usersBlocked(ctx, db, user.ID, author.ID); err != nil {
+		return err
+	} else if blocked {
+		return nil
+	}
+	if unsubscribed, err := threadUnsubscribed(ctx, db, user.ID, parent); err != nil {
+		return err
+	} else if unsubscribed {
+		return nil
+	}
 
 	// Select last 10 notifications to see if an identical notification exists.
 	notifs, _, err := GetNotifications(ctx, db, receiver, 10, "")
@@ -580,13 +647,99 @@ func CreateCommentReplyNotification(ctx context.Context, db *sql.DB, receiver ui
 	return CreateNotification(ctx, db, receiver, NotificationTypeCommentReply, n)
 }
 
+// NotificationThreadReply is for when a comment is added to a thread (a post,
+// or a comment subtree) that a user follows.
+type NotificationThreadReply struct {
+	PostID    uid.ID  `json:"postId"`
+	ThreadID  *uid.ID `json:"threadId"` // The followed comment; nil if the post is followed.
+	CommentID uid.ID  `json:"commentId"`
+
+	// If NumComments > 1, the first user that commented.
+	CommentAuthor string `json:"commentAuthor"`
+
+	// If NumComments > 1, many new comments have been added to the thread and
+	// CommentID and CommentAuthor is that of the first one.
+	NumComments int `json:"noComments"`
+
+	// First time this notification was created.
+	FirstCreatedAt time.Time `json:"firstCreatedAt"`
+}
+
+func (n NotificationThreadReply) marshalJSONForAPI(ctx context.Context, db *sql.DB) ([]byte, error) {
+	type T NotificationThreadReply
+	out := struct {
+		T
+		Post *Post `json:"post"`
+	}{
+		T: (T)(n),
+	}
+
+	post, err := GetPost(ctx, db, &n.PostID, "", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	out.Post = post
+	return json.Marshal(out)
+}
+
+// CreateThreadReplyNotification creates a notification of type thread_reply.
+// If an unseen notification of the same thread exists in the last 10 items,
+// that notification is updated instead.
+func CreateThreadReplyNotification(ctx context.Context, db *sql.DB, receiver, post uid.ID, thread *uid.ID, comment uid.ID, author *User) error {
+	user, err := GetUser(ctx, db, receiver, nil)
+	if err != nil {
+		return err
+	}
+	if !user.NotificationPreferences.Channels(NotificationTypeThreadReply).InApp {
+		return nil
+	}
+
+	sameThread := func(a, b *uid.ID) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return *a == *b
+	}
+
+	// Select last 10 notifications to see if an identical notification exists.
+	notifs, _, err := GetNotifications(ctx, db, receiver, 10, "")
+	if err != nil {
+		return err
+	}
+	for _, notif := range notifs {
+		if notif.Type == NotificationTypeThreadReply {
+			tr := notif.Notif.(*NotificationThreadReply)
+			if tr.PostID == post && sameThread(tr.ThreadID, thread) && !notif.Seen {
+				tr.NumComments++
+				return notif.Update(ctx)
+			}
+		}
+	}
+
+	n := NotificationThreadReply{
+		PostID:         post,
+		ThreadID:       thread,
+		CommentID:      comment,
+		CommentAuthor:  author.Username,
+		NumComments:    1,
+		FirstCreatedAt: time.Now(),
+	}
+	return CreateNotification(ctx, db, receiver, NotificationTypeThreadReply, n)
+}
+
 func updateNewNotificationsCount(ctx context.Context, db *sql.DB, user uid.ID) error {
 	_, err := db.ExecContext(ctx, "UPDATE users SET notifications_new_count = (SELECT COUNT(*) FROM notifications WHERE user_id = ? AND seen = FALSE) WHERE id = ?", user, user)
+	if err == nil {
+		publishNotificationsCount(ctx, db, user)
+	}
 	return err
 }
 
 func resetNewNotificationsCount(ctx context.Context, db *sql.DB, user uid.ID) error {
 	_, err := db.ExecContext(ctx, "UPDATE users SET notifications_new_count = 0 WHERE id = ?", user)
+	if err == nil {
+		publishNotificationsCount(ctx, db, user)
+	}
 	return err
 }
 
@@ -653,7 +806,7 @@ func (n NotificationNewVotes) marshalJSONForAPI(ctx context.Context, db *sql.DB)
 func CreateNewVotesNotification(ctx context.Context, db *sql.DB, user uid.ID, community string, isPost bool, targetID uid.ID) error {
 	if user, err := GetUser(ctx, db, user, nil); err != nil {
 		return err
-	} else if user.UpvoteNotificationsOff {
+	} else if !user.NotificationPreferences.Channels(NotificationTypeUpvote).InApp {
 		return nil
 	}
 
@@ -944,3 +1097,95 @@ func CreateNewBadgeNotification(ctx context.Context, db *sql.DB, user uid.ID, ba
 	}
 	return CreateNotification(ctx, db, user, NotificationTypeNewBadge, n)
 }
+
+// NotificationDataExport is sent when a data export of the user is ready to be
+// downloaded.
+type NotificationDataExport struct {
+	ExportID uid.ID `json:"exportId"`
+}
+
+func (n NotificationDataExport) marshalJSONForAPI(ctx context.Context, db *sql.DB) ([]byte, error) {
+	out := struct {
+		ExportID uid.ID      `json:"exportId"`
+		Export   *DataExport `json:"export"` // Nil if the export has expired.
+	}{
+		ExportID: n.ExportID,
+	}
+	export, err := GetDataExport(ctx, db, n.ExportID)
+	if err != nil && err != errDataExportNotFound {
+		return nil, err
+	}
+	out.Export = export
+	return json.Marshal(out)
+}
+
+func CreateDataExportNotification(ctx context.Context, db *sql.DB, user uid.ID, export uid.ID) error {
+	return CreateNotification(ctx, db, user, NotificationTypeDataExport, NotificationDataExport{ExportID: export})
+}
+
+// NotificationCommunityRequest is sent to the requester of a community when the
+// request is either approved or rejected.
+type NotificationCommunityRequest struct {
+	RequestID int `json:"requestId"`
+}
+
+func (n NotificationCommunityRequest) marshalJSONForAPI(ctx context.Context, db *sql.DB) ([]byte, error) {
+	out := struct {
+		Request   *CommunityRequest `json:"request"`
+		Community *Community        `json:"community"` // Nil unless the request is approved.
+	}{}
+	req, err := GetCommunityRequest(ctx, db, n.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	out.Request = req
+	if req.CommunityID.Valid {
+		if out.Community, err = GetCommunityByID(ctx, db, req.CommunityID.ID, nil); err != nil && err != errCommunityNotFound {
+			return nil, err
+		}
+	}
+	return json.Marshal(out)
+}
+
+func CreateCommunityRequestNotification(ctx context.Context, db *sql.DB, user uid.ID, request int) error {
+	return CreateNotification(ctx, db, user, NotificationTypeCommRequest, NotificationCommunityRequest{RequestID: request})
+}
+
+// NotificationMention is sent to a user who is mentioned (as @username or
+// u/username) in a post or a comment.
+type NotificationMention struct {
+	PostID    uid.ID  `json:"postId"`
+	CommentID *uid.ID `json:"commentId"` // Nil if the mention is in the post.
+	AuthorID  uid.ID  `json:"authorId"`
+}
+
+func (n NotificationMention) marshalJSONForAPI(ctx context.Context, db *sql.DB) ([]byte, error) {
+	type T NotificationMention
+	out := struct {
+		T
+		Post           *Post  `json:"post"`
+		AuthorUsername string `json:"authorUsername"`
+	}{
+		T: (T)(n),
+	}
+	post, err := GetPost(ctx, db, &n.PostID, "", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	out.Post = post
+	author, err := GetUser(ctx, db, n.AuthorID, nil)
+	if err != nil {
+		return nil, err
+	}
+	out.AuthorUsername = author.Username
+	return json.Marshal(out)
+}
+
+func CreateMentionNotification(ctx context.Context, db *sql.DB, user, author, post uid.ID, comment *uid.ID) error {
+	n := NotificationMention{
+		PostID:    post,
+		CommentID: comment,
+		AuthorID:  author,
+	}
+	return CreateNotification(ctx, db, user, NotificationTypeMention, n)
+}
@@ -0,0 +1,237 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	_ "time/tzdata" // so that quiet hours work on hosts without a tz database
+
+	"github.com/discuitnet/discuit/internal/httperr"
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// NotificationChannels are the channels through which a notification of some
+// type is delivered. Push and email deliveries are of in-app notifications, so
+// they're off whenever InApp is off. Emails are sent as part of email digests
+// (see email_digest.go).
+type NotificationChannels struct {
+	InApp bool `json:"inApp"`
+	Push  bool `json:"push"`
+	Email bool `json:"email"`
+}
+
+// QuietHours is a daily period during which no push notifications are sent
+// (notifications are still created). Start and End are in "HH:MM" format, in
+// the time zone Timezone (an IANA time zone name, like "Europe/Berlin"). If End
+// is before Start, the period spans midnight.
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+}
+
+// NotificationPreferences are a user's notification preferences.
+type NotificationPreferences struct {
+	Types      map[NotificationType]NotificationChannels `json:"types"`
+	QuietHours *QuietHours                               `json:"quietHours"`
+}
+
+func defaultNotificationChannels(t NotificationType) NotificationChannels {
+	c := NotificationChannels{InApp: true, Push: true}
+	switch t {
+	case NotificationTypeUpvote:
+		c.Push = false
+	case NotificationTypeNewComment, NotificationTypeCommentReply, NotificationTypeMention, NotificationTypeThreadReply:
+		c.Email = true
+	}
+	return c
+}
+
+// DefaultNotificationPreferences returns the notification preferences of
+// users who haven't set any: all notifications are shown in-app, push
+// notifications are sent for all but upvotes, and only replies and mentions
+// are included in email digests (which are off unless the user turns them
+// on).
+func DefaultNotificationPreferences() *NotificationPreferences {
+	p := &NotificationPreferences{Types: make(map[NotificationType]NotificationChannels)}
+	for _, t := range notificationTypes {
+		p.Types[t] = defaultNotificationChannels(t)
+	}
+	return p
+}
+
+// Channels returns the channels of notifications of type t.
+func (p *NotificationPreferences) Channels(t NotificationType) NotificationChannels {
+	if c, ok := p.Types[t]; ok {
+		return c
+	}
+	return defaultNotificationChannels(t)
+}
+
+// Validate returns an error if p is invalid. Missing types are set to their
+// defaults.
+func (p *NotificationPreferences) Validate() error {
+	if p.Types == nil {
+		p.Types = make(map[NotificationType]NotificationChannels)
+	}
+	for t, c := range p.Types {
+		if !t.Valid() {
+			return httperr.NewBadRequest("invalid_notification_type", fmt.Sprintf("Invalid notification type %q.", t))
+		}
+		if !c.InApp {
+			p.Types[t] = NotificationChannels{}
+		}
+	}
+	for _, t := range notificationTypes {
+		if _, ok := p.Types[t]; !ok {
+			p.Types[t] = defaultNotificationChannels(t)
+		}
+	}
+	if p.QuietHours != nil {
+		return p.QuietHours.validate()
+	}
+	return nil
+}
+
+// legacyToggles returns the values of User.UpvoteNotificationsOff and
+// User.ReplyNotificationsOff that correspond to p.
+func (p *NotificationPreferences) legacyToggles() (upvotesOff, repliesOff bool) {
+	upvotesOff = !p.Channels(NotificationTypeUpvote).InApp
+	repliesOff = !p.Channels(NotificationTypeNewComment).InApp &&
+		!p.Channels(NotificationTypeCommentReply).InApp &&
+		!p.Channels(NotificationTypeThreadReply).InApp
+	return
+}
+
+// applyLegacyToggles sets the channels of upvote and reply notifications to
+// either their defaults or to off.
+func (p *NotificationPreferences) applyLegacyToggles(upvotesOff, repliesOff bool) {
+	set := func(t NotificationType, off bool) {
+		if off {
+			p.Types[t] = NotificationChannels{}
+		} else if !p.Channels(t).InApp {
+			p.Types[t] = defaultNotificationChannels(t)
+		}
+	}
+	set(NotificationTypeUpvote, upvotesOff)
+	set(NotificationTypeNewComment, repliesOff)
+	set(NotificationTypeCommentReply, repliesOff)
+	set(NotificationTypeThreadReply, repliesOff)
+}
+
+// parseNotificationPreferences parses the stored preferences of a user. If
+// there are none (or they're invalid), the defaults are returned, adjusted to
+// the legacy toggles.
+func parseNotificationPreferences(data msql.NullString, upvotesOff, repliesOff bool) *NotificationPreferences {
+	if data.Valid {
+		p := &NotificationPreferences{}
+		if err := json.Unmarshal([]byte(data.String), p); err == nil && p.Validate() == nil {
+			return p
+		}
+	}
+	p := DefaultNotificationPreferences()
+	p.applyLegacyToggles(upvotesOff, repliesOff)
+	return p
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q *QuietHours) validate() error {
+	if _, err := parseClock(q.Start); err != nil {
+		return httperr.NewBadRequest("invalid_quiet_hours", "Invalid quiet hours start time.")
+	}
+	if _, err := parseClock(q.End); err != nil {
+		return httperr.NewBadRequest("invalid_quiet_hours", "Invalid quiet hours end time.")
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return httperr.NewBadRequest("invalid_quiet_hours", "Invalid quiet hours time zone.")
+	}
+	return nil
+}
+
+// contains reports whether t is within the quiet hours.
+func (q *QuietHours) contains(t time.Time) bool {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	m := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+// pushAllowed reports whether a push notification of type t is to be sent at
+// time now.
+func (p *NotificationPreferences) pushAllowed(t NotificationType, now time.Time) bool {
+	if !p.Channels(t).Push {
+		return false
+	}
+	return p.QuietHours == nil || !p.QuietHours.contains(now)
+}
+
+// getNotificationPreferences returns the notification preferences of user,
+// and whether user is deleted.
+func getNotificationPreferences(ctx context.Context, db *sql.DB, user uid.ID) (*NotificationPreferences, bool, error) {
+	var (
+		deleted                bool
+		upvotesOff, repliesOff bool
+		data                   msql.NullString
+	)
+	row := db.QueryRowContext(ctx, "SELECT deleted_at IS NOT NULL, upvote_notifications_off, reply_notifications_off, notification_preferences FROM users WHERE id = ?", user)
+	if err := row.Scan(&deleted, &upvotesOff, &repliesOff, &data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, errUserNotFound
+		}
+		return nil, false, err
+	}
+	return parseNotificationPreferences(data, upvotesOff, repliesOff), deleted, nil
+}
+
+// SetNotificationPreferences validates and saves the user's notification
+// preferences. The legacy toggles, UpvoteNotificationsOff and
+// ReplyNotificationsOff, are updated to match.
+func (u *User) SetNotificationPreferences(ctx context.Context, p *NotificationPreferences) error {
+	if u.Deleted {
+		return ErrUserDeleted
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	upvotesOff, repliesOff := p.legacyToggles()
+	if _, err := u.db.ExecContext(ctx, "UPDATE users SET notification_preferences = ?, upvote_notifications_off = ?, reply_notifications_off = ? WHERE id = ?",
+		string(data), upvotesOff, repliesOff, u.ID); err != nil {
+		return err
+	}
+
+	u.NotificationPreferences = p
+	if u.NotificationPreferencesPublic != nil {
+		u.NotificationPreferencesPublic = p
+	}
+	u.UpvoteNotificationsOff, u.ReplyNotificationsOff = upvotesOff, repliesOff
+	u.savedUpvoteNotificationsOff, u.savedReplyNotificationsOff = upvotesOff, repliesOff
+	return nil
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursContains(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 1, 1, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		q    QuietHours
+		t    time.Time
+		want bool
+	}{
+		{QuietHours{"09:00", "17:00", "UTC"}, at(12, 0), true},
+		{QuietHours{"09:00", "17:00", "UTC"}, at(17, 0), false},
+		{QuietHours{"09:00", "17:00", "UTC"}, at(8, 59), false},
+		{QuietHours{"22:00", "07:00", "UTC"}, at(23, 30), true},
+		{QuietHours{"22:00", "07:00", "UTC"}, at(3, 0), true},
+		{QuietHours{"22:00", "07:00", "UTC"}, at(7, 0), false},
+		{QuietHours{"22:00", "07:00", "UTC"}, at(12, 0), false},
+		{QuietHours{"22:00", "07:00", "Asia/Tokyo"}, at(14, 0), true}, // 23:00 in Tokyo
+		{QuietHours{"22:00", "07:00", "Asia/Tokyo"}, at(22, 30), false},
+	}
+	for _, test := range tests {
+		if got := test.q.contains(test.t); got != test.want {
+			t.Errorf("%+v.contains(%v) = %v, want %v", test.q, test.t, got, test.want)
+		}
+	}
+}
+
+func TestNotificationPreferencesLegacyToggles(t *testing.T) {
+	p := DefaultNotificationPreferences()
+	p.applyLegacyToggles(true, false)
+	if upvotesOff, repliesOff := p.legacyToggles(); !upvotesOff || repliesOff {
+		t.Errorf("legacyToggles() = %v, %v, want true, false", upvotesOff, repliesOff)
+	}
+	if p.Channels(NotificationTypeUpvote).InApp {
+		t.Error("upvote notifications not turned off")
+	}
+	p.applyLegacyToggles(false, true)
+	if p.Channels(NotificationTypeUpvote) != defaultNotificationChannels(NotificationTypeUpvote) {
+		t.Error("upvote notifications not reset to defaults")
+	}
+	if p.Channels(NotificationTypeCommentReply).InApp {
+		t.Error("reply notifications not turned off")
+	}
+}
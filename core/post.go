@@ -9,7 +9,6 @@ import (
 	"log"
 	"math"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -20,7 +19,6 @@ import (
 	msql "github.com/discuitnet/discuit/internal/sql"
 	"github.com/discuitnet/discuit/internal/uid"
 	"github.com/discuitnet/discuit/internal/utils"
-	"golang.org/x/exp/slices"
 )
 
 const (
@@ -28,6 +26,7 @@ const (
 	maxPostBodyLength    = 20000 // in runes.
 	maxPostTitleLength   = 255   // in runes.
 	maxPostLinkLength    = 2048  // in bytes
+	maxLinkImageSize     = 20 << 20
 	maxCommentDepth      = 15
 	maxCommentBodyLength = maxPostBodyLength
 	commentsFetchLimit   = 500
@@ -128,11 +127,19 @@ type Post struct {
 
 	LockedAt msql.NullTime `json:"lockedAt"`
 
+	// Live posts (live threads) have their comments sorted newest first and
+	// streamed to the clients. They're locked once they've been idle for a
+	// while (see LockIdleLiveThreads).
+	Live      bool          `json:"live"`
+	LiveSince msql.NullTime `json:"liveSince,omitempty"`
+
 	Upvotes   int `json:"upvotes"`
 	Downvotes int `json:"downvotes"`
 	Points    int `json:"-"` // Upvotes - Downvotes
 
 	Hotness        int           `json:"hotness"`
+	Controversy    int           `json:"-"` // See PostControversy.
+	Rising         int           `json:"-"` // See postRising.
 	CreatedAt      time.Time     `json:"createdAt"`
 	EditedAt       msql.NullTime `json:"editedAt"`
 	LastActivityAt time.Time     `json:"lastActivityAt"`
@@ -150,6 +157,11 @@ type Post struct {
 	DeletedContentBy uid.NullID    `json:"-"`
 	DeletedContentAs UserGroup     `json:"deletedContentAs,omitempty"`
 
+	// Held posts are hidden from feeds until they're approved by a mod.
+	Held       bool            `json:"held"`
+	HeldAt     msql.NullTime   `json:"heldAt,omitempty"`
+	HeldReason msql.NullString `json:"heldReason,omitempty"`
+
 	NumComments  int             `json:"noComments"`
 	Comments     []*Comment      `json:"comments"`
 	CommentsNext msql.NullString `json:"commentsNext"` // pagination cursor
@@ -163,6 +175,10 @@ type Post struct {
 	AuthorMutedByViewer    bool `json:"isAuthorMuted"`
 	CommunityMutedByViewer bool `json:"isCommunityMuted"`
 
+	// Whether the logged in user has hidden the post (see hidden_post.go).
+	// It's set only by the API endpoint of a single post.
+	HiddenByViewer bool `json:"isHidden"`
+
 	Community *Community `json:"community,omitempty"`
 	Author    *User      `json:"author,omitempty"`
 }
@@ -190,6 +206,8 @@ var selectPostCols = []string{
 	"posts.downvotes",
 	"posts.points",
 	"posts.hotness",
+	"posts.controversy",
+	"posts.rising",
 	"posts.created_at",
 	"posts.edited_at",
 	"posts.last_activity_at",
@@ -203,6 +221,9 @@ var selectPostCols = []string{
 	"posts.deleted_content_at",
 	"posts.deleted_content_by",
 	"posts.deleted_content_as",
+	"posts.held_at",
+	"posts.held_reason",
+	"posts.live_since",
 }
 
 var selectPostJoins = []string{
@@ -294,7 +315,11 @@ func GetPostsByIDs(ctx context.Context, db *sql.DB, viewer *uid.ID, includeDelet
 		return nil, fmt.Errorf("db error on query '%s' with args (%v)", query, args)
 	}
 
-	return scanPosts(ctx, db, rows, viewer)
+	posts, err := scanPosts(ctx, db, rows, viewer)
+	if err != nil {
+		return nil, err
+	}
+	return filterHiddenPosts(db, posts, viewer)
 }
 
 // scanPosts returns errPostNotFound is no posts are found.
@@ -333,6 +358,8 @@ func scanPosts(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.ID)
 			&post.Downvotes,
 			&post.Points,
 			&post.Hotness,
+			&post.Controversy,
+			&post.Rising,
 			&post.CreatedAt,
 			&post.EditedAt,
 			&post.LastActivityAt,
@@ -346,6 +373,9 @@ func scanPosts(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.ID)
 			&post.DeletedContentAt,
 			&post.DeletedContentBy,
 			&post.DeletedContentAs,
+			&post.HeldAt,
+			&post.HeldReason,
+			&post.LiveSince,
 		}
 
 		linkImage := &images.Image{}
@@ -361,6 +391,8 @@ func scanPosts(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.ID)
 		if err := rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("scanning post rows.Scan: %w", err)
 		}
+		post.Held = post.HeldAt.Valid
+		post.Live = post.LiveSince.Valid
 
 		if proPic.ID != nil {
 			proPic.PostScan()
@@ -418,6 +450,9 @@ func scanPosts(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.ID)
 				}
 			}
 		}
+		if err := stripPostEmbeds(ctx, db, *viewer, posts); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := populatePostsImages(ctx, db, posts); err != nil {
@@ -598,6 +633,18 @@ func createPost(ctx context.Context, db *sql.DB, opts *createPostOpts) (*Post, e
 		return nil, errUserBannedFromCommunity
 	}
 
+	var links []string
+	if opts.postType == PostTypeLink {
+		links = append(links, opts.link.URL)
+	}
+	verdict, err := checkContent(ctx, db, opts.community, opts.title+"\n"+opts.body, links...)
+	if err != nil {
+		return nil, err
+	}
+	if err := verdict.scoreSpam(ctx, db, opts.community, opts.author, opts.title+"\n"+opts.body, links); err != nil {
+		return nil, err
+	}
+
 	// Truncate title and body if max lengths are exceeded.
 	var post Post
 	post.Title = opts.title
@@ -618,6 +665,11 @@ func createPost(ctx context.Context, db *sql.DB, opts *createPostOpts) (*Post, e
 		{Name: "created_at", Value: post.CreatedAt},
 		{Name: "hotness", Value: PostHotness(0, 0, post.CreatedAt)},
 	}
+	if verdict.hold {
+		cols = append(cols,
+			msql.ColumnValue{Name: "held_at", Value: post.CreatedAt},
+			msql.ColumnValue{Name: "held_reason", Value: verdict.holdReason})
+	}
 
 	if opts.postType == PostTypeLink {
 		data, err := json.Marshal(opts.link)
@@ -682,21 +734,15 @@ func createPost(ctx context.Context, db *sql.DB, opts *createPostOpts) (*Post, e
 		}
 	}
 
-	for _, table := range postsTables {
-		if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (community_id, post_id, user_id, created_at) VALUES (?, ?, ?, ?)", table),
-			opts.community, post.ID, opts.author, post.CreatedAt); err != nil {
+	// Held posts are added to the top posts tables, and to the user's profile,
+	// once they're approved.
+	if !verdict.hold {
+		if err := insertPostListings(ctx, tx, post.ID, opts.community, opts.author, post.CreatedAt, 0); err != nil {
 			tx.Rollback()
 			return nil, err
 		}
 	}
 
-	// For the user profile page.
-	if _, err := tx.ExecContext(ctx, "INSERT INTO posts_comments (target_id, user_id, target_type) VALUES (?, ?, ?)",
-		post.ID, opts.author, ContentTypePost); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-
 	if _, err := tx.ExecContext(ctx, "UPDATE users SET no_posts = no_posts + 1 WHERE id = ?", opts.author); err != nil {
 		tx.Rollback()
 		return nil, err
@@ -706,9 +752,40 @@ func createPost(ctx context.Context, db *sql.DB, opts *createPostOpts) (*Post, e
 		return nil, err
 	}
 
+	verdict.record(db, opts.community, uid.NullID{Valid: true, ID: post.ID}, ReportTypePost, post.ID)
+	if !verdict.hold {
+		mentionUsers(db, opts.author, post.ID, opts.community, nil, post.Title+"\n"+post.Body.String)
+	}
 	return GetPost(ctx, db, &post.ID, "", nil, false)
 }
 
+// insertPostListings adds the post to the top posts tables and to the profile
+// of its author.
+func insertPostListings(ctx context.Context, tx *sql.Tx, post, community, author uid.ID, createdAt time.Time, points int) error {
+	for _, table := range postsTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (community_id, post_id, user_id, created_at, points) VALUES (?, ?, ?, ?, ?)", table),
+			community, post, author, createdAt, points); err != nil {
+			return err
+		}
+	}
+
+	// For the user profile page.
+	_, err := tx.ExecContext(ctx, "INSERT IGNORE INTO posts_comments (target_id, user_id, target_type) VALUES (?, ?, ?)",
+		post, author, ContentTypePost)
+	return err
+}
+
+// deletePostListings undoes insertPostListings.
+func deletePostListings(ctx context.Context, tx *sql.Tx, post, author uid.ID) error {
+	for _, table := range postsTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE post_id = ?", table), post); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, "DELETE FROM posts_comments WHERE target_id = ? AND user_id = ?", post, author)
+	return err
+}
+
 func CreateTextPost(ctx context.Context, db *sql.DB, author, community uid.ID, title string, body string) (*Post, error) {
 	return createPost(ctx, db, &createPostOpts{
 		postType:  PostTypeText,
@@ -744,75 +821,44 @@ func CreateImagePost(ctx context.Context, db *sql.DB, author, community uid.ID,
 	})
 }
 
-// getLinkPostImage returns the og:image of the url or, if no og:image can be
-// found and the url is itself is an image, then that image. If no image is
-// found in either case, it returns nil.
-func getLinkPostImage(u *url.URL) []byte {
-	fullURL := u.String()
-	res, err := httputil.Get(fullURL)
+// getLinkPostImage downloads the preview image of the link (see
+// LinkInfo.Image). If there's no such image, or if it could not be downloaded,
+// it returns nil.
+func getLinkPostImage(ctx context.Context, info *LinkInfo) []byte {
+	if info.Image == "" {
+		return nil
+	}
+	res, err := httputil.Fetch(ctx, info.Image, &httputil.FetchOptions{
+		MaxBodySize:  maxLinkImageSize,
+		ContentTypes: []string{"image/*"},
+	})
 	if err != nil {
 		return nil
 	}
 	defer res.Body.Close()
-
-	imageURL, err := httputil.ExtractOpenGraphImage(res.Body)
-	if err != nil {
-		log.Printf("error extracting the og:image tag of url: %v\n", u)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
 		return nil
 	}
-	if imageURL == "" {
-		// Since og:image is not found, see if the link itself is an image.
-		probablyAnImage := slices.Contains([]string{"image/jpeg", "image/png", "image/webp"}, res.Header.Get("Content-Type"))
-		if !probablyAnImage {
-			exts := []string{".jpg", ".jpeg", ".png", ".webp"}
-			for _, v := range exts {
-				if strings.HasSuffix(u.Path, v) {
-					probablyAnImage = true
-					break
-				}
-			}
-		}
-		if probablyAnImage {
-			imageURL = fullURL
-		}
+	image, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil
 	}
-	if imageURL != "" {
-		res, err := httputil.Get(imageURL)
-		if err != nil {
-			return nil
-		}
-		defer res.Body.Close()
-		if res.StatusCode < 200 || res.StatusCode > 299 {
-			return nil
-		}
-		image, err := io.ReadAll(res.Body)
-		if err != nil {
-			return nil
-		}
-		if len(image) == 0 {
-			return nil
-		}
-		return image
+	if len(image) == 0 {
+		return nil
 	}
-
-	return nil
+	return image
 }
 
 func CreateLinkPost(ctx context.Context, db *sql.DB, author, community uid.ID, title string, link string) (*Post, error) {
-	errInvalidURL := httperr.NewBadRequest("invalid-url", "Invalid URL.")
-	if len(link) > maxPostLinkLength {
-		link = link[:maxPostLinkLength]
+	u, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
 	}
 
-	u, err := url.Parse(link)
+	info, err := getLinkInfo(ctx, u)
 	if err != nil {
-		return nil, errInvalidURL
-	}
-	if !u.IsAbs() {
-		u.Scheme = "http"
-	}
-	if u.Hostname() == "" {
-		return nil, errInvalidURL
+		log.Printf("Failed to unfurl link %s: %v\n", u, err)
+		// Continue on error...
 	}
 
 	return createPost(ctx, db, &createPostOpts{
@@ -820,11 +866,16 @@ func CreateLinkPost(ctx context.Context, db *sql.DB, author, community uid.ID, t
 		author:    author,
 		community: community,
 		title:     title,
-		linkImage: getLinkPostImage(u),
+		linkImage: getLinkPostImage(ctx, info),
 		link: postLink{
-			Version:  1,
-			URL:      u.String(),
-			Hostname: u.Hostname(),
+			Version:     2,
+			URL:         info.URL,
+			Hostname:    info.Hostname,
+			Title:       info.Title,
+			Description: info.Description,
+			SiteName:    info.SiteName,
+			Favicon:     info.Favicon,
+			Embed:       info.Embed,
 		},
 	})
 }
@@ -834,6 +885,34 @@ func (p *Post) truncateTitleAndBody() {
 	p.Body.String = utils.TruncateUnicodeString(p.Body.String, maxPostBodyLength)
 }
 
+// stripPostEmbeds removes the embeds of link posts in posts if viewer has
+// turned embeds off.
+func stripPostEmbeds(ctx context.Context, db *sql.DB, viewer uid.ID, posts []*Post) error {
+	found := false
+	for _, post := range posts {
+		if post.Link != nil && post.Link.Embed != nil {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	var embedsOff bool
+	if err := db.QueryRowContext(ctx, "SELECT embeds_off FROM users WHERE id = ?", viewer).Scan(&embedsOff); err != nil {
+		return err
+	}
+	if embedsOff {
+		for _, post := range posts {
+			if post.Link != nil {
+				post.Link.Embed = nil
+			}
+		}
+	}
+	return nil
+}
+
 func (p *Post) HasLinkImage() bool {
 	return p.Link != nil && p.Link.Image != nil && p.Link.Image.ID != nil
 }
@@ -850,6 +929,14 @@ func (p *Post) Save(ctx context.Context, user uid.ID) error {
 
 	p.truncateTitleAndBody()
 
+	var links []string
+	if p.Type == PostTypeLink && p.Link != nil {
+		links = append(links, p.Link.URL)
+	}
+	verdict, err := checkContent(ctx, p.db, p.CommunityID, p.Title+"\n"+p.Body.String, links...)
+	if err != nil {
+		return err
+	}
 	now := time.Now()
 	var args []any
 	query := "UPDATE posts SET title = ?"
@@ -861,12 +948,112 @@ func (p *Post) Save(ctx context.Context, user uid.ID) error {
 	query += ", edited_at = ? WHERE id = ?"
 	args = append(args, now, p.ID)
 
-	_, err := p.db.ExecContext(ctx, query, args...)
-	if err == nil {
-		p.EditedAt.Valid = true
-		p.EditedAt.Time = now
+	if _, err = p.db.ExecContext(ctx, query, args...); err != nil {
+		return err
 	}
-	return err
+	p.EditedAt.Valid = true
+	p.EditedAt.Time = now
+	if verdict.hold {
+		if err := p.hold(ctx, verdict.holdReason); err != nil {
+			return err
+		}
+	}
+	verdict.record(p.db, p.CommunityID, uid.NullID{Valid: true, ID: p.ID}, ReportTypePost, p.ID)
+	if !p.Held {
+		mentionUsers(p.db, p.AuthorID, p.ID, p.CommunityID, nil, p.Title+"\n"+p.Body.String)
+	}
+	return nil
+}
+
+// hold holds the post for review by the mods. If the post is already held,
+// only the reason is updated.
+func (p *Post) hold(ctx context.Context, reason string) error {
+	if p.Held {
+		if _, err := p.db.ExecContext(ctx, "UPDATE posts SET held_reason = ? WHERE id = ?", reason, p.ID); err != nil {
+			return err
+		}
+		p.HeldReason = msql.NewNullString(reason)
+		return nil
+	}
+
+	now := time.Now()
+	err := msql.Transact(ctx, p.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE posts SET held_at = ?, held_reason = ? WHERE id = ? AND held_at IS NULL", now, reason, p.ID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err // Held concurrently.
+		}
+		return deletePostListings(ctx, tx, p.ID, p.AuthorID)
+	})
+	if err != nil {
+		return err
+	}
+	p.Held = true
+	p.HeldAt = msql.NewNullTime(now)
+	p.HeldReason = msql.NewNullString(reason)
+	return nil
+}
+
+// Approve releases a held post. Only mods of the community and admins can
+// approve posts.
+func (p *Post) Approve(ctx context.Context, mod uid.ID) error {
+	if !p.Held {
+		return errNotHeld
+	}
+	if ok, err := UserModOrAdmin(ctx, p.db, p.CommunityID, mod); err != nil {
+		return err
+	} else if !ok {
+		return errNotMod
+	}
+
+	err := msql.Transact(ctx, p.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE posts SET held_at = NULL, held_reason = NULL WHERE id = ? AND held_at IS NOT NULL", p.ID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return errNotHeld // Approved concurrently.
+		}
+		if p.Deleted {
+			return nil
+		}
+		return insertPostListings(ctx, tx, p.ID, p.CommunityID, p.AuthorID, p.CreatedAt, p.Points)
+	})
+	if err != nil {
+		return err
+	}
+	p.Held = false
+	p.HeldAt = msql.NullTime{}
+	p.HeldReason = msql.NullString{}
+	if !p.Deleted {
+		// The mentions held back while the post was held.
+		mentionUsers(p.db, p.AuthorID, p.ID, p.CommunityID, nil, p.Title+"\n"+p.Body.String)
+	}
+	return nil
+}
+
+// ViewableBy reports whether viewer (nil if not logged in) can see the post.
+// Held posts can only be seen by their authors, the mods of the community, and
+// admins. Posts of shadow banned users can only be seen by their authors and
+// admins.
+func (p *Post) ViewableBy(ctx context.Context, viewer *uid.ID) (bool, error) {
+	if hidden, err := ContentHidden(p.db, p.AuthorID, viewer); err != nil || hidden {
+		return false, err
+	}
+	if !p.Held {
+		return true, nil
+	}
+	if viewer == nil {
+		return false, nil
+	}
+	if p.AuthorID == *viewer {
+		return true, nil
+	}
+	return UserModOrAdmin(ctx, p.db, p.CommunityID, *viewer)
 }
 
 // StripAuthorInfo should be called if the author account of the post is deleted
@@ -1045,6 +1232,7 @@ func (p *Post) Lock(ctx context.Context, user uid.ID, g UserGroup) error {
 		p.LockedAt = msql.NewNullTime(now)
 		p.LockedBy.Valid, p.LockedBy.ID = true, user
 		p.LockedAs = g
+		publishPostLocked(p.ID)
 	}
 	return err
 }
@@ -1066,16 +1254,121 @@ func (p *Post) Unlock(ctx context.Context, user uid.ID) error {
 		return httperr.NewForbidden("not-mod-not-admin", "User is neither a moderator nor an admin.")
 	}
 
-	_, err = p.db.ExecContext(ctx, "UPDATE posts SET locked = ?, locked_by = null, locked_by_group = ?, locked_at = null WHERE id = ?", false, UserGroupNaN, p.ID)
+	// A live thread that's unlocked is live afresh, so that it's not locked
+	// again right away for being idle.
+	liveSince := p.LiveSince
+	if liveSince.Valid {
+		liveSince = msql.NewNullTime(time.Now())
+	}
+
+	_, err = p.db.ExecContext(ctx, "UPDATE posts SET locked = ?, locked_by = null, locked_by_group = ?, locked_at = null, live_since = ? WHERE id = ?", false, UserGroupNaN, liveSince, p.ID)
 	if err == nil {
 		p.Locked = false
 		p.LockedAt.Valid = false
 		p.LockedBy.Valid = false
 		p.LockedAs = UserGroupNaN
+		p.LiveSince = liveSince
 	}
 	return err
 }
 
+// SetLive turns the live mode of the post on or off on behalf of user, who
+// must be either a moderator of the community or an admin.
+func (p *Post) SetLive(ctx context.Context, user uid.ID, live bool) error {
+	if p.Deleted {
+		return httperr.NewForbidden("post-deleted", "Post is deleted.")
+	}
+
+	isMod, err := UserMod(ctx, p.db, p.CommunityID, user)
+	if err != nil {
+		return err
+	}
+	u, err := GetUser(ctx, p.db, user, nil)
+	if err != nil {
+		return err
+	}
+	if !(isMod || u.Admin) {
+		return httperr.NewForbidden("not-mod-not-admin", "User is neither a moderator nor an admin.")
+	}
+
+	if p.Live == live {
+		return nil
+	}
+	var liveSince msql.NullTime
+	if live {
+		liveSince = msql.NewNullTime(time.Now())
+	}
+	if _, err := p.db.ExecContext(ctx, "UPDATE posts SET live_since = ? WHERE id = ?", liveSince, p.ID); err != nil {
+		return err
+	}
+	p.Live = live
+	p.LiveSince = liveSince
+	return nil
+}
+
+// liveThreadIdle reports whether a live thread, made live at liveSince and
+// with its last activity at lastActivityAt, has been idle for idle at time now.
+func liveThreadIdle(lastActivityAt, liveSince, now time.Time, idle time.Duration) bool {
+	last := lastActivityAt
+	if liveSince.After(last) {
+		last = liveSince
+	}
+	return now.Sub(last) > idle
+}
+
+// LockIdleLiveThreads locks all the live threads that haven't had any activity
+// (nor been made live) in the last idle duration (see liveThreadIdle). The
+// posts are locked without a user, as the mods of their communities. It
+// returns the number of posts locked.
+func LockIdleLiveThreads(ctx context.Context, db *sql.DB, idle time.Duration) (int, error) {
+	now := time.Now()
+	cutoff := now.Add(-idle)
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, last_activity_at, live_since FROM posts
+		WHERE live_since IS NOT NULL AND locked = FALSE AND deleted = FALSE AND last_activity_at < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []uid.ID
+	for rows.Next() {
+		var (
+			id             uid.ID
+			lastActivityAt time.Time
+			liveSince      time.Time
+		)
+		if err := rows.Scan(&id, &lastActivityAt, &liveSince); err != nil {
+			return 0, err
+		}
+		if liveThreadIdle(lastActivityAt, liveSince, now, idle) {
+			ids = append(ids, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		// The conditions are checked again in case the post got new activity
+		// in the meantime.
+		res, err := db.ExecContext(ctx, `
+			UPDATE posts SET locked = TRUE, locked_by = NULL, locked_by_group = ?, locked_at = ?
+			WHERE id = ? AND locked = FALSE AND GREATEST(last_activity_at, live_since) < ?`, UserGroupMods, time.Now(), id, cutoff)
+		if err != nil {
+			return n, err
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return n, err
+		} else if affected > 0 {
+			n++
+			publishPostLocked(id)
+		}
+	}
+	return n, nil
+}
+
 const MaxPinnedPosts = 2
 
 // Pin pins a post on behalf of user to its community if siteWide is false,
@@ -1205,7 +1498,7 @@ func (p *Post) Vote(ctx context.Context, user uid.ID, up bool) error {
 		point = -1
 	}
 
-	query := "UPDATE posts SET points = points + ?, hotness = ?"
+	query := "UPDATE posts SET points = points + ?, hotness = ?, controversy = ?"
 	newUpvotes, newDownvotes := p.Upvotes, p.Downvotes
 	if up {
 		query += ", upvotes = upvotes + 1"
@@ -1216,12 +1509,18 @@ func (p *Post) Vote(ctx context.Context, user uid.ID, up bool) error {
 	}
 	query += " WHERE id = ?"
 
-	_, err = tx.ExecContext(ctx, query, point, PostHotness(newUpvotes, newDownvotes, p.CreatedAt), p.ID)
+	_, err = tx.ExecContext(ctx, query, point, PostHotness(newUpvotes, newDownvotes, p.CreatedAt),
+		PostControversy(newUpvotes, newDownvotes), p.ID)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if err = p.updateRising(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return err
 	}
@@ -1237,8 +1536,9 @@ func (p *Post) Vote(ctx context.Context, user uid.ID, up bool) error {
 		incrementUserPoints(ctx, p.db, p.AuthorID, 1)
 	}
 
-	// Attempt to create a notification (only for upvotes).
-	if !p.AuthorID.EqualsTo(user) && up {
+	// Attempt to create a notification (only for upvotes, and not for the votes
+	// of shadow banned users).
+	if shadowBanned, _ := userShadowBanned(p.db, user); !p.AuthorID.EqualsTo(user) && up && !shadowBanned {
 		go func() {
 			if err := CreateNewVotesNotification(context.Background(), p.db, p.AuthorID, p.CommunityName, true, p.ID); err != nil {
 				log.Printf("Failed creating new_votes notification: %v\n", err)
@@ -1246,6 +1546,7 @@ func (p *Post) Vote(ctx context.Context, user uid.ID, up bool) error {
 		}()
 	}
 
+	p.publishVotes()
 	return p.updatePostsTablesPoints(ctx)
 }
 
@@ -1272,7 +1573,7 @@ func (p *Post) DeleteVote(ctx context.Context, user uid.ID) error {
 		return err
 	}
 
-	query := "UPDATE posts SET points = points + ?, hotness = ?"
+	query := "UPDATE posts SET points = points + ?, hotness = ?, controversy = ?"
 	point := 1
 	newUpvotes, newDownvotes := p.Upvotes, p.Downvotes
 	if up {
@@ -1285,12 +1586,18 @@ func (p *Post) DeleteVote(ctx context.Context, user uid.ID) error {
 	}
 	query += " WHERE id = ?"
 
-	_, err = tx.ExecContext(ctx, query, point, PostHotness(newUpvotes, newDownvotes, p.CreatedAt), p.ID)
+	_, err = tx.ExecContext(ctx, query, point, PostHotness(newUpvotes, newDownvotes, p.CreatedAt),
+		PostControversy(newUpvotes, newDownvotes), p.ID)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if err = p.updateRising(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return err
 	}
@@ -1306,6 +1613,7 @@ func (p *Post) DeleteVote(ctx context.Context, user uid.ID) error {
 		incrementUserPoints(ctx, p.db, p.AuthorID, -1)
 	}
 
+	p.publishVotes()
 	return p.updatePostsTablesPoints(ctx)
 }
 
@@ -1330,13 +1638,14 @@ func (p *Post) ChangeVote(ctx context.Context, user uid.ID, up bool) error {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, "UPDATE post_votes SET up = ? WHERE id = ?", up, id)
+	// A changed vote is a new vote as far as the rising score goes.
+	_, err = tx.ExecContext(ctx, "UPDATE post_votes SET up = ?, created_at = ? WHERE id = ?", up, time.Now(), id)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	query := "UPDATE posts SET points = points + ?, hotness = ?"
+	query := "UPDATE posts SET points = points + ?, hotness = ?, controversy = ?"
 	points := 2
 	newUpvotes, newDownvotes := p.Upvotes, p.Downvotes
 	if dbUp {
@@ -1351,12 +1660,18 @@ func (p *Post) ChangeVote(ctx context.Context, user uid.ID, up bool) error {
 	}
 	query += " WHERE id = ?"
 
-	_, err = tx.ExecContext(ctx, query, points, PostHotness(newUpvotes, newDownvotes, p.CreatedAt), p.ID)
+	_, err = tx.ExecContext(ctx, query, points, PostHotness(newUpvotes, newDownvotes, p.CreatedAt),
+		PostControversy(newUpvotes, newDownvotes), p.ID)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if err = p.updateRising(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return err
 	}
@@ -1375,6 +1690,7 @@ func (p *Post) ChangeVote(ctx context.Context, user uid.ID, up bool) error {
 		incrementUserPoints(ctx, p.db, p.AuthorID, point)
 	}
 
+	p.publishVotes()
 	return p.updatePostsTablesPoints(ctx)
 }
 
@@ -1414,7 +1730,7 @@ func getComments(ctx context.Context, db *sql.DB, viewer *uid.ID, where string,
 		}
 		return ret(nil, err)
 	}
-	return ret(c, nil)
+	return ret(filterHiddenComments(db, c, viewer))
 }
 
 // CommentsCursor is an API pagination cursor.
@@ -1423,17 +1739,47 @@ type CommentsCursor struct {
 	NextID  uid.ID
 }
 
+// commentsPageClause returns the end of the where clause, starting at cursor
+// (which could be nil), of a query for a page of the comments of a post. The
+// comments of live posts are sorted newest first, and the rest by upvotes.
+func commentsPageClause(live bool, cursor *CommentsCursor) (string, []any) {
+	var (
+		clause string
+		args   []any
+	)
+	if live {
+		// Newest first. (IDs are time-ordered.)
+		if cursor != nil {
+			clause += "AND comments.id <= ? "
+			args = append(args, cursor.NextID)
+		}
+		clause += "ORDER BY comments.id DESC LIMIT ?"
+	} else {
+		if cursor != nil {
+			clause += "AND (comments.upvotes, comments.id) <= (?, ?) "
+			args = append(args, cursor.Upvotes, cursor.NextID)
+		}
+		clause += "ORDER BY upvotes DESC, comments.id DESC LIMIT ?"
+	}
+	return clause, append(args, commentsFetchLimit+1)
+}
+
 // GetComments populates c.Comments and returns the next comment's cursor.
 func (p *Post) GetComments(ctx context.Context, viewer *uid.ID, cursor *CommentsCursor) (*CommentsCursor, error) {
 	var args []any
 	where := "WHERE comments.post_id = ? "
 	args = append(args, p.ID)
-	if cursor != nil {
-		where += "AND (comments.upvotes, comments.id) <= (?, ?) "
-		args = append(args, cursor.Upvotes, cursor.NextID)
+	if cond, condArgs, err := whereNotShadowBanned(p.db, "comments", viewer); err != nil {
+		return nil, err
+	} else if cond != "" {
+		// The hidden comments with replies are shown as deleted comments (see
+		// filterHiddenComments).
+		where += "AND (comments.no_replies > 0 OR " + cond + ") "
+		args = append(args, condArgs...)
 	}
-	where += "ORDER BY upvotes DESC, comments.id DESC LIMIT ?"
-	args = append(args, commentsFetchLimit+1)
+	page, pageArgs := commentsPageClause(p.Live, cursor)
+	where += page
+	args = append(args, pageArgs...)
 
 	all, err := getComments(ctx, p.db, viewer, where, args...)
 	if err != nil {
@@ -1663,6 +2009,177 @@ func PostHotness(upvotes, downvotes int, date time.Time) int {
 	return int(math.Round(hotness * 10000000))
 }
 
+// PostControversy calculates the controversy score of a post: the more votes,
+// and the more evenly they're split between up and down, the higher the score.
+// Posts with no downvotes (or no upvotes) aren't controversial at all. (The
+// migration that added the posts.controversy column has a SQL version of this
+// function.)
+func PostControversy(upvotes, downvotes int) int {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	balance := float64(min(upvotes, downvotes)) / float64(max(upvotes, downvotes))
+	return int(math.Round(math.Pow(float64(upvotes+downvotes), balance) * 1000))
+}
+
+// risingMaxAge is the age after which posts are no longer in the rising feed.
+const risingMaxAge = time.Hour * 24
+
+// risingWindow is the window of time in which the votes of a post count towards
+// its rising score.
+const risingWindow = time.Hour * 3
+
+// risingVote is a vote as seen by postRising.
+type risingVote struct {
+	up bool
+	at time.Time // The time of the vote.
+}
+
+// postRising calculates the rising score of a post at time now from its votes:
+// the points that the post has gained in the last risingWindow, with each vote
+// counting for less the older it is (and nothing once it's older than
+// risingWindow). So the score of a post that stops getting votes decays to zero
+// within risingWindow.
+//
+// The score is updated on every vote, and, so that it decays, by
+// UpdatePostsRising. Only posts younger than risingMaxAge are ranked by it.
+func postRising(votes []risingVote, now time.Time) int {
+	score := 0.0
+	for _, vote := range votes {
+		age := now.Sub(vote.at)
+		if age < 0 {
+			age = 0
+		}
+		if age >= risingWindow {
+			continue
+		}
+		weight := 1 - float64(age)/float64(risingWindow)
+		if !vote.up {
+			weight = -weight
+		}
+		score += weight
+	}
+	return int(math.Round(score * 1000))
+}
+
+// updateRising recalculates and saves the rising score of p from the votes in
+// the database (as seen by tx).
+func (p *Post) updateRising(ctx context.Context, tx *sql.Tx) error {
+	now := time.Now()
+	if now.Sub(p.CreatedAt) > risingMaxAge {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT up, created_at FROM post_votes WHERE post_id = ? AND created_at > ?", p.ID, now.Add(-risingWindow))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var votes []risingVote
+	for rows.Next() {
+		var vote risingVote
+		if err := rows.Scan(&vote.up, &vote.at); err != nil {
+			return err
+		}
+		votes = append(votes, vote)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	p.Rising = postRising(votes, now)
+	_, err = tx.ExecContext(ctx, "UPDATE posts SET rising = ? WHERE id = ?", p.Rising, p.ID)
+	return err
+}
+
+// UpdatePostsRising recalculates the rising scores of all the posts that have
+// a non-zero score or that got votes in the last risingWindow, so that the
+// scores of posts decay as they stop getting votes. The scores of posts older
+// than risingMaxAge are zeroed. It returns the number of posts whose score
+// changed.
+func UpdatePostsRising(ctx context.Context, db *sql.DB) (int, error) {
+	now := time.Now()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT post_votes.post_id, post_votes.up, post_votes.created_at
+		FROM post_votes
+		INNER JOIN posts ON posts.id = post_votes.post_id
+		WHERE post_votes.created_at > ? AND posts.created_at > ? AND posts.deleted = FALSE`,
+		now.Add(-risingWindow), now.Add(-risingMaxAge))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	votes := make(map[uid.ID][]risingVote)
+	for rows.Next() {
+		var (
+			postID uid.ID
+			vote   risingVote
+		)
+		if err := rows.Scan(&postID, &vote.up, &vote.at); err != nil {
+			return 0, err
+		}
+		votes[postID] = append(votes[postID], vote)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	scores := make(map[uid.ID]int, len(votes))
+	for postID, postVotes := range votes {
+		scores[postID] = postRising(postVotes, now)
+	}
+
+	// The posts with a score that's now stale (this includes the posts older
+	// than risingMaxAge, which are not in scores, and so are zeroed).
+	current := make(map[uid.ID]int)
+	rows, err = db.QueryContext(ctx, "SELECT id, rising FROM posts WHERE rising <> 0")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			postID uid.ID
+			rising int
+		)
+		if err := rows.Scan(&postID, &rising); err != nil {
+			return 0, err
+		}
+		current[postID] = rising
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	update := func(postID uid.ID, score int) error {
+		if current[postID] == score {
+			return nil
+		}
+		if _, err := db.ExecContext(ctx, "UPDATE posts SET rising = ? WHERE id = ?", score, postID); err != nil {
+			return err
+		}
+		n++
+		return nil
+	}
+	for postID, score := range scores {
+		if err := update(postID, score); err != nil {
+			return n, err
+		}
+	}
+	for postID := range current {
+		if _, ok := scores[postID]; !ok {
+			if err := update(postID, 0); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
 // UpdateAllPostsHotness applies the PostHotness function to every row in the
 // posts table.
 func UpdateAllPostsHotness(ctx context.Context, db *sql.DB) error {
@@ -1800,26 +2317,47 @@ func RemoveTempImages(ctx context.Context, db *sql.DB) (int, error) {
 }
 
 // postLink is the link metadata of a link post as stored in the database.
+//
+// Version 1 links have only the URL and the Hostname fields. Version 2 links
+// also have the rest of the preview data found when the link was unfurled.
 type postLink struct {
-	Version  int    `json:"v"`
-	URL      string `json:"u"`
-	Hostname string `json:"h"`
+	Version     int        `json:"v"`
+	URL         string     `json:"u"`
+	Hostname    string     `json:"h"`
+	Title       string     `json:"t,omitempty"`
+	Description string     `json:"d,omitempty"`
+	SiteName    string     `json:"s,omitempty"`
+	Favicon     string     `json:"f,omitempty"`
+	Embed       *LinkEmbed `json:"e,omitempty"`
 }
 
 func (pl *postLink) PostLink() *PostLink {
 	return &PostLink{
-		Version:  pl.Version,
-		URL:      pl.URL,
-		Hostname: pl.Hostname,
+		Version:     pl.Version,
+		URL:         pl.URL,
+		Hostname:    pl.Hostname,
+		Title:       pl.Title,
+		Description: pl.Description,
+		SiteName:    pl.SiteName,
+		Favicon:     pl.Favicon,
+		Embed:       pl.Embed,
 	}
 }
 
 // PostLink is the object to be sent to the client.
 type PostLink struct {
-	Version  int           `json:"-"`
-	URL      string        `json:"url"`
-	Hostname string        `json:"hostname"`
-	Image    *images.Image `json:"image"`
+	Version     int           `json:"-"`
+	URL         string        `json:"url"`
+	Hostname    string        `json:"hostname"`
+	Title       string        `json:"title,omitempty"`
+	Description string        `json:"description,omitempty"`
+	SiteName    string        `json:"siteName,omitempty"`
+	Favicon     string        `json:"favicon,omitempty"`
+	Image       *images.Image `json:"image"`
+
+	// Embed is nil if the link is not from an embeddable provider, or if the
+	// viewer has turned embeds off.
+	Embed *LinkEmbed `json:"embed,omitempty"`
 }
 
 func (pl *PostLink) SetImageCopies() {
@@ -1831,6 +2369,11 @@ func (pl *PostLink) SetImageCopies() {
 	}
 }
 
+// GetSitePinnedPosts returns the posts that are pinned site-wide.
+func GetSitePinnedPosts(ctx context.Context, db *sql.DB, viewer *uid.ID) ([]*Post, error) {
+	return getPinnedPosts(ctx, db, viewer, nil)
+}
+
 // If community is null, site-wide pinned posts are returned.
 func getPinnedPosts(ctx context.Context, db *sql.DB, viewer, community *uid.ID) ([]*Post, error) {
 	var args []any
@@ -1858,5 +2401,5 @@ func getPinnedPosts(ctx context.Context, db *sql.DB, viewer, community *uid.ID)
 		}
 		return nil, err
 	}
-	return posts, err
+	return filterHiddenPosts(db, posts, viewer)
 }
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/testdb"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+func TestLiveThreadIdle(t *testing.T) {
+	now := time.Now()
+	idle := time.Hour * 6
+	tests := []struct {
+		name                      string
+		lastActivityAt, liveSince time.Time
+		want                      bool
+	}{
+		{"recent activity", now.Add(-time.Hour), now.Add(-time.Hour * 24), false},
+		{"old activity, made live long ago", now.Add(-time.Hour * 7), now.Add(-time.Hour * 24), true},
+		{"old activity, made live recently", now.Add(-time.Hour * 24), now.Add(-time.Hour), false},
+		{"old activity, made live just over idle ago", now.Add(-time.Hour * 24), now.Add(-idle - time.Minute), true},
+		{"activity just under idle ago", now.Add(-idle + time.Minute), now.Add(-time.Hour * 24), false},
+	}
+	for _, test := range tests {
+		if got := liveThreadIdle(test.lastActivityAt, test.liveSince, now, idle); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// insertTestComments adds a top-level comment by author to post for each of
+// upvotes, with that many upvotes, and returns the IDs of the comments.
+func insertTestComments(t *testing.T, db *sql.DB, post *Post, author *User, upvotes []int) []uid.ID {
+	t.Helper()
+	ids := make([]uid.ID, len(upvotes))
+	rows := make([][]msql.ColumnValue, len(upvotes))
+	for i, n := range upvotes {
+		ids[i] = uid.New()
+		rows[i] = []msql.ColumnValue{
+			{Name: "id", Value: ids[i]},
+			{Name: "post_id", Value: post.ID},
+			{Name: "post_public_id", Value: post.PublicID},
+			{Name: "community_id", Value: post.CommunityID},
+			{Name: "community_name", Value: post.CommunityName},
+			{Name: "user_id", Value: author.ID},
+			{Name: "username", Value: author.Username},
+			{Name: "body", Value: fmt.Sprintf("Comment %d", i)},
+			{Name: "upvotes", Value: n},
+			{Name: "points", Value: n},
+		}
+	}
+	query, args := msql.BuildInsertQuery("comments", rows...)
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("inserting comments: %v", err)
+	}
+	return ids
+}
+
+func TestGetCommentsPagination(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	community := newTestCommunity(t, db)
+	author := newTestUser(t, db)
+
+	// Enough comments for three pages, with ties in upvotes across pages.
+	upvotes := make([]int, commentsFetchLimit*2+50)
+	for i := range upvotes {
+		upvotes[i] = i % 7
+	}
+
+	for _, live := range []bool{false, true} {
+		post := newTestPost(t, db, author, community)
+		if live {
+			if err := post.SetLive(ctx, newTestAdmin(t, db).ID, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ids := insertTestComments(t, db, post, author, upvotes)
+
+		want := make([]int, len(ids)) // indexes into ids and upvotes
+		for i := range want {
+			want[i] = i
+		}
+		slices.SortFunc(want, func(a, b int) int {
+			if !live && upvotes[a] != upvotes[b] {
+				return upvotes[b] - upvotes[a]
+			}
+			return bytes.Compare(ids[b].Bytes(), ids[a].Bytes())
+		})
+
+		var (
+			got    []uid.ID
+			pages  int
+			cursor *CommentsCursor
+		)
+		for {
+			next, err := post.GetComments(ctx, nil, cursor)
+			if err != nil {
+				t.Fatalf("live %v: %v", live, err)
+			}
+			if pages++; pages > 10 {
+				t.Fatalf("live %v: pagination does not end", live)
+			}
+			for _, c := range post.Comments {
+				got = append(got, c.ID)
+			}
+			if next == nil {
+				break
+			}
+			cursor = next
+		}
+
+		if pages != 3 {
+			t.Errorf("live %v: got %d pages, want 3", live, pages)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("live %v: got %d comments, want %d", live, len(got), len(want))
+		}
+		for i, j := range want {
+			if got[i] != ids[j] {
+				t.Fatalf("live %v: comment %d is %v, want %v (comment %d)", live, i, got[i], ids[j], j)
+			}
+		}
+	}
+}
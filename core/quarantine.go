@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+)
+
+// Quarantine quarantines the community: its posts are left out of the
+// site-wide feed, and it's left out of community listings and search (and the
+// list of default communities). Its members can still see its posts in their
+// home feeds, and it's still reachable through its URL. Quarantining an
+// already quarantined community only updates the reason.
+func (c *Community) Quarantine(ctx context.Context, reason string) error {
+	now := time.Now()
+	if c.Quarantined {
+		now = c.QuarantinedAt.Time
+	}
+	err := msql.Transact(ctx, c.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE communities SET quarantined_at = ?, quarantine_reason = ? WHERE id = ?", now, nullString(reason), c.ID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM default_communities WHERE community_id = ?", c.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Quarantined = true
+	c.QuarantinedAt = msql.NewNullTime(now)
+	c.QuarantineReason = nullString(reason)
+	if c.IsDefault != nil {
+		*c.IsDefault = false
+	}
+	return nil
+}
+
+// Unquarantine lifts the quarantine of the community.
+func (c *Community) Unquarantine(ctx context.Context) error {
+	if _, err := c.db.ExecContext(ctx, "UPDATE communities SET quarantined_at = NULL, quarantine_reason = NULL WHERE id = ?", c.ID); err != nil {
+		return err
+	}
+	c.Quarantined = false
+	c.QuarantinedAt = msql.NullTime{}
+	c.QuarantineReason = msql.NullString{}
+	return nil
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/discuitnet/discuit/internal/pubsub"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// Real-time events are published to a pubsub.Broker, from which they're
+// streamed to the clients (see server/events.go). The events of a user (like
+// changes to the count of unseen notifications) are published to
+// UserEventsChannel, and those of a post (new and edited comments, votes, and
+// locks) to PostEventsChannel. Events carry only IDs and counts, and no
+// content, so that clients fetch the content (which might be hidden from some)
+// through the API.
+
+var realtimeBroker struct {
+	sync.RWMutex
+	broker pubsub.Broker
+}
+
+// SetRealtimeBroker sets the broker to which real-time events are published.
+// If it's not set, no events are published.
+func SetRealtimeBroker(b pubsub.Broker) {
+	realtimeBroker.Lock()
+	defer realtimeBroker.Unlock()
+	realtimeBroker.broker = b
+}
+
+func getRealtimeBroker() pubsub.Broker {
+	realtimeBroker.RLock()
+	defer realtimeBroker.RUnlock()
+	return realtimeBroker.broker
+}
+
+// UserEventsChannel returns the channel of the real-time events of user.
+func UserEventsChannel(user uid.ID) string {
+	return "user:" + user.String()
+}
+
+// PostEventsChannel returns the channel of the real-time events of post.
+func PostEventsChannel(post uid.ID) string {
+	return "post:" + post.String()
+}
+
+// RealtimeEvent is an event published to a real-time events channel.
+type RealtimeEvent struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+func publishEvent(channel, eventType string, data any) {
+	b := getRealtimeBroker()
+	if b == nil {
+		return
+	}
+	msg, err := json.Marshal(RealtimeEvent{Type: eventType, Data: data})
+	if err != nil {
+		log.Printf("Failed marshaling real-time event: %v\n", err)
+		return
+	}
+	if err := b.Publish(channel, msg); err != nil {
+		log.Printf("Failed publishing real-time event: %v\n", err)
+	}
+}
+
+// publishNotificationsCount publishes the number of unseen notifications of
+// user.
+func publishNotificationsCount(ctx context.Context, db *sql.DB, user uid.ID) {
+	if getRealtimeBroker() == nil {
+		return
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT notifications_new_count FROM users WHERE id = ?", user).Scan(&count); err != nil {
+		log.Printf("Failed getting notifications count: %v\n", err)
+		return
+	}
+	publishEvent(UserEventsChannel(user), "notifications_count", struct {
+		Count int `json:"count"`
+	}{count})
+}
+
+func publishNewComment(post, comment uid.ID, parent *uid.ID) {
+	publishEvent(PostEventsChannel(post), "new_comment", struct {
+		PostID    uid.ID  `json:"postId"`
+		CommentID uid.ID  `json:"commentId"`
+		ParentID  *uid.ID `json:"parentId"`
+	}{post, comment, parent})
+}
+
+func publishCommentEdited(post, comment uid.ID) {
+	publishEvent(PostEventsChannel(post), "comment_edited", struct {
+		PostID    uid.ID `json:"postId"`
+		CommentID uid.ID `json:"commentId"`
+	}{post, comment})
+}
+
+func publishPostLocked(post uid.ID) {
+	publishEvent(PostEventsChannel(post), "post_locked", struct {
+		PostID uid.ID `json:"postId"`
+	}{post})
+}
+
+func (p *Post) publishVotes() {
+	publishEvent(PostEventsChannel(p.ID), "post_votes", struct {
+		PostID    uid.ID `json:"postId"`
+		Upvotes   int    `json:"upvotes"`
+		Downvotes int    `json:"downvotes"`
+	}{p.ID, p.Upvotes, p.Downvotes})
+}
+
+func (c *Comment) publishVotes() {
+	publishEvent(PostEventsChannel(c.PostID), "comment_votes", struct {
+		PostID    uid.ID `json:"postId"`
+		CommentID uid.ID `json:"commentId"`
+		Upvotes   int    `json:"upvotes"`
+		Downvotes int    `json:"downvotes"`
+	}{c.PostID, c.ID, c.Upvotes, c.Downvotes})
+}
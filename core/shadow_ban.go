@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// The posts and comments of shadow banned users are hidden from everyone except
+// the users themselves and the admins, and their actions don't notify anyone.
+// Unlike a ban, the user is not told about it, so that the user doesn't just
+// create a new account.
+
+// Whether a user is shadow banned is always read from the database (and not
+// cached), so that a shadow ban takes effect right away on every server.
+
+func userShadowBanned(db *sql.DB, user uid.ID) (bool, error) {
+	var banned bool
+	err := db.QueryRow("SELECT shadow_banned_at IS NOT NULL FROM users WHERE id = ?", user).Scan(&banned)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return banned, err
+}
+
+// shadowBannedUsers returns the set of users, out of users, that are shadow
+// banned.
+func shadowBannedUsers(db *sql.DB, users []uid.ID) (map[uid.ID]bool, error) {
+	set := make(map[uid.ID]bool)
+	if len(users) == 0 {
+		return set, nil
+	}
+	args := make([]any, len(users))
+	for i := range users {
+		args[i] = users[i]
+	}
+	rows, err := db.Query("SELECT id FROM users WHERE shadow_banned_at IS NOT NULL AND id IN "+msql.InClauseQuestionMarks(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// hiddenAuthors returns the set of users, out of authors, whose posts and
+// comments are hidden from viewer (see ContentHidden). Viewer can be nil.
+func hiddenAuthors(db *sql.DB, authors []uid.ID, viewer *uid.ID) (map[uid.ID]bool, error) {
+	if admin, err := IsAdmin(db, viewer); err != nil || admin {
+		return map[uid.ID]bool{}, err
+	}
+	set, err := shadowBannedUsers(db, authors)
+	if err != nil {
+		return nil, err
+	}
+	if viewer != nil {
+		delete(set, *viewer)
+	}
+	return set, nil
+}
+
+// ShadowBan shadow bans the user, or lifts the shadow ban if ban is false.
+func (u *User) ShadowBan(ctx context.Context, ban bool) error {
+	if u.Deleted {
+		return ErrUserDeleted
+	}
+
+	var t msql.NullTime
+	if ban {
+		t = msql.NewNullTime(time.Now())
+	}
+	err := msql.Transact(ctx, u.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE users SET shadow_banned_at = ? WHERE id = ? AND (shadow_banned_at IS NOT NULL) = ?", t, u.ID, !ban)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err // Nothing changed.
+		}
+
+		// The comments of shadow banned users are not counted in the comment
+		// counts of posts (and neither are held comments).
+		op := "+"
+		if ban {
+			op = "-"
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE posts
+			INNER JOIN (SELECT post_id, COUNT(*) AS n FROM comments WHERE user_id = ? AND held_at IS NULL GROUP BY post_id) AS c ON c.post_id = posts.id
+			SET posts.no_comments = posts.no_comments `+op+` c.n`, u.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	u.ShadowBannedAt = t
+	u.ShadowBanned = ban
+	return nil
+}
+
+// ContentHidden reports whether the posts and comments of author are hidden
+// from viewer (because author is shadow banned). Viewer can be nil.
+func ContentHidden(db *sql.DB, author uid.ID, viewer *uid.ID) (bool, error) {
+	if viewer != nil && *viewer == author {
+		return false, nil
+	}
+	if banned, err := userShadowBanned(db, author); err != nil || !banned {
+		return false, err
+	}
+	admin, err := IsAdmin(db, viewer)
+	return !admin, err
+}
+
+// whereNotShadowBanned returns a condition, to be added to a where clause, that
+// leaves out the rows of table authored by shadow banned users (other than
+// viewer). It returns an empty string if no rows need to be left out.
+func whereNotShadowBanned(db *sql.DB, table string, viewer *uid.ID) (string, []any, error) {
+	if admin, err := IsAdmin(db, viewer); err != nil || admin {
+		return "", nil, err
+	}
+	if viewer == nil {
+		return table + ".user_id NOT IN (SELECT id FROM users WHERE shadow_banned_at IS NOT NULL)", nil, nil
+	}
+	return table + ".user_id NOT IN (SELECT id FROM users WHERE shadow_banned_at IS NOT NULL AND id <> ?)", []any{*viewer}, nil
+}
+
+// filterHiddenPosts removes from posts the ones that are hidden from viewer.
+func filterHiddenPosts(db *sql.DB, posts []*Post, viewer *uid.ID) ([]*Post, error) {
+	authors := make([]uid.ID, len(posts))
+	for i, post := range posts {
+		authors[i] = post.AuthorID
+	}
+	hidden, err := hiddenAuthors(db, authors, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := posts[:0]
+	for _, post := range posts {
+		if !hidden[post.AuthorID] {
+			filtered = append(filtered, post)
+		}
+	}
+	return filtered, nil
+}
+
+// filterHiddenComments removes from comments the ones that are hidden from
+// viewer. Hidden comments that have replies are kept so that the replies are
+// not orphaned, but they are made to look like deleted comments. (Queries that
+// are paginated should leave out the hidden comments without replies
+// themselves, with whereNotShadowBanned, so that pages are not cut short.)
+func filterHiddenComments(db *sql.DB, comments []*Comment, viewer *uid.ID) ([]*Comment, error) {
+	authors := make([]uid.ID, len(comments))
+	for i, comment := range comments {
+		authors[i] = comment.AuthorID
+	}
+	hidden, err := hiddenAuthors(db, authors, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := comments[:0]
+	for _, comment := range comments {
+		if hidden[comment.AuthorID] {
+			if comment.NumReplies == 0 {
+				continue
+			}
+			if !comment.Deleted {
+				comment.Deleted = true
+				comment.DeletedAt = msql.NewNullTime(comment.CreatedAt)
+				comment.DeletedAs = UserGroupNormal
+			}
+			comment.StripContent()
+		}
+		filtered = append(filtered, comment)
+	}
+	return filtered, nil
+}
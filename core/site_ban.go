@@ -0,0 +1,196 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// SiteBan is a site-wide ban of a user, as recorded in the ban history of the
+// user (the user_bans table). The current ban, if any, is also stored in the
+// users table (see User.BannedAt).
+type SiteBan struct {
+	ID               int             `json:"id"`
+	UserID           uid.ID          `json:"userId"`
+	BannedBy         uid.NullID      `json:"bannedBy"`
+	BannedByUsername msql.NullString `json:"bannedByUsername"`
+	Reason           msql.NullString `json:"reason"`
+	Note             msql.NullString `json:"note"` // Only for admins.
+	ExpiresAt        msql.NullTime   `json:"expiresAt"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	LiftedAt         msql.NullTime   `json:"liftedAt"`
+	LiftedBy         uid.NullID      `json:"liftedBy"`
+}
+
+// Ban bans the user from site. Important: Make sure to log out all sessions of
+// this user before calling this function, and never allow this user to login.
+//
+// Note: An admin can be banned.
+func (u *User) Ban(ctx context.Context) error {
+	return u.BanWithReason(ctx, uid.NullID{}, "", "", nil)
+}
+
+// BanWithReason is like Ban, but it also records who banned the user, the
+// reason for the ban (which the user gets to see), and an internal note. If
+// expires is not nil, the ban is lifted at that time (see LiftExpiredBans). If
+// the user is already banned, the current ban is replaced.
+func (u *User) BanWithReason(ctx context.Context, by uid.NullID, reason, note string, expires *time.Time) error {
+	if u.Deleted {
+		return ErrUserDeleted
+	}
+
+	now := time.Now()
+	banReason, banNote := nullString(reason), nullString(note)
+	banExpiresAt := msql.NullTime{}
+	if expires != nil {
+		banExpiresAt = msql.NewNullTime(*expires)
+	}
+
+	err := msql.Transact(ctx, u.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE user_bans SET lifted_at = ?, lifted_by = ? WHERE user_id = ? AND lifted_at IS NULL", now, by, u.ID); err != nil {
+			return err
+		}
+		query, args := msql.BuildInsertQuery("user_bans", []msql.ColumnValue{
+			{Name: "user_id", Value: u.ID},
+			{Name: "banned_by", Value: by},
+			{Name: "reason", Value: banReason},
+			{Name: "note", Value: banNote},
+			{Name: "expires_at", Value: banExpiresAt},
+			{Name: "created_at", Value: now},
+		})
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE users SET banned_at = ?, ban_reason = ?, ban_note = ?, ban_expires_at = ? WHERE id = ?",
+			now, banReason, banNote, banExpiresAt, u.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	u.BannedAt = msql.NewNullTime(now)
+	u.Banned = true
+	u.BanReason = banReason
+	u.BanNote = banNote
+	u.BanExpiresAt = banExpiresAt
+	return nil
+}
+
+// Unban lifts the ban of the user.
+func (u *User) Unban(ctx context.Context) error {
+	return u.UnbanBy(ctx, uid.NullID{})
+}
+
+// UnbanBy is like Unban, but it also records in the ban history who lifted the
+// ban.
+func (u *User) UnbanBy(ctx context.Context, by uid.NullID) error {
+	if u.Deleted {
+		return ErrUserDeleted
+	}
+
+	err := msql.Transact(ctx, u.db, func(tx *sql.Tx) error {
+		_, err := unbanUsersTx(ctx, tx, by, time.Now(), "users.id = ?", u.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	u.BannedAt = msql.NullTime{}
+	u.Banned = false
+	u.BanReason = msql.NullString{}
+	u.BanNote = msql.NullString{}
+	u.BanExpiresAt = msql.NullTime{}
+	return nil
+}
+
+// BanExpired reports whether the user is banned, and the ban has expired (but
+// has not been lifted yet).
+func (u *User) BanExpired() bool {
+	return u.Banned && u.BanExpiresAt.Valid && !u.BanExpiresAt.Time.After(time.Now())
+}
+
+// unbanUsersTx lifts the bans of the users matching where (a condition on the
+// users table), recording now as the time of lifting in the ban history. It
+// returns the number of users unbanned.
+func unbanUsersTx(ctx context.Context, tx *sql.Tx, by uid.NullID, now time.Time, where string, args ...any) (int, error) {
+	_, err := tx.ExecContext(ctx, `UPDATE user_bans SET lifted_at = ?, lifted_by = ?
+		WHERE lifted_at IS NULL AND user_id IN (SELECT users.id FROM users WHERE `+where+`)`, append([]any{now, by}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE users SET banned_at = NULL, ban_reason = NULL, ban_note = NULL, ban_expires_at = NULL WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// LiftExpiredBans lifts the site bans that have expired. It returns the number
+// of users unbanned.
+func LiftExpiredBans(ctx context.Context, db *sql.DB) (n int, err error) {
+	now := time.Now()
+	const where = "users.banned_at IS NOT NULL AND users.ban_expires_at <= ?"
+	err = msql.Transact(ctx, db, func(tx *sql.Tx) error {
+		n, err = unbanUsersTx(ctx, tx, uid.NullID{}, now, where, now)
+		return err
+	})
+	return
+}
+
+// GetUserBans returns the site ban history of user (newest first).
+func GetUserBans(ctx context.Context, db *sql.DB, user uid.ID) ([]*SiteBan, error) {
+	query := msql.BuildSelectQuery("user_bans", []string{
+		"user_bans.id",
+		"user_bans.user_id",
+		"user_bans.banned_by",
+		"users.username",
+		"user_bans.reason",
+		"user_bans.note",
+		"user_bans.expires_at",
+		"user_bans.created_at",
+		"user_bans.lifted_at",
+		"user_bans.lifted_by",
+	}, []string{
+		"LEFT JOIN users ON users.id = user_bans.banned_by",
+	}, "WHERE user_bans.user_id = ? ORDER BY user_bans.id DESC")
+
+	rows, err := db.QueryContext(ctx, query, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []*SiteBan{}
+	for rows.Next() {
+		b := &SiteBan{}
+		if err := rows.Scan(
+			&b.ID,
+			&b.UserID,
+			&b.BannedBy,
+			&b.BannedByUsername,
+			&b.Reason,
+			&b.Note,
+			&b.ExpiresAt,
+			&b.CreatedAt,
+			&b.LiftedAt,
+			&b.LiftedBy); err != nil {
+			return nil, err
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
+
+// nullString returns an invalid msql.NullString if s is empty.
+func nullString(s string) msql.NullString {
+	if s == "" {
+		return msql.NullString{}
+	}
+	return msql.NewNullString(s)
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+
+	"github.com/discuitnet/discuit/internal/httperr"
+)
+
+const siteSettingsDBKey = "site_settings" // for the key column of the application_data table
+
+// SiteSettings are the site-wide settings that admins can change at runtime.
+// Their initial values come from the config file (see GetSiteSettings).
+type SiteSettings struct {
+	DisableImagePosts      bool `json:"disableImagePosts"`
+	DisableSignups         bool `json:"disableSignups"`
+	ForumCreationReqPoints int  `json:"forumCreationReqPoints"`
+	MaxForumsPerUser       int  `json:"maxForumsPerUser"`
+}
+
+// Validate returns an httperr.Error if any of the settings are invalid.
+func (s *SiteSettings) Validate() error {
+	if s.ForumCreationReqPoints < 0 {
+		return httperr.NewBadRequest("invalid_settings", "Community creation points cannot be negative.")
+	}
+	if s.MaxForumsPerUser < 0 {
+		return httperr.NewBadRequest("invalid_settings", "Max communities per user cannot be negative.")
+	}
+	return nil
+}
+
+// siteSettingsCache holds the site settings saved in the database, so that
+// they're not read on every request that needs them. It's cleared by
+// SaveSiteSettings.
+var siteSettingsCache = struct {
+	sync.Mutex
+	loaded  bool
+	rawJSON string // Empty if the settings were never saved.
+}{}
+
+// GetSiteSettings returns the site settings saved in the database. Settings
+// that were never saved take their values from defaults.
+func GetSiteSettings(ctx context.Context, db *sql.DB, defaults SiteSettings) (*SiteSettings, error) {
+	rawJSON, err := savedSiteSettings(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	settings := defaults
+	if rawJSON == "" {
+		return &settings, nil
+	}
+	if err := json.Unmarshal([]byte(rawJSON), &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// savedSiteSettings returns the JSON of the saved site settings (or an empty
+// string if they were never saved), from siteSettingsCache if it's there.
+func savedSiteSettings(ctx context.Context, db *sql.DB) (string, error) {
+	siteSettingsCache.Lock()
+	defer siteSettingsCache.Unlock()
+	if siteSettingsCache.loaded {
+		return siteSettingsCache.rawJSON, nil
+	}
+
+	rawJSON := ""
+	row := db.QueryRowContext(ctx, "SELECT `value` FROM application_data WHERE `key` = ?", siteSettingsDBKey)
+	if err := row.Scan(&rawJSON); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	siteSettingsCache.loaded, siteSettingsCache.rawJSON = true, rawJSON
+	return rawJSON, nil
+}
+
+// SaveSiteSettings saves settings to the database.
+func SaveSiteSettings(ctx context.Context, db *sql.DB, settings *SiteSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO application_data (`key`, `value`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `value` = VALUES(`value`)", siteSettingsDBKey, string(data))
+
+	siteSettingsCache.Lock()
+	siteSettingsCache.loaded = false
+	siteSettingsCache.Unlock()
+	return err
+}
@@ -0,0 +1,404 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// The sitemap protocol allows at most 50,000 URLs per file. Posts are sharded
+// into files of sitemapShardSize consecutive posts (ordered by ID) so that only
+// the shards that have changed need to be regenerated.
+const (
+	sitemapShardSize = 10000
+
+	// A shard is regenerated in full at least this often, regardless of
+	// whether any of its posts have changed (to catch community changes, like
+	// communities being marked NSFW).
+	sitemapMaxAge = time.Hour * 24
+)
+
+// SitemapType is the type of content a sitemap shard lists.
+type SitemapType string
+
+const (
+	SitemapTypeCommunities = SitemapType("communities")
+	SitemapTypePosts       = SitemapType("posts")
+)
+
+// Valid reports whether t is a supported sitemap type.
+func (t SitemapType) Valid() bool {
+	return t == SitemapTypeCommunities || t == SitemapTypePosts
+}
+
+// SitemapURL is a single entry of a sitemap. Loc is a path relative to the
+// root of the site.
+type SitemapURL struct {
+	Loc     string    `json:"l"`
+	LastMod time.Time `json:"m,omitempty"`
+}
+
+// Sitemap is a single shard of the site's sitemap.
+type Sitemap struct {
+	Type        SitemapType
+	Shard       int
+	FirstID     uid.NullID
+	LastID      uid.NullID
+	NumItems    int // Number of rows the shard covers (including excluded rows).
+	URLs        []SitemapURL
+	LastMod     msql.NullTime
+	GeneratedAt time.Time
+}
+
+// Filename returns the name of the file the shard is served as.
+func (s *Sitemap) Filename() string {
+	return fmt.Sprintf("%s-%d.xml", s.Type, s.Shard)
+}
+
+func (s *Sitemap) full() bool {
+	return s.NumItems >= sitemapShardSize
+}
+
+func (s *Sitemap) stale(now time.Time) bool {
+	return now.Sub(s.GeneratedAt) > sitemapMaxAge
+}
+
+var selectSitemapCols = []string{
+	"type",
+	"shard",
+	"first_id",
+	"last_id",
+	"no_items",
+	"urls",
+	"lastmod",
+	"generated_at",
+}
+
+func getSitemaps(ctx context.Context, db *sql.DB, withURLs bool, where string, args ...any) ([]*Sitemap, error) {
+	cols := selectSitemapCols
+	if !withURLs {
+		cols = make([]string, len(selectSitemapCols))
+		copy(cols, selectSitemapCols)
+		cols[5] = "''"
+	}
+	query := msql.BuildSelectQuery("sitemaps", cols, nil, where)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var maps []*Sitemap
+	for rows.Next() {
+		s := &Sitemap{}
+		var urls []byte
+		if err := rows.Scan(&s.Type, &s.Shard, &s.FirstID, &s.LastID, &s.NumItems, &urls, &s.LastMod, &s.GeneratedAt); err != nil {
+			return nil, err
+		}
+		if len(urls) > 0 {
+			if err := json.Unmarshal(urls, &s.URLs); err != nil {
+				return nil, err
+			}
+		}
+		maps = append(maps, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return maps, nil
+}
+
+// GetSitemaps returns all sitemap shards, without their URLs, ordered by type
+// and shard number.
+func GetSitemaps(ctx context.Context, db *sql.DB) ([]*Sitemap, error) {
+	return getSitemaps(ctx, db, false, "ORDER BY type, shard")
+}
+
+// GetSitemap returns the sitemap shard, with its URLs.
+func GetSitemap(ctx context.Context, db *sql.DB, t SitemapType, shard int) (*Sitemap, error) {
+	maps, err := getSitemaps(ctx, db, true, "WHERE type = ? AND shard = ?", t, shard)
+	if err != nil {
+		return nil, err
+	}
+	if len(maps) == 0 {
+		return nil, errSitemapNotFound
+	}
+	return maps[0], nil
+}
+
+func (s *Sitemap) save(ctx context.Context, db *sql.DB) error {
+	urls, err := json.Marshal(s.URLs)
+	if err != nil {
+		return err
+	}
+	s.LastMod = msql.NullTime{}
+	for _, u := range s.URLs {
+		if !u.LastMod.IsZero() && (!s.LastMod.Valid || u.LastMod.After(s.LastMod.Time)) {
+			s.LastMod = msql.NewNullTime(u.LastMod)
+		}
+	}
+	query := `INSERT INTO sitemaps (type, shard, first_id, last_id, no_items, urls, lastmod, generated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE first_id = VALUES(first_id), last_id = VALUES(last_id), no_items = VALUES(no_items),
+			urls = VALUES(urls), lastmod = VALUES(lastmod), generated_at = VALUES(generated_at)`
+	_, err = db.ExecContext(ctx, query, s.Type, s.Shard, s.FirstID, s.LastID, s.NumItems, urls, s.LastMod, s.GeneratedAt)
+	return err
+}
+
+// UpdateSitemaps regenerates the sitemap shards that are out of date and
+// creates new shards for posts that aren't yet in any. It returns the number of
+// shards that were (re)generated.
+//
+// NSFW and deleted communities, and deleted posts and posts of such
+// communities, are excluded from the sitemap.
+func UpdateSitemaps(ctx context.Context, db *sql.DB) (int, error) {
+	n, err := updateCommunitiesSitemaps(ctx, db)
+	if err != nil {
+		return n, fmt.Errorf("communities sitemap: %w", err)
+	}
+	m, err := updatePostsSitemaps(ctx, db)
+	if err != nil {
+		return n + m, fmt.Errorf("posts sitemap: %w", err)
+	}
+	return n + m, nil
+}
+
+// updateCommunitiesSitemaps regenerates all the community shards. There are
+// comparatively few communities, so no attempt is made to do this
+// incrementally.
+func updateCommunitiesSitemaps(ctx context.Context, db *sql.DB) (int, error) {
+	now := time.Now()
+	query := "SELECT name FROM communities WHERE deleted_at IS NULL AND nsfw = false ORDER BY created_at"
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var urls []SitemapURL
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return 0, err
+		}
+		urls = append(urls, SitemapURL{Loc: "/" + url.PathEscape(name)})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	shards := 0
+	for i := 0; i == 0 || i < len(urls); i += sitemapShardSize {
+		s := &Sitemap{
+			Type:        SitemapTypeCommunities,
+			Shard:       shards,
+			URLs:        urls[i:min(i+sitemapShardSize, len(urls))],
+			GeneratedAt: now,
+		}
+		s.NumItems = len(s.URLs)
+		if err := s.save(ctx, db); err != nil {
+			return shards, err
+		}
+		shards++
+	}
+
+	_, err = db.ExecContext(ctx, "DELETE FROM sitemaps WHERE type = ? AND shard >= ?", SitemapTypeCommunities, shards)
+	return shards, err
+}
+
+func updatePostsSitemaps(ctx context.Context, db *sql.DB) (int, error) {
+	now := time.Now()
+	shards, err := getSitemaps(ctx, db, false, "WHERE type = ? ORDER BY shard", SitemapTypePosts)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, s := range shards {
+		if !s.full() {
+			// Only the last shard can be partially filled; it's handled below.
+			break
+		}
+		if !s.stale(now) {
+			if changed, err := sitemapPostsChanged(ctx, db, s); err != nil {
+				return n, err
+			} else if !changed {
+				continue
+			}
+		}
+		// A full shard keeps its bounds, even if some of its posts were
+		// removed from the database since.
+		first, last := s.FirstID, s.LastID
+		if err := s.fill(ctx, db, "WHERE posts.id BETWEEN ? AND ?", first, last); err != nil {
+			return n, err
+		}
+		s.FirstID, s.LastID, s.NumItems = first, last, sitemapShardSize
+		s.GeneratedAt = now
+		if err := s.save(ctx, db); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	// Refill the last shard, if it's not full, and create new shards for any
+	// remaining posts.
+	var (
+		last  *Sitemap
+		after uid.NullID // The ID of the last post in the last full shard.
+	)
+	if k := len(shards); k > 0 {
+		if shards[k-1].full() {
+			after = shards[k-1].LastID
+		} else {
+			last = shards[k-1]
+			if k > 1 {
+				after = shards[k-2].LastID
+			}
+		}
+	}
+	for {
+		if last == nil {
+			last = &Sitemap{Type: SitemapTypePosts, Shard: len(shards)}
+			shards = append(shards, last)
+		}
+		if after.Valid {
+			err = last.fill(ctx, db, "WHERE posts.id > ? ORDER BY posts.id LIMIT ?", after, sitemapShardSize)
+		} else {
+			err = last.fill(ctx, db, "ORDER BY posts.id LIMIT ?", sitemapShardSize)
+		}
+		if err != nil {
+			return n, err
+		}
+		if last.NumItems == 0 && last.Shard > 0 {
+			return n, nil // No new posts.
+		}
+		last.GeneratedAt = now
+		if err := last.save(ctx, db); err != nil {
+			return n, err
+		}
+		n++
+		if !last.full() {
+			return n, nil
+		}
+		after, last = last.LastID, nil
+	}
+}
+
+// sitemapPostsChanged reports whether any of the posts covered by s were
+// updated, commented on, or deleted since s was generated.
+func sitemapPostsChanged(ctx context.Context, db *sql.DB, s *Sitemap) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM posts WHERE id BETWEEN ? AND ?
+		AND (last_activity_at > ? OR edited_at > ? OR deleted_at > ?)`
+	t := s.GeneratedAt
+	if err := db.QueryRowContext(ctx, query, s.FirstID, s.LastID, t, t, t).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// fill loads the URLs of s from the posts selected by where. FirstID, LastID,
+// and NumItems are set according to the rows read.
+func (s *Sitemap) fill(ctx context.Context, db *sql.DB, where string, args ...any) error {
+	query := msql.BuildSelectQuery("posts", []string{
+		"posts.id",
+		"posts.public_id",
+		"communities.name",
+		"posts.last_activity_at",
+		"posts.deleted",
+		"communities.nsfw OR communities.deleted_at IS NOT NULL",
+	}, []string{"INNER JOIN communities ON communities.id = posts.community_id"}, where)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.URLs, s.NumItems = nil, 0
+	s.FirstID, s.LastID = uid.NullID{}, uid.NullID{}
+	for rows.Next() {
+		var (
+			id                uid.ID
+			publicID, name    string
+			lastActivity      time.Time
+			deleted, excluded bool
+		)
+		if err := rows.Scan(&id, &publicID, &name, &lastActivity, &deleted, &excluded); err != nil {
+			return err
+		}
+		if !s.FirstID.Valid {
+			s.FirstID = uid.NullID{Valid: true, ID: id}
+		}
+		s.LastID = uid.NullID{Valid: true, ID: id}
+		s.NumItems++
+		if deleted || excluded {
+			continue
+		}
+		s.URLs = append(s.URLs, SitemapURL{
+			Loc:     "/" + url.PathEscape(name) + "/post/" + publicID,
+			LastMod: lastActivity,
+		})
+	}
+	return rows.Err()
+}
+
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// WriteSitemapIndex writes the sitemap index file, listing all the shards in
+// maps, to w. baseURL is the absolute URL of the site's root (without a
+// trailing slash) and prefix the path under which shards are served.
+func WriteSitemapIndex(w io.Writer, maps []*Sitemap, baseURL, prefix string) error {
+	type sitemap struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod,omitempty"`
+	}
+	index := struct {
+		XMLName  xml.Name  `xml:"sitemapindex"`
+		XMLNS    string    `xml:"xmlns,attr"`
+		Sitemaps []sitemap `xml:"sitemap"`
+	}{XMLNS: sitemapXMLNS}
+	for _, s := range maps {
+		item := sitemap{Loc: baseURL + prefix + s.Filename()}
+		if s.LastMod.Valid {
+			item.LastMod = s.LastMod.Time.UTC().Format(time.RFC3339)
+		}
+		index.Sitemaps = append(index.Sitemaps, item)
+	}
+	return writeXML(w, index)
+}
+
+// WriteXML writes the shard as a sitemap urlset to w.
+func (s *Sitemap) WriteXML(w io.Writer, baseURL string) error {
+	type entry struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod,omitempty"`
+	}
+	set := struct {
+		XMLName xml.Name `xml:"urlset"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		URLs    []entry  `xml:"url"`
+	}{XMLNS: sitemapXMLNS}
+	for _, u := range s.URLs {
+		item := entry{Loc: baseURL + u.Loc}
+		if !u.LastMod.IsZero() {
+			item.LastMod = u.LastMod.UTC().Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, item)
+	}
+	return writeXML(w, set)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,549 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/discuitnet/discuit/internal/httperr"
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	spamVelocityWindow  = time.Minute * 10
+	spamDuplicateWindow = time.Hour * 24
+	contentHashesMaxAge = time.Hour * 24 * 7
+
+	// Texts shorter than this (in bytes, after normalization) are not checked
+	// for duplicates; short replies are repeated all the time.
+	minDuplicateCheckLength = 24
+)
+
+// SpamSignals are the signals, about a new post or comment and its author,
+// that a SpamClassifier scores.
+type SpamSignals struct {
+	Author    uid.ID
+	Community uid.ID
+	Text      string // Title and body (of posts) or body (of comments).
+
+	AccountAge time.Duration
+	Points     int
+
+	// LinkDomains are the hostnames of the links in the content.
+	LinkDomains []string
+
+	// DomainCount is the highest number of times, among LinkDomains, that a
+	// domain was linked to on the site in the last 24 hours.
+	DomainCount int
+
+	// DuplicateCount is the number of times the same text was posted on the
+	// site in the last 24 hours.
+	DuplicateCount int
+
+	// RecentCount is the number of posts and comments created by the author in
+	// the last 10 minutes.
+	RecentCount int
+
+	// SharedIPAccounts is the number of other accounts seen on any of the IP
+	// addresses of the author, and SharedIPBannedAccounts is how many of them
+	// are banned.
+	SharedIPAccounts       int
+	SharedIPBannedAccounts int
+}
+
+// SpamScore is the result of a SpamClassifier. Scores are integers that
+// start at 0, where 100 means almost certainly spam.
+type SpamScore struct {
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+func (s *SpamScore) add(points int, reason string) {
+	s.Score += points
+	s.Reasons = append(s.Reasons, reason)
+}
+
+// SpamClassifier scores new content for how likely it is to be spam.
+type SpamClassifier interface {
+	Classify(ctx context.Context, s *SpamSignals) (*SpamScore, error)
+}
+
+// HeuristicSpamClassifier is the default SpamClassifier. It adds up fixed
+// amounts for each suspicious signal.
+type HeuristicSpamClassifier struct{}
+
+func (HeuristicSpamClassifier) Classify(ctx context.Context, s *SpamSignals) (*SpamScore, error) {
+	score := &SpamScore{}
+	newAccount := s.AccountAge < time.Hour*24*7
+
+	if s.AccountAge < time.Hour*24 {
+		score.add(25, "Account is less than a day old.")
+	} else if newAccount {
+		score.add(10, "Account is less than a week old.")
+	}
+
+	if s.Points < 0 {
+		score.add(20, "Author has negative points.")
+	} else if s.Points <= 1 && newAccount {
+		score.add(10, "Author has no points.")
+	}
+
+	if s.SharedIPBannedAccounts > 0 {
+		score.add(min(30+15*(s.SharedIPBannedAccounts-1), 60), fmt.Sprintf("Author shares an IP address with %d banned account(s).", s.SharedIPBannedAccounts))
+	} else if s.SharedIPAccounts >= 3 {
+		score.add(10, fmt.Sprintf("Author shares an IP address with %d other accounts.", s.SharedIPAccounts))
+	}
+
+	if s.DuplicateCount >= 3 {
+		score.add(40, fmt.Sprintf("Same text was posted %d times in the last day.", s.DuplicateCount))
+	} else if s.DuplicateCount > 0 {
+		score.add(20, "Same text was posted in the last day.")
+	}
+
+	if newAccount && len(s.LinkDomains) > 0 {
+		score.add(min(10*len(s.LinkDomains), 30), "New account posting links.")
+		if s.DomainCount >= 5 {
+			score.add(20, fmt.Sprintf("Linked domain was linked to %d times in the last day.", s.DomainCount))
+		}
+	}
+
+	if s.RecentCount >= 10 {
+		score.add(40, fmt.Sprintf("Author posted %d times in the last 10 minutes.", s.RecentCount))
+	} else if s.RecentCount >= 5 {
+		score.add(20, fmt.Sprintf("Author posted %d times in the last 10 minutes.", s.RecentCount))
+	}
+
+	if s.AccountAge > time.Hour*24*30 && s.Points > 100 && score.Score > 0 {
+		score.add(-min(30, score.Score), "Author is an established user.")
+	}
+
+	return score, nil
+}
+
+// SpamOptions configure the scoring of new content. Content with a score of
+// FlagScore or more is listed for admins to review, HoldScore or more is also
+// held for review by the mods, and ShadowLimitScore or more shadow limits its
+// author. A threshold of 0 disables the corresponding action.
+type SpamOptions struct {
+	Classifier       SpamClassifier
+	FlagScore        int
+	HoldScore        int
+	ShadowLimitScore int
+}
+
+var spamOptions = struct {
+	sync.RWMutex
+	SpamOptions
+}{
+	SpamOptions: SpamOptions{
+		Classifier:       HeuristicSpamClassifier{},
+		FlagScore:        40,
+		HoldScore:        60,
+		ShadowLimitScore: 90,
+	},
+}
+
+// SetSpamOptions sets the options used to score new content. If
+// opts.Classifier is nil, the HeuristicSpamClassifier is used.
+func SetSpamOptions(opts SpamOptions) {
+	if opts.Classifier == nil {
+		opts.Classifier = HeuristicSpamClassifier{}
+	}
+	spamOptions.Lock()
+	defer spamOptions.Unlock()
+	spamOptions.SpamOptions = opts
+}
+
+func getSpamOptions() SpamOptions {
+	spamOptions.RLock()
+	defer spamOptions.RUnlock()
+	return spamOptions.SpamOptions
+}
+
+// contentHash returns the hash of text used to detect duplicate content, or
+// nil if text is too short to be checked.
+func contentHash(text string) []byte {
+	text = strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	if len(text) < minDuplicateCheckLength {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(text))
+	return sum[:]
+}
+
+// domainHash returns the hash under which links to hostname are recorded.
+func domainHash(hostname string) []byte {
+	sum := sha256.Sum256([]byte("domain:" + strings.TrimPrefix(strings.ToLower(hostname), "www.")))
+	return sum[:]
+}
+
+func countContentHashes(ctx context.Context, db *sql.DB, hash []byte, since time.Time) (n int, err error) {
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM content_hashes WHERE hash = ? AND created_at > ?", hash, since).Scan(&n)
+	return
+}
+
+// recordContentHashes saves the hashes of text and of the domains of links,
+// so that later content can be checked against them.
+func recordContentHashes(ctx context.Context, db *sql.DB, author uid.ID, text string, hostnames []string) error {
+	var rows [][]msql.ColumnValue
+	now := time.Now()
+	add := func(hash []byte) {
+		rows = append(rows, []msql.ColumnValue{
+			{Name: "hash", Value: hash},
+			{Name: "user_id", Value: author},
+			{Name: "created_at", Value: now},
+		})
+	}
+	if hash := contentHash(text); hash != nil {
+		add(hash)
+	}
+	for _, h := range hostnames {
+		add(domainHash(h))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	query, args := msql.BuildInsertQuery("content_hashes", rows...)
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// PurgeContentHashes removes the content hashes that are too old to be used
+// for duplicate checks.
+func PurgeContentHashes(ctx context.Context, db *sql.DB) (int, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM content_hashes WHERE created_at < ?", time.Now().Add(-contentHashesMaxAge))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// gatherSpamSignals collects the signals of a new post or comment of author.
+func gatherSpamSignals(ctx context.Context, db *sql.DB, community, author uid.ID, text string, hostnames []string) (*SpamSignals, error) {
+	s := &SpamSignals{
+		Author:      author,
+		Community:   community,
+		Text:        text,
+		LinkDomains: hostnames,
+	}
+
+	var createdAt time.Time
+	if err := db.QueryRowContext(ctx, "SELECT created_at, points FROM users WHERE id = ?", author).Scan(&createdAt, &s.Points); err != nil {
+		return nil, err
+	}
+	s.AccountAge = time.Since(createdAt)
+
+	since := time.Now().Add(-spamDuplicateWindow)
+	if hash := contentHash(text); hash != nil {
+		n, err := countContentHashes(ctx, db, hash, since)
+		if err != nil {
+			return nil, err
+		}
+		s.DuplicateCount = n
+	}
+	for _, h := range hostnames {
+		n, err := countContentHashes(ctx, db, domainHash(h), since)
+		if err != nil {
+			return nil, err
+		}
+		s.DomainCount = max(s.DomainCount, n)
+	}
+
+	since = time.Now().Add(-spamVelocityWindow)
+	row := db.QueryRowContext(ctx, `SELECT
+		(SELECT COUNT(*) FROM posts WHERE user_id = ? AND created_at > ?) +
+		(SELECT COUNT(*) FROM comments WHERE user_id = ? AND created_at > ?)`, author, since, author, since)
+	if err := row.Scan(&s.RecentCount); err != nil {
+		return nil, err
+	}
+
+	row = db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT users.id), COUNT(DISTINCT IF(users.banned_at IS NULL, NULL, users.id))
+		FROM user_ips AS others
+		INNER JOIN users ON users.id = others.user_id
+		WHERE others.user_id <> ? AND others.ip_hash IN (SELECT ip_hash FROM user_ips WHERE user_id = ?)`, author, author)
+	if err := row.Scan(&s.SharedIPAccounts, &s.SharedIPBannedAccounts); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SpamAction is the action taken on content because of its spam score.
+type SpamAction int
+
+const (
+	SpamActionNone = SpamAction(iota)
+	SpamActionHold
+	SpamActionShadowLimit // The content is held and the author shadow limited.
+)
+
+func (a SpamAction) MarshalText() ([]byte, error) {
+	switch a {
+	case SpamActionNone:
+		return []byte("none"), nil
+	case SpamActionHold:
+		return []byte("hold"), nil
+	case SpamActionShadowLimit:
+		return []byte("shadow_limit"), nil
+	}
+	return nil, errors.New("unsupported spam action")
+}
+
+func (a *SpamAction) UnmarshalText(data []byte) error {
+	switch string(data) {
+	case "none":
+		*a = SpamActionNone
+	case "hold":
+		*a = SpamActionHold
+	case "shadow_limit":
+		*a = SpamActionShadowLimit
+	default:
+		return errors.New("unsupported spam action")
+	}
+	return nil
+}
+
+// SpamFlag is a post or comment that scored high enough to be listed for
+// admins to review.
+type SpamFlag struct {
+	ID          uid.ID        `json:"id"`
+	TargetType  ContentType   `json:"targetType"`
+	TargetID    uid.ID        `json:"targetId"`
+	CommunityID uid.ID        `json:"communityId"`
+	UserID      uid.ID        `json:"userId"`
+	Score       int           `json:"score"`
+	Reasons     []string      `json:"reasons"`
+	Action      SpamAction    `json:"action"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	ReviewedAt  msql.NullTime `json:"reviewedAt"`
+	ReviewedBy  uid.NullID    `json:"reviewedBy"`
+
+	// Only one of these is set, depending on TargetType.
+	Post    *Post    `json:"post,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+var selectSpamFlagCols = []string{
+	"spam_flags.id",
+	"spam_flags.target_type",
+	"spam_flags.target_id",
+	"spam_flags.community_id",
+	"spam_flags.user_id",
+	"spam_flags.score",
+	"spam_flags.reasons",
+	"spam_flags.action",
+	"spam_flags.created_at",
+	"spam_flags.reviewed_at",
+	"spam_flags.reviewed_by",
+}
+
+func getSpamFlags(ctx context.Context, db *sql.DB, where string, args ...any) ([]*SpamFlag, error) {
+	query := msql.BuildSelectQuery("spam_flags", selectSpamFlagCols, nil, where)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []*SpamFlag{}
+	for rows.Next() {
+		f := &SpamFlag{}
+		var reasons []byte
+		if err := rows.Scan(&f.ID, &f.TargetType, &f.TargetID, &f.CommunityID, &f.UserID, &f.Score, &reasons,
+			&f.Action, &f.CreatedAt, &f.ReviewedAt, &f.ReviewedBy); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(reasons, &f.Reasons); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// GetSpamFlag returns the spam flag with the given id.
+func GetSpamFlag(ctx context.Context, db *sql.DB, id uid.ID) (*SpamFlag, error) {
+	flags, err := getSpamFlags(ctx, db, "WHERE spam_flags.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(flags) == 0 {
+		return nil, errSpamFlagNotFound
+	}
+	return flags[0], nil
+}
+
+// GetSpamFlags returns a page of spam flags (newest first), with their posts
+// and comments populated, and the total number of such flags. If pending is
+// true, only flags that are yet to be reviewed are returned.
+func GetSpamFlags(ctx context.Context, db *sql.DB, viewer uid.ID, pending bool, limit, page int) (int, []*SpamFlag, error) {
+	where := ""
+	if pending {
+		where = "WHERE spam_flags.reviewed_at IS NULL"
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM spam_flags "+where).Scan(&count); err != nil {
+		return 0, nil, err
+	}
+
+	flags, err := getSpamFlags(ctx, db, where+" ORDER BY spam_flags.created_at DESC LIMIT ? OFFSET ?", limit, limit*(page-1))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var postIDs, commentIDs []uid.ID
+	for _, f := range flags {
+		if f.TargetType == ContentTypePost {
+			postIDs = append(postIDs, f.TargetID)
+		} else {
+			commentIDs = append(commentIDs, f.TargetID)
+		}
+	}
+	posts, err := GetPostsByIDs(ctx, db, &viewer, true, postIDs...)
+	if err != nil && err != errPostNotFound {
+		return 0, nil, err
+	}
+	comments, err := GetCommentsByIDs(ctx, db, &viewer, commentIDs...)
+	if err != nil && err != errCommentNotFound {
+		return 0, nil, err
+	}
+	for _, f := range flags {
+		for _, p := range posts {
+			if f.TargetType == ContentTypePost && p.ID == f.TargetID {
+				f.Post = p
+			}
+		}
+		for _, c := range comments {
+			if f.TargetType == ContentTypeComment && c.ID == f.TargetID {
+				f.Comment = c
+			}
+		}
+	}
+
+	return count, flags, nil
+}
+
+// Review marks the flag as reviewed by admin.
+func (f *SpamFlag) Review(ctx context.Context, db *sql.DB, admin uid.ID) error {
+	if f.ReviewedAt.Valid {
+		return httperr.NewBadRequest("already_reviewed", "Spam flag is already reviewed.")
+	}
+	now := time.Now()
+	if _, err := db.ExecContext(ctx, "UPDATE spam_flags SET reviewed_at = ?, reviewed_by = ? WHERE id = ?", now, admin, f.ID); err != nil {
+		return err
+	}
+	f.ReviewedAt = msql.NewNullTime(now)
+	f.ReviewedBy = uid.NullID{Valid: true, ID: admin}
+	return nil
+}
+
+func createSpamFlag(ctx context.Context, db *sql.DB, t ContentType, target, community, author uid.ID, score *SpamScore, action SpamAction) error {
+	reasons, err := json.Marshal(score.Reasons)
+	if err != nil {
+		return err
+	}
+	query, args := msql.BuildInsertQuery("spam_flags", []msql.ColumnValue{
+		{Name: "id", Value: uid.New()},
+		{Name: "target_type", Value: t},
+		{Name: "target_id", Value: target},
+		{Name: "community_id", Value: community},
+		{Name: "user_id", Value: author},
+		{Name: "score", Value: score.Score},
+		{Name: "reasons", Value: reasons},
+		{Name: "action", Value: action},
+		{Name: "created_at", Value: time.Now()},
+	})
+	_, err = db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// userShadowLimited reports whether user is shadow limited. All new posts
+// and comments of shadow limited users are held for review, but they're shown
+// as usual to the users themselves.
+func userShadowLimited(ctx context.Context, db *sql.DB, user uid.ID) (bool, error) {
+	var limited bool
+	if err := db.QueryRowContext(ctx, "SELECT shadow_limited_at IS NOT NULL FROM users WHERE id = ?", user).Scan(&limited); err != nil {
+		return false, err
+	}
+	return limited, nil
+}
+
+// SetUserShadowLimited shadow limits user, or lifts the limit if limit is
+// false.
+func SetUserShadowLimited(ctx context.Context, db *sql.DB, user uid.ID, limit bool) error {
+	var value any
+	if limit {
+		value = time.Now()
+	}
+	_, err := db.ExecContext(ctx, "UPDATE users SET shadow_limited_at = ? WHERE id = ?", value, user)
+	return err
+}
+
+// scoreSpam scores new content of author and updates v accordingly. Admins and
+// the mods of community are exempt.
+func (v *contentVerdict) scoreSpam(ctx context.Context, db *sql.DB, community, author uid.ID, text string, links []string) error {
+	if ok, err := UserModOrAdmin(ctx, db, community, author); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+
+	v.newContent = true
+	v.author = author
+	v.text = text
+	for _, h := range linkHostnames(text, links) {
+		h = strings.ToLower(h)
+		if !slices.Contains(v.hostnames, h) {
+			v.hostnames = append(v.hostnames, h)
+		}
+	}
+
+	if limited, err := userShadowLimited(ctx, db, author); err != nil {
+		return err
+	} else if limited {
+		v.hold = true
+		v.holdReason = "The author is shadow limited."
+		return nil
+	}
+
+	signals, err := gatherSpamSignals(ctx, db, community, author, text, v.hostnames)
+	if err != nil {
+		return err
+	}
+	opts := getSpamOptions()
+	score, err := opts.Classifier.Classify(ctx, signals)
+	if err != nil {
+		// Don't block posting when the classifier fails.
+		log.Printf("Spam classifier error: %v\n", err)
+		return nil
+	}
+
+	if opts.HoldScore > 0 && score.Score >= opts.HoldScore {
+		v.spamAction = SpamActionHold
+		if !v.hold {
+			v.hold = true
+			v.holdReason = fmt.Sprintf("Likely spam (score %d).", score.Score)
+		}
+	}
+	if opts.ShadowLimitScore > 0 && score.Score >= opts.ShadowLimitScore {
+		v.spamAction = SpamActionShadowLimit
+	}
+	if v.spamAction != SpamActionNone || (opts.FlagScore > 0 && score.Score >= opts.FlagScore) {
+		v.spamScore = score
+	}
+	return nil
+}
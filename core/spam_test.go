@@ -0,0 +1,49 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestContentHash(t *testing.T) {
+	a := contentHash("Buy cheap watches at our  store today!")
+	b := contentHash("buy cheap WATCHES at our store\ntoday!")
+	if a == nil || !bytes.Equal(a, b) {
+		t.Errorf("texts differing only in case and whitespace should have the same hash")
+	}
+	if contentHash("Thanks!") != nil {
+		t.Errorf("short texts should not be hashed")
+	}
+}
+
+func TestHeuristicSpamClassifier(t *testing.T) {
+	cases := []struct {
+		name     string
+		signals  SpamSignals
+		minScore int
+		maxScore int
+	}{
+		{"established user", SpamSignals{AccountAge: time.Hour * 24 * 365, Points: 500}, 0, 0},
+		{"new user", SpamSignals{AccountAge: time.Hour, Points: 1}, 30, 40},
+		{"new user spamming links", SpamSignals{
+			AccountAge:     time.Hour,
+			Points:         1,
+			LinkDomains:    []string{"spam.example", "spam2.example", "spam3.example"},
+			DomainCount:    10,
+			DuplicateCount: 5,
+			RecentCount:    12,
+		}, 100, 200},
+		{"banned accounts on ip", SpamSignals{AccountAge: time.Hour * 24 * 10, Points: 5, SharedIPBannedAccounts: 2}, 45, 45},
+	}
+	for _, item := range cases {
+		score, err := HeuristicSpamClassifier{}.Classify(context.Background(), &item.signals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score.Score < item.minScore || score.Score > item.maxScore {
+			t.Errorf("%s: score %d not in [%d, %d] (%v)", item.name, score.Score, item.minScore, item.maxScore, score.Reasons)
+		}
+	}
+}
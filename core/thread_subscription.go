@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// Users are subscribed to the threads of their own posts and comments by
+// default: the author of a post is notified of new comments on it (see
+// NotificationNewComment), and the author of a comment is notified of replies
+// to it (see NotificationCommentReply). Besides these, users can follow any
+// post, or any comment subtree, to be notified of new comments in it (see
+// NotificationThreadReply), and they can unfollow the threads they're
+// subscribed to by default.
+
+// ThreadSubscription is a thread (a post, or a comment subtree) that a user has
+// either followed or unfollowed.
+type ThreadSubscription struct {
+	ID         int       `json:"id"`
+	UserID     uid.ID    `json:"-"`
+	PostID     uid.ID    `json:"postId"`
+	CommentID  *uid.ID   `json:"commentId"` // Nil if the thread is the whole post.
+	Subscribed bool      `json:"subscribed"`
+	CreatedAt  time.Time `json:"createdAt"`
+
+	Post *Post `json:"post,omitempty"`
+}
+
+// SubscribeToThread makes user follow (or, if subscribe is false, unfollow)
+// the thread of post, or the thread of comment (a comment of post), if comment
+// is not nil.
+func SubscribeToThread(ctx context.Context, db *sql.DB, user, post uid.ID, comment *uid.ID, subscribe bool) error {
+	if _, err := GetPost(ctx, db, &post, "", nil, false); err != nil {
+		return err
+	}
+	target := post
+	if comment != nil {
+		c, err := GetComment(ctx, db, *comment, nil)
+		if err != nil {
+			return err
+		}
+		if c.PostID != post {
+			return errCommentNotFound
+		}
+		target = c.ID
+	}
+
+	_, err := db.ExecContext(ctx, `INSERT INTO thread_subscriptions (user_id, post_id, comment_id, target_id, subscribed) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE subscribed = ?`, user, post, comment, target, subscribe, subscribe)
+	return err
+}
+
+// GetThreadSubscriptions returns the threads that user has followed or
+// unfollowed (newest first). If fillPosts is true, the Post field of each
+// subscription is populated.
+func GetThreadSubscriptions(ctx context.Context, db *sql.DB, user uid.ID, fillPosts bool) ([]*ThreadSubscription, error) {
+	query := msql.BuildSelectQuery("thread_subscriptions", []string{
+		"id",
+		"user_id",
+		"post_id",
+		"comment_id",
+		"subscribed",
+		"created_at",
+	}, nil, "WHERE user_id = ? ORDER BY id DESC")
+	rows, err := db.QueryContext(ctx, query, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []*ThreadSubscription{}
+	var postIDs []uid.ID
+	for rows.Next() {
+		sub := &ThreadSubscription{}
+		var comment uid.NullID
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.PostID, &comment, &sub.Subscribed, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		if comment.Valid {
+			sub.CommentID = &comment.ID
+		}
+		subs = append(subs, sub)
+		postIDs = append(postIDs, sub.PostID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if fillPosts && len(postIDs) > 0 {
+		posts, err := GetPostsByIDs(ctx, db, &user, true, postIDs...)
+		if err != nil && err != errPostNotFound {
+			return nil, err
+		}
+		for _, sub := range subs {
+			for _, post := range posts {
+				if post.ID == sub.PostID {
+					sub.Post = post
+					break
+				}
+			}
+		}
+	}
+	return subs, nil
+}
+
+// DeleteThreadSubscription deletes the subscription of user with the given id,
+// which reverts the thread to its default (that is, user is subscribed to it
+// only if it's user's post or comment).
+func DeleteThreadSubscription(ctx context.Context, db *sql.DB, user uid.ID, id int) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM thread_subscriptions WHERE id = ? AND user_id = ?", id, user)
+	return err
+}
+
+// threadUnsubscribed reports whether user has unfollowed the thread of target
+// (a post or a comment).
+func threadUnsubscribed(ctx context.Context, db *sql.DB, user, target uid.ID) (bool, error) {
+	var subscribed bool
+	if err := db.QueryRowContext(ctx, "SELECT subscribed FROM thread_subscriptions WHERE user_id = ? AND target_id = ?", user, target).Scan(&subscribed); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return !subscribed, nil
+}
+
+// notifyThreadSubscribers notifies the users who follow post, or any of the
+// ancestors of comment (ancestors, root first), of comment. The author of the
+// post and the author of the parent comment are skipped, for they're notified
+// otherwise.
+func notifyThreadSubscribers(ctx context.Context, db *sql.DB, post *Post, comment uid.ID, ancestors []uid.ID, author *User, parentAuthor *uid.ID) error {
+	targets := append([]uid.ID{post.ID}, ancestors...)
+	args := make([]any, len(targets))
+	for i := range targets {
+		args[i] = targets[i]
+	}
+	rows, err := db.QueryContext(ctx, "SELECT user_id, target_id FROM thread_subscriptions WHERE subscribed = TRUE AND target_id IN "+msql.InClauseQuestionMarks(len(args)), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// If a user follows more than one of the threads, the user is notified of
+	// only the innermost one.
+	depth := func(target uid.ID) int {
+		for i := range targets {
+			if targets[i] == target {
+				return i
+			}
+		}
+		return -1
+	}
+	threads := make(map[uid.ID]uid.ID) // user -> thread
+	for rows.Next() {
+		var user, target uid.ID
+		if err := rows.Scan(&user, &target); err != nil {
+			return err
+		}
+		if thread, ok := threads[user]; !ok || depth(target) > depth(thread) {
+			threads[user] = target
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for user, thread := range threads {
+		if user == author.ID || user == post.AuthorID || (parentAuthor != nil && user == *parentAuthor) {
+			continue
+		}
+		if muted, err := UserMuted(ctx, db, user, author.ID); err != nil {
+			return err
+		} else if muted {
+			continue
+		}
+		if blocked, err := usersBlocked(ctx, db, user, author.ID); err != nil {
+			return err
+		} else if blocked {
+			continue
+		}
+		var threadComment *uid.ID
+		if thread != post.ID {
+			t := thread
+			threadComment = &t
+		}
+		if err := CreateThreadReplyNotification(ctx, db, user, post.ID, threadComment, comment, author); err != nil {
+			return err
+		}
+	}
+	return nil
+}
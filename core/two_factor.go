@@ -0,0 +1,215 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/totp"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+const numRecoveryCodes = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCode returns a random code of the form xxxxx-xxxxx.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+func hashRecoveryCode(code string) []byte {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(code))
+	return sum[:]
+}
+
+// replaceRecoveryCodes deletes the existing recovery codes of user and
+// returns a new set. Only the hashes of the codes are saved.
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, user uid.ID) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", user); err != nil {
+		return nil, err
+	}
+	codes := make([]string, numRecoveryCodes)
+	rows := make([][]msql.ColumnValue, numRecoveryCodes)
+	now := time.Now()
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		rows[i] = []msql.ColumnValue{
+			{Name: "user_id", Value: user},
+			{Name: "code_hash", Value: hashRecoveryCode(code)},
+			{Name: "created_at", Value: now},
+		}
+	}
+	query, args := msql.BuildInsertQuery("recovery_codes", rows...)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// StartTwoFactorEnrolment generates a new TOTP secret for the user, which is
+// not in effect until EnableTwoFactor is called with a valid code. It returns
+// the secret and the provisioning URI (for a QR code) with issuer as the name
+// of the site.
+func (u *User) StartTwoFactorEnrolment(ctx context.Context, issuer string) (secret, uri string, err error) {
+	if u.TwoFactorEnabled {
+		return "", "", err2FAAlreadyEnabled
+	}
+	if secret, err = totp.GenerateSecret(); err != nil {
+		return "", "", err
+	}
+	if _, err = u.db.ExecContext(ctx, "UPDATE users SET totp_secret = ?, totp_last_counter = 0 WHERE id = ?", secret, u.ID); err != nil {
+		return "", "", err
+	}
+	return secret, totp.ProvisioningURI(issuer, u.Username, secret), nil
+}
+
+// EnableTwoFactor turns on two-factor authentication, if code is valid for the
+// secret generated by StartTwoFactorEnrolment. It returns the recovery codes,
+// which are not retrievable later.
+func (u *User) EnableTwoFactor(ctx context.Context, code string) ([]string, error) {
+	if u.TwoFactorEnabled {
+		return nil, err2FAAlreadyEnabled
+	}
+
+	var secret msql.NullString
+	if err := u.db.QueryRowContext(ctx, "SELECT totp_secret FROM users WHERE id = ?", u.ID).Scan(&secret); err != nil {
+		return nil, err
+	}
+	if !secret.Valid {
+		return nil, err2FANotEnrolling
+	}
+	ok, counter, err := totp.Validate(secret.String, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrWrongTwoFactorCode
+	}
+
+	var codes []string
+	err = msql.Transact(ctx, u.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_enabled_at = ?, totp_last_counter = ? WHERE id = ?", time.Now(), counter, u.ID); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(ctx, tx, u.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.TwoFactorEnabled = true
+	return codes, nil
+}
+
+// DisableTwoFactor turns off two-factor authentication and deletes the
+// recovery codes of the user. It's also how admins reset the two-factor
+// authentication of a user who has lost access to it.
+func (u *User) DisableTwoFactor(ctx context.Context) error {
+	err := msql.Transact(ctx, u.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = 0 WHERE id = ?", u.ID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", u.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	u.TwoFactorEnabled = false
+	return nil
+}
+
+// VerifyTwoFactor checks code, which is either a TOTP code or an unused
+// recovery code, and returns ErrWrongTwoFactorCode if it's invalid. Codes are
+// only accepted once.
+func (u *User) VerifyTwoFactor(ctx context.Context, code string) error {
+	if !u.TwoFactorEnabled {
+		return err2FANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) > 6 {
+		// A recovery code.
+		res, err := u.db.ExecContext(ctx, "UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+			time.Now(), u.ID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrWrongTwoFactorCode
+		}
+		return nil
+	}
+
+	var secret string
+	if err := u.db.QueryRowContext(ctx, "SELECT totp_secret FROM users WHERE id = ?", u.ID).Scan(&secret); err != nil {
+		return err
+	}
+	ok, counter, err := totp.Validate(secret, code, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrWrongTwoFactorCode
+	}
+
+	// Only accept codes of later time steps than the last accepted code, so
+	// that a code cannot be replayed.
+	res, err := u.db.ExecContext(ctx, "UPDATE users SET totp_last_counter = ? WHERE id = ? AND totp_last_counter < ?", counter, u.ID, counter)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrWrongTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user with a new
+// set.
+func (u *User) RegenerateRecoveryCodes(ctx context.Context) ([]string, error) {
+	if !u.TwoFactorEnabled {
+		return nil, err2FANotEnabled
+	}
+	var codes []string
+	err := msql.Transact(ctx, u.db, func(tx *sql.Tx) (err error) {
+		codes, err = replaceRecoveryCodes(ctx, tx, u.ID)
+		return
+	})
+	return codes, err
+}
+
+// RecoveryCodesLeft returns the number of unused recovery codes of the user.
+func (u *User) RecoveryCodesLeft(ctx context.Context) (n int, err error) {
+	err = u.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", u.ID).Scan(&n)
+	return
+}
+
+// UserModOfAny reports whether user is a moderator of at least one community.
+func UserModOfAny(ctx context.Context, db *sql.DB, user uid.ID) (bool, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM community_mods WHERE user_id = ?", user).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
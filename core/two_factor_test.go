@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/discuitnet/discuit/internal/testdb"
+	"github.com/discuitnet/discuit/internal/totp"
+)
+
+// enableTwoFactor turns on two-factor authentication for user with the code
+// of the current time step, which it returns along with the TOTP secret and
+// the recovery codes.
+func enableTwoFactor(t *testing.T, user *User) (secret string, counter int64, codes []string) {
+	t.Helper()
+	secret, _, err := user.StartTwoFactorEnrolment(context.Background(), "Discuit")
+	if err != nil {
+		t.Fatalf("starting enrolment: %v", err)
+	}
+	counter = totp.Counter(time.Now())
+	codes, err = user.EnableTwoFactor(context.Background(), totpCode(t, secret, counter))
+	if err != nil {
+		t.Fatalf("enabling two-factor authentication: %v", err)
+	}
+	return secret, counter, codes
+}
+
+func totpCode(t *testing.T, secret string, counter int64) string {
+	t.Helper()
+	code, err := totp.CodeAt(secret, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestRecoveryCodesSingleUse(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, db)
+	_, _, codes := enableTwoFactor(t, user)
+	if len(codes) != numRecoveryCodes {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), numRecoveryCodes)
+	}
+
+	// Codes are accepted regardless of case, dashes, and surrounding spaces.
+	if err := user.VerifyTwoFactor(ctx, " "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))+" "); err != nil {
+		t.Fatalf("using a recovery code: %v", err)
+	}
+	// Backdate the use, so that using the code again within the same second
+	// would still change the row.
+	if _, err := db.Exec("UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND used_at IS NOT NULL", time.Now().Add(-time.Hour), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.VerifyTwoFactor(ctx, codes[0]); err != ErrWrongTwoFactorCode {
+		t.Errorf("using a recovery code twice: got error %v, want %v", err, ErrWrongTwoFactorCode)
+	}
+	if n, err := user.RecoveryCodesLeft(ctx); err != nil {
+		t.Fatal(err)
+	} else if n != numRecoveryCodes-1 {
+		t.Errorf("got %d recovery codes left, want %d", n, numRecoveryCodes-1)
+	}
+
+	// The recovery codes of one user don't work for another.
+	other := newTestUser(t, db)
+	enableTwoFactor(t, other)
+	if err := other.VerifyTwoFactor(ctx, codes[1]); err != ErrWrongTwoFactorCode {
+		t.Errorf("using another user's recovery code: got error %v, want %v", err, ErrWrongTwoFactorCode)
+	}
+
+	// Regenerating the codes invalidates the old ones.
+	if _, err := user.RegenerateRecoveryCodes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.VerifyTwoFactor(ctx, codes[1]); err != ErrWrongTwoFactorCode {
+		t.Errorf("using a replaced recovery code: got error %v, want %v", err, ErrWrongTwoFactorCode)
+	}
+}
+
+func TestTwoFactorCodeReplay(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, db)
+	secret, counter, _ := enableTwoFactor(t, user)
+
+	// The code used to enable two-factor authentication is spent.
+	if err := user.VerifyTwoFactor(ctx, totpCode(t, secret, counter)); err != ErrWrongTwoFactorCode {
+		t.Errorf("replaying the enrolment code: got error %v, want %v", err, ErrWrongTwoFactorCode)
+	}
+
+	// The code of the next time step is accepted (allowing for clock drift),
+	// but only once.
+	next := totpCode(t, secret, counter+1)
+	if err := user.VerifyTwoFactor(ctx, next); err != nil {
+		t.Fatalf("using the next code: %v", err)
+	}
+	if err := user.VerifyTwoFactor(ctx, next); err != ErrWrongTwoFactorCode {
+		t.Errorf("replaying a code: got error %v, want %v", err, ErrWrongTwoFactorCode)
+	}
+
+	// As are the codes of time steps before the last accepted one.
+	if err := user.VerifyTwoFactor(ctx, totpCode(t, secret, counter)); err != ErrWrongTwoFactorCode {
+		t.Errorf("using an older code: got error %v, want %v", err, ErrWrongTwoFactorCode)
+	}
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	user := newTestUser(t, db)
+	_, _, codes := enableTwoFactor(t, user)
+	if _, _, err := user.StartTwoFactorEnrolment(ctx, "Discuit"); err != err2FAAlreadyEnabled {
+		t.Errorf("enrolling while enabled: got error %v, want %v", err, err2FAAlreadyEnabled)
+	}
+
+	if err := user.DisableTwoFactor(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetUser(ctx, db, user.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TwoFactorEnabled {
+		t.Fatal("two-factor authentication still enabled after disabling")
+	}
+	if err := got.VerifyTwoFactor(ctx, codes[0]); err != err2FANotEnabled {
+		t.Errorf("verifying while disabled: got error %v, want %v", err, err2FANotEnabled)
+	}
+	if n, err := got.RecoveryCodesLeft(ctx); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("got %d recovery codes left after disabling, want 0", n)
+	}
+
+	// Two-factor authentication can be enabled again, with a new secret, in
+	// the same time step, and the old recovery codes don't carry over.
+	secret, counter, _ := enableTwoFactor(t, got)
+	if got, err = GetUser(ctx, db, user.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !got.TwoFactorEnabled {
+		t.Fatal("two-factor authentication not enabled after re-enabling")
+	}
+	if err := got.VerifyTwoFactor(ctx, codes[0]); err != ErrWrongTwoFactorCode {
+		t.Errorf("using a recovery code from before disabling: got error %v, want %v", err, ErrWrongTwoFactorCode)
+	}
+	if err := got.VerifyTwoFactor(ctx, totpCode(t, secret, counter+1)); err != nil {
+		t.Errorf("verifying after re-enabling: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha1"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -127,11 +128,54 @@ type User struct {
 	EmbedsOff               bool     `json:"embedsOff"`
 	HideUserProfilePictures bool     `json:"hideUserProfilePictures"`
 
+	// The notification preferences of the user (see
+	// notification_preferences.go). NotificationPreferencesPublic is set only
+	// for the user themself.
+	NotificationPreferences       *NotificationPreferences `json:"-"`
+	NotificationPreferencesPublic *NotificationPreferences `json:"notificationPreferences,omitempty"`
+
+	// How often the user receives email digests (see email_digest.go).
+	EmailDigest       EmailDigestFrequency `json:"emailDigest"`
+	EmailDigestSentAt msql.NullTime        `json:"-"`
+
+	// If true, the posts that the user has opened are left out of their feeds
+	// (see hidden_post.go).
+	HideViewedPosts bool `json:"hideViewedPosts"`
+
 	// No banned users are supposed to be logged in. Make sure to log them out
 	// before banning.
 	BannedAt msql.NullTime `json:"bannedAt"`
 	Banned   bool          `json:"isBanned"`
 
+	// The reason for the ban, and when it's lifted (if it's not a permanent
+	// ban). Only admins and the banned user are supposed to see these. The
+	// note is for admins only. See site_ban.go.
+	BanReason    msql.NullString `json:"-"`
+	BanNote      msql.NullString `json:"-"`
+	BanExpiresAt msql.NullTime   `json:"-"`
+
+	// The posts and comments of a shadow banned user are hidden from everyone
+	// except the user and admins (see shadow_ban.go). The user is not supposed
+	// to find out.
+	ShadowBannedAt msql.NullTime `json:"-"`
+	ShadowBanned   bool          `json:"-"`
+
+	// Whether two-factor authentication is on (see two_factor.go).
+	TwoFactorEnabled bool `json:"-"`
+
+	// The profile of a deactivated user is hidden until the user logs in
+	// again (or, if an admin deactivated the account, until an admin
+	// reactivates it). If DeleteAt is set, the account is deleted at that
+	// time, unless the deletion is cancelled (see deactivation.go).
+	// DeleteAtPublic is set only for the user themself and admins.
+	Deactivated       bool          `json:"deactivated"`
+	DeactivatedAt     msql.NullTime `json:"-"`
+	DeactivatedBy     uid.NullID    `json:"-"`
+	DeleteAt          msql.NullTime `json:"-"`
+	DeleteAtPublic    *time.Time    `json:"deleteAt,omitempty"`
+	DeleteScheduledBy uid.NullID    `json:"-"`
+	DeleteWithContent bool          `json:"-"` // Whether to delete posts and comments too.
+
 	MutedByViewer bool `json:"-"`
 
 	NumNewNotifications int `json:"notificationsNewCount"`
@@ -146,6 +190,11 @@ type User struct {
 	preGhostCreatedAt time.Time
 	preGhostDeletedAt msql.NullTime
 	preGhostBadges    Badges
+
+	// The values of the legacy notification toggles as last saved, to know if
+	// they're changed by Update.
+	savedUpvoteNotificationsOff bool
+	savedReplyNotificationsOff  bool
 }
 
 // IsUsernameValid returns nil if name only consists
@@ -240,6 +289,20 @@ func buildSelectUserQuery(where string) string {
 		"users.phone_code",
 		"users.phone_number",
 		"users.full_name",
+		"users.totp_enabled_at IS NOT NULL",
+		"users.deactivated_at",
+		"users.deactivated_by",
+		"users.delete_at",
+		"users.delete_scheduled_by",
+		"users.delete_content",
+		"users.ban_reason",
+		"users.ban_note",
+		"users.ban_expires_at",
+		"users.shadow_banned_at",
+		"users.notification_preferences",
+		"users.email_digest",
+		"users.email_digest_sent_at",
+		"users.hide_viewed_posts",
 	}
 	cols = append(cols, images.ImageColumns("pro_pic")...)
 	joins := []string{
@@ -317,6 +380,7 @@ func scanUsers(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.ID)
 			db:     db,
 			Badges: make(Badges, 0),
 		}
+		var notifPrefs msql.NullString
 		dests := []any{
 			&u.ID,
 			&u.Username,
@@ -343,6 +407,20 @@ func scanUsers(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.ID)
 			&u.PhoneCode,
 			&u.PhoneNumber,
 			&u.FullName,
+			&u.TwoFactorEnabled,
+			&u.DeactivatedAt,
+			&u.DeactivatedBy,
+			&u.DeleteAt,
+			&u.DeleteScheduledBy,
+			&u.DeleteWithContent,
+			&u.BanReason,
+			&u.BanNote,
+			&u.BanExpiresAt,
+			&u.ShadowBannedAt,
+			&notifPrefs,
+			&u.EmailDigest,
+			&u.EmailDigestSentAt,
+			&u.HideViewedPosts,
 		}
 
 		proPic := &images.Image{}
@@ -362,6 +440,11 @@ func scanUsers(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.ID)
 		if u.BannedAt.Valid {
 			u.Banned = true
 		}
+		u.Deactivated = u.DeactivatedAt.Valid
+		u.ShadowBanned = u.ShadowBannedAt.Valid
+		u.NotificationPreferences = parseNotificationPreferences(notifPrefs, u.UpvoteNotificationsOff, u.ReplyNotificationsOff)
+		u.savedUpvoteNotificationsOff = u.UpvoteNotificationsOff
+		u.savedReplyNotificationsOff = u.ReplyNotificationsOff
 
 		if proPic.ID != nil {
 			proPic.PostScan()
@@ -411,6 +494,11 @@ func scanUsers(ctx context.Context, db *sql.DB, rows *sql.Rows, viewer *uid.ID)
 				user.EmailPublic = new(string)
 				*user.EmailPublic = user.Email.String
 			}
+			user.NotificationPreferencesPublic = user.NotificationPreferences
+		}
+		if user.DeleteAt.Valid && ((viewer != nil && *viewer == user.ID) || viewerAdmin) {
+			user.DeleteAtPublic = new(time.Time)
+			*user.DeleteAtPublic = user.DeleteAt.Time
 		}
 		// Set the user info of deleted users to the ghost user for everyone
 		// except the admins.
@@ -601,27 +689,52 @@ func (u *User) Update(ctx context.Context) error {
 	}
 
 	u.About.String = utils.TruncateUnicodeString(u.About.String, maxUserProfileAboutLength)
+
+	// If the legacy notification toggles are changed, the notification
+	// preferences are updated to match.
+	var notifPrefs *string
+	if u.UpvoteNotificationsOff != u.savedUpvoteNotificationsOff || u.ReplyNotificationsOff != u.savedReplyNotificationsOff {
+		u.NotificationPreferences.applyLegacyToggles(u.UpvoteNotificationsOff, u.ReplyNotificationsOff)
+		data, err := json.Marshal(u.NotificationPreferences)
+		if err != nil {
+			return err
+		}
+		notifPrefs = new(string)
+		*notifPrefs = string(data)
+	}
+
 	_, err := u.db.ExecContext(ctx, `
 	UPDATE users SET
 		email = ?, 
 		about_me = ?,
 		upvote_notifications_off = ?,
 		reply_notifications_off = ?,
+		notification_preferences = COALESCE(?, notification_preferences),
 		home_feed = ?,
 		remember_feed_sort = ?,
 		embeds_off = ?,
-		hide_user_profile_pictures = ?
+		hide_user_profile_pictures = ?,
+		email_digest = ?,
+		hide_viewed_posts = ?
 	WHERE id = ?`,
 		u.EmailPublic,
 		u.About,
 		u.UpvoteNotificationsOff,
 		u.ReplyNotificationsOff,
+		notifPrefs,
 		u.HomeFeed,
 		u.RememberFeedSort,
 		u.EmbedsOff,
 		u.HideUserProfilePictures,
+		u.EmailDigest,
+		u.HideViewedPosts,
 		u.ID)
-	return err
+	if err != nil {
+		return err
+	}
+	u.savedUpvoteNotificationsOff = u.UpvoteNotificationsOff
+	u.savedReplyNotificationsOff = u.ReplyNotificationsOff
+	return nil
 }
 
 func (u *User) IsGhost() bool {
@@ -700,6 +813,21 @@ func (u *User) Delete(ctx context.Context) error {
 			return err
 		}
 
+		// Delete the user's thread subscriptions.
+		if _, err := tx.ExecContext(ctx, "DELETE FROM thread_subscriptions WHERE user_id = ?", u.ID); err != nil {
+			return err
+		}
+
+		// Delete the mentions of and by the user.
+		if _, err := tx.ExecContext(ctx, "DELETE FROM mentions WHERE user_id = ? OR by_user_id = ?", u.ID, u.ID); err != nil {
+			return err
+		}
+
+		// Delete both the user's blocked users and blocked by's.
+		if _, err := tx.ExecContext(ctx, "DELETE FROM blocked_users WHERE user_id = ? OR blocked_user_id = ?", u.ID, u.ID); err != nil {
+			return err
+		}
+
 		// Delete the user's muted communities.
 		if _, err := tx.ExecContext(ctx, "DELETE FROM muted_communities WHERE user_id = ?", u.ID); err != nil {
 			return err
@@ -715,6 +843,24 @@ func (u *User) Delete(ctx context.Context) error {
 			return err
 		}
 
+		// Delete the user's hidden and viewed posts.
+		if _, err := tx.ExecContext(ctx, "DELETE FROM hidden_posts WHERE user_id = ?", u.ID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM viewed_posts WHERE user_id = ?", u.ID); err != nil {
+			return err
+		}
+
+		// Delete the user's custom feeds.
+		if _, err := tx.ExecContext(ctx, "DELETE FROM custom_feeds WHERE user_id = ?", u.ID); err != nil {
+			return err
+		}
+
+		// Delete the user's data exports.
+		if err := deleteUserDataExportsTx(ctx, tx, u.ID); err != nil {
+			return err
+		}
+
 		// Delete the user's profile picture
 		if err := u.DeleteProPicTx(ctx, tx); err != nil {
 			return err
@@ -729,6 +875,10 @@ func (u *User) Delete(ctx context.Context) error {
 				about_me = ?, 
 				is_admin = ?,
 				notifications_new_count = ?,
+				deactivated_at = ?,
+				deactivated_by = ?,
+				delete_at = ?,
+				delete_scheduled_by = ?,
 				deleted_at = ? 
 			  WHERE id = ?`
 		args := []any{
@@ -738,6 +888,10 @@ func (u *User) Delete(ctx context.Context) error {
 			nil,
 			false,
 			0,
+			nil,
+			nil,
+			nil,
+			nil,
 			now,
 			u.ID,
 		}
@@ -812,33 +966,6 @@ func (u *User) DeleteContent(ctx context.Context, n int, admin uid.ID) error {
 	return nil
 }
 
-// Ban bans the user from site. Important: Make sure to log out all sessions of
-// this user before calling this function, and never allow this user to login.
-//
-// Note: An admin can be banned.
-func (u *User) Ban(ctx context.Context) error {
-	if u.Deleted {
-		return ErrUserDeleted
-	}
-
-	t := time.Now()
-	_, err := u.db.ExecContext(ctx, "UPDATE users SET banned_at = ? WHERE id = ?", t, u.ID)
-	if err == nil {
-		u.BannedAt = msql.NewNullTime(t)
-		u.Banned = true
-	}
-	return err
-}
-
-func (u *User) Unban(ctx context.Context) error {
-	if u.Deleted {
-		return ErrUserDeleted
-	}
-
-	_, err := u.db.ExecContext(ctx, "UPDATE users SET banned_at = NULL WHERE id = ?", u.ID)
-	return err
-}
-
 // MakeAdmin makes the user an admin of the site. If isAdmin is false
 // admin is removed as an admin.
 func (u *User) MakeAdmin(ctx context.Context, isAdmin bool) error {
@@ -867,6 +994,23 @@ func (u *User) ChangePassword(ctx context.Context, previousPass, newPass string)
 	return err
 }
 
+// ResetPassword sets the password of the user to newPass without checking the
+// previous password (used by admins).
+func (u *User) ResetPassword(ctx context.Context, newPass string) error {
+	if u.Deleted {
+		return ErrUserDeleted
+	}
+	hash, err := HashPassword([]byte(newPass))
+	if err != nil {
+		return err
+	}
+	if _, err = u.db.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", hash, u.ID); err != nil {
+		return err
+	}
+	u.Password = string(hash)
+	return nil
+}
+
 func (u *User) ResetNewNotificationsCount(ctx context.Context) error {
 	err := resetNewNotificationsCount(ctx, u.db, u.ID)
 	if err == nil {
@@ -930,10 +1074,23 @@ func (u *User) Saw(ctx context.Context, userIP string) error {
 	return err
 }
 
-// UserSeen updates user's LastSeen to current time. It also updates the IP
-// address of the user.
+// UserSeen updates user's LastSeen to current time. It also updates the
+// (hashed) IP address of the user, and adds it to the user's IP history.
 func UserSeen(ctx context.Context, db *sql.DB, user uid.ID, userIP string) error {
-	_, err := db.ExecContext(ctx, "UPDATE users SET last_seen = ?, last_seen_ip = ? WHERE id = ? AND deleted_at IS NULL", time.Now(), userIP, user)
+	now := time.Now()
+	var ipHash msql.NullString
+	if userIP != "" {
+		ipHash = msql.NewNullString(hashIP(userIP))
+	}
+	res, err := db.ExecContext(ctx, "UPDATE users SET last_seen = ?, last_seen_ip = ? WHERE id = ? AND deleted_at IS NULL", now, ipHash, user)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 || userIP == "" {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO user_ips (user_id, ip_hash, first_seen_at, last_seen_at) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE last_seen_at = ?`, user, ipHash, now, now, now)
 	return err
 }
 
@@ -944,6 +1101,46 @@ func CountAllUsers(ctx context.Context, db *sql.DB) (n int, err error) {
 	return
 }
 
+// SearchUsers returns a page of users (newest first) whose username or email
+// contains q (all users, if q is empty), and the total number of such users. If
+// bannedOnly is true, only banned users are returned.
+func SearchUsers(ctx context.Context, db *sql.DB, q string, bannedOnly bool, limit, page int, viewer *uid.ID) (int, []*User, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	if q != "" {
+		conds = append(conds, "(users.username_lc LIKE ? OR users.email LIKE ?)")
+		like := "%" + msql.EscapeLike(strings.ToLower(q)) + "%"
+		args = append(args, like, like)
+	}
+	if bannedOnly {
+		conds = append(conds, "users.banned_at IS NOT NULL")
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users "+where, args...).Scan(&count); err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, buildSelectUserQuery(where+" ORDER BY users.created_at DESC LIMIT ? OFFSET ?"), append(args, limit, limit*(page-1))...)
+	if err != nil {
+		return 0, nil, err
+	}
+	users, err := scanUsers(ctx, db, rows, viewer)
+	if err != nil {
+		if err == errUserNotFound {
+			return count, []*User{}, nil
+		}
+		return 0, nil, err
+	}
+	return count, users, nil
+}
+
 func (u *User) LoadModdingList(ctx context.Context) error {
 	comms, err := getCommunities(ctx, u.db, nil, "WHERE communities.id IN (SELECT community_mods.community_id FROM community_mods WHERE user_id = ?)", u.ID)
 	if err == nil {
@@ -1075,7 +1272,7 @@ func (u *User) RemoveBadge(id int) error {
 		return ErrUserDeleted
 	}
 
-	_, err := u.db.Exec("DELTE FROM user_badges WHERE id = ? and user_id = ?", id, u.ID)
+	_, err := u.db.Exec("DELETE FROM user_badges WHERE id = ? and user_id = ?", id, u.ID)
 	return err
 }
 
@@ -1088,6 +1285,25 @@ func NewBadgeType(db *sql.DB, name string) error {
 	return nil
 }
 
+// GetBadgeTypes returns the names of all user badge types.
+func GetBadgeTypes(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM badge_types ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
 // A Badge corresponds to a row in the user_badges table.
 type Badge struct {
 	ID        int       `json:"id"`
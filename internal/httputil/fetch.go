@@ -0,0 +1,259 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	maxRedirects = 5
+
+	// DefaultMaxBodySize is the maximum size of a response body read by Fetch
+	// if FetchOptions.MaxBodySize is not set.
+	DefaultMaxBodySize = 10 << 20
+)
+
+var (
+	// ErrAddressBlocked is returned when a request is refused because the
+	// destination resolves to a non-public IP address.
+	ErrAddressBlocked = errors.New("httputil: destination address not allowed")
+
+	ErrSchemeNotAllowed      = errors.New("httputil: url scheme not allowed")
+	ErrTooManyRedirects      = errors.New("httputil: too many redirects")
+	ErrContentTypeNotAllowed = errors.New("httputil: content type not allowed")
+	ErrBodyTooLarge          = errors.New("httputil: response body too large")
+)
+
+// blockedNetworks are the special-purpose address ranges, on top of the ones
+// covered by the net.IP.Is* functions, that no outbound request is allowed to
+// reach.
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",       // "This" network.
+	"100.64.0.0/10",   // Carrier-grade NAT.
+	"192.0.0.0/24",    // IETF protocol assignments.
+	"192.0.2.0/24",    // TEST-NET-1.
+	"198.18.0.0/15",   // Benchmarking.
+	"198.51.100.0/24", // TEST-NET-2.
+	"203.0.113.0/24",  // TEST-NET-3.
+	"240.0.0.0/4",     // Reserved.
+	"64:ff9b::/96",    // NAT64 (can map to internal IPv4 addresses).
+	"2001::/32",       // Teredo (can map to internal IPv4 addresses).
+	"2001:db8::/32",   // Documentation.
+	"2002::/16",       // 6to4 (can map to internal IPv4 addresses).
+	"fec0::/10",       // Site-local (deprecated).
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// IPAllowed reports whether ip is a public unicast address that outbound
+// requests may be made to. Private, loopback, link-local, multicast, and other
+// special-purpose addresses are not allowed.
+func IPAllowed(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4 // IPv4-mapped IPv6 addresses are checked as IPv4.
+	}
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		ip.Equal(net.IPv4bcast) {
+		return false
+	}
+	for _, n := range blockedNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// safeDialControl is run after DNS resolution, right before a connection is
+// made, so it catches hostnames that resolve to internal addresses (including
+// by way of DNS rebinding).
+func safeDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IPAllowed(ip) {
+		log.Printf("httputil: refused connection to %s\n", address)
+		return ErrAddressBlocked
+	}
+	return nil
+}
+
+// SafeClient is an HTTP client that's safe to use with user-submitted URLs.
+// It refuses to connect to non-public addresses and to follow more than a few
+// redirects.
+var SafeClient = &http.Client{
+	Timeout: time.Second * 6,
+	Transport: &http.Transport{
+		Proxy: nil, // A proxy would be dialed instead of the destination.
+		DialContext: (&net.Dialer{
+			Timeout: time.Second * 5,
+			Control: safeDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout:   time.Second * 5,
+		ResponseHeaderTimeout: time.Second * 5,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       time.Second * 90,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			log.Printf("httputil: refused to fetch %s: %v\n", via[0].URL, ErrTooManyRedirects)
+			return ErrTooManyRedirects
+		}
+		if !schemeAllowed(req.URL) {
+			log.Printf("httputil: refused redirect to %s: %v\n", req.URL, ErrSchemeNotAllowed)
+			return ErrSchemeNotAllowed
+		}
+		req.Header.Set("User-Agent", userAgent)
+		return nil
+	},
+}
+
+func schemeAllowed(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// FetchOptions are the options of Fetch.
+type FetchOptions struct {
+	// MaxBodySize is the maximum number of bytes that can be read from the
+	// response body. Reading past it returns ErrBodyTooLarge. If zero,
+	// DefaultMaxBodySize is used.
+	MaxBodySize int64
+
+	// ContentTypes are the allowed media types of the response (like
+	// "text/html"). A type of the form "image/*" matches all subtypes. If
+	// empty, any type is allowed.
+	ContentTypes []string
+}
+
+func (o *FetchOptions) contentTypeAllowed(header string) bool {
+	if len(o.ContentTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	for _, t := range o.ContentTypes {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch makes a GET request to rawURL using SafeClient, with an ordinary
+// looking User-Agent. Only http and https URLs are allowed. If opts is nil,
+// default options are used. Make sure to close the http.Response.Body.
+func Fetch(ctx context.Context, rawURL string, opts *FetchOptions) (*http.Response, error) {
+	if opts == nil {
+		opts = &FetchOptions{}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if !schemeAllowed(u) {
+		log.Printf("httputil: refused to fetch %s: %v\n", rawURL, ErrSchemeNotAllowed)
+		return nil, ErrSchemeNotAllowed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	res, err := SafeClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Header.Get("Content-Type") == "" && len(opts.ContentTypes) > 0 {
+		if err := sniffContentType(res); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+	}
+	if contentType := res.Header.Get("Content-Type"); !opts.contentTypeAllowed(contentType) {
+		res.Body.Close()
+		log.Printf("httputil: refused to fetch %s: %v (%q)\n", rawURL, ErrContentTypeNotAllowed, contentType)
+		return nil, fmt.Errorf("%w: %q", ErrContentTypeNotAllowed, contentType)
+	}
+
+	maxSize := opts.MaxBodySize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBodySize
+	}
+	res.Body = &limitedReadCloser{rc: res.Body, url: rawURL, remaining: maxSize}
+	return res, nil
+}
+
+// sniffContentType sets the Content-Type header of res, which the server didn't
+// set, to the type detected from the start of the body. The body is left
+// unchanged for the caller.
+func sniffContentType(res *http.Response) error {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(res.Body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+	res.Header.Set("Content-Type", http.DetectContentType(head))
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), res.Body), res.Body}
+	return nil
+}
+
+// limitedReadCloser returns ErrBodyTooLarge, instead of io.EOF, if there's more
+// data to be read after the limit is reached.
+type limitedReadCloser struct {
+	rc        io.ReadCloser
+	url       string
+	remaining int64
+}
+
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		var b [1]byte
+		n, err := l.rc.Read(b[:])
+		if n > 0 {
+			log.Printf("httputil: stopped reading %s: %v\n", l.url, ErrBodyTooLarge)
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.rc.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+func (l *limitedReadCloser) Close() error {
+	return l.rc.Close()
+}
@@ -112,6 +112,17 @@ func (w gzipResponseWriter) Write(p []byte) (int, error) {
 	return w.Writer.Write(p)
 }
 
+// Flush flushes the gzip writer and then the underlying response writer (for
+// streaming responses).
+func (w gzipResponseWriter) Flush() {
+	if f, ok := w.Writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func GzipHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !AcceptEncoding(r.Header, "gzip") {
@@ -2,9 +2,11 @@
 package httputil
 
 import (
+	"context"
 	"io"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"golang.org/x/net/html"
@@ -16,7 +18,9 @@ func GetIP(r *http.Request) string {
 	return host
 }
 
-var httpClient = &http.Client{
+// proxyClient is used by ProxyRequest, which is only used to proxy requests
+// to a trusted (and usually local) address.
+var proxyClient = &http.Client{
 	Timeout: time.Second * 6,
 }
 
@@ -24,15 +28,10 @@ const (
 	userAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:94.0) Gecko/20100101 Firefox/94.0"
 )
 
-// Get fetches the file at url with an ordinary looking User-Agent. Make sure to
-// close the http.Response.Body.
+// Get fetches the file at url with an ordinary looking User-Agent. It's
+// Fetch with default options. Make sure to close the http.Response.Body.
 func Get(url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", userAgent)
-	return httpClient.Do(req)
+	return Fetch(context.Background(), url, nil)
 }
 
 // ExtractOpenGraphImage returns the Open Graph image tag of the HTML document in r.
@@ -108,7 +107,7 @@ func ProxyRequest(w http.ResponseWriter, r *http.Request, url string) {
 	req.Header = r.Header
 	req.Header.Set("User-Agent", userAgent)
 
-	resp, err := httpClient.Do(req)
+	resp, err := proxyClient.Do(req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -121,3 +120,81 @@ func ProxyRequest(w http.ResponseWriter, r *http.Request, url string) {
 	w.WriteHeader(resp.StatusCode)
 	io.Copy(w, resp.Body)
 }
+
+// PageMetadata is the metadata of an HTML document that's useful for
+// generating link previews.
+type PageMetadata struct {
+	Title       string // og:title, or else the <title> element.
+	Description string // og:description, or else the description meta tag.
+	SiteName    string // og:site_name.
+	Image       string // og:image.
+	Favicon     string // The href of the first icon link tag.
+}
+
+// ExtractPageMetadata returns the metadata found in the HTML document in r.
+// URLs in the returned value are as they appear in the document, and so may be
+// relative.
+func ExtractPageMetadata(r io.Reader) (*PageMetadata, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	attr := func(n *html.Node, key string) string {
+		for _, a := range n.Attr {
+			if strings.EqualFold(a.Key, key) {
+				return a.Val
+			}
+		}
+		return ""
+	}
+	setIfEmpty := func(dst *string, val string) {
+		if *dst == "" {
+			*dst = strings.TrimSpace(val)
+		}
+	}
+
+	m := &PageMetadata{}
+	var title, description string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "title":
+				if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+					setIfEmpty(&title, n.FirstChild.Data)
+				}
+			case "meta":
+				content := attr(n, "content")
+				switch attr(n, "property") {
+				case "og:title":
+					setIfEmpty(&m.Title, content)
+				case "og:description":
+					setIfEmpty(&m.Description, content)
+				case "og:site_name":
+					setIfEmpty(&m.SiteName, content)
+				case "og:image":
+					setIfEmpty(&m.Image, content)
+				}
+				if strings.EqualFold(attr(n, "name"), "description") {
+					setIfEmpty(&description, content)
+				}
+			case "link":
+				href := attr(n, "href")
+				for _, rel := range strings.Fields(strings.ToLower(attr(n, "rel"))) {
+					if rel == "icon" {
+						setIfEmpty(&m.Favicon, href)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	setIfEmpty(&m.Title, title)
+	setIfEmpty(&m.Description, description)
+	return m, nil
+}
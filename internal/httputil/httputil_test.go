@@ -0,0 +1,110 @@
+package httputil
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExtractPageMetadata(t *testing.T) {
+	tests := []struct {
+		doc    string
+		expect PageMetadata
+	}{
+		{
+			doc: `<html><head><title>Page title</title><meta name="description" content="Page description"></head></html>`,
+			expect: PageMetadata{
+				Title:       "Page title",
+				Description: "Page description",
+			},
+		},
+		{
+			doc: `<html><head>
+				<title>Page title</title>
+				<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta property="og:site_name" content="Site">
+				<meta property="og:image" content="https://example.com/a.jpg">
+				<link rel="shortcut icon" href="/favicon.ico">
+				<link rel="alternate" type="application/json+oembed" href="https://example.com/oembed?url=x">
+			</head></html>`,
+			expect: PageMetadata{
+				Title:       "OG title",
+				Description: "OG description",
+				SiteName:    "Site",
+				Image:       "https://example.com/a.jpg",
+				Favicon:     "/favicon.ico",
+			},
+		},
+		{
+			doc:    `not an html document`,
+			expect: PageMetadata{},
+		},
+	}
+	for _, test := range tests {
+		got, err := ExtractPageMetadata(strings.NewReader(test.doc))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *got != test.expect {
+			t.Errorf("expected %+v, got %+v", test.expect, *got)
+		}
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	tests := []struct {
+		ip     string
+		expect bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"2002:7f00:1::1", false},      // 6to4 of 127.0.0.1.
+		{"2001:0:4136:e378::1", false}, // Teredo.
+		{"fec0::1", false},
+	}
+	for _, test := range tests {
+		if got := IPAllowed(net.ParseIP(test.ip)); got != test.expect {
+			t.Errorf("IPAllowed(%s): expected %v, got %v", test.ip, test.expect, got)
+		}
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	body := "<!DOCTYPE html><html><head><title>Hello</title></head></html>"
+	res := &http.Response{Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	if err := sniffContentType(res); err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("got content type %q, want text/html", got)
+	}
+	if !(&FetchOptions{ContentTypes: []string{"text/html"}}).contentTypeAllowed(res.Header.Get("Content-Type")) {
+		t.Error("sniffed content type not allowed")
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != body {
+		t.Errorf("body changed by sniffing: got %q", data)
+	}
+}
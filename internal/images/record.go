@@ -145,6 +145,16 @@ func (r *ImageRecord) store() store {
 	return matchStore(r.StoreName)
 }
 
+// Data returns the image file, as it was saved (in its original size and
+// format).
+func (r *ImageRecord) Data() ([]byte, error) {
+	store := r.store()
+	if store == nil {
+		return nil, fmt.Errorf("image store %v is not found", r.StoreName)
+	}
+	return store.get(r)
+}
+
 func (r *ImageRecord) StoreExists() bool {
 	return r.store() != nil
 }
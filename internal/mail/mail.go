@@ -0,0 +1,154 @@
+// Package mail implements sending emails through pluggable senders: an SMTP
+// server, or a folder on disk (for development and tests).
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is an email. At least one of Text and HTML should be set. If both
+// are, the message is sent as multipart/alternative.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+
+	// Headers are extra headers (like List-Unsubscribe).
+	Headers map[string]string
+}
+
+// Sender sends emails.
+type Sender interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// Bytes returns m in the RFC 5322 format.
+func (m *Message) Bytes() ([]byte, error) {
+	if m.Text == "" && m.HTML == "" {
+		return nil, errors.New("mail: empty message")
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value) // no header injection
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", m.From)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	keys := make([]string, 0, len(m.Headers))
+	for key := range m.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		header(key, m.Headers[key])
+	}
+
+	if m.Text == "" || m.HTML == "" {
+		body, contentType := m.Text, "text/plain"
+		if m.HTML != "" {
+			body, contentType = m.HTML, "text/html"
+		}
+		header("Content-Type", contentType+"; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		return buf.Bytes(), writeQuotedPrintable(&buf, body)
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", m.Text},
+		{"text/html", m.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, s string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// SMTPSender sends emails through an SMTP server. If Username is empty, no
+// authentication is done.
+type SMTPSender struct {
+	Addr     string // host:port
+	Username string
+	Password string
+}
+
+func (s *SMTPSender) Send(ctx context.Context, m *Message) error {
+	data, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, m.From, []string{m.To}, data)
+}
+
+// FileSender saves emails as .eml files in Folder instead of sending them.
+type FileSender struct {
+	Folder string
+}
+
+func (s *FileSender) Send(ctx context.Context, m *Message) error {
+	data, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Folder, 0755); err != nil {
+		return err
+	}
+	to := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, m.To)
+	name := time.Now().Format("20060102-150405.000000000") + "-" + to + ".eml"
+	return os.WriteFile(filepath.Join(s.Folder, name), data, 0644)
+}
@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	s := &FileSender{Folder: dir}
+	m := &Message{
+		From:    "site@example.com",
+		To:      "user@example.com",
+		Subject: "Hello, wörld",
+		Text:    "Plain text",
+		HTML:    "<p>HTML</p>",
+		Headers: map[string]string{"List-Unsubscribe": "<https://example.com/unsubscribe>"},
+	}
+	if err := s.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("want 1 .eml file, got %v (err: %v)", files, err)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != m.Subject {
+		t.Errorf("subject: got %q (err: %v), want %q", subject, err, m.Subject)
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != m.Headers["List-Unsubscribe"] {
+		t.Errorf("List-Unsubscribe: got %q", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type: got %q (err: %v)", mediaType, err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(p) // quoted-printable is decoded by the reader
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, string(data))
+	}
+	if strings.Join(bodies, "|") != "Plain text|<p>HTML</p>" {
+		t.Errorf("bodies: got %q", bodies)
+	}
+}
+
+func TestMessageHeaderInjection(t *testing.T) {
+	m := &Message{From: "a@example.com", To: "b@example.com\r\nBcc: c@example.com", Subject: "s", Text: "t"}
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "\r\nBcc:") {
+		t.Error("header injected")
+	}
+}
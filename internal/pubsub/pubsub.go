@@ -0,0 +1,287 @@
+// Package pubsub implements a publish-subscribe message broker, either within
+// a single process or, backed by Redis, across many.
+package pubsub
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// SubscriptionBufferSize is the number of messages that are buffered for a
+// subscriber. A subscriber that falls further behind is dropped.
+const SubscriptionBufferSize = 64
+
+// Broker delivers the messages published to a channel to all the subscribers
+// of that channel.
+type Broker interface {
+	// Publish publishes data to channel. It doesn't block on slow
+	// subscribers.
+	Publish(channel string, data []byte) error
+
+	// Subscribe subscribes to channels. Close the returned subscription when
+	// done.
+	Subscribe(channels ...string) *Subscription
+
+	Close() error
+}
+
+// Subscription is a subscription to one or more channels.
+type Subscription struct {
+	hub      *hub
+	channels []string
+
+	c         chan Message
+	closeOnce sync.Once
+
+	mu      sync.Mutex // guards the following
+	dropped bool
+}
+
+// Message is a message published to a channel.
+type Message struct {
+	Channel string
+	Data    []byte
+}
+
+// C returns the channel on which messages are delivered. It's closed when the
+// subscription is closed, or when the subscriber is dropped for not keeping up
+// (see Dropped).
+func (s *Subscription) C() <-chan Message {
+	return s.c
+}
+
+// Dropped reports whether the subscription was closed because the subscriber
+// didn't keep up with the messages. Some messages are lost in that case.
+func (s *Subscription) Dropped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close closes the subscription. It's safe to call Close more than once.
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+	s.close(false)
+}
+
+func (s *Subscription) close(dropped bool) {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.dropped = dropped
+		s.mu.Unlock()
+		close(s.c)
+	})
+}
+
+// hub delivers messages to the subscriptions of a single process.
+type hub struct {
+	mu   sync.RWMutex // guards subs
+	subs map[string]map[*Subscription]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subs: make(map[string]map[*Subscription]struct{})}
+}
+
+func (h *hub) subscribe(channels ...string) *Subscription {
+	s := &Subscription{
+		hub:      h,
+		channels: channels,
+		c:        make(chan Message, SubscriptionBufferSize),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, channel := range channels {
+		if h.subs[channel] == nil {
+			h.subs[channel] = make(map[*Subscription]struct{})
+		}
+		h.subs[channel][s] = struct{}{}
+	}
+	return s
+}
+
+func (h *hub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, channel := range s.channels {
+		delete(h.subs[channel], s)
+		if len(h.subs[channel]) == 0 {
+			delete(h.subs, channel)
+		}
+	}
+}
+
+func (h *hub) deliver(channel string, data []byte) {
+	var slow []*Subscription
+	h.mu.RLock()
+	for s := range h.subs[channel] {
+		select {
+		case s.c <- Message{Channel: channel, Data: data}:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range slow {
+		h.remove(s)
+		s.close(true)
+	}
+}
+
+func (h *hub) closeAll() {
+	h.mu.Lock()
+	var all []*Subscription
+	for _, subs := range h.subs {
+		for s := range subs {
+			all = append(all, s)
+		}
+	}
+	h.subs = make(map[string]map[*Subscription]struct{})
+	h.mu.Unlock()
+	for _, s := range all {
+		s.close(false)
+	}
+}
+
+// MemoryBroker is a Broker for a single process.
+type MemoryBroker struct {
+	hub *hub
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{hub: newHub()}
+}
+
+func (b *MemoryBroker) Publish(channel string, data []byte) error {
+	b.hub.deliver(channel, data)
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(channels ...string) *Subscription {
+	return b.hub.subscribe(channels...)
+}
+
+func (b *MemoryBroker) Close() error {
+	b.hub.closeAll()
+	return nil
+}
+
+// RedisBroker is a Broker backed by Redis pub/sub, so that messages published
+// by any process reach the subscribers of all processes. Each process keeps a
+// single Redis connection for receiving messages, which it fans out to its
+// subscribers.
+type RedisBroker struct {
+	pool   *redis.Pool
+	prefix string
+	hub    *hub
+
+	mu     sync.Mutex // guards the following
+	conn   *redis.PubSubConn
+	closed bool
+}
+
+// NewRedisBroker returns a RedisBroker that uses the Redis server at address.
+// The names of the Redis channels used are prefixed with prefix.
+func NewRedisBroker(network, address, prefix string) *RedisBroker {
+	b := &RedisBroker{
+		pool: &redis.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 240 * time.Second,
+			Dial:        func() (redis.Conn, error) { return redis.Dial(network, address) },
+		},
+		prefix: prefix,
+		hub:    newHub(),
+	}
+	go b.receive(network, address)
+	return b
+}
+
+func (b *RedisBroker) Publish(channel string, data []byte) error {
+	conn := b.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("PUBLISH", b.prefix+channel, data)
+	return err
+}
+
+func (b *RedisBroker) Subscribe(channels ...string) *Subscription {
+	return b.hub.subscribe(channels...)
+}
+
+// receive receives all the messages published (by any process) and delivers
+// them to the subscribers of this process. It reconnects if the connection is
+// lost.
+func (b *RedisBroker) receive(network, address string) {
+	backoff := time.Second
+	for {
+		b.mu.Lock()
+		closed := b.closed
+		b.mu.Unlock()
+		if closed {
+			return
+		}
+
+		if err := b.receiveConn(network, address); err != nil {
+			log.Printf("pubsub: redis receive error (reconnecting in %v): %v\n", backoff, err)
+			time.Sleep(backoff)
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+func (b *RedisBroker) receiveConn(network, address string) error {
+	c, err := redis.Dial(network, address)
+	if err != nil {
+		return err
+	}
+	conn := &redis.PubSubConn{Conn: c}
+	defer conn.Close()
+	if err := conn.PSubscribe(b.prefix + "*"); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.conn = conn
+	b.mu.Unlock()
+
+	for {
+		switch v := conn.Receive().(type) {
+		case redis.Message:
+			b.hub.deliver(v.Channel[len(b.prefix):], v.Data)
+		case redis.Subscription:
+			if v.Count == 0 { // unsubscribed by Close
+				return nil
+			}
+		case error:
+			b.mu.Lock()
+			closed := b.closed
+			b.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return v
+		}
+	}
+}
+
+func (b *RedisBroker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	if b.conn != nil {
+		b.conn.PUnsubscribe()
+	}
+	b.mu.Unlock()
+	b.hub.closeAll()
+	return b.pool.Close()
+}
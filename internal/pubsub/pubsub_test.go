@@ -0,0 +1,56 @@
+package pubsub
+
+import "testing"
+
+func TestMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	a := b.Subscribe("a")
+	ab := b.Subscribe("a", "b")
+	defer a.Close()
+	defer ab.Close()
+
+	b.Publish("a", []byte("1"))
+	b.Publish("b", []byte("2"))
+	b.Publish("c", []byte("3"))
+
+	if m := <-a.C(); m.Channel != "a" || string(m.Data) != "1" {
+		t.Errorf("a: got %v %q", m.Channel, m.Data)
+	}
+	if len(a.C()) != 0 {
+		t.Errorf("a: got %d extra messages", len(a.C()))
+	}
+	for _, want := range []string{"1", "2"} {
+		if m := <-ab.C(); string(m.Data) != want {
+			t.Errorf("ab: got %q, want %q", m.Data, want)
+		}
+	}
+
+	a.Close()
+	a.Close() // no panic
+	b.Publish("a", []byte("4"))
+	if _, ok := <-a.C(); ok {
+		t.Error("closed subscription received a message")
+	}
+}
+
+func TestMemoryBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	s := b.Subscribe("a")
+	for i := 0; i < SubscriptionBufferSize+1; i++ {
+		b.Publish("a", []byte("x"))
+	}
+	n := 0
+	for range s.C() {
+		n++
+	}
+	if n != SubscriptionBufferSize {
+		t.Errorf("got %d messages, want %d", n, SubscriptionBufferSize)
+	}
+	if !s.Dropped() {
+		t.Error("slow subscriber not dropped")
+	}
+}
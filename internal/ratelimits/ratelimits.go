@@ -2,11 +2,21 @@
 package ratelimits
 
 import (
+	"database/sql"
+	"sync"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
+// Limiter is a token bucket rate limiter.
+type Limiter interface {
+	// Limit takes a token from the bucket with the ID bucketID (which is
+	// filled to maxTokens after interval). It returns true if there was a
+	// token left in the bucket.
+	Limit(bucketID string, interval time.Duration, maxTokens int) (bool, error)
+}
+
 func redisKey(bucketID, suffix string) string {
 	return "rl:" + bucketID + ":" + suffix
 }
@@ -52,3 +62,103 @@ func Limit(conn redis.Conn, bucketID string, interval time.Duration, maxTokens i
 	}
 	return false, nil
 }
+
+// RedisLimiter is a Limiter that keeps its buckets in Redis.
+type RedisLimiter struct {
+	Pool *redis.Pool
+}
+
+func (l *RedisLimiter) Limit(bucketID string, interval time.Duration, maxTokens int) (bool, error) {
+	conn := l.Pool.Get()
+	defer conn.Close()
+	return Limit(conn, bucketID, interval, maxTokens)
+}
+
+type bucket struct {
+	tokens      int
+	lastUpdated time.Time
+}
+
+// MemoryLimiter is a Limiter that keeps its buckets in memory. It's only
+// suitable for single-node deployments (and tests).
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewMemoryLimiter returns an in-memory Limiter.
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket)}
+}
+
+func (l *MemoryLimiter) Limit(bucketID string, interval time.Duration, maxTokens int) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[bucketID]
+	if !ok || now.Sub(b.lastUpdated) >= interval {
+		b = &bucket{tokens: maxTokens, lastUpdated: now}
+		l.buckets[bucketID] = b
+	}
+	if b.tokens <= 0 {
+		return false, nil
+	}
+	b.tokens--
+	return true, nil
+}
+
+// Purge removes the buckets that haven't been used for longer than maxAge.
+func (l *MemoryLimiter) Purge(maxAge time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, b := range l.buckets {
+		if time.Since(b.lastUpdated) > maxAge {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// SQLLimiter is a Limiter that keeps its buckets in the rate_limits table of
+// the database.
+type SQLLimiter struct {
+	DB *sql.DB
+}
+
+func (l *SQLLimiter) Limit(bucketID string, interval time.Duration, maxTokens int) (bool, error) {
+	tx, err := l.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var tokens int
+	var lastUpdated int64
+	err = tx.QueryRow("SELECT tokens, updated_at FROM rate_limits WHERE bucket = ? FOR UPDATE", bucketID).Scan(&tokens, &lastUpdated)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if err == sql.ErrNoRows || now.Sub(time.Unix(lastUpdated, 0)) >= interval {
+		tokens, lastUpdated = maxTokens, now.Unix()
+	}
+
+	ok := tokens > 0
+	if ok {
+		tokens--
+	}
+	if _, err := tx.Exec("INSERT INTO rate_limits (bucket, tokens, updated_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE tokens = ?, updated_at = ?",
+		bucketID, tokens, lastUpdated, tokens, lastUpdated); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// Purge removes the buckets that haven't been used for longer than maxAge.
+func (l *SQLLimiter) Purge(maxAge time.Duration) error {
+	_, err := l.DB.Exec("DELETE FROM rate_limits WHERE updated_at < ?", time.Now().Add(-maxAge).Unix())
+	return err
+}
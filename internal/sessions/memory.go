@@ -0,0 +1,184 @@
+package sessions
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a session store that keeps everything in memory. It's meant
+// for single-node deployments and for tests; all sessions are lost when the
+// process exits.
+type MemoryStore struct {
+	// CookieName is the name of the session cookie.
+	CookieName string
+
+	// Session ID length (ie cookie value).
+	IDLength int
+
+	mu           sync.Mutex
+	sessions     map[string]memoryEntry // Values are JSON encoded, as in Redis.
+	userSessions map[string]map[string]struct{}
+	otps         map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	data    string
+	expires time.Time
+}
+
+func (e memoryEntry) expired() bool {
+	return time.Now().After(e.expires)
+}
+
+// NewMemoryStore returns an in-memory session store.
+func NewMemoryStore(cookieName string) *MemoryStore {
+	return &MemoryStore{
+		CookieName:   cookieName,
+		IDLength:     defaultSessionIDLength,
+		sessions:     make(map[string]memoryEntry),
+		userSessions: make(map[string]map[string]struct{}),
+		otps:         make(map[string]memoryEntry),
+	}
+}
+
+// Close is a no-op.
+func (ms *MemoryStore) Close() error {
+	return nil
+}
+
+// Get returns a session from the store, or, if absent, it creates one. Call
+// Save to store the session and set a Set-Cookie header.
+func (ms *MemoryStore) Get(r *http.Request) (*Session, error) {
+	return getSession(ms, ms.CookieName, ms.IDLength, r)
+}
+
+// Save saves the session values and, if the session is new, it adds a
+// Set-Cookie header to ResponseWriter.
+func (ms *MemoryStore) Save(w http.ResponseWriter, r *http.Request, s *Session) error {
+	data, err := json.Marshal(s.Values)
+	if err != nil {
+		return err
+	}
+
+	setSessionCookie(w, r, ms.CookieName, s)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.sessions[s.ID] = memoryEntry{data: string(data), expires: time.Now().Add(sessionTTL)}
+	return nil
+}
+
+func (ms *MemoryStore) Load(sessionID string) (*Session, error) {
+	ms.mu.Lock()
+	entry, ok := ms.sessions[sessionID]
+	if ok && entry.expired() {
+		delete(ms.sessions, sessionID)
+		ok = false
+	}
+	ms.mu.Unlock()
+	if !ok {
+		return nil, ErrNoSession
+	}
+
+	s := &Session{
+		store:     ms,
+		ID:        sessionID,
+		Values:    make(map[string]interface{}),
+		CookieSet: true,
+	}
+	if err := json.Unmarshal([]byte(entry.data), &s.Values); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (ms *MemoryStore) Delete(sessionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.sessions, sessionID)
+	return nil
+}
+
+func (ms *MemoryStore) AddUserSession(user, sessionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	set, ok := ms.userSessions[user]
+	if !ok {
+		set = make(map[string]struct{})
+		ms.userSessions[user] = set
+	}
+	set[sessionID] = struct{}{}
+	return nil
+}
+
+func (ms *MemoryStore) RemoveUserSession(user, sessionID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if set, ok := ms.userSessions[user]; ok {
+		delete(set, sessionID)
+		if len(set) == 0 {
+			delete(ms.userSessions, user)
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStore) UserSessions(user string) ([]string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ids := []string{}
+	for id := range ms.userSessions[user] {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (ms *MemoryStore) DeleteUserSessions(user string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.userSessions, user)
+	return nil
+}
+
+func (ms *MemoryStore) SaveOTP(key, code string, ttl time.Duration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.otps[key] = memoryEntry{data: code, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (ms *MemoryStore) ValidateOTP(key, code string) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	entry, ok := ms.otps[key]
+	if !ok || entry.expired() {
+		return false, nil
+	}
+	return entry.data == code, nil
+}
+
+func (ms *MemoryStore) DeleteOTP(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.otps, key)
+	return nil
+}
+
+// PurgeExpired removes the expired sessions and OTPs from memory.
+func (ms *MemoryStore) PurgeExpired() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for id, entry := range ms.sessions {
+		if entry.expired() {
+			delete(ms.sessions, id)
+		}
+	}
+	for key, entry := range ms.otps {
+		if entry.expired() {
+			delete(ms.otps, key)
+		}
+	}
+	return nil
+}
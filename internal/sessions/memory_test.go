@@ -0,0 +1,85 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore("SID")
+
+	// A new session is only persisted on Save, which sets the cookie.
+	r := httptest.NewRequest("GET", "/", nil)
+	ses, err := store.Get(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ses.CookieSet {
+		t.Fatal("new session has CookieSet true")
+	}
+	ses.Values["uid"] = "abc"
+	w := httptest.NewRecorder()
+	if err := ses.Save(w, r); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != ses.ID {
+		t.Fatalf("expected the session cookie to be set, got %v", cookies)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "SID", Value: ses.ID})
+	got, err := store.Get(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CookieSet || got.Values["uid"] != "abc" {
+		t.Errorf("stored session not returned, got %+v", got)
+	}
+
+	if err := store.AddUserSession("user", ses.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := store.UserSessions("user"); len(ids) != 1 || ids[0] != ses.ID {
+		t.Errorf("expected user sessions [%s], got %v", ses.ID, ids)
+	}
+	store.RemoveUserSession("user", ses.ID)
+	if ids, _ := store.UserSessions("user"); len(ids) != 0 {
+		t.Errorf("expected no user sessions, got %v", ids)
+	}
+
+	store.Delete(ses.ID)
+	if _, err := store.Load(ses.ID); err != ErrNoSession {
+		t.Errorf("expected ErrNoSession for a deleted session, got %v", err)
+	}
+}
+
+func TestMemoryStoreOTP(t *testing.T) {
+	store := NewMemoryStore("SID")
+	store.SaveOTP("key", "1234", time.Minute)
+	store.SaveOTP("expired", "1234", -time.Second)
+
+	tests := []struct {
+		key, code string
+		expect    bool
+	}{
+		{"key", "1234", true},
+		{"key", "4321", false},
+		{"expired", "1234", false},
+		{"missing", "1234", false},
+	}
+	for _, test := range tests {
+		if ok, err := store.ValidateOTP(test.key, test.code); err != nil {
+			t.Fatal(err)
+		} else if ok != test.expect {
+			t.Errorf("ValidateOTP(%q, %q) expected %v, got %v", test.key, test.code, test.expect, ok)
+		}
+	}
+
+	store.DeleteOTP("key")
+	if ok, _ := store.ValidateOTP("key", "1234"); ok {
+		t.Error("deleted OTP validated")
+	}
+}
@@ -0,0 +1,185 @@
+package sessions
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisStore implements a session store which uses Redis as its underlying
+// data store.
+type RedisStore struct {
+	// CookieName is the name of the session cookie.
+	CookieName string
+
+	// Session ID length (ie cookie value).
+	IDLength int
+
+	pool *redis.Pool
+}
+
+// NewRedisStore returns a session store that uses Redis for storage. Redis
+// runs on tcp port 6381 by default.
+func NewRedisStore(network, address, cookieName string) (*RedisStore, error) {
+	store := &RedisStore{CookieName: cookieName, IDLength: defaultSessionIDLength}
+	store.pool = &redis.Pool{
+		MaxIdle: 30,
+		// MaxActive:   10,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(network, address)
+		},
+	}
+
+	return store, nil
+}
+
+// Close closes the underlying redis resources.
+func (rs *RedisStore) Close() error {
+	return rs.pool.Close()
+}
+
+// Get returns a session from the store, or, if absent, it creates one. Call
+// Save to store the session and set a Set-Cookie header.
+func (rs *RedisStore) Get(r *http.Request) (*Session, error) {
+	return getSession(rs, rs.CookieName, rs.IDLength, r)
+}
+
+// Save saves the session values to redis and, if the session is new, it adds a
+// Set-Cookie header to ResponseWriter.
+func (rs *RedisStore) Save(w http.ResponseWriter, r *http.Request, s *Session) error {
+	data, err := json.Marshal(s.Values)
+	if err != nil {
+		return err
+	}
+
+	setSessionCookie(w, r, rs.CookieName, s)
+
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	key := rs.RedisKey(s.ID)
+
+	conn.Send("MULTI")
+	conn.Send("SET", key, string(data))
+	conn.Send("EXPIRE", key, int64(float64(sessionTTL)/1e9))
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+// Load returns the stored session with the given ID.
+func (rs *RedisStore) Load(sessionID string) (*Session, error) {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	res, err := redis.String(conn.Do("GET", rs.RedisKey(sessionID)))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, ErrNoSession
+		}
+		return nil, err
+	}
+
+	s := &Session{
+		store:     rs,
+		ID:        sessionID,
+		Values:    make(map[string]interface{}),
+		CookieSet: true,
+	}
+	if err := json.Unmarshal([]byte(res), &s.Values); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Delete removes the session with the given ID from the store. Deleting a
+// session that doesn't exist is not an error.
+func (rs *RedisStore) Delete(sessionID string) error {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", rs.RedisKey(sessionID))
+	return err
+}
+
+// RedisKey returns the key the session data is stored in Redis.
+func (rs *RedisStore) RedisKey(sessionID string) string {
+	return "rs_" + rs.CookieName + ":" + sessionID
+}
+
+// userSessionsKey returns the Redis key where the set of session IDs of the
+// user are stored.
+func userSessionsKey(user string) string {
+	return "sessions:" + user
+}
+
+func (rs *RedisStore) AddUserSession(user, sessionID string) error {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SADD", userSessionsKey(user), sessionID)
+	return err
+}
+
+func (rs *RedisStore) RemoveUserSession(user, sessionID string) error {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SREM", userSessionsKey(user), sessionID)
+	return err
+}
+
+func (rs *RedisStore) UserSessions(user string) ([]string, error) {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	return redis.Strings(conn.Do("SMEMBERS", userSessionsKey(user)))
+}
+
+func (rs *RedisStore) DeleteUserSessions(user string) error {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", userSessionsKey(user))
+	return err
+}
+
+// SaveOTP saves the OTP code into Redis with an expiration time.
+func (rs *RedisStore) SaveOTP(key, otpCode string, ttl time.Duration) error {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	// Set the OTP code with expiration
+	_, err := conn.Do("SETEX", "otp:"+key, int(ttl.Seconds()), otpCode)
+	return err
+}
+
+// ValidateOTP retrieves and validates the OTP code saved under key.
+func (rs *RedisStore) ValidateOTP(key, otpCode string) (bool, error) {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	// Retrieve the OTP code from Redis
+	storedOTP, err := redis.String(conn.Do("GET", "otp:"+key))
+	if err == redis.ErrNil {
+		// OTP does not exist or has expired
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Compare stored OTP with provided OTP
+	return storedOTP == otpCode, nil
+}
+
+// DeleteOTP deletes the OTP code saved under key.
+func (rs *RedisStore) DeleteOTP(key string) error {
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", "otp:"+key)
+	return err
+}
@@ -1,15 +1,21 @@
 package sessions
 
 import (
-	"encoding/json"
+	"errors"
 	"math/rand"
 	"net/http"
 	"time"
+)
+
+const (
+	defaultSessionIDLength = 36
 
-	"github.com/gomodule/redigo/redis"
+	// sessionTTL is how long a session lasts after it was last saved.
+	sessionTTL = time.Hour * 24 * 30 * 12 // 1 year
 )
 
-const defaultSessionIDLength = 36
+// ErrNoSession is returned by Load if there's no session with the given ID.
+var ErrNoSession = errors.New("sessions: session not found")
 
 // Store is a session store.
 type Store interface {
@@ -21,6 +27,38 @@ type Store interface {
 	// Save saves the session to the underlying store and sets http cookie
 	// headers.
 	Save(w http.ResponseWriter, r *http.Request, s *Session) error
+
+	// Load returns the stored session with the given ID, or ErrNoSession.
+	Load(sessionID string) (*Session, error)
+
+	// Delete removes the session with the given ID from the store. Deleting a
+	// session that doesn't exist is not an error.
+	Delete(sessionID string) error
+
+	// AddUserSession, RemoveUserSession, and UserSessions maintain the set of
+	// session IDs of each user (identified by user), so that all sessions of a
+	// user can be found (and logged out). DeleteUserSessions deletes the set
+	// (but not the sessions themselves).
+	AddUserSession(user, sessionID string) error
+	RemoveUserSession(user, sessionID string) error
+	UserSessions(user string) ([]string, error)
+	DeleteUserSessions(user string) error
+
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// OTPStore stores short-lived one-time passwords.
+type OTPStore interface {
+	// SaveOTP saves code under key, to expire after ttl.
+	SaveOTP(key, code string, ttl time.Duration) error
+
+	// ValidateOTP reports whether code matches the unexpired code saved under
+	// key.
+	ValidateOTP(key, code string) (bool, error)
+
+	// DeleteOTP deletes the code saved under key, if any.
+	DeleteOTP(key string) error
 }
 
 // Session stores a map of session values.
@@ -52,121 +90,46 @@ func (s *Session) Clear() {
 	s.Values = make(map[string]interface{})
 }
 
-// RedisStore implements a session store which uses Redis as its underlying
-// data store.
-type RedisStore struct {
-	// CookieName is the name of the session cookie.
-	CookieName string
-
-	// Session ID length (ie cookie value).
-	IDLength int
-
-	pool *redis.Pool
-}
-
-// NewRedisStore returns a session store that uses Redis for storage. Redis
-// runs on tcp port 6381 by default.
-func NewRedisStore(network, address, cookieName string) (*RedisStore, error) {
-	store := &RedisStore{CookieName: cookieName, IDLength: defaultSessionIDLength}
-	store.pool = &redis.Pool{
-		MaxIdle: 30,
-		// MaxActive:   10,
-		IdleTimeout: 240 * time.Second,
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial(network, address)
-		},
+func newSession(store Store, idLength int) *Session {
+	return &Session{
+		store:     store,
+		ID:        generateID(idLength),
+		Values:    make(map[string]interface{}),
+		CookieSet: false,
 	}
-
-	return store, nil
 }
 
-// Close closes the underlying redis resources.
-func (rs *RedisStore) Close() error {
-	return rs.pool.Close()
-}
-
-// Get returns a session from the store, or, if absent, it creates one. Call
-// Save to store the session and set a Set-Cookie header.
-func (rs *RedisStore) Get(r *http.Request) (*Session, error) {
-	cookie, err := r.Cookie(rs.CookieName)
+// getSession is the common implementation of Store.Get.
+func getSession(store Store, cookieName string, idLength int, r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(cookieName)
 	if err == http.ErrNoCookie { // only possible error
-		return rs.newSession()
+		return newSession(store, idLength), nil
 	}
-
-	conn := rs.pool.Get()
-	defer conn.Close()
-
-	res, err := redis.String(conn.Do("GET", rs.RedisKey(cookie.Value)))
+	s, err := store.Load(cookie.Value)
 	if err != nil {
-		if err == redis.ErrNil { // cookie exists but no matching store record
-			return rs.newSession()
+		if err == ErrNoSession { // cookie exists but no matching store record
+			return newSession(store, idLength), nil
 		}
 		return nil, err
 	}
-
-	s := &Session{
-		store:     rs,
-		ID:        cookie.Value,
-		Values:    make(map[string]interface{}),
-		CookieSet: true,
-	}
-
-	if err := json.Unmarshal([]byte(res), &s.Values); err != nil {
-		return nil, err
-	}
-
 	return s, nil
 }
 
-func (rs *RedisStore) newSession() (*Session, error) {
-	s := &Session{
-		store:     rs,
-		ID:        generateID(rs.IDLength),
-		Values:    make(map[string]interface{}),
-		CookieSet: false,
-	}
-	return s, nil
-}
-
-// Save saves the session values to redis and, if the session is new, it adds a
-// Set-Cookie header to ResponseWriter.
-func (rs *RedisStore) Save(w http.ResponseWriter, r *http.Request, s *Session) error {
-	data, err := json.Marshal(s.Values)
-	if err != nil {
-		return err
-	}
-
-	expires := time.Hour * 24 * 30 * 12 // 1 year
-
-	cookie, err := r.Cookie(rs.CookieName)
+// setSessionCookie adds a Set-Cookie header for s, if the session is new.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, cookieName string, s *Session) {
+	cookie, err := r.Cookie(cookieName)
 	if !s.CookieSet && (err == http.ErrNoCookie || cookie.Value != s.ID) {
 		http.SetCookie(w, &http.Cookie{
-			Name:     rs.CookieName,
+			Name:     cookieName,
 			Value:    s.ID,
 			Secure:   true,
 			HttpOnly: true,
 			Path:     "/",
-			Expires:  time.Now().UTC().Add(expires),
+			Expires:  time.Now().UTC().Add(sessionTTL),
 			SameSite: http.SameSiteLaxMode,
 		})
 		s.CookieSet = true
 	}
-
-	conn := rs.pool.Get()
-	defer conn.Close()
-
-	key := rs.RedisKey(s.ID)
-
-	conn.Send("MULTI")
-	conn.Send("SET", key, string(data))
-	conn.Send("EXPIRE", key, int64(float64(expires)/1e9))
-	_, err = conn.Do("EXEC")
-	return err
-}
-
-// RedisKey returns the key the session data is stored in Redis.
-func (rs *RedisStore) RedisKey(sessionID string) string {
-	return "rs_" + rs.CookieName + ":" + sessionID
 }
 
 func generateID(length int) string {
@@ -179,38 +142,3 @@ func generateID(length int) string {
 
 	return id
 }
-
-// SaveOTP saves the OTP code and session ID into Redis with an expiration time.
-func (rs *RedisStore) SaveOTP(sessionID, otpCode string, ttl time.Duration) error {
-	conn := rs.pool.Get()
-	defer conn.Close()
-
-	// Create the key for OTP storage
-	key := "otp:" + sessionID
-
-	// Set the OTP code with expiration
-	_, err := conn.Do("SETEX", key, int(ttl.Seconds()), otpCode)
-	return err
-}
-
-// ValidateOTP retrieves and validates the OTP code for a given session ID.
-func (rs *RedisStore) ValidateOTP(sessionID, otpCode string) (bool, error) {
-	conn := rs.pool.Get()
-	defer conn.Close()
-
-	// Create the key for OTP storage
-	key := "otp:" + sessionID
-
-	// Retrieve the OTP code from Redis
-	storedOTP, err := redis.String(conn.Do("GET", key))
-	if err == redis.ErrNil {
-		// OTP does not exist or has expired
-		return false, nil
-	}
-	if err != nil {
-		return false, err
-	}
-
-	// Compare stored OTP with provided OTP
-	return storedOTP == otpCode, nil
-}
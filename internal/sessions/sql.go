@@ -0,0 +1,151 @@
+package sessions
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// SQLStore is a session store backed by the (MariaDB) database, using the
+// sessions, user_sessions, and otps tables. Expired rows are ignored, and
+// removed by PurgeExpired.
+type SQLStore struct {
+	// CookieName is the name of the session cookie.
+	CookieName string
+
+	// Session ID length (ie cookie value).
+	IDLength int
+
+	db *sql.DB
+}
+
+// NewSQLStore returns a session store that uses db for storage.
+func NewSQLStore(db *sql.DB, cookieName string) *SQLStore {
+	return &SQLStore{CookieName: cookieName, IDLength: defaultSessionIDLength, db: db}
+}
+
+// Close is a no-op; the database is not owned by the store.
+func (ss *SQLStore) Close() error {
+	return nil
+}
+
+// Get returns a session from the store, or, if absent, it creates one. Call
+// Save to store the session and set a Set-Cookie header.
+func (ss *SQLStore) Get(r *http.Request) (*Session, error) {
+	return getSession(ss, ss.CookieName, ss.IDLength, r)
+}
+
+// Save saves the session values to the database and, if the session is new, it
+// adds a Set-Cookie header to ResponseWriter.
+func (ss *SQLStore) Save(w http.ResponseWriter, r *http.Request, s *Session) error {
+	data, err := json.Marshal(s.Values)
+	if err != nil {
+		return err
+	}
+
+	setSessionCookie(w, r, ss.CookieName, s)
+
+	expires := time.Now().Add(sessionTTL)
+	_, err = ss.db.Exec("INSERT INTO sessions (id, data, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE data = ?, expires_at = ?",
+		s.ID, data, expires, data, expires)
+	return err
+}
+
+func (ss *SQLStore) Load(sessionID string) (*Session, error) {
+	var data []byte
+	err := ss.db.QueryRow("SELECT data FROM sessions WHERE id = ? AND expires_at > ?", sessionID, time.Now()).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoSession
+		}
+		return nil, err
+	}
+
+	s := &Session{
+		store:     ss,
+		ID:        sessionID,
+		Values:    make(map[string]interface{}),
+		CookieSet: true,
+	}
+	if err := json.Unmarshal(data, &s.Values); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (ss *SQLStore) Delete(sessionID string) error {
+	_, err := ss.db.Exec("DELETE FROM sessions WHERE id = ?", sessionID)
+	return err
+}
+
+func (ss *SQLStore) AddUserSession(user, sessionID string) error {
+	_, err := ss.db.Exec("INSERT IGNORE INTO user_sessions (username, session_id) VALUES (?, ?)", user, sessionID)
+	return err
+}
+
+func (ss *SQLStore) RemoveUserSession(user, sessionID string) error {
+	_, err := ss.db.Exec("DELETE FROM user_sessions WHERE username = ? AND session_id = ?", user, sessionID)
+	return err
+}
+
+func (ss *SQLStore) UserSessions(user string) ([]string, error) {
+	rows, err := ss.db.Query("SELECT session_id FROM user_sessions WHERE username = ?", user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (ss *SQLStore) DeleteUserSessions(user string) error {
+	_, err := ss.db.Exec("DELETE FROM user_sessions WHERE username = ?", user)
+	return err
+}
+
+func (ss *SQLStore) SaveOTP(key, code string, ttl time.Duration) error {
+	expires := time.Now().Add(ttl)
+	_, err := ss.db.Exec("INSERT INTO otps (otp_key, code, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE code = ?, expires_at = ?",
+		key, code, expires, code, expires)
+	return err
+}
+
+func (ss *SQLStore) ValidateOTP(key, code string) (bool, error) {
+	var stored string
+	err := ss.db.QueryRow("SELECT code FROM otps WHERE otp_key = ? AND expires_at > ?", key, time.Now()).Scan(&stored)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return stored == code, nil
+}
+
+func (ss *SQLStore) DeleteOTP(key string) error {
+	_, err := ss.db.Exec("DELETE FROM otps WHERE otp_key = ?", key)
+	return err
+}
+
+// PurgeExpired deletes the expired sessions (and their entries in the sets of
+// user sessions) and OTPs.
+func (ss *SQLStore) PurgeExpired() error {
+	now := time.Now()
+	if _, err := ss.db.Exec("DELETE FROM user_sessions WHERE session_id IN (SELECT id FROM sessions WHERE expires_at <= ?)", now); err != nil {
+		return err
+	}
+	if _, err := ss.db.Exec("DELETE FROM sessions WHERE expires_at <= ?", now); err != nil {
+		return err
+	}
+	_, err := ss.db.Exec("DELETE FROM otps WHERE expires_at <= ?", now)
+	return err
+}
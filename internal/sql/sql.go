@@ -343,6 +343,13 @@ func InClauseQuestionMarks(n int) string {
 	return b.String()
 }
 
+// EscapeLike escapes the characters in s that are special in the pattern of a
+// LIKE expression (with the default escape character), so that s is matched
+// literally.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // ColumnValue represents value in a table's row and the column it belongs to.
 type ColumnValue struct {
 	Name  string // name of column
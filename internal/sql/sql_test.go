@@ -316,6 +316,24 @@ func TestInClauseQuestionMark(t *testing.T) {
 	}
 }
 
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		s, expect string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"100%", `100\%`},
+		{"a_b", `a\_b`},
+		{`a\b`, `a\\b`},
+		{`%_\`, `\%\_\\`},
+	}
+	for _, test := range tests {
+		if s := EscapeLike(test.s); s != test.expect {
+			t.Errorf("expected: %v, got: %v", test.expect, s)
+		}
+	}
+}
+
 func TestBuildInsertQuery(t *testing.T) {
 	tests := []struct {
 		table string // table name
@@ -0,0 +1,39 @@
+// Package testdb opens the database used by the tests that need one.
+package testdb
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// EnvVar is the environment variable that holds the DSN of the test database,
+// for example:
+//
+//	DISCUIT_TEST_DB='root:pass@tcp(localhost:3306)/discuit_test?parseTime=true' go test ./...
+//
+// The database should have had the migrations run on it, and tests are free to
+// write to it (they don't clean up after themselves). Tests that need a
+// database are skipped if it's not set.
+const EnvVar = "DISCUIT_TEST_DB"
+
+// Open returns a connection to the test database, which is closed when t
+// finishes. It skips t if EnvVar is not set.
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(EnvVar)
+	if dsn == "" {
+		t.Skip(EnvVar + " is not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("cannot connect to the test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,96 @@
+// Package totp implements time-based one-time passwords (RFC 6238), as used by
+// authenticator apps, with the common defaults: HMAC-SHA1, 6 digits, and a 30
+// second period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	digits     = 6
+	period     = 30 // in seconds
+	secretSize = 20 // in bytes
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random secret, base32 encoded.
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return b32.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// ProvisioningURI returns the otpauth:// URI (usually shown as a QR code) by
+// which authenticator apps add an account.
+func ProvisioningURI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(digits))
+	q.Set("period", fmt.Sprint(period))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Counter returns the time step of t.
+func Counter(t time.Time) int64 {
+	return t.Unix() / period
+}
+
+// CodeAt returns the code of secret for the time step counter.
+func CodeAt(secret string, counter int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, n%1000000), nil
+}
+
+// Validate checks code against secret at time t, allowing for one time step of
+// clock drift either way. If the code is valid, it returns the time step it
+// matched, which callers should store and require later codes to be past, so
+// that a code can't be used twice.
+func Validate(secret, code string, t time.Time) (bool, int64, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != digits {
+		return false, 0, nil
+	}
+	now := Counter(t)
+	for _, counter := range []int64{now, now - 1, now + 1} {
+		want, err := CodeAt(secret, counter)
+		if err != nil {
+			return false, 0, err
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return true, counter, nil
+		}
+	}
+	return false, 0, nil
+}
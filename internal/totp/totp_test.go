@@ -0,0 +1,50 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// The SHA1 test vectors of RFC 6238 (truncated to 6 digits).
+func TestCodeAt(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, item := range cases {
+		got, err := CodeAt(secret, Counter(time.Unix(item.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != item.want {
+			t.Errorf("time %d: got %s, want %s", item.unix, got, item.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, _ := CodeAt(secret, Counter(now.Add(-time.Second*period)))
+	if ok, counter, _ := Validate(secret, code, now); !ok || counter != Counter(now)-1 {
+		t.Errorf("code of the previous time step should be valid")
+	}
+	code, _ = CodeAt(secret, Counter(now.Add(-time.Second*period*3)))
+	if ok, _, _ := Validate(secret, code, now); ok {
+		t.Errorf("old code should not be valid")
+	}
+	if ok, _, _ := Validate(secret, "12345", now); ok {
+		t.Errorf("short code should not be valid")
+	}
+}
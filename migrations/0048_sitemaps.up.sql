@@ -0,0 +1,12 @@
+create table if not exists sitemaps (
+	type varchar (16) not null,
+	shard int not null,
+	first_id binary (12),
+	last_id binary (12),
+	no_items int not null default 0,
+	urls mediumtext not null,
+	lastmod datetime,
+	generated_at datetime not null default current_timestamp(),
+
+	primary key (type, shard)
+);
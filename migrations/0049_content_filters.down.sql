@@ -0,0 +1,12 @@
+delete from reports where reason_id = (select id from report_reasons where title = "Content filter");
+delete from report_reasons where title = "Content filter";
+
+alter table comments drop index comments_held;
+alter table comments drop column held_reason;
+alter table comments drop column held_at;
+
+alter table posts drop index posts_held;
+alter table posts drop column held_reason;
+alter table posts drop column held_at;
+
+drop table if exists content_filters;
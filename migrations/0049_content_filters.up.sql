@@ -0,0 +1,26 @@
+create table if not exists content_filters (
+	id binary (12) not null,
+	community_id binary (12),
+	filter_type tinyint not null,
+	pattern varchar (255) not null,
+	action tinyint not null,
+	created_by binary (12) not null,
+	created_at datetime not null default current_timestamp(),
+
+	primary key (id),
+	index (community_id),
+	foreign key (community_id) references communities (id),
+	foreign key (created_by) references users (id)
+);
+
+alter table posts add column held_at datetime;
+alter table posts add column held_reason varchar (255);
+alter table posts add index posts_held (community_id, held_at);
+
+/* Held comments are counted in posts.no_comments only once they are approved
+(see Comment.Approve). */
+alter table comments add column held_at datetime;
+alter table comments add column held_reason varchar (255);
+alter table comments add index comments_held (community_id, held_at);
+
+insert into report_reasons (title, description) values ("Content filter", "Matched a content filter of the community or the site.");
@@ -0,0 +1,7 @@
+alter table users drop column shadow_limited_at;
+
+drop table if exists spam_flags;
+
+drop table if exists content_hashes;
+
+drop table if exists user_ips;
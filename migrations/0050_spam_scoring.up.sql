@@ -0,0 +1,44 @@
+create table if not exists user_ips (
+	user_id binary (12) not null,
+	ip varchar (45) not null,
+	first_seen_at datetime not null default current_timestamp(),
+	last_seen_at datetime not null default current_timestamp(),
+
+	primary key (user_id, ip),
+	index (ip),
+	foreign key (user_id) references users (id)
+);
+
+insert into user_ips (user_id, ip, first_seen_at, last_seen_at)
+	select id, last_seen_ip, last_seen, last_seen from users where last_seen_ip is not null and last_seen_ip <> "";
+
+create table if not exists content_hashes (
+	hash binary (32) not null,
+	user_id binary (12) not null,
+	created_at datetime not null default current_timestamp(),
+
+	index (hash, created_at),
+	index (created_at)
+);
+
+create table if not exists spam_flags (
+	id binary (12) not null,
+	target_type tinyint not null,
+	target_id binary (12) not null,
+	community_id binary (12) not null,
+	user_id binary (12) not null,
+	score int not null,
+	reasons text not null,
+	action tinyint not null,
+	created_at datetime not null default current_timestamp(),
+	reviewed_at datetime,
+	reviewed_by binary (12),
+
+	primary key (id),
+	index (reviewed_at, created_at),
+	index (user_id),
+	foreign key (community_id) references communities (id),
+	foreign key (user_id) references users (id)
+);
+
+alter table users add column shadow_limited_at datetime;
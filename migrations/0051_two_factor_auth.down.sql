@@ -0,0 +1,5 @@
+drop table if exists recovery_codes;
+
+alter table users drop column totp_last_counter;
+alter table users drop column totp_enabled_at;
+alter table users drop column totp_secret;
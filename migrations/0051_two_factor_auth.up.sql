@@ -0,0 +1,15 @@
+alter table users add column totp_secret varchar (64);
+alter table users add column totp_enabled_at datetime;
+alter table users add column totp_last_counter bigint not null default 0;
+
+create table if not exists recovery_codes (
+	id int not null auto_increment,
+	user_id binary (12) not null,
+	code_hash binary (32) not null,
+	created_at datetime not null default current_timestamp(),
+	used_at datetime,
+
+	primary key (id),
+	index (user_id),
+	foreign key (user_id) references users (id)
+);
@@ -0,0 +1,7 @@
+drop table if exists rate_limits;
+
+drop table if exists otps;
+
+drop table if exists user_sessions;
+
+drop table if exists sessions;
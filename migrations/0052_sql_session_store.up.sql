@@ -0,0 +1,36 @@
+/* Tables for the SQL backend of the session store (sessionStore: sql), which
+also keeps OTPs and rate limit buckets. Nothing is backfilled: sessions in Redis
+are not carried over, so switching to the SQL store logs everyone out. */
+create table if not exists sessions (
+	id varchar (64) not null,
+	data mediumtext not null,
+	expires_at datetime not null,
+
+	primary key (id),
+	index (expires_at)
+);
+
+create table if not exists user_sessions (
+	username varchar (64) not null,
+	session_id varchar (64) not null,
+
+	primary key (username, session_id)
+);
+
+create table if not exists otps (
+	otp_key varchar (255) not null,
+	code varchar (64) not null,
+	expires_at datetime not null,
+
+	primary key (otp_key),
+	index (expires_at)
+);
+
+create table if not exists rate_limits (
+	bucket varchar (255) not null,
+	tokens int not null,
+	updated_at bigint not null,
+
+	primary key (bucket),
+	index (updated_at)
+);
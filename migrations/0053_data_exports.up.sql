@@ -0,0 +1,15 @@
+create table if not exists data_exports (
+	id binary (12) not null,
+	user_id binary (12) not null,
+	status tinyint not null default 0,
+	size bigint not null default 0,
+	error text,
+	created_at datetime not null default current_timestamp(),
+	completed_at datetime,
+	expires_at datetime,
+
+	primary key (id),
+	index (user_id, created_at),
+	index (expires_at),
+	foreign key (user_id) references users (id)
+);
@@ -0,0 +1,7 @@
+drop index users_delete_at on users;
+
+alter table users drop column delete_content;
+alter table users drop column delete_scheduled_by;
+alter table users drop column delete_at;
+alter table users drop column deactivated_by;
+alter table users drop column deactivated_at;
@@ -0,0 +1,7 @@
+alter table users add column deactivated_at datetime;
+alter table users add column deactivated_by binary (12);
+alter table users add column delete_at datetime;
+alter table users add column delete_scheduled_by binary (12);
+alter table users add column delete_content bool not null default false;
+
+create index users_delete_at on users (delete_at);
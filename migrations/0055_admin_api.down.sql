@@ -0,0 +1,4 @@
+alter table users drop column ban_expires_at;
+alter table users drop column ban_reason;
+
+drop table if exists admin_audit_log;
@@ -0,0 +1,19 @@
+create table if not exists admin_audit_log (
+	id bigint unsigned not null auto_increment,
+	admin_id binary (12) not null,
+	action varchar (64) not null,
+	target_type varchar (32),
+	target varchar (255),
+	details text,
+	ip varchar (45),
+	created_at datetime not null default current_timestamp(),
+
+	primary key (id),
+	index (admin_id, created_at),
+	index (target_type, target),
+	index (created_at),
+	foreign key (admin_id) references users (id)
+);
+
+alter table users add column ban_reason text;
+alter table users add column ban_expires_at datetime;
@@ -0,0 +1,4 @@
+alter table users drop index users_ban_expires_at;
+alter table users drop column ban_note;
+
+drop table if exists user_bans;
@@ -0,0 +1,20 @@
+create table if not exists user_bans (
+	id bigint unsigned not null auto_increment,
+	user_id binary (12) not null,
+	banned_by binary (12),
+	reason text,
+	note text,
+	expires_at datetime,
+	created_at datetime not null default current_timestamp(),
+	lifted_at datetime,
+	lifted_by binary (12),
+
+	primary key (id),
+	index (user_id, created_at),
+	foreign key (user_id) references users (id),
+	foreign key (banned_by) references users (id),
+	foreign key (lifted_by) references users (id)
+);
+
+alter table users add column ban_note text;
+alter table users add index users_ban_expires_at (ban_expires_at);
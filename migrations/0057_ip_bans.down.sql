@@ -0,0 +1,9 @@
+-- The hashes cannot be turned back into addresses.
+update users set last_seen_ip = null where length(last_seen_ip) > 45;
+alter table users modify column last_seen_ip varchar (45);
+delete from user_ips;
+alter table user_ips drop index last_seen_at;
+alter table user_ips change ip_hash ip varchar (45) not null;
+
+drop table if exists ip_ban_matches;
+drop table if exists ip_bans;
@@ -0,0 +1,33 @@
+create table if not exists ip_bans (
+	id int unsigned not null auto_increment,
+	cidr varchar (64) not null,
+	action tinyint not null,
+	reason text,
+	created_by binary (12) not null,
+	created_at datetime not null default current_timestamp(),
+	expires_at datetime,
+
+	primary key (id),
+	unique key (cidr),
+	foreign key (created_by) references users (id)
+);
+
+create table if not exists ip_ban_matches (
+	id bigint unsigned not null auto_increment,
+	ip_ban_id int unsigned not null,
+	user_id binary (12),
+	event varchar (16) not null,
+	blocked bool not null,
+	created_at datetime not null default current_timestamp(),
+
+	primary key (id),
+	index (created_at),
+	foreign key (ip_ban_id) references ip_bans (id) on delete cascade,
+	foreign key (user_id) references users (id)
+);
+
+-- Only hashes of IP addresses are kept from now on (the existing addresses are
+-- hashed by the server, see core.HashStoredIPs).
+alter table user_ips change ip ip_hash varchar (64) not null;
+alter table user_ips add index (last_seen_at);
+alter table users modify column last_seen_ip varchar (64);
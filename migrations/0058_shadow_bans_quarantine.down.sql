@@ -0,0 +1,5 @@
+alter table communities drop column quarantine_reason;
+alter table communities drop column quarantined_at;
+
+alter table users drop index users_shadow_banned_at;
+alter table users drop column shadow_banned_at;
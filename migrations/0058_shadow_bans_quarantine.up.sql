@@ -0,0 +1,5 @@
+alter table users add column shadow_banned_at datetime;
+alter table users add index users_shadow_banned_at (shadow_banned_at);
+
+alter table communities add column quarantined_at datetime;
+alter table communities add column quarantine_reason text;
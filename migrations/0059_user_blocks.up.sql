@@ -0,0 +1,12 @@
+create table if not exists blocked_users (
+	id bigint not null auto_increment,
+	user_id binary (12) not null,
+	blocked_user_id binary (12) not null,
+	created_at datetime not null default current_timestamp(),
+
+	primary key (id),
+	foreign key (user_id) references users (id),
+	foreign key (blocked_user_id) references users (id),
+	unique (user_id, blocked_user_id),
+	key (blocked_user_id)
+);
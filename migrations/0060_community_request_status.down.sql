@@ -0,0 +1,7 @@
+drop index community_requests_status on community_requests;
+
+alter table community_requests drop column community_id;
+alter table community_requests drop column resolved_at;
+alter table community_requests drop column resolved_by;
+alter table community_requests drop column reject_reason;
+alter table community_requests drop column status;
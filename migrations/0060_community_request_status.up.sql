@@ -0,0 +1,7 @@
+alter table community_requests add column status tinyint not null default 0;
+alter table community_requests add column reject_reason text;
+alter table community_requests add column resolved_by binary (12);
+alter table community_requests add column resolved_at datetime;
+alter table community_requests add column community_id binary (12);
+
+create index community_requests_status on community_requests (status, created_at);
@@ -0,0 +1,15 @@
+create table if not exists mentions (
+	id bigint not null auto_increment,
+	user_id binary (12) not null,
+	by_user_id binary (12) not null,
+	post_id binary (12) not null,
+	target_id binary (12) not null,
+	target_type tinyint not null,
+	created_at datetime not null default current_timestamp(),
+
+	primary key (id),
+	unique (user_id, target_id),
+	key (post_id, created_at),
+	foreign key (user_id) references users (id),
+	foreign key (by_user_id) references users (id)
+);
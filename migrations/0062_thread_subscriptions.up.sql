@@ -0,0 +1,14 @@
+create table if not exists thread_subscriptions (
+	id bigint not null auto_increment,
+	user_id binary (12) not null,
+	post_id binary (12) not null,
+	comment_id binary (12),
+	target_id binary (12) not null,
+	subscribed bool not null,
+	created_at datetime not null default current_timestamp(),
+
+	primary key (id),
+	unique (user_id, target_id),
+	key (target_id),
+	foreign key (user_id) references users (id)
+);
@@ -0,0 +1 @@
+alter table users add column notification_preferences text;
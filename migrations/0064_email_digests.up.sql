@@ -0,0 +1,2 @@
+alter table users add column email_digest tinyint not null default 0;
+alter table users add column email_digest_sent_at datetime;
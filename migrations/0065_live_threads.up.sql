@@ -0,0 +1 @@
+alter table posts add column live_since datetime;
@@ -0,0 +1,2 @@
+drop table if exists custom_feed_communities;
+drop table if exists custom_feeds;
@@ -0,0 +1,4 @@
+alter table users drop column hide_viewed_posts;
+
+drop table if exists viewed_posts;
+drop table if exists hidden_posts;
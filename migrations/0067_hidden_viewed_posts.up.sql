@@ -0,0 +1,23 @@
+create table if not exists hidden_posts (
+	user_id binary (12) not null,
+	post_id binary (12) not null,
+	created_at datetime not null default current_timestamp(),
+
+	primary key (user_id, post_id),
+	index (user_id, created_at),
+	foreign key (user_id) references users (id),
+	foreign key (post_id) references posts (id)
+);
+
+create table if not exists viewed_posts (
+	user_id binary (12) not null,
+	post_id binary (12) not null,
+	viewed_at datetime not null default current_timestamp(),
+
+	primary key (user_id, post_id),
+	index (viewed_at),
+	foreign key (user_id) references users (id),
+	foreign key (post_id) references posts (id)
+);
+
+alter table users add column hide_viewed_posts bool not null default false;
@@ -0,0 +1,7 @@
+alter table post_votes drop index post_votes_created_at;
+
+alter table posts drop index posts_rising;
+alter table posts drop index posts_controversy;
+
+alter table posts drop column rising;
+alter table posts drop column controversy;
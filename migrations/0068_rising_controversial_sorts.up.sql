@@ -0,0 +1,13 @@
+alter table posts add column controversy bigint not null default 0;
+alter table posts add column rising bigint not null default 0;
+
+alter table posts add index posts_controversy (deleted, controversy, id);
+alter table posts add index posts_rising (deleted, rising, id);
+
+/* The rising scores are calculated from the votes of the last few hours (see
+postRising), by UpdatePostsRising. */
+alter table post_votes add index post_votes_created_at (created_at);
+
+/* Keep this in sync with PostControversy. */
+update posts set controversy = round(power(upvotes + downvotes, least(upvotes, downvotes) / greatest(upvotes, downvotes)) * 1000)
+	where upvotes > 0 and downvotes > 0;
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+)
+
+// /api/_account?action={deactivate|delete} [POST]
+//
+// Deactivates the account of the logged in user, or schedules its deletion
+// after the grace period (config.AccountDeletionGraceDays). The user is logged
+// out of all sessions, and logging in again reactivates the account (and
+// cancels the deletion).
+func (s *Server) updateAccount(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	reqBody := struct {
+		// Password is the password of the logged in user.
+		Password string `json:"password"`
+
+		// Whether to delete the posts and comments of the user as well (only
+		// for the delete action).
+		DeleteContent bool `json:"deleteContent"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+
+	if err := s.rateLimit(r, "deactivate_account_1_"+r.viewer.String(), time.Second*5, 1); err != nil {
+		return err
+	}
+
+	user, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+	if err != nil {
+		return err
+	}
+	if _, err := core.MatchLoginCredentials(r.ctx, s.db, user.Username, reqBody.Password); err != nil {
+		if err == core.ErrWrongPassword {
+			return httperr.NewForbidden("wrong_password", "Wrong password.")
+		}
+		return err
+	}
+
+	switch r.urlQueryParamsValue("action") {
+	case "deactivate":
+		if err := user.Deactivate(r.ctx, user.ID); err != nil {
+			return err
+		}
+	case "delete":
+		if err := user.ScheduleDeletion(r.ctx, s.config.AccountDeletionGraceDays, reqBody.DeleteContent, user.ID); err != nil {
+			return err
+		}
+	default:
+		return httperr.NewBadRequest("invalid_action", "Unsupported action.")
+	}
+
+	if err := s.LogoutAllSessionsOfUser(user); err != nil {
+		return err
+	}
+	return w.writeJSON(user)
+}
+
+// profileViewable returns an error if the profile of user is hidden from the
+// viewer of r (because the account is deactivated).
+func (s *Server) profileViewable(r *request, user *core.User) error {
+	if !user.Deactivated || (r.loggedIn && *r.viewer == user.ID) {
+		return nil
+	}
+	if r.loggedIn {
+		admin, err := core.IsAdmin(s.db, r.viewer)
+		if err != nil {
+			return err
+		}
+		if admin {
+			return nil
+		}
+	}
+	return httperr.NewNotFound("user_not_found", "User not found.")
+}
+
+// DeleteScheduledUsers deletes the accounts whose deletion grace period is
+// over. It returns the number of accounts deleted.
+func (s *Server) DeleteScheduledUsers(ctx context.Context) (int, error) {
+	users, err := core.GetUsersDueForDeletion(ctx, s.db)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, user := range users {
+		if err := s.LogoutAllSessionsOfUser(user); err != nil {
+			return n, err
+		}
+		if err := user.DeleteScheduled(ctx); err != nil {
+			log.Printf("Failed to delete user %s (scheduled deletion): %v\n", user.Username, err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
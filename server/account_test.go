@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/discuitnet/discuit/config"
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/testdb"
+)
+
+func newTestServer(t *testing.T, db *sql.DB) *Server {
+	t.Helper()
+	s, err := New(db, &config.Config{
+		SessionCookieName:  "SID",
+		SessionStore:       "memory",
+		HMACSecret:         "secret",
+		CSRFOff:            true,
+		NoLogToFile:        true,
+		PaginationLimit:    10,
+		PaginationLimitMax: 50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// newTestUser creates a user with a random name and the password "password".
+func newTestUser(t *testing.T, db *sql.DB) *core.User {
+	t.Helper()
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	u, err := core.RegisterUser(context.Background(), db, "u"+hex.EncodeToString(b), "", "password", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func testLogin(t *testing.T, s *Server, username string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(map[string]string{"username": username, "password": "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/_login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginDeactivated(t *testing.T) {
+	db := testdb.Open(t)
+	s := newTestServer(t, db)
+	ctx := context.Background()
+
+	admin, err := core.MakeAdmin(ctx, db, newTestUser(t, db).Username, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, byAdmin := newTestUser(t, db), newTestUser(t, db)
+	if err := self.Deactivate(ctx, self.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := byAdmin.Deactivate(ctx, admin.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Logging in reactivates an account deactivated by the user.
+	if w := testLogin(t, s, self.Username); w.Code != http.StatusOK {
+		t.Errorf("self deactivated: got status %d, want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+	if u, err := core.GetUser(ctx, db, self.ID, nil); err != nil {
+		t.Fatal(err)
+	} else if u.Deactivated {
+		t.Error("self deactivated: account not reactivated on login")
+	}
+
+	// But not one deactivated by an admin.
+	if w := testLogin(t, s, byAdmin.Username); w.Code != http.StatusForbidden {
+		t.Errorf("deactivated by admin: got status %d, want %d (%s)", w.Code, http.StatusForbidden, w.Body)
+	}
+	if u, err := core.GetUser(ctx, db, byAdmin.ID, nil); err != nil {
+		t.Fatal(err)
+	} else if !u.Deactivated {
+		t.Error("deactivated by admin: account reactivated on login")
+	}
+}
+
+func TestDeleteScheduledUsers(t *testing.T) {
+	db := testdb.Open(t)
+	s := newTestServer(t, db)
+	ctx := context.Background()
+
+	due, notDue, banned := newTestUser(t, db), newTestUser(t, db), newTestUser(t, db)
+	for _, u := range []*core.User{due, notDue, banned} {
+		if err := u.ScheduleDeletion(ctx, 30, false, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// As if the grace period was over.
+	if _, err := db.ExecContext(ctx, "UPDATE users SET delete_at = ? WHERE id IN (?, ?)", time.Now().Add(-time.Hour), due.ID, banned.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := banned.Ban(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.DeleteScheduledUsers(ctx); err != nil {
+		t.Fatal(err)
+	} else if n < 1 {
+		t.Errorf("deleted %d users, want at least 1", n)
+	}
+
+	tests := []struct {
+		name        string
+		user        *core.User
+		wantDeleted bool
+	}{
+		{"due", due, true},
+		{"not due", notDue, false},
+		{"banned", banned, false}, // Banned users cannot be deleted.
+	}
+	for _, test := range tests {
+		u, err := core.GetUser(ctx, db, test.user.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Deleted != test.wantDeleted {
+			t.Errorf("%s: got deleted %v, want %v", test.name, u.Deleted, test.wantDeleted)
+		}
+	}
+}
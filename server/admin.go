@@ -5,9 +5,13 @@ import (
 
 	"github.com/discuitnet/discuit/core"
 	"github.com/discuitnet/discuit/internal/httperr"
+	"github.com/discuitnet/discuit/internal/uid"
 )
 
 // /api/_admin [POST]
+//
+// Deprecated: Use the admin API (/api/admin/v1/...) instead. Actions taken
+// through this endpoint are recorded in the audit log as well.
 func (s *Server) adminActions(w *responseWriter, r *request) error {
 	if !r.loggedIn {
 		return errNotLoggedIn
@@ -66,7 +70,7 @@ func (s *Server) adminActions(w *responseWriter, r *request) error {
 				return err
 			}
 		}
-		if err := user.Ban(r.ctx); err != nil {
+		if err := user.BanWithReason(r.ctx, uid.NullID{Valid: true, ID: admin.ID}, "", "", nil); err != nil {
 			return err
 		}
 	case "unban_user":
@@ -78,9 +82,63 @@ func (s *Server) adminActions(w *responseWriter, r *request) error {
 		if err != nil {
 			return err
 		}
-		if err := user.Unban(r.ctx); err != nil {
+		if err := user.UnbanBy(r.ctx, uid.NullID{Valid: true, ID: admin.ID}); err != nil {
 			return err
 		}
+	case "reset_2fa":
+		username, ok := reqBody["username"].(string)
+		if !ok {
+			return invalidJSONErr
+		}
+		user, err := core.GetUserByUsername(r.ctx, s.db, username, nil)
+		if err != nil {
+			return err
+		}
+		if err := user.DisableTwoFactor(r.ctx); err != nil {
+			return err
+		}
+	case "deactivate_user", "reactivate_user", "schedule_user_deletion", "cancel_user_deletion":
+		username, ok := reqBody["username"].(string)
+		if !ok {
+			return invalidJSONErr
+		}
+		user, err := core.GetUserByUsername(r.ctx, s.db, username, r.viewer)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case "deactivate_user":
+			if err := user.Deactivate(r.ctx, admin.ID); err != nil {
+				return err
+			}
+			if err := s.LogoutAllSessionsOfUser(user); err != nil {
+				return err
+			}
+		case "reactivate_user":
+			if err := user.Reactivate(r.ctx); err != nil {
+				return err
+			}
+		case "schedule_user_deletion":
+			days := s.config.AccountDeletionGraceDays
+			if _, ok := reqBody["days"]; ok {
+				n, ok := reqBody["days"].(float64)
+				if !ok {
+					return invalidJSONErr
+				}
+				days = int(n)
+			}
+			deleteContent, _ := reqBody["deleteContent"].(bool)
+			if err := user.ScheduleDeletion(r.ctx, days, deleteContent, admin.ID); err != nil {
+				return err
+			}
+			if err := s.LogoutAllSessionsOfUser(user); err != nil {
+				return err
+			}
+		case "cancel_user_deletion":
+			if err := user.CancelDeletion(r.ctx); err != nil {
+				return err
+			}
+		}
 	case "add_default_forum", "remove_default_forum":
 		name, ok := reqBody["name"].(string)
 		if !ok {
@@ -97,5 +155,14 @@ func (s *Server) adminActions(w *responseWriter, r *request) error {
 		return httperr.NewBadRequest("invalid_action", "Unsupported admin action.")
 	}
 
+	a := &adminAction{admin: admin, details: reqBody}
+	if username, ok := reqBody["username"].(string); ok {
+		a.setTarget("user", username)
+	} else if name, ok := reqBody["name"].(string); ok {
+		a.setTarget("community", name)
+	}
+	s.recordAdminAction(r, action, a)
+
+	w.Header().Set("Deprecation", "true")
 	return w.writeString(`{"success:":true}`)
 }
@@ -0,0 +1,777 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+	"github.com/discuitnet/discuit/internal/httputil"
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// The admin API (under /api/admin/v1/). Every endpoint requires the logged in
+// user to be an admin, and every successful call is recorded in the admin audit
+// log.
+
+// adminAction is the audit log entry of an admin API call. Handlers set the
+// target of the action, and optionally some details, on it.
+type adminAction struct {
+	admin      *core.User
+	targetType string
+	target     string
+	details    any
+}
+
+func (a *adminAction) setTarget(targetType, target string) {
+	a.targetType, a.target = targetType, target
+}
+
+type adminHandler func(w *responseWriter, r *request, a *adminAction) error
+
+// withAdmin returns a handler that calls h only if the logged in user is an
+// admin, and that records the call under action in the audit log if h
+// succeeds.
+func (s *Server) withAdmin(action string, h adminHandler) http.Handler {
+	return s.withHandler(func(w *responseWriter, r *request) error {
+		if !r.loggedIn {
+			return errNotLoggedIn
+		}
+		admin, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+		if err != nil {
+			return err
+		}
+		if !admin.Admin {
+			return httperr.NewForbidden("not_admin", "You are not an admin.")
+		}
+
+		a := &adminAction{admin: admin}
+		if err := h(w, r, a); err != nil {
+			return err
+		}
+		s.recordAdminAction(r, action, a)
+		return nil
+	})
+}
+
+// recordAdminAction creates an audit log entry for the action. It only logs
+// the error on failure, since the action has already been taken.
+func (s *Server) recordAdminAction(r *request, action string, a *adminAction) {
+	if err := core.CreateAuditEntry(r.ctx, s.db, a.admin.ID, action, a.targetType, a.target, a.details, httputil.GetIP(r.req)); err != nil {
+		log.Printf("Error creating admin audit log entry (action: %s): %v\n", action, err)
+	}
+}
+
+// siteSettings returns the current site settings, with the values in the
+// config file as defaults.
+func (s *Server) siteSettings(ctx context.Context) (*core.SiteSettings, error) {
+	return core.GetSiteSettings(ctx, s.db, core.SiteSettings{
+		DisableImagePosts:      s.config.DisableImagePosts,
+		ForumCreationReqPoints: s.config.ForumCreationReqPoints,
+		MaxForumsPerUser:       s.config.MaxForumsPerUser,
+	})
+}
+
+// getPage returns the page URL query parameter (1, if it's not set).
+func getPage(r *request) (int, error) {
+	page := 1
+	if spage := r.urlQueryParamsValue("page"); spage != "" {
+		var err error
+		if page, err = strconv.Atoi(spage); err != nil || page < 1 {
+			return 0, httperr.NewBadRequest("invalid_page", "Invalid page.")
+		}
+	}
+	return page, nil
+}
+
+// adminUser is a user as seen through the admin API, with the fields hidden
+// from public view.
+type adminUser struct {
+	*core.User
+	Email        msql.NullString `json:"email"`
+	BanReason    msql.NullString `json:"banReason"`
+	BanNote      msql.NullString `json:"banNote"`
+	BanExpiresAt msql.NullTime   `json:"banExpiresAt"`
+	LastSeen     time.Time       `json:"lastSeen"`
+	ShadowBanned bool            `json:"shadowBanned"`
+}
+
+func newAdminUser(u *core.User) *adminUser {
+	return &adminUser{
+		User:         u,
+		Email:        u.Email,
+		BanReason:    u.BanReason,
+		BanNote:      u.BanNote,
+		BanExpiresAt: u.BanExpiresAt,
+		LastSeen:     u.LastSeen,
+		ShadowBanned: u.ShadowBanned,
+	}
+}
+
+// /api/admin/v1/users?q={query}&filter={all|banned}&page={page}&limit={limit} [GET]
+func (s *Server) adminSearchUsers(w *responseWriter, r *request, a *adminAction) error {
+	query := r.urlQueryParams()
+	limit, err := getFeedLimit(query, s.config.PaginationLimit, s.config.PaginationLimitMax)
+	if err != nil {
+		return err
+	}
+	page, err := getPage(r)
+	if err != nil {
+		return err
+	}
+
+	bannedOnly := false
+	switch query.Get("filter") {
+	case "", "all":
+	case "banned":
+		bannedOnly = true
+	default:
+		return errInvalidFeedFilter
+	}
+
+	count, users, err := core.SearchUsers(r.ctx, s.db, query.Get("q"), bannedOnly, limit, page, r.viewer)
+	if err != nil {
+		return err
+	}
+	a.details = map[string]any{"q": query.Get("q"), "bannedOnly": bannedOnly, "page": page}
+
+	res := struct {
+		NoUsers int          `json:"noUsers"`
+		Limit   int          `json:"limit"`
+		Page    int          `json:"page"`
+		Users   []*adminUser `json:"users"`
+	}{
+		NoUsers: count,
+		Limit:   limit,
+		Page:    page,
+		Users:   make([]*adminUser, len(users)),
+	}
+	for i, u := range users {
+		res.Users[i] = newAdminUser(u)
+	}
+	a.details = map[string]any{"query": query.Get("q"), "filter": query.Get("filter")}
+	return w.writeJSON(res)
+}
+
+// adminTargetUser returns the user in the username route variable, and sets it
+// as the target of a.
+func (s *Server) adminTargetUser(r *request, a *adminAction) (*core.User, error) {
+	user, err := core.GetUserByUsername(r.ctx, s.db, r.muxVar("username"), r.viewer)
+	if err != nil {
+		return nil, err
+	}
+	a.setTarget("user", user.Username)
+	return user, nil
+}
+
+// /api/admin/v1/users/{username} [GET]
+func (s *Server) adminGetUser(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+	if err := user.LoadModdingList(r.ctx); err != nil {
+		return err
+	}
+	return w.writeJSON(newAdminUser(user))
+}
+
+// /api/admin/v1/users/{username} [DELETE]
+//
+// Deletes the account immediately (as opposed to a scheduled deletion).
+func (s *Server) adminDeleteUser(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+	if user.Admin {
+		return httperr.NewForbidden("cannot_delete_admin", "Cannot delete an admin.")
+	}
+	if user.Banned {
+		return httperr.NewForbidden("user_banned", "Unban the user before deleting the account.")
+	}
+
+	reqBody := struct {
+		DeleteContent bool `json:"deleteContent"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+
+	if err := s.LogoutAllSessionsOfUser(user); err != nil {
+		return err
+	}
+	if reqBody.DeleteContent {
+		if err := user.DeleteContent(r.ctx, 0, a.admin.ID); err != nil {
+			return err
+		}
+	}
+	if err := user.Delete(r.ctx); err != nil {
+		return err
+	}
+	a.details = reqBody
+	return w.writeString(`{"success":true}`)
+}
+
+// /api/admin/v1/users/{username}/ban [POST]
+func (s *Server) adminBanUser(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+	if user.Admin {
+		return httperr.NewForbidden("no_ban_admin", "Admin can't ban another admin.")
+	}
+
+	reqBody := struct {
+		// The reason is shown to the user, the note is only for admins.
+		Reason string `json:"reason"`
+		Note   string `json:"note"`
+
+		// If zero, the ban is permanent.
+		ExpiresInDays int `json:"expiresInDays"`
+
+		// If set, the posts and comments of the user made in the last
+		// deleteContentDays days are deleted (0 deletes all of them).
+		DeleteContentDays *int `json:"deleteContentDays"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+	if reqBody.ExpiresInDays < 0 {
+		return httperr.NewBadRequest("invalid_expiry", "Ban expiry cannot be negative.")
+	}
+
+	var expires *time.Time
+	if reqBody.ExpiresInDays > 0 {
+		t := time.Now().Add(time.Hour * 24 * time.Duration(reqBody.ExpiresInDays))
+		expires = &t
+	}
+
+	if err := s.LogoutAllSessionsOfUser(user); err != nil {
+		return err
+	}
+	if reqBody.DeleteContentDays != nil {
+		if err := user.DeleteContent(r.ctx, *reqBody.DeleteContentDays, a.admin.ID); err != nil {
+			return err
+		}
+	}
+	if err := user.BanWithReason(r.ctx, uid.NullID{Valid: true, ID: a.admin.ID}, reqBody.Reason, reqBody.Note, expires); err != nil {
+		return err
+	}
+	a.details = reqBody
+	return w.writeJSON(newAdminUser(user))
+}
+
+// /api/admin/v1/users/{username}/ban [DELETE]
+func (s *Server) adminUnbanUser(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+	if !user.Banned {
+		return httperr.NewBadRequest("not_banned", "User is not banned.")
+	}
+	if err := user.UnbanBy(r.ctx, uid.NullID{Valid: true, ID: a.admin.ID}); err != nil {
+		return err
+	}
+	return w.writeJSON(newAdminUser(user))
+}
+
+// /api/admin/v1/users/{username}/shadow_ban [POST, DELETE]
+func (s *Server) adminShadowBanUser(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+	if err := user.ShadowBan(r.ctx, r.req.Method == "POST"); err != nil {
+		return err
+	}
+	return w.writeJSON(newAdminUser(user))
+}
+
+// /api/admin/v1/users/{username}/bans [GET]
+//
+// Returns the site ban history of the user.
+func (s *Server) adminGetUserBans(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+	bans, err := core.GetUserBans(r.ctx, s.db, user.ID)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(bans)
+}
+
+// /api/admin/v1/users/{username}/password [POST]
+//
+// Sets a new password for the user and logs the user out of all sessions. If
+// no password is given, a random one is generated. The new password is
+// included in the response. The passwords of admins cannot be reset.
+func (s *Server) adminResetPassword(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+	if user.Admin {
+		return httperr.NewForbidden("target_admin", "The passwords of admins cannot be reset.")
+	}
+
+	reqBody := struct {
+		Password string `json:"password"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+	if reqBody.Password == "" {
+		reqBody.Password = randomPassword(16)
+	}
+
+	if err := user.ResetPassword(r.ctx, reqBody.Password); err != nil {
+		return err
+	}
+	if err := s.LogoutAllSessionsOfUser(user); err != nil {
+		return err
+	}
+	return w.writeJSON(map[string]string{"password": reqBody.Password})
+}
+
+// /api/admin/v1/users/{username}/admin [PUT]
+func (s *Server) adminSetAdmin(w *responseWriter, r *request, a *adminAction) error {
+	reqBody := struct {
+		Admin bool `json:"isAdmin"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+
+	user, err := core.MakeAdmin(r.ctx, s.db, r.muxVar("username"), reqBody.Admin)
+	if err != nil {
+		return err
+	}
+	a.setTarget("user", user.Username)
+	a.details = reqBody
+	return w.writeJSON(newAdminUser(user))
+}
+
+// /api/admin/v1/users/{username}/badges [POST]
+func (s *Server) adminAddBadge(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+
+	reqBody := struct {
+		BadgeType string `json:"badgeType"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+	if err := user.AddBadge(r.ctx, reqBody.BadgeType); err != nil {
+		return err
+	}
+	a.details = reqBody
+	return w.writeJSON(user.Badges)
+}
+
+// /api/admin/v1/users/{username}/badges/{badgeType} [DELETE]
+func (s *Server) adminRemoveBadge(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+
+	badgeType := r.muxVar("badgeType")
+	if err := user.RemoveBadgesByType(badgeType); err != nil {
+		return err
+	}
+	a.details = map[string]string{"badgeType": badgeType}
+	return w.writeString(`{"success":true}`)
+}
+
+// /api/admin/v1/badges [GET]
+func (s *Server) adminGetBadgeTypes(w *responseWriter, r *request, a *adminAction) error {
+	types, err := core.GetBadgeTypes(r.ctx, s.db)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(types)
+}
+
+// /api/admin/v1/badges [POST]
+func (s *Server) adminNewBadgeType(w *responseWriter, r *request, a *adminAction) error {
+	reqBody := struct {
+		Name string `json:"name"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+	if reqBody.Name == "" {
+		return httperr.NewBadRequest("invalid_badge_type", "Badge type name is empty.")
+	}
+	if err := core.NewBadgeType(s.db, reqBody.Name); err != nil {
+		return err
+	}
+	a.setTarget("badge_type", reqBody.Name)
+	w.WriteHeader(http.StatusCreated)
+	return w.writeString(`{"success":true}`)
+}
+
+// adminTargetCommunity returns the community in the name route variable, and
+// sets it as the target of a.
+func (s *Server) adminTargetCommunity(r *request, a *adminAction) (*core.Community, error) {
+	comm, err := core.GetCommunityByName(r.ctx, s.db, r.muxVar("name"), r.viewer)
+	if err != nil {
+		return nil, err
+	}
+	a.setTarget("community", comm.Name)
+	return comm, nil
+}
+
+// /api/admin/v1/communities/{name} [PUT]
+func (s *Server) adminUpdateCommunity(w *responseWriter, r *request, a *adminAction) error {
+	comm, err := s.adminTargetCommunity(r, a)
+	if err != nil {
+		return err
+	}
+
+	reqBody := struct {
+		Default          *bool  `json:"isDefault"`
+		Quarantined      *bool  `json:"quarantined"`
+		QuarantineReason string `json:"quarantineReason"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+	if reqBody.Quarantined != nil {
+		if *reqBody.Quarantined {
+			err = comm.Quarantine(r.ctx, reqBody.QuarantineReason)
+		} else {
+			err = comm.Unquarantine(r.ctx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if reqBody.Default != nil {
+		if *reqBody.Default && comm.Quarantined {
+			return httperr.NewBadRequest("community_quarantined", "A quarantined community cannot be a default community.")
+		}
+		if err := comm.SetDefault(r.ctx, *reqBody.Default); err != nil {
+			return err
+		}
+		comm.IsDefault = reqBody.Default
+	}
+	a.details = reqBody
+	return w.writeJSON(comm)
+}
+
+// /api/admin/v1/communities/{name}/add_all_users [POST]
+//
+// Makes every user a member of the community.
+func (s *Server) adminAddAllUsersToCommunity(w *responseWriter, r *request, a *adminAction) error {
+	comm, err := s.adminTargetCommunity(r, a)
+	if err != nil {
+		return err
+	}
+	if err := core.AddAllUsersToCommunity(r.ctx, s.db, comm.Name); err != nil {
+		return err
+	}
+	return w.writeString(`{"success":true}`)
+}
+
+// /api/admin/v1/communities/{name}/mods/{username} [PUT, DELETE]
+func (s *Server) adminCommunityMod(w *responseWriter, r *request, a *adminAction) error {
+	comm, err := s.adminTargetCommunity(r, a)
+	if err != nil {
+		return err
+	}
+	user, err := core.GetUserByUsername(r.ctx, s.db, r.muxVar("username"), nil)
+	if err != nil {
+		return err
+	}
+
+	isMod := r.req.Method == "PUT"
+	if err := core.MakeUserMod(r.ctx, s.db, comm, a.admin.ID, user.ID, isMod); err != nil {
+		return err
+	}
+	a.details = map[string]any{"username": user.Username, "isMod": isMod}
+
+	mods, err := core.GetCommunityMods(r.ctx, s.db, comm.ID)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(mods)
+}
+
+func (s *Server) adminTargetCommunityRequest(r *request, a *adminAction) (*core.CommunityRequest, error) {
+	id, err := strconv.Atoi(r.muxVar("requestID"))
+	if err != nil {
+		return nil, httperr.NewBadRequest("invalid_id", "Invalid request ID.")
+	}
+	req, err := core.GetCommunityRequest(r.ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	a.setTarget("community_request", strconv.Itoa(req.ID))
+	return req, nil
+}
+
+// /api/admin/v1/community_requests/{requestID}/approve [POST]
+//
+// Creates the requested community, with the requester as its moderator.
+func (s *Server) adminApproveCommunityRequest(w *responseWriter, r *request, a *adminAction) error {
+	req, err := s.adminTargetCommunityRequest(r, a)
+	if err != nil {
+		return err
+	}
+	comm, err := req.Approve(r.ctx, a.admin.ID)
+	if err != nil {
+		return err
+	}
+	a.details = map[string]any{"community": comm.Name, "requester": req.ByUser}
+	return w.writeJSON(comm)
+}
+
+// /api/admin/v1/community_requests/{requestID}/reject [POST]
+func (s *Server) adminRejectCommunityRequest(w *responseWriter, r *request, a *adminAction) error {
+	req, err := s.adminTargetCommunityRequest(r, a)
+	if err != nil {
+		return err
+	}
+	reqBody := struct {
+		Reason string `json:"reason"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+	if err := req.Reject(r.ctx, a.admin.ID, reqBody.Reason); err != nil {
+		return err
+	}
+	a.details = map[string]any{"community": req.CommunityName, "requester": req.ByUser, "reason": reqBody.Reason}
+	return w.writeJSON(req)
+}
+
+// /api/admin/v1/settings [GET]
+func (s *Server) adminGetSiteSettings(w *responseWriter, r *request, a *adminAction) error {
+	settings, err := s.siteSettings(r.ctx)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(settings)
+}
+
+// /api/admin/v1/settings [PUT]
+//
+// Fields missing from the request body retain their current values.
+func (s *Server) adminUpdateSiteSettings(w *responseWriter, r *request, a *adminAction) error {
+	settings, err := s.siteSettings(r.ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.unmarshalJSONBody(settings); err != nil {
+		return err
+	}
+	if err := core.SaveSiteSettings(r.ctx, s.db, settings); err != nil {
+		return err
+	}
+	a.details = settings
+	return w.writeJSON(settings)
+}
+
+// /api/admin/v1/pins [GET]
+//
+// Returns the posts pinned site-wide.
+func (s *Server) adminGetSitePins(w *responseWriter, r *request, a *adminAction) error {
+	posts, err := core.GetSitePinnedPosts(r.ctx, s.db, r.viewer)
+	if err != nil {
+		return err
+	}
+	if posts == nil {
+		posts = []*core.Post{}
+	}
+	return w.writeJSON(posts)
+}
+
+// /api/admin/v1/pins [POST]
+func (s *Server) adminPinPost(w *responseWriter, r *request, a *adminAction) error {
+	reqBody := struct {
+		PostID string `json:"postId"` // public ID
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+	return s.adminSitePin(w, r, a, reqBody.PostID, false)
+}
+
+// /api/admin/v1/pins/{postID} [DELETE]
+func (s *Server) adminUnpinPost(w *responseWriter, r *request, a *adminAction) error {
+	return s.adminSitePin(w, r, a, r.muxVar("postID"), true)
+}
+
+func (s *Server) adminSitePin(w *responseWriter, r *request, a *adminAction, publicID string, unpin bool) error {
+	post, err := core.GetPost(r.ctx, s.db, nil, publicID, r.viewer, true)
+	if err != nil {
+		return err
+	}
+	a.setTarget("post", post.PublicID)
+	if err := post.Pin(r.ctx, a.admin.ID, true, unpin, false); err != nil {
+		return err
+	}
+	return w.writeJSON(post)
+}
+
+// /api/admin/v1/audit_log?admin={username}&page={page}&limit={limit} [GET]
+func (s *Server) adminGetAuditLog(w *responseWriter, r *request, a *adminAction) error {
+	query := r.urlQueryParams()
+	limit, err := getFeedLimit(query, s.config.PaginationLimit, s.config.PaginationLimitMax)
+	if err != nil {
+		return err
+	}
+	page, err := getPage(r)
+	if err != nil {
+		return err
+	}
+
+	res := struct {
+		NoEntries int                `json:"noEntries"`
+		Limit     int                `json:"limit"`
+		Page      int                `json:"page"`
+		Entries   []*core.AuditEntry `json:"entries"`
+	}{
+		Limit: limit,
+		Page:  page,
+	}
+
+	var byAdmin *core.User
+	if username := query.Get("admin"); username != "" {
+		if byAdmin, err = core.GetUserByUsername(r.ctx, s.db, username, nil); err != nil {
+			return err
+		}
+		a.setTarget("user", byAdmin.Username)
+		res.NoEntries, res.Entries, err = core.GetAuditEntries(r.ctx, s.db, &byAdmin.ID, limit, page)
+	} else {
+		res.NoEntries, res.Entries, err = core.GetAuditEntries(r.ctx, s.db, nil, limit, page)
+	}
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(res)
+}
+
+// /api/admin/v1/users/{username}/shared_ips [GET]
+//
+// Returns the accounts that have used any of the IP addresses that the user
+// has used.
+func (s *Server) adminGetUsersSharingIPs(w *responseWriter, r *request, a *adminAction) error {
+	user, err := s.adminTargetUser(r, a)
+	if err != nil {
+		return err
+	}
+	shared, err := core.GetUsersSharingIPs(r.ctx, s.db, user.ID, r.viewer)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(shared)
+}
+
+// /api/admin/v1/ip_bans?filter={active|all} [GET]
+func (s *Server) adminGetIPBans(w *responseWriter, r *request, a *adminAction) error {
+	activeOnly := true
+	switch r.urlQueryParamsValue("filter") {
+	case "", "active":
+	case "all":
+		activeOnly = false
+	default:
+		return errInvalidFeedFilter
+	}
+	bans, err := core.GetIPBans(r.ctx, s.db, activeOnly)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(bans)
+}
+
+// /api/admin/v1/ip_bans [POST]
+func (s *Server) adminCreateIPBan(w *responseWriter, r *request, a *adminAction) error {
+	reqBody := struct {
+		CIDR   string           `json:"cidr"` // An IP address or a CIDR range.
+		Action core.IPBanAction `json:"action"`
+		Reason string           `json:"reason"`
+
+		// If zero, the ban doesn't expire.
+		ExpiresInDays int `json:"expiresInDays"`
+	}{}
+	if err := r.unmarshalJSONBody(&reqBody); err != nil {
+		return err
+	}
+	if reqBody.ExpiresInDays < 0 {
+		return httperr.NewBadRequest("invalid_expiry", "Ban expiry cannot be negative.")
+	}
+
+	var expires *time.Time
+	if reqBody.ExpiresInDays > 0 {
+		t := time.Now().Add(time.Hour * 24 * time.Duration(reqBody.ExpiresInDays))
+		expires = &t
+	}
+
+	ban, err := core.CreateIPBan(r.ctx, s.db, reqBody.CIDR, reqBody.Action, reqBody.Reason, a.admin.ID, expires)
+	if err != nil {
+		return err
+	}
+	a.setTarget("ip_ban", ban.CIDR)
+	a.details = reqBody
+	w.WriteHeader(http.StatusCreated)
+	return w.writeJSON(ban)
+}
+
+// /api/admin/v1/ip_bans/{banID} [DELETE]
+func (s *Server) adminDeleteIPBan(w *responseWriter, r *request, a *adminAction) error {
+	id, err := strconv.Atoi(r.muxVar("banID"))
+	if err != nil {
+		return httperr.NewBadRequest("invalid_id", "Invalid ID.")
+	}
+	ban, err := core.GetIPBan(r.ctx, s.db, id)
+	if err != nil {
+		return err
+	}
+	if err := ban.Delete(r.ctx, s.db); err != nil {
+		return err
+	}
+	a.setTarget("ip_ban", ban.CIDR)
+	return w.writeJSON(ban)
+}
+
+// /api/admin/v1/ip_bans/matches?page={page}&limit={limit} [GET]
+//
+// Returns the signups and logins that were blocked or flagged by IP bans.
+func (s *Server) adminGetIPBanMatches(w *responseWriter, r *request, a *adminAction) error {
+	limit, err := getFeedLimit(r.urlQueryParams(), s.config.PaginationLimit, s.config.PaginationLimitMax)
+	if err != nil {
+		return err
+	}
+	page, err := getPage(r)
+	if err != nil {
+		return err
+	}
+
+	res := struct {
+		NoMatches int                `json:"noMatches"`
+		Limit     int                `json:"limit"`
+		Page      int                `json:"page"`
+		Matches   []*core.IPBanMatch `json:"matches"`
+	}{
+		Limit: limit,
+		Page:  page,
+	}
+	if res.NoMatches, res.Matches, err = core.GetIPBanMatches(r.ctx, s.db, limit, page); err != nil {
+		return err
+	}
+	return w.writeJSON(res)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// /api/blocks [GET, POST]
+func (s *Server) handleBlocks(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	if r.req.Method == "POST" {
+		request := struct {
+			UserID uid.ID `json:"userId"`
+		}{}
+		if err := r.unmarshalJSONBody(&request); err != nil {
+			return err
+		}
+		if err := core.BlockUser(r.ctx, s.db, *r.viewer, request.UserID); err != nil {
+			return err
+		}
+	}
+
+	blocks, err := core.GetBlockedUsers(r.ctx, s.db, *r.viewer, true)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(blocks)
+}
+
+// /api/blocks/{blockedUserID} [DELETE]
+func (s *Server) deleteBlock(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	blockedUserID, err := strToID(r.muxVar("blockedUserID"))
+	if err != nil {
+		return err
+	}
+
+	if err := core.UnblockUser(r.ctx, s.db, *r.viewer, blockedUserID); err != nil {
+		return err
+	}
+
+	return w.writeString(`{"success":true}`)
+}
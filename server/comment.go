@@ -75,6 +75,11 @@ func (s *Server) getComment(w *responseWriter, r *request) error {
 	if err != nil {
 		return err
 	}
+	if hidden, err := core.ContentHidden(s.db, comment.AuthorID, r.viewer); err != nil {
+		return err
+	} else if hidden {
+		return httperr.NewNotFound("comment_not_found", "Comment(s) not found.")
+	}
 
 	return w.writeJSON(comment)
 }
@@ -170,6 +175,10 @@ func (s *Server) updateComment(w *responseWriter, r *request) error {
 			if err = comment.ChangeUserGroup(r.ctx, *r.viewer, g); err != nil {
 				return err
 			}
+		case "approve":
+			if err = comment.Approve(r.ctx, *r.viewer); err != nil {
+				return err
+			}
 		default:
 			return httperr.NewBadRequest("invalid_action", "Unsupported action.")
 		}
@@ -45,9 +45,14 @@ func (s *Server) createCommunity(w *responseWriter, r *request) error {
 		return err
 	}
 
+	settings, err := s.siteSettings(r.ctx)
+	if err != nil {
+		return err
+	}
+
 	name := values["name"]
 	about := values["about"]
-	comm, err := core.CreateCommunity(r.ctx, s.db, *r.viewer, s.config.ForumCreationReqPoints, s.config.MaxForumsPerUser, name, about)
+	comm, err := core.CreateCommunity(r.ctx, s.db, *r.viewer, settings.ForumCreationReqPoints, settings.MaxForumsPerUser, name, about)
 	if err != nil {
 		return err
 	}
@@ -830,11 +835,27 @@ func (s *Server) handleCommunityRequests(w *responseWriter, r *request) error {
 	}
 
 	if r.req.Method == "GET" {
-		if !user.Admin {
-			return httperr.NewForbidden("", "Not admin.")
+		// Admins get the requests of everyone (the pending ones, unless the
+		// status query parameter says otherwise), and everyone else gets only
+		// their own requests.
+		var (
+			byUser string
+			status *core.CommunityRequestStatus
+		)
+		if user.Admin {
+			status = new(core.CommunityRequestStatus)
+			if text := r.urlQueryParamsValue("status"); text == "all" {
+				status = nil
+			} else if text != "" {
+				if err := status.UnmarshalText([]byte(text)); err != nil {
+					return httperr.NewBadRequest("invalid_status", "Invalid community request status.")
+				}
+			}
+		} else {
+			byUser = user.Username
 		}
 
-		items, err := core.GetCommunityRequests(r.ctx, s.db)
+		items, err := core.GetCommunityRequests(r.ctx, s.db, byUser, status)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// contentFilterRequest is the JSON body of a request to create a content
+// filter.
+type contentFilterRequest struct {
+	Type    core.ContentFilterType   `json:"type"`
+	Pattern string                   `json:"pattern"`
+	Action  core.ContentFilterAction `json:"action"`
+}
+
+// contentFiltersScope returns the community whose content filters the request
+// is about (nil for site-wide filters), after making sure the viewer is
+// allowed to manage them. Site-wide filters are managed by admins and
+// community filters by the mods of the community (and admins).
+func (s *Server) contentFiltersScope(r *request, siteWide bool) (*uid.ID, error) {
+	if !r.loggedIn {
+		return nil, errNotLoggedIn
+	}
+
+	if siteWide {
+		admin, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+		if err != nil {
+			return nil, err
+		}
+		if !admin.Admin {
+			return nil, httperr.NewForbidden("not_admin", "You are not an admin.")
+		}
+		return nil, nil
+	}
+
+	cid, err := strToID(r.muxVar("communityID"))
+	if err != nil {
+		return nil, err
+	}
+	comm, err := core.GetCommunityByID(r.ctx, s.db, cid, r.viewer)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := userModOrAdmin(r.ctx, s.db, *r.viewer, comm); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errNotAdminNorMod
+	}
+	return &comm.ID, nil
+}
+
+func (s *Server) handleGetContentFilters(w *responseWriter, r *request, siteWide bool) error {
+	community, err := s.contentFiltersScope(r, siteWide)
+	if err != nil {
+		return err
+	}
+
+	filters, err := core.GetContentFilters(r.ctx, s.db, community)
+	if err != nil {
+		return err
+	}
+	if filters == nil {
+		filters = []*core.ContentFilter{}
+	}
+	return w.writeJSON(filters)
+}
+
+func (s *Server) handleCreateContentFilter(w *responseWriter, r *request, siteWide bool) error {
+	community, err := s.contentFiltersScope(r, siteWide)
+	if err != nil {
+		return err
+	}
+
+	var req contentFilterRequest
+	if err := r.unmarshalJSONBody(&req); err != nil {
+		return err
+	}
+
+	filter, err := core.CreateContentFilter(r.ctx, s.db, community, req.Type, req.Pattern, req.Action, *r.viewer)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(filter)
+}
+
+func (s *Server) handleCreateContentFilters(w *responseWriter, r *request, siteWide bool) error {
+	community, err := s.contentFiltersScope(r, siteWide)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Filters []contentFilterRequest `json:"filters"`
+	}
+	if err := r.unmarshalJSONBody(&req); err != nil {
+		return err
+	}
+	if len(req.Filters) == 0 {
+		return httperr.NewBadRequest("invalid_filters", "Invalid filters list.")
+	}
+
+	type result struct {
+		Pattern string              `json:"pattern"`
+		Error   string              `json:"error,omitempty"`
+		Result  *core.ContentFilter `json:"result,omitempty"`
+	}
+	var results []result
+
+	for _, f := range req.Filters {
+		filter, err := core.CreateContentFilter(r.ctx, s.db, community, f.Type, f.Pattern, f.Action, *r.viewer)
+		if err != nil {
+			results = append(results, result{Pattern: f.Pattern, Error: err.Error()})
+		} else {
+			results = append(results, result{Pattern: f.Pattern, Result: filter})
+		}
+	}
+
+	return w.writeJSON(results)
+}
+
+func (s *Server) handleDeleteContentFilter(w *responseWriter, r *request, siteWide bool) error {
+	community, err := s.contentFiltersScope(r, siteWide)
+	if err != nil {
+		return err
+	}
+
+	filterID, err := strToID(r.muxVar("filterID"))
+	if err != nil {
+		return err
+	}
+	filter, err := core.GetContentFilter(r.ctx, s.db, filterID)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the filter belongs to the scope of the request.
+	if (community == nil && filter.CommunityID.Valid) ||
+		(community != nil && (!filter.CommunityID.Valid || filter.CommunityID.ID != *community)) {
+		return httperr.NewNotFound("filter_not_found", "Content filter not found.")
+	}
+
+	if err := filter.Delete(r.ctx, s.db); err != nil {
+		return err
+	}
+	return w.writeJSON(filter)
+}
+
+// /api/_filters [GET]
+func (s *Server) getContentFilters(w *responseWriter, r *request) error {
+	return s.handleGetContentFilters(w, r, true)
+}
+
+// /api/_filters [POST]
+func (s *Server) createContentFilters(w *responseWriter, r *request) error {
+	return s.handleCreateContentFilters(w, r, true)
+}
+
+// /api/_filters/one [POST]
+func (s *Server) createContentFilter(w *responseWriter, r *request) error {
+	return s.handleCreateContentFilter(w, r, true)
+}
+
+// /api/_filters/{filterID} [DELETE]
+func (s *Server) deleteContentFilter(w *responseWriter, r *request) error {
+	return s.handleDeleteContentFilter(w, r, true)
+}
+
+// /api/communities/{communityID}/filters [GET]
+func (s *Server) getCommunityContentFilters(w *responseWriter, r *request) error {
+	return s.handleGetContentFilters(w, r, false)
+}
+
+// /api/communities/{communityID}/filters [POST]
+func (s *Server) createCommunityContentFilters(w *responseWriter, r *request) error {
+	return s.handleCreateContentFilters(w, r, false)
+}
+
+// /api/communities/{communityID}/filters/one [POST]
+func (s *Server) createCommunityContentFilter(w *responseWriter, r *request) error {
+	return s.handleCreateContentFilter(w, r, false)
+}
+
+// /api/communities/{communityID}/filters/{filterID} [DELETE]
+func (s *Server) deleteCommunityContentFilter(w *responseWriter, r *request) error {
+	return s.handleDeleteContentFilter(w, r, false)
+}
+
+// /api/communities/{communityID}/held_comments [GET]
+func (s *Server) getCommunityHeldComments(w *responseWriter, r *request) error {
+	community, err := s.contentFiltersScope(r, false)
+	if err != nil {
+		return err
+	}
+
+	query := r.urlQueryParams()
+	limit, err := getFeedLimit(query, s.config.PaginationLimit, s.config.PaginationLimitMax)
+	if err != nil {
+		return err
+	}
+	page := 1
+	if spage := query.Get("page"); spage != "" {
+		if page, err = strconv.Atoi(spage); err != nil || page < 1 {
+			return httperr.NewBadRequest("invalid_page", "Invalid page.")
+		}
+	}
+
+	res := struct {
+		NoComments int             `json:"noComments"`
+		Limit      int             `json:"limit"`
+		Page       int             `json:"page"`
+		Comments   []*core.Comment `json:"comments"`
+	}{
+		Limit: limit,
+		Page:  page,
+	}
+	res.NoComments, res.Comments, err = core.GetCommentsHeld(r.ctx, s.db, *community, *r.viewer, limit, page)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(res)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+	msql "github.com/discuitnet/discuit/internal/sql"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+var errNotCustomFeedOwner = httperr.NewForbidden("not-custom-feed-owner", "Not your custom feed.")
+
+// /api/users/{username}/feeds [GET, POST]
+func (s *Server) handleCustomFeeds(w *responseWriter, r *request) error {
+	user, err := core.GetUserByUsername(r.ctx, s.db, strings.ToLower(r.muxVar("username")), r.viewer)
+	if err != nil {
+		return err
+	}
+	userIsViewer := r.loggedIn && user.ID == *r.viewer
+
+	if r.req.Method == "POST" {
+		if !r.loggedIn {
+			return errNotLoggedIn
+		}
+		if !userIsViewer {
+			return errNotCustomFeedOwner
+		}
+
+		form := struct {
+			Name        string          `json:"name"`
+			DisplayName string          `json:"displayName"` // Optional field, defaults to Name.
+			Description msql.NullString `json:"description"`
+			Public      bool            `json:"public"`
+			Communities []uid.ID        `json:"communities"` // Optional field.
+		}{}
+		if err := r.unmarshalJSONBody(&form); err != nil {
+			return err
+		}
+		if form.Name == "" {
+			return httperr.NewBadRequest("custom-feed-name-empty", "Custom feed name cannot be empty.")
+		}
+		if form.DisplayName == "" {
+			form.DisplayName = form.Name
+		}
+		if len(form.Communities) > core.MaxCustomFeedCommunities {
+			return httperr.NewBadRequest("custom-feed-full", "Too many communities.")
+		}
+
+		if err := s.rateLimit(r, "cfeed_c_1_"+r.viewer.String(), time.Second*2, 1); err != nil {
+			return err
+		}
+		if err := s.rateLimit(r, "cfeed_c_2_"+r.viewer.String(), time.Hour*24, 100); err != nil {
+			return err
+		}
+
+		feed, err := core.CreateCustomFeed(r.ctx, s.db, *r.viewer, form.Name, form.DisplayName, form.Description, form.Public)
+		if err != nil {
+			return err
+		}
+		for _, community := range form.Communities {
+			if err := feed.AddCommunity(r.ctx, s.db, community); err != nil {
+				return err
+			}
+		}
+		return s.writeCustomFeed(w, r, feed)
+	}
+
+	feeds, err := core.GetUsersCustomFeeds(r.ctx, s.db, user.ID, !userIsViewer)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(feeds)
+}
+
+func (s *Server) withCustomFeedByName(f func(*responseWriter, *request, *core.CustomFeed) error) handler {
+	return handler(func(w *responseWriter, r *request) error {
+		user, err := core.GetUserByUsername(r.ctx, s.db, r.muxVar("username"), nil)
+		if err != nil {
+			return err
+		}
+		feed, err := core.GetCustomFeedByName(r.ctx, s.db, user.ID, r.muxVar("feedname"))
+		if err != nil {
+			return err
+		}
+		return s.withCustomFeed(f)(w, r, feed)
+	})
+}
+
+func (s *Server) withCustomFeedByID(f func(*responseWriter, *request, *core.CustomFeed) error) handler {
+	return handler(func(w *responseWriter, r *request) error {
+		feedID, err := strconv.Atoi(r.muxVar("feedId"))
+		if err != nil {
+			return httperr.NewBadRequest("invalid-custom-feed-id", "Invalid custom feed id.")
+		}
+		feed, err := core.GetCustomFeed(r.ctx, s.db, feedID)
+		if err != nil {
+			return err
+		}
+		return s.withCustomFeed(f)(w, r, feed)
+	})
+}
+
+// withCustomFeed checks that the viewer can view the feed, and, for requests
+// other than GET, that the viewer owns the feed.
+func (s *Server) withCustomFeed(f func(*responseWriter, *request, *core.CustomFeed) error) func(*responseWriter, *request, *core.CustomFeed) error {
+	return func(w *responseWriter, r *request, feed *core.CustomFeed) error {
+		if !feed.ViewableBy(r.viewer) {
+			return httperr.NewNotFound("custom-feed-not-found", "Custom feed not found.")
+		}
+		if r.req.Method != "GET" {
+			if !r.loggedIn {
+				return errNotLoggedIn
+			}
+			if feed.UserID != *r.viewer {
+				return errNotCustomFeedOwner
+			}
+			if err := s.rateLimit(r, "cfeed_e_1_"+r.viewer.String(), time.Second, 2); err != nil {
+				return err
+			}
+		}
+		return f(w, r, feed)
+	}
+}
+
+// writeCustomFeed writes feed, along with its communities, as the response.
+func (s *Server) writeCustomFeed(w *responseWriter, r *request, feed *core.CustomFeed) error {
+	comms, err := feed.GetCommunities(r.ctx, s.db, r.viewer)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(struct {
+		*core.CustomFeed
+		Communities []*core.Community `json:"communities"`
+	}{feed, comms})
+}
+
+// /api/users/{username}/feeds/{feedname} [GET, PUT, DELETE]
+// /api/feeds/{feedId} [GET, PUT, DELETE]
+func (s *Server) handleCustomFeed(w *responseWriter, r *request, feed *core.CustomFeed) error {
+	switch r.req.Method {
+	case "PUT":
+		data, err := io.ReadAll(r.req.Body)
+		if err != nil {
+			return err
+		}
+		if err := feed.UnmarshalUpdatableFieldsJSON(data); err != nil {
+			return httperr.NewBadRequest("", "Bad JSON body.")
+		}
+		if err := feed.Update(r.ctx, s.db); err != nil {
+			return err
+		}
+	case "DELETE":
+		if err := feed.Delete(r.ctx, s.db); err != nil {
+			return err
+		}
+		return w.writeJSON(feed)
+	}
+	return s.writeCustomFeed(w, r, feed)
+}
+
+// /api/feeds/{feedId}/communities [POST, DELETE]
+func (s *Server) handleCustomFeedCommunities(w *responseWriter, r *request, feed *core.CustomFeed) error {
+	form := struct {
+		CommunityID uid.ID `json:"communityId"`
+	}{}
+	if err := r.unmarshalJSONBody(&form); err != nil {
+		return err
+	}
+	if r.req.Method == "POST" {
+		if err := feed.AddCommunity(r.ctx, s.db, form.CommunityID); err != nil {
+			return err
+		}
+	} else {
+		if err := feed.RemoveCommunity(r.ctx, s.db, form.CommunityID); err != nil {
+			return err
+		}
+	}
+	return s.writeCustomFeed(w, r, feed)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+)
+
+// /api/_export [GET]
+func (s *Server) getDataExports(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	exports, err := core.GetUserDataExports(r.ctx, s.db, *r.viewer)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(exports)
+}
+
+// /api/_export [POST]
+//
+// Starts building a data export of the logged in user. The user is notified
+// when it's ready to be downloaded.
+func (s *Server) requestDataExport(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	export, err := core.RequestDataExport(r.ctx, s.db, *r.viewer)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return w.writeJSON(export)
+}
+
+// /api/_export/{exportID}/download?expires={unix_time}&sig={signature} [GET]
+//
+// The link is signed so that it can be opened without a session (in a
+// download manager, say) until the export expires.
+func (s *Server) downloadDataExport(w *responseWriter, r *request) error {
+	exportID, err := strToID(r.muxVar("exportID"))
+	if err != nil {
+		return err
+	}
+
+	query := r.urlQueryParams()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !core.ValidDataExportSignature(exportID, expires, query.Get("sig")) {
+		return httperr.NewForbidden("invalid_export_link", "Download link is invalid or expired.")
+	}
+
+	export, err := core.GetDataExport(r.ctx, s.db, exportID)
+	if err != nil {
+		return err
+	}
+	if export.Status != core.DataExportStatusReady {
+		return httperr.NewNotFound("export_not_found", "Data export not found.")
+	}
+
+	file, err := os.Open(export.Filepath())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	name := "discuit-export-" + export.CreatedAt.Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	modtime := time.Time{}
+	if export.CompletedAt.Valid {
+		modtime = export.CompletedAt.Time
+	}
+	http.ServeContent(w, r.req, name, modtime, file)
+	return nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Unsubscribe - {{.SiteName}}</title>
+</head>
+<body style="font-family: sans-serif; max-width: 600px; margin: 2em auto; padding: 0 1em;">
+{{if .Done}}<p>You've been unsubscribed from email digests. You can turn them back on in your <a href="/settings">settings</a>.</p>
+{{else if .Invalid}}<p>This unsubscribe link is invalid.</p>
+{{else}}<p>Stop receiving email digests from {{.SiteName}}?</p>
+<form method="POST"><button type="submit">Unsubscribe</button></form>
+{{end}}</body>
+</html>
+`))
+
+// /api/email_digest/unsubscribe?user={userID}&sig={signature} [GET, POST]
+//
+// The link in email digests. It's signed so that it works without a session. A
+// GET request asks for confirmation (so that link prefetchers don't
+// unsubscribe users), and a POST request, which is what email clients send for
+// one-click unsubscribes (RFC 8058), unsubscribes. It's not behind the CSRF
+// check, for such requests have no CSRF token.
+func (s *Server) unsubscribeEmailDigest(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		SiteName      string
+		Done, Invalid bool
+	}{SiteName: s.config.SiteName}
+
+	query := r.URL.Query()
+	user, err := uid.FromString(query.Get("user"))
+	if err != nil {
+		data.Invalid = true
+	} else if r.Method == "POST" {
+		if err := core.UnsubscribeFromEmailDigest(r.Context(), s.db, user, query.Get("sig")); err != nil {
+			if err == core.ErrInvalidUnsubscribeLink {
+				data.Invalid = true
+			} else {
+				s.logInternalServerError(r, err)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			data.Done = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if data.Invalid {
+		w.WriteHeader(http.StatusForbidden)
+	}
+	if err := unsubscribePage.Execute(w, data); err != nil {
+		s.logInternalServerError(r, err)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+	"github.com/discuitnet/discuit/internal/httputil"
+)
+
+const (
+	// How often a comment line is sent on an idle event stream, so that
+	// proxies don't close it and dead connections are detected.
+	eventsHeartbeatInterval = time.Second * 25
+
+	// Event streams are closed after this long (clients reconnect), so that
+	// a stream doesn't outlive the session it was opened with.
+	eventsMaxDuration = time.Hour
+
+	// A write to an event stream that takes longer than this closes it.
+	eventsWriteTimeout = time.Second * 30
+)
+
+// /api/_events[?post={postID}] [GET]
+//
+// Streams real-time events as Server-Sent Events: the events of the logged in
+// user (like changes to the count of unseen notifications), and, if post is
+// set, the events of the post (new comments and votes). Each event is a JSON
+// core.RealtimeEvent. If the client doesn't keep up with the events, an
+// "overflow" event is sent and the stream is closed; clients are expected to
+// reconnect and refetch.
+func (s *Server) streamEvents(w *responseWriter, r *request) error {
+	bucket := "events_" + httputil.GetIP(r.req)
+	if r.loggedIn {
+		bucket = "events_" + r.viewer.String()
+	}
+	if err := s.rateLimit(r, bucket, time.Minute, 30); err != nil {
+		return err
+	}
+
+	var channels []string
+	if r.loggedIn {
+		channels = append(channels, core.UserEventsChannel(*r.viewer))
+	}
+	if postID := r.urlQueryParamsValue("post"); postID != "" {
+		id, err := strToID(postID)
+		if err != nil {
+			return err
+		}
+		post, err := core.GetPost(r.ctx, s.db, &id, "", r.viewer, false)
+		if err != nil {
+			return err
+		}
+		if ok, err := post.ViewableBy(r.ctx, r.viewer); err != nil {
+			return err
+		} else if !ok {
+			return httperr.NewNotFound("post/not-found", "Post not found.")
+		}
+		channels = append(channels, core.PostEventsChannel(post.ID))
+	}
+	if len(channels) == 0 {
+		return errNotLoggedIn
+	}
+
+	sub := s.broker.Subscribe(channels...)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	write := func(format string, a ...any) error {
+		rc.SetWriteDeadline(time.Now().Add(eventsWriteTimeout)) // not supported by all writers
+		if _, err := fmt.Fprintf(w, format, a...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // for nginx
+	w.WriteHeader(http.StatusOK)
+	if err := write("retry: 5000\n\n"); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	timeout := time.NewTimer(eventsMaxDuration)
+	defer timeout.Stop()
+
+	for {
+		var err error
+		select {
+		case msg, ok := <-sub.C():
+			if !ok {
+				if sub.Dropped() {
+					write("event: overflow\ndata: {}\n\n")
+				}
+				return nil
+			}
+			err = write("data: %s\n\n", msg.Data)
+		case <-heartbeat.C:
+			err = write(": ping\n\n")
+		case <-timeout.C:
+			return nil
+		case <-r.ctx.Done():
+			return nil
+		}
+		if err != nil {
+			return nil // the client is gone
+		}
+	}
+}
@@ -39,6 +39,9 @@ func (s *Server) getUsersFeed(w *responseWriter, r *request) error {
 	if err != nil {
 		return err
 	}
+	if err := s.profileViewable(r, user); err != nil {
+		return err
+	}
 
 	if user.Banned { // Forbid viewing profile of banned users except for admins.
 		if !r.loggedIn {
@@ -78,7 +81,7 @@ func (s *Server) getUsersFeed(w *responseWriter, r *request) error {
 }
 
 func isFilterValid(filter string) bool {
-	validFilters := []string{"", "all", "deleted", "locked"}
+	validFilters := []string{"", "all", "deleted", "locked", "held"}
 	for _, f := range validFilters {
 		if f == filter {
 			return true
@@ -114,7 +117,7 @@ func (s *Server) feed(w *responseWriter, r *request) error {
 	}
 	var set *core.FeedResultSet
 
-	feed := query.Get("feed") // All or home or community.
+	feed := query.Get("feed") // All or home or community (or a custom feed, if customFeedId is set).
 	if filter == "" {
 		// Home, all and community feeds.
 		homeFeed := feed == "home"
@@ -129,14 +132,39 @@ func (s *Server) feed(w *responseWriter, r *request) error {
 		if cid != nil {
 			homeFeed = false
 		}
+		var customFeedID *int
+		if idText := query.Get("customFeedId"); idText != "" {
+			id, err := strconv.Atoi(idText)
+			if err != nil {
+				return httperr.NewBadRequest("invalid-custom-feed-id", "Invalid custom feed id.")
+			}
+			customFeed, err := core.GetCustomFeed(r.ctx, s.db, id)
+			if err != nil {
+				return err
+			}
+			if !customFeed.ViewableBy(r.viewer) {
+				return httperr.NewNotFound("custom-feed-not-found", "Custom feed not found.")
+			}
+			customFeedID = &customFeed.ID
+			homeFeed, cid = false, nil
+		}
+		var hideViewed bool
+		if r.loggedIn {
+			if hideViewed, err = core.UserHidesViewedPosts(r.ctx, s.db, *r.viewer); err != nil {
+				return err
+			}
+		}
 		set, err = core.GetFeed(r.ctx, s.db, &core.FeedOptions{
 			Sort:        sort,
 			DefaultSort: sort == s.config.DefaultFeedSort,
 			Viewer:      r.viewer,
 			Community:   cid,
 			Homefeed:    homeFeed,
+			CustomFeed:  customFeedID,
 			Limit:       limit,
 			Next:        nextText,
+			HideHidden:  r.loggedIn,
+			HideViewed:  hideViewed,
 		})
 		if err != nil {
 			return err
@@ -182,6 +210,8 @@ func (s *Server) feed(w *responseWriter, r *request) error {
 			res.NoPosts, res.Posts, err = core.GetPostsDeleted(r.ctx, s.db, communityID, limit, page)
 		} else if filter == "locked" {
 			res.NoPosts, res.Posts, err = core.GetPostsLocked(r.ctx, s.db, communityID, limit, page)
+		} else if filter == "held" {
+			res.NoPosts, res.Posts, err = core.GetPostsHeld(r.ctx, s.db, communityID, limit, page)
 		} else {
 			return errInvalidFeedFilter
 		}
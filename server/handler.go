@@ -36,6 +36,11 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 	rw.w.WriteHeader(statusCode)
 }
 
+// Unwrap is for http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
 func (rw *responseWriter) writeJSON(v any) error {
 	return json.NewEncoder(rw).Encode(v)
 }
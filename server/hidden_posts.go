@@ -0,0 +1,68 @@
+package server
+
+import (
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// /api/hidden_posts [GET, POST]
+func (s *Server) handleHiddenPosts(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	if r.req.Method == "POST" {
+		request := struct {
+			PostID uid.ID `json:"postId"`
+		}{}
+		if err := r.unmarshalJSONBody(&request); err != nil {
+			return err
+		}
+		if _, err := core.GetPost(r.ctx, s.db, &request.PostID, "", nil, true); err != nil {
+			return err
+		}
+		if err := core.HidePost(r.ctx, s.db, *r.viewer, request.PostID); err != nil {
+			return err
+		}
+		return w.writeString(`{"success":true}`)
+	}
+
+	query := r.urlQueryParams()
+	limit, err := getFeedLimit(query, s.config.PaginationLimit, s.config.PaginationLimitMax)
+	if err != nil {
+		return err
+	}
+	set, err := core.GetHiddenPosts(r.ctx, s.db, *r.viewer, limit, query.Get("next"))
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(set)
+}
+
+// /api/hidden_posts/{postID} [DELETE]
+func (s *Server) unhidePost(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	postID, err := strToID(r.muxVar("postID"))
+	if err != nil {
+		return err
+	}
+	if err := core.UnhidePost(r.ctx, s.db, *r.viewer, postID); err != nil {
+		return err
+	}
+	return w.writeString(`{"success":true}`)
+}
+
+// /api/viewed_posts [DELETE]
+func (s *Server) clearViewedPosts(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	if err := core.ClearViewedPosts(r.ctx, s.db, *r.viewer); err != nil {
+		return err
+	}
+	return w.writeString(`{"success":true}`)
+}
@@ -41,8 +41,13 @@ func (s *Server) addPost(w *responseWriter, r *request) error {
 		return err
 	}
 
-	// Disallow image post creation if image posts are disabled in config.
-	if s.config.DisableImagePosts && req.PostType == core.PostTypeImage {
+	// Disallow image post creation if image posts are disabled in the site
+	// settings.
+	settings, err := s.siteSettings(r.ctx)
+	if err != nil {
+		return err
+	}
+	if settings.DisableImagePosts && req.PostType == core.PostTypeImage {
 		return httperr.NewForbidden("no_image_posts", "Image posts are not allowed")
 	}
 
@@ -99,11 +104,29 @@ func (s *Server) getPost(w *responseWriter, r *request) error {
 	if err != nil {
 		return err
 	}
+	if ok, err := post.ViewableBy(r.ctx, r.viewer); err != nil {
+		return err
+	} else if !ok {
+		return httperr.NewNotFound("post/not-found", "Post(s) not found.")
+	}
 
 	if _, err = post.GetComments(r.ctx, r.viewer, nil); err != nil {
 		return err
 	}
 
+	if r.loggedIn {
+		if post.HiddenByViewer, err = core.PostHiddenBy(r.ctx, s.db, *r.viewer, post.ID); err != nil {
+			return err
+		}
+		if hideViewed, err := core.UserHidesViewedPosts(r.ctx, s.db, *r.viewer); err != nil {
+			return err
+		} else if hideViewed {
+			if err = core.MarkPostViewed(r.ctx, s.db, *r.viewer, post.ID); err != nil {
+				return err
+			}
+		}
+	}
+
 	if fetchCommunity := r.urlQueryParamsValue("fetchCommunity"); fetchCommunity == "" || fetchCommunity == "true" {
 		comm, err := core.GetCommunityByID(r.ctx, s.db, post.CommunityID, r.viewer)
 		if err != nil {
@@ -194,6 +217,14 @@ func (s *Server) updatePost(w *responseWriter, r *request) error {
 			if err = post.Pin(r.ctx, *r.viewer, siteWide, action == "unpin", false); err != nil {
 				return err
 			}
+		case "approve":
+			if err = post.Approve(r.ctx, *r.viewer); err != nil {
+				return err
+			}
+		case "live", "unlive":
+			if err = post.SetLive(r.ctx, *r.viewer, action == "live"); err != nil {
+				return err
+			}
 		default:
 			return httperr.NewBadRequest("invalid_action", "Unsupported action.")
 		}
@@ -279,7 +310,11 @@ func (s *Server) postVote(w *responseWriter, r *request) error {
 
 // /api/_uploads [ POST ]
 func (s *Server) imageUpload(w *responseWriter, r *request) error {
-	if s.config.DisableImagePosts {
+	settings, err := s.siteSettings(r.ctx)
+	if err != nil {
+		return err
+	}
+	if settings.DisableImagePosts {
 		return httperr.NewForbidden("no_image_posts", "Image posts are not all allowed.")
 	}
 	if !r.loggedIn {
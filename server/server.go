@@ -23,6 +23,7 @@ import (
 	"github.com/discuitnet/discuit/internal/httperr"
 	"github.com/discuitnet/discuit/internal/httputil"
 	"github.com/discuitnet/discuit/internal/images"
+	"github.com/discuitnet/discuit/internal/pubsub"
 	"github.com/discuitnet/discuit/internal/ratelimits"
 	"github.com/discuitnet/discuit/internal/sessions"
 	"github.com/discuitnet/discuit/internal/uid"
@@ -41,13 +42,16 @@ var (
 	}
 
 	errNotAdminNorMod = httperr.NewForbidden("not_admin_nor_mod", "User neither an admin nor a mod.")
+
+	errTwoFactorSetupRequired = httperr.NewForbidden("2fa_setup_required", "Two-factor authentication must be enabled to continue.")
+
+	errIPBanned = httperr.NewForbidden("ip_banned", "Signing up or logging in from your network is not allowed.")
 )
 
 type Server struct {
 	config *config.Config
 
-	db        *sql.DB
-	redisPool *redis.Pool
+	db *sql.DB
 
 	// for /api routes
 	router *mux.Router
@@ -55,7 +59,12 @@ type Server struct {
 	// for all other routes
 	staticRouter *mux.Router
 
-	sessions *sessions.RedisStore
+	sessions sessions.Store
+	otps     sessions.OTPStore
+	limiter  ratelimits.Limiter
+
+	// Real-time events are published to broker (see events.go).
+	broker pubsub.Broker
 
 	// react serve
 	reactPath  string
@@ -72,26 +81,44 @@ type Server struct {
 func New(db *sql.DB, conf *config.Config) (*Server, error) {
 	r := mux.NewRouter()
 
-	redisStore, err := sessions.NewRedisStore("tcp", conf.RedisAddress, conf.SessionCookieName)
-	if err != nil {
-		return nil, err
-	}
-
 	s := &Server{
-		db: db,
-		redisPool: &redis.Pool{
-			MaxIdle:     3,
-			IdleTimeout: 240 * time.Second,
-			Dial:        func() (redis.Conn, error) { return redis.Dial("tcp", conf.RedisAddress) },
-		},
+		db:           db,
 		router:       r,
 		staticRouter: mux.NewRouter(),
-		sessions:     redisStore,
 		config:       conf,
 		reactPath:    "./ui/dist/",
 		reactIndex:   "index.html",
 	}
 
+	switch conf.SessionStore {
+	case "", "redis":
+		redisStore, err := sessions.NewRedisStore("tcp", conf.RedisAddress, conf.SessionCookieName)
+		if err != nil {
+			return nil, err
+		}
+		s.sessions, s.otps = redisStore, redisStore
+		s.limiter = &ratelimits.RedisLimiter{Pool: &redis.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 240 * time.Second,
+			Dial:        func() (redis.Conn, error) { return redis.Dial("tcp", conf.RedisAddress) },
+		}}
+		s.broker = pubsub.NewRedisBroker("tcp", conf.RedisAddress, "events:")
+	case "memory":
+		memoryStore := sessions.NewMemoryStore(conf.SessionCookieName)
+		s.sessions, s.otps = memoryStore, memoryStore
+		s.limiter = ratelimits.NewMemoryLimiter()
+		s.broker = pubsub.NewMemoryBroker()
+	case "sql":
+		sqlStore := sessions.NewSQLStore(db, conf.SessionCookieName)
+		s.sessions, s.otps = sqlStore, sqlStore
+		s.limiter = &ratelimits.SQLLimiter{DB: db}
+		s.broker = pubsub.NewMemoryBroker() // single node only
+	default:
+		return nil, fmt.Errorf("unknown session store %q (should be one of redis, memory, or sql)", conf.SessionStore)
+	}
+
+	core.SetRealtimeBroker(s.broker)
+
 	if keys, err := core.GetApplicationVAPIDKeys(context.Background(), db); err != nil {
 		log.Printf("Error generating vapid keys: %v (you might want to run migrations)\n", err)
 	} else {
@@ -110,6 +137,14 @@ func New(db *sql.DB, conf *config.Config) (*Server, error) {
 	r.Handle("/api/_signup_v2", s.withHandler(s.signupVer2)).Methods("POST")
 	r.Handle("/api/_logout", s.withHandler(s.logout)).Methods("POST")
 	r.Handle("/api/_user", s.withHandler(s.getLoggedInUser)).Methods("GET")
+	r.Handle("/api/_events", s.withHandler(s.streamEvents)).Methods("GET")
+	r.Handle("/api/_login/2fa", s.withHandler(s.loginSecondFactor)).Methods("POST")
+	r.Handle("/api/_2fa", s.withHandler(s.getTwoFactorStatus)).Methods("GET")
+	r.Handle("/api/_2fa", s.withHandler(s.updateTwoFactor)).Methods("POST")
+	r.Handle("/api/_account", s.withHandler(s.updateAccount)).Methods("POST")
+	r.Handle("/api/_export", s.withHandler(s.getDataExports)).Methods("GET")
+	r.Handle("/api/_export", s.withHandler(s.requestDataExport)).Methods("POST")
+	r.Handle("/api/_export/{exportID}/download", s.withHandler(s.downloadDataExport)).Methods("GET")
 
 	r.Handle("/api/users/{username}", s.withHandler(s.getUser)).Methods("GET")
 	r.Handle("/api/users/{username}", s.withHandler(s.deleteUser)).Methods("DELETE")
@@ -126,11 +161,23 @@ func New(db *sql.DB, conf *config.Config) (*Server, error) {
 	r.Handle("/api/lists/{listId}/items", s.withHandler(s.withListByID(s.handleListItems))).Methods("GET", "POST", "DELETE")
 	r.Handle("/api/lists/{listId}/items/{itemId}", s.withHandler(s.withListByID(s.deleteListItem))).Methods("DELETE")
 
+	r.Handle("/api/users/{username}/feeds", s.withHandler(s.handleCustomFeeds)).Methods("GET", "POST")
+	r.Handle("/api/users/{username}/feeds/{feedname}", s.withHandler(s.withCustomFeedByName(s.handleCustomFeed))).Methods("GET", "PUT", "DELETE")
+	r.Handle("/api/feeds/{feedId}", s.withHandler(s.withCustomFeedByID(s.handleCustomFeed))).Methods("GET", "PUT", "DELETE")
+	r.Handle("/api/feeds/{feedId}/communities", s.withHandler(s.withCustomFeedByID(s.handleCustomFeedCommunities))).Methods("POST", "DELETE")
+
 	r.Handle("/api/mutes", s.withHandler(s.handleMutes)).Methods("GET", "POST", "DELETE")
 	r.Handle("/api/mutes/users/{mutedUserID}", s.withHandler(s.deleteUserMute)).Methods("DELETE")
 	r.Handle("/api/mutes/communities/{mutedCommunityID}", s.withHandler(s.deleteCommunityMute)).Methods("DELETE")
 	r.Handle("/api/mutes/{muteID}", s.withHandler(s.deleteMute)).Methods("DELETE")
 
+	r.Handle("/api/blocks", s.withHandler(s.handleBlocks)).Methods("GET", "POST")
+	r.Handle("/api/blocks/{blockedUserID}", s.withHandler(s.deleteBlock)).Methods("DELETE")
+
+	r.Handle("/api/hidden_posts", s.withHandler(s.handleHiddenPosts)).Methods("GET", "POST")
+	r.Handle("/api/hidden_posts/{postID}", s.withHandler(s.unhidePost)).Methods("DELETE")
+	r.Handle("/api/viewed_posts", s.withHandler(s.clearViewedPosts)).Methods("DELETE")
+
 	r.Handle("/api/posts", s.withHandler(s.feed)).Methods("GET")
 	r.Handle("/api/posts", s.withHandler(s.addPost)).Methods("POST")
 	r.Handle("/api/posts/{postID}", s.withHandler(s.getPost)).Methods("GET")
@@ -165,6 +212,13 @@ func New(db *sql.DB, conf *config.Config) (*Server, error) {
 	r.Handle("/api/communities/{communityID}/reports", s.withHandler(s.getCommunityReports)).Methods("GET")
 	r.Handle("/api/communities/{communityID}/reports/{reportID}", s.withHandler(s.deleteReport)).Methods("DELETE")
 
+	r.Handle("/api/communities/{communityID}/held_comments", s.withHandler(s.getCommunityHeldComments)).Methods("GET")
+
+	r.Handle("/api/communities/{communityID}/filters", s.withHandler(s.getCommunityContentFilters)).Methods("GET")
+	r.Handle("/api/communities/{communityID}/filters", s.withHandler(s.createCommunityContentFilters)).Methods("POST")
+	r.Handle("/api/communities/{communityID}/filters/one", s.withHandler(s.createCommunityContentFilter)).Methods("POST")
+	r.Handle("/api/communities/{communityID}/filters/{filterID}", s.withHandler(s.deleteCommunityContentFilter)).Methods("DELETE")
+
 	r.Handle("/api/communities/{communityID}/banned", s.withHandler(s.handleCommunityBanned)).Methods("GET", "POST", "DELETE")
 
 	r.Handle("/api/communities/{communityID}/pro_pic", s.withHandler(s.handleCommunityProPic)).Methods("POST", "DELETE")
@@ -172,20 +226,58 @@ func New(db *sql.DB, conf *config.Config) (*Server, error) {
 
 	r.Handle("/api/notifications", s.withHandler(s.getNotifications)).Methods("GET")
 	r.Handle("/api/notifications", s.withHandler(s.updateNotifications)).Methods("POST")
+	r.Handle("/api/notifications/subscriptions", s.withHandler(s.handleThreadSubscriptions)).Methods("GET", "POST")
+	r.Handle("/api/notifications/subscriptions/{subscriptionID}", s.withHandler(s.deleteThreadSubscription)).Methods("DELETE")
 	r.Handle("/api/notifications/{notificationID}", s.withHandler(s.getNotification)).Methods("GET", "PUT")
 	r.Handle("/api/notifications/{notificationID}", s.withHandler(s.deleteNotification)).Methods("DELETE")
 
 	r.Handle("/api/push_subscriptions", s.withHandler(s.pushSubscriptions)).Methods("POST")
 
 	r.Handle("/api/community_requests", s.withHandler(s.handleCommunityRequests)).Methods("GET", "POST")
-	r.Handle("/api/community_requests/{requestID}", s.withHandler(s.deleteCommunityRequest)).Methods("DELTE")
+	r.Handle("/api/community_requests/{requestID}", s.withHandler(s.deleteCommunityRequest)).Methods("DELETE")
 
 	r.Handle("/api/_report", s.withHandler(s.report)).Methods("POST")
 
+	r.Handle("/api/_sessions", s.withHandler(s.getSessions)).Methods("GET")
+	r.Handle("/api/_sessions", s.withHandler(s.revokeOtherSessions)).Methods("DELETE")
+	r.Handle("/api/_sessions/{sessionID}", s.withHandler(s.revokeSession)).Methods("DELETE")
+
 	r.Handle("/api/_settings", s.withHandler(s.updateUserSettings)).Methods("POST")
 
 	r.Handle("/api/_admin", s.withHandler(s.adminActions)).Methods("POST")
 
+	r.Handle("/api/admin/v1/users", s.withAdmin("search_users", s.adminSearchUsers)).Methods("GET")
+	r.Handle("/api/admin/v1/users/{username}", s.withAdmin("get_user", s.adminGetUser)).Methods("GET")
+	r.Handle("/api/admin/v1/users/{username}", s.withAdmin("delete_user", s.adminDeleteUser)).Methods("DELETE")
+	r.Handle("/api/admin/v1/users/{username}/ban", s.withAdmin("ban_user", s.adminBanUser)).Methods("POST")
+	r.Handle("/api/admin/v1/users/{username}/ban", s.withAdmin("unban_user", s.adminUnbanUser)).Methods("DELETE")
+	r.Handle("/api/admin/v1/users/{username}/shadow_ban", s.withAdmin("shadow_ban_user", s.adminShadowBanUser)).Methods("POST")
+	r.Handle("/api/admin/v1/users/{username}/shadow_ban", s.withAdmin("lift_shadow_ban", s.adminShadowBanUser)).Methods("DELETE")
+	r.Handle("/api/admin/v1/users/{username}/bans", s.withAdmin("get_user_bans", s.adminGetUserBans)).Methods("GET")
+	r.Handle("/api/admin/v1/users/{username}/shared_ips", s.withAdmin("get_users_sharing_ips", s.adminGetUsersSharingIPs)).Methods("GET")
+	r.Handle("/api/admin/v1/users/{username}/password", s.withAdmin("reset_password", s.adminResetPassword)).Methods("POST")
+	r.Handle("/api/admin/v1/users/{username}/admin", s.withAdmin("set_admin", s.adminSetAdmin)).Methods("PUT")
+	r.Handle("/api/admin/v1/users/{username}/badges", s.withAdmin("add_badge", s.adminAddBadge)).Methods("POST")
+	r.Handle("/api/admin/v1/users/{username}/badges/{badgeType}", s.withAdmin("remove_badge", s.adminRemoveBadge)).Methods("DELETE")
+	r.Handle("/api/admin/v1/badges", s.withAdmin("get_badge_types", s.adminGetBadgeTypes)).Methods("GET")
+	r.Handle("/api/admin/v1/badges", s.withAdmin("new_badge_type", s.adminNewBadgeType)).Methods("POST")
+	r.Handle("/api/admin/v1/communities/{name}", s.withAdmin("update_community", s.adminUpdateCommunity)).Methods("PUT")
+	r.Handle("/api/admin/v1/communities/{name}/add_all_users", s.withAdmin("add_all_users_to_community", s.adminAddAllUsersToCommunity)).Methods("POST")
+	r.Handle("/api/admin/v1/communities/{name}/mods/{username}", s.withAdmin("add_community_mod", s.adminCommunityMod)).Methods("PUT")
+	r.Handle("/api/admin/v1/communities/{name}/mods/{username}", s.withAdmin("remove_community_mod", s.adminCommunityMod)).Methods("DELETE")
+	r.Handle("/api/admin/v1/community_requests/{requestID}/approve", s.withAdmin("approve_community_request", s.adminApproveCommunityRequest)).Methods("POST")
+	r.Handle("/api/admin/v1/community_requests/{requestID}/reject", s.withAdmin("reject_community_request", s.adminRejectCommunityRequest)).Methods("POST")
+	r.Handle("/api/admin/v1/settings", s.withAdmin("get_site_settings", s.adminGetSiteSettings)).Methods("GET")
+	r.Handle("/api/admin/v1/settings", s.withAdmin("update_site_settings", s.adminUpdateSiteSettings)).Methods("PUT")
+	r.Handle("/api/admin/v1/pins", s.withAdmin("get_site_pins", s.adminGetSitePins)).Methods("GET")
+	r.Handle("/api/admin/v1/pins", s.withAdmin("pin_post", s.adminPinPost)).Methods("POST")
+	r.Handle("/api/admin/v1/pins/{postID}", s.withAdmin("unpin_post", s.adminUnpinPost)).Methods("DELETE")
+	r.Handle("/api/admin/v1/ip_bans", s.withAdmin("get_ip_bans", s.adminGetIPBans)).Methods("GET")
+	r.Handle("/api/admin/v1/ip_bans", s.withAdmin("create_ip_ban", s.adminCreateIPBan)).Methods("POST")
+	r.Handle("/api/admin/v1/ip_bans/matches", s.withAdmin("get_ip_ban_matches", s.adminGetIPBanMatches)).Methods("GET")
+	r.Handle("/api/admin/v1/ip_bans/{banID}", s.withAdmin("delete_ip_ban", s.adminDeleteIPBan)).Methods("DELETE")
+	r.Handle("/api/admin/v1/audit_log", s.withAdmin("get_audit_log", s.adminGetAuditLog)).Methods("GET")
+
 	r.Handle("/api/_link_info", s.withHandler(s.getLinkInfo)).Methods("GET")
 
 	r.Handle("/api/analytics", s.withHandler(s.handleAnalytics)).Methods("POST")
@@ -194,6 +286,16 @@ func New(db *sql.DB, conf *config.Config) (*Server, error) {
 	r.Handle("/api/_blacklists/one", s.withHandler(s.createBlackDomain)).Methods("POST")
 	r.Handle("/api/_blacklists", s.withHandler(s.createBlackDomains)).Methods("POST")
 
+	r.Handle("/api/_filters", s.withHandler(s.getContentFilters)).Methods("GET")
+	r.Handle("/api/_filters", s.withHandler(s.createContentFilters)).Methods("POST")
+	r.Handle("/api/_filters/one", s.withHandler(s.createContentFilter)).Methods("POST")
+	r.Handle("/api/_filters/{filterID}", s.withHandler(s.deleteContentFilter)).Methods("DELETE")
+
+	r.Handle("/api/_spam_flags", s.withHandler(s.getSpamFlags)).Methods("GET")
+	r.Handle("/api/_spam_flags/{flagID}", s.withHandler(s.updateSpamFlag)).Methods("PUT")
+
+	r.HandleFunc("/api/email_digest/unsubscribe", s.unsubscribeEmailDigest).Methods("GET", "POST")
+
 	r.NotFoundHandler = http.HandlerFunc(s.apiNotFoundHandler)
 	r.MethodNotAllowedHandler = http.HandlerFunc(s.apiMethodNotAllowedHandler)
 
@@ -203,6 +305,8 @@ func New(db *sql.DB, conf *config.Config) (*Server, error) {
 		DB:            db,
 		EnableCORS:    false,
 	})
+	s.staticRouter.HandleFunc("/sitemap.xml", s.serveSitemapIndex).Methods("GET")
+	s.staticRouter.HandleFunc(sitemapsPathPrefix+"{name}", s.serveSitemap).Methods("GET")
 
 	if conf.UIProxy != "" {
 		s.staticRouter.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -262,9 +366,27 @@ func (s *Server) closeLoggers() {
 // Close closes the server.
 func (s *Server) Close() error {
 	s.closeLoggers()
+	s.broker.Close()
 	return s.sessions.Close()
 }
 
+// PurgeExpiredSessions removes the expired sessions, OTPs, and rate limit
+// buckets from stores that don't expire them on their own (Redis does).
+func (s *Server) PurgeExpiredSessions() error {
+	if p, ok := s.sessions.(interface{ PurgeExpired() error }); ok {
+		if err := p.PurgeExpired(); err != nil {
+			return err
+		}
+	}
+	switch l := s.limiter.(type) {
+	case *ratelimits.MemoryLimiter:
+		l.Purge(time.Hour * 24)
+	case *ratelimits.SQLLimiter:
+		return l.Purge(time.Hour * 24)
+	}
+	return nil
+}
+
 // updateUserLastSeen updates the last seen time and the last seen IP address of
 // the logged in user, if the user is logged in, in Redis and persists it to
 // MariaDB.
@@ -275,11 +397,13 @@ func updateUserLastSeen(ctx context.Context, w http.ResponseWriter, r *http.Requ
 	}
 
 	update := func() error {
+		ip := httputil.GetIP(r)
 		ses.Values["last_seen"] = time.Now().Unix()
+		ses.Values["last_seen_ip"] = ip
 		if err := ses.Save(w, r); err != nil {
 			return err
 		}
-		return core.UserSeen(ctx, db, *uid, httputil.GetIP(r))
+		return core.UserSeen(ctx, db, *uid, ip)
 	}
 
 	ts, ok := ses.Values["last_seen"]
@@ -322,6 +446,11 @@ func (s *Server) withHandler(h handler) http.Handler {
 			}
 		}
 
+		if setup, _ := ses.Values["2fa_setup_required"].(bool); setup && !twoFactorSetupAllowed(r.URL.Path) {
+			s.writeError(w, r, errTwoFactorSetupRequired)
+			return
+		}
+
 		if err = h(&responseWriter{w: w}, newRequest(r, ses)); err != nil {
 			s.writeError(w, r, err)
 			return
@@ -409,7 +538,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	beginT := time.Now()
 
 	if r.URL.Path == "/robots.txt" {
-		http.ServeFile(w, r, "./robots.txt")
+		s.serveRobotsTxt(w, r)
 	} else if r.URL.Path == "/manifest.json" {
 		w.Header().Add("Cache-Control", "no-cache")
 		http.ServeFile(w, r, "./ui/dist/manifest.json")
@@ -774,6 +903,9 @@ func (s *Server) insertMetaTags(doc *html.Node, r *http.Request) {
 		// post page
 		post, err := core.GetPost(ctx, s.db, nil, list[2], nil, true)
 		if err == nil {
+			if ok, _ := post.ViewableBy(ctx, nil); !ok {
+				return
+			}
 			appendTitle(post.Title, "")
 			sep := " • "
 			upVotes := strconv.Itoa(post.Upvotes) + " upvote"
@@ -891,32 +1023,100 @@ func isLoggedIn(ses *sessions.Session) (bool, *uid.ID) {
 	return true, &userID
 }
 
-// userSessionsSetRedisKey returns the Redis key where the set of session IDs of the user
-// are stored. This is so that when deleting or banning an account, all sessions
-// of that user can be purged.
-//
-// Username should be all lowercase.
-func userSessionsSetRedisKey(username string) string {
-	return "sessions:" + username
-}
-
 // loginUser persists the authenticated user onto the session.
 func (s *Server) loginUser(u *core.User, ses *sessions.Session, w http.ResponseWriter, r *http.Request) error {
+	if u.BanExpired() {
+		// The ban is yet to be lifted by the background job.
+		if err := u.Unban(r.Context()); err != nil {
+			return err
+		}
+	}
 	if u.Banned {
-		return httperr.NewForbidden("account_suspended", "User account suspended.")
+		return errAccountSuspended(u)
+	}
+	if !u.Admin {
+		if _, err := s.checkIPBan(r.Context(), r, "login", &u.ID); err != nil {
+			return err
+		}
 	}
 
-	conn := s.redisPool.Get()
-	defer conn.Close()
+	// Logging in reactivates a deactivated account and cancels its deletion,
+	// unless the account was deactivated, or the deletion scheduled, by an
+	// admin.
+	if u.DeleteAt.Valid && !u.DeletionScheduledBySelf() {
+		return httperr.NewForbidden("account_deletion_scheduled", "User account is scheduled for deletion.")
+	}
+	if u.Deactivated && !u.DeactivatedBySelf() {
+		return httperr.NewForbidden("account_deactivated", "User account is deactivated.")
+	}
+	if u.Deactivated {
+		if err := u.Reactivate(r.Context()); err != nil {
+			return err
+		}
+	}
 
-	if _, err := conn.Do("SADD", userSessionsSetRedisKey(u.UsernameLowerCase), ses.ID); err != nil {
+	// The set of sessions of the user is kept so that when deleting or banning
+	// an account, all sessions of that user can be purged.
+	if err := s.sessions.AddUserSession(u.UsernameLowerCase, ses.ID); err != nil {
 		return err
 	}
 
+	if s.config.RequireTwoFactorForMods && !u.TwoFactorEnabled {
+		required := u.Admin
+		if !required {
+			var err error
+			if required, err = core.UserModOfAny(r.Context(), s.db, u.ID); err != nil {
+				return err
+			}
+		}
+		if required {
+			ses.Values["2fa_setup_required"] = true
+		}
+	}
+
 	ses.Values["uid"] = u.ID.String()
+	ses.Values["created_at"] = time.Now().Unix()
+	ses.Values["user_agent"] = r.UserAgent()
+	ses.Values["last_seen_ip"] = httputil.GetIP(r)
 	return ses.Save(w, r)
 }
 
+// errAccountSuspended returns the error shown to a banned user on login, which
+// includes the reason for the ban and its duration.
+func errAccountSuspended(u *core.User) error {
+	msg := "User account suspended"
+	if u.BanExpiresAt.Valid {
+		msg += " until " + u.BanExpiresAt.Time.UTC().Format("January 2, 2006 15:04 MST")
+	} else {
+		msg += " permanently"
+	}
+	msg += "."
+	if u.BanReason.Valid {
+		msg += " Reason: " + strings.TrimSuffix(u.BanReason.String, ".") + "."
+	}
+	return httperr.NewForbidden("account_suspended", msg)
+}
+
+// checkIPBan returns the IP ban that covers the IP address of r, if any. The
+// attempt to sign up or log in (event) is recorded if the ban blocks it, or if
+// user is known. If the ban blocks, errIPBanned is returned.
+func (s *Server) checkIPBan(ctx context.Context, r *http.Request, event string, user *uid.ID) (*core.IPBan, error) {
+	ban, err := core.MatchIPBan(ctx, s.db, httputil.GetIP(r))
+	if err != nil || ban == nil {
+		return nil, err
+	}
+	blocked := ban.Action == core.IPBanActionBlock
+	if blocked || user != nil {
+		if err := ban.RecordMatch(ctx, s.db, user, event, blocked); err != nil {
+			log.Printf("Error recording ip ban match: %v\n", err)
+		}
+	}
+	if blocked {
+		return ban, errIPBanned
+	}
+	return ban, nil
+}
+
 func (s *Server) logoutUser(u *core.User, ses *sessions.Session, w http.ResponseWriter, r *http.Request) error {
 	if err := core.DeleteWebPushSubscription(r.Context(), s.db, ses.ID); err != nil {
 		return err
@@ -928,30 +1128,25 @@ func (s *Server) logoutUser(u *core.User, ses *sessions.Session, w http.Response
 		return err
 	}
 
-	conn := s.redisPool.Get()
-	defer conn.Close()
-
-	_, err := conn.Do("SREM", userSessionsSetRedisKey(u.UsernameLowerCase), ses.ID)
-	return err
+	return s.sessions.RemoveUserSession(u.UsernameLowerCase, ses.ID)
 }
 
 func (s *Server) LogoutAllSessionsOfUser(u *core.User) error {
-	conn := s.redisPool.Get()
-	defer conn.Close()
-
-	sessionIDs, err := redis.Strings(conn.Do("SMEMBERS", userSessionsSetRedisKey(u.UsernameLowerCase)))
+	sessionIDs, err := s.sessions.UserSessions(u.UsernameLowerCase)
 	if err != nil {
 		return err
 	}
 
 	for _, id := range sessionIDs {
-		if _, err := conn.Do("DEL", s.sessions.RedisKey(id)); err != nil {
+		if err := core.DeleteWebPushSubscription(context.Background(), s.db, id); err != nil {
+			return err
+		}
+		if err := s.sessions.Delete(id); err != nil {
 			return err
 		}
 	}
 
-	_, err = conn.Do("DEL", userSessionsSetRedisKey(u.UsernameLowerCase))
-	return err
+	return s.sessions.DeleteUserSessions(u.UsernameLowerCase)
 }
 
 // strToID always returns either a nil-error or an error of type httperr.Error.
@@ -977,13 +1172,7 @@ func (s *Server) rateLimit(r *request, bucketID string, interval time.Duration,
 		}
 	}
 
-	conn, err := s.redisPool.Dial()
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	if ok, err := ratelimits.Limit(conn, bucketID, interval, maxTokens); err != nil {
+	if ok, err := s.limiter.Limit(bucketID, interval, maxTokens); err != nil {
 		return err
 	} else if !ok {
 		return &httperr.Error{
@@ -1019,23 +1208,25 @@ func (s *Server) getLinkInfo(w *responseWriter, r *request) error {
 		return err
 	}
 
-	url := r.urlQueryParamsValue("url")
-	res, err := httputil.Get(url)
+	info, err := core.GetLinkInfo(r.ctx, r.urlQueryParamsValue("url"))
 	if err != nil {
-		return err
+		if info == nil {
+			return err
+		}
+		return httperr.NewBadRequest("link_unreachable", "Could not fetch the link.")
 	}
-	defer res.Body.Close()
 
-	title, err := httputil.ExtractOpenGraphTitle(res.Body)
-	if err != nil {
-		return err
+	if info.Embed != nil {
+		viewer, err := core.GetUser(r.ctx, s.db, *r.viewer, nil)
+		if err != nil {
+			return err
+		}
+		if viewer.EmbedsOff {
+			info.Embed = nil
+		}
 	}
 
-	out := struct {
-		Title string `json:"title"`
-	}{Title: title}
-
-	return w.writeJSON(out)
+	return w.writeJSON(info)
 }
 
 func (s *Server) handleAnalytics(w *responseWriter, r *request) error {
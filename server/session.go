@@ -0,0 +1,232 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+	"github.com/discuitnet/discuit/internal/sessions"
+)
+
+// userSession is a logged in session of a user, as shown to the user.
+type userSession struct {
+	// ID is derived from the session ID (which is the value of the session
+	// cookie, and so never sent in a response).
+	ID         string     `json:"id"`
+	Current    bool       `json:"current"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  *time.Time `json:"createdAt"`  // Null for sessions created before this was recorded.
+	LastSeenAt *time.Time `json:"lastSeenAt"` // Null if never updated.
+
+	sessionID string
+}
+
+// publicSessionID returns the ID by which a session is identified in the API.
+func publicSessionID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:16])
+}
+
+func sessionValueTime(ses *sessions.Session, key string) *time.Time {
+	v, ok := ses.Values[key].(float64)
+	if !ok {
+		return nil
+	}
+	t := time.Unix(int64(v), 0)
+	return &t
+}
+
+func sessionValueString(ses *sessions.Session, key string) string {
+	v, _ := ses.Values[key].(string)
+	return v
+}
+
+// describeUserAgent returns a short, human-readable description (like
+// "Firefox on Windows") of a User-Agent header.
+func describeUserAgent(ua string) string {
+	browser, os := "Unknown browser", "unknown device"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			os = o.name
+			break
+		}
+	}
+	return browser + " on " + os
+}
+
+// getUserSessions returns the sessions of the logged in user of r. Session IDs
+// that are no longer in the store (expired) are removed from the user's set.
+func (s *Server) getUserSessions(r *request, user *core.User) ([]*userSession, error) {
+	ids, err := s.sessions.UserSessions(user.UsernameLowerCase)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*userSession
+	for _, id := range ids {
+		ses, err := s.sessions.Load(id)
+		if err != nil {
+			if err == sessions.ErrNoSession {
+				if err := s.sessions.RemoveUserSession(user.UsernameLowerCase, id); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		if sessionValueString(ses, "uid") != user.ID.String() {
+			continue // Logged out, but not removed from the set.
+		}
+		ua := sessionValueString(ses, "user_agent")
+		list = append(list, &userSession{
+			ID:         publicSessionID(id),
+			Current:    id == r.ses.ID,
+			Device:     describeUserAgent(ua),
+			UserAgent:  ua,
+			IP:         sessionValueString(ses, "last_seen_ip"),
+			CreatedAt:  sessionValueTime(ses, "created_at"),
+			LastSeenAt: sessionValueTime(ses, "last_seen"),
+			sessionID:  id,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Current != list[j].Current {
+			return list[i].Current
+		}
+		a, b := list[i].LastSeenAt, list[j].LastSeenAt
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.After(*b)
+	})
+	return list, nil
+}
+
+// revokeUserSession logs out the session with the ID sessionID of user.
+func (s *Server) revokeUserSession(r *request, user *core.User, sessionID string) error {
+	if err := core.DeleteWebPushSubscription(r.ctx, s.db, sessionID); err != nil {
+		return err
+	}
+	if err := s.sessions.Delete(sessionID); err != nil {
+		return err
+	}
+	return s.sessions.RemoveUserSession(user.UsernameLowerCase, sessionID)
+}
+
+// /api/_sessions [GET]
+func (s *Server) getSessions(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	user, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+	if err != nil {
+		return err
+	}
+
+	list, err := s.getUserSessions(r, user)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []*userSession{}
+	}
+	return w.writeJSON(list)
+}
+
+// /api/_sessions/{sessionID} [DELETE]
+func (s *Server) revokeSession(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	user, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+	if err != nil {
+		return err
+	}
+
+	list, err := s.getUserSessions(r, user)
+	if err != nil {
+		return err
+	}
+
+	publicID := r.muxVar("sessionID")
+	for _, item := range list {
+		if item.ID != publicID {
+			continue
+		}
+		if item.Current {
+			err = s.logoutUser(user, r.ses, w, r.req)
+		} else {
+			err = s.revokeUserSession(r, user, item.sessionID)
+		}
+		if err != nil {
+			return err
+		}
+		return w.writeJSON(item)
+	}
+
+	return httperr.NewNotFound("session_not_found", "Session not found.")
+}
+
+// /api/_sessions [DELETE]
+//
+// Logs out all sessions of the user other than the current one.
+func (s *Server) revokeOtherSessions(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	user, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+	if err != nil {
+		return err
+	}
+
+	list, err := s.getUserSessions(r, user)
+	if err != nil {
+		return err
+	}
+
+	revoked := 0
+	for _, item := range list {
+		if item.Current {
+			continue
+		}
+		if err := s.revokeUserSession(r, user, item.sessionID); err != nil {
+			return err
+		}
+		revoked++
+	}
+
+	return w.writeJSON(map[string]any{"revoked": revoked})
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+	"github.com/gorilla/mux"
+)
+
+// sitemapsPathPrefix is the path under which individual sitemap shards are
+// served.
+const sitemapsPathPrefix = "/sitemaps/"
+
+// siteBaseURL returns the configured URL of the site, without a trailing
+// slash, or an empty string if it's not set. The host header of the request is
+// never used, since the sitemaps are cached by shared caches.
+func (s *Server) siteBaseURL() string {
+	return strings.TrimSuffix(s.config.SiteURL, "/")
+}
+
+// /robots.txt [GET]
+func (s *Server) serveRobotsTxt(w http.ResponseWriter, r *http.Request) {
+	data, err := os.ReadFile("./robots.txt")
+	if err != nil && !os.IsNotExist(err) {
+		s.logInternalServerError(r, err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	text := strings.TrimRight(string(data), "\n")
+	if base := s.siteBaseURL(); base != "" {
+		if text != "" {
+			text += "\n\n"
+		}
+		text += "Sitemap: " + base + "/sitemap.xml\n"
+	} else if text != "" {
+		text += "\n"
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(text))
+}
+
+// /sitemap.xml [GET]
+func (s *Server) serveSitemapIndex(w http.ResponseWriter, r *http.Request) {
+	base := s.siteBaseURL()
+	if base == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	maps, err := core.GetSitemaps(r.Context(), s.db)
+	if err != nil {
+		s.logInternalServerError(r, err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if err := core.WriteSitemapIndex(w, maps, base, sitemapsPathPrefix); err != nil {
+		s.logInternalServerError(r, err)
+	}
+}
+
+// /sitemaps/{type}-{shard}.xml [GET]
+func (s *Server) serveSitemap(w http.ResponseWriter, r *http.Request) {
+	base := s.siteBaseURL()
+	if base == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := strings.TrimSuffix(mux.Vars(r)["name"], ".xml")
+	i := strings.LastIndex(name, "-")
+	if i == -1 {
+		http.NotFound(w, r)
+		return
+	}
+	t := core.SitemapType(name[:i])
+	shard, err := strconv.Atoi(name[i+1:])
+	if err != nil || !t.Valid() {
+		http.NotFound(w, r)
+		return
+	}
+
+	sitemap, err := core.GetSitemap(r.Context(), s.db, t, shard)
+	if err != nil {
+		if httperr.IsNotFound(err) {
+			http.NotFound(w, r)
+			return
+		}
+		s.logInternalServerError(r, err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if err := sitemap.WriteXML(w, base); err != nil {
+		s.logInternalServerError(r, err)
+	}
+}
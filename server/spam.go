@@ -0,0 +1,124 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+)
+
+// /api/_spam_flags [GET]
+func (s *Server) getSpamFlags(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	admin, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+	if err != nil {
+		return err
+	}
+	if !admin.Admin {
+		return httperr.NewForbidden("not_admin", "You are not an admin.")
+	}
+
+	query := r.urlQueryParams()
+	limit, err := getFeedLimit(query, s.config.PaginationLimit, s.config.PaginationLimitMax)
+	if err != nil {
+		return err
+	}
+	page := 1
+	if spage := query.Get("page"); spage != "" {
+		if page, err = strconv.Atoi(spage); err != nil || page < 1 {
+			return httperr.NewBadRequest("invalid_page", "Invalid page.")
+		}
+	}
+
+	pending := true
+	switch query.Get("filter") {
+	case "", "pending":
+	case "all":
+		pending = false
+	default:
+		return errInvalidFeedFilter
+	}
+
+	res := struct {
+		NoFlags int              `json:"noFlags"`
+		Limit   int              `json:"limit"`
+		Page    int              `json:"page"`
+		Flags   []*core.SpamFlag `json:"flags"`
+	}{
+		Limit: limit,
+		Page:  page,
+	}
+	res.NoFlags, res.Flags, err = core.GetSpamFlags(r.ctx, s.db, admin.ID, pending, limit, page)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(res)
+}
+
+// /api/_spam_flags/{flagID} [PUT]
+//
+// The action URL query parameter is one of dismiss (mark as reviewed), approve
+// (approve the held content and mark as reviewed), or unlimit (lift the shadow
+// limit of the author and mark as reviewed).
+func (s *Server) updateSpamFlag(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	admin, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+	if err != nil {
+		return err
+	}
+	if !admin.Admin {
+		return httperr.NewForbidden("not_admin", "You are not an admin.")
+	}
+
+	flagID, err := strToID(r.muxVar("flagID"))
+	if err != nil {
+		return err
+	}
+	flag, err := core.GetSpamFlag(r.ctx, s.db, flagID)
+	if err != nil {
+		return err
+	}
+
+	switch r.urlQueryParams().Get("action") {
+	case "dismiss":
+	case "approve":
+		if flag.TargetType == core.ContentTypePost {
+			post, err := core.GetPost(r.ctx, s.db, &flag.TargetID, "", r.viewer, true)
+			if err != nil {
+				return err
+			}
+			if post.Held {
+				if err := post.Approve(r.ctx, admin.ID); err != nil {
+					return err
+				}
+			}
+		} else {
+			comment, err := core.GetComment(r.ctx, s.db, flag.TargetID, r.viewer)
+			if err != nil {
+				return err
+			}
+			if comment.Held {
+				if err := comment.Approve(r.ctx, admin.ID); err != nil {
+					return err
+				}
+			}
+		}
+	case "unlimit":
+		if err := core.SetUserShadowLimited(r.ctx, s.db, flag.UserID, false); err != nil {
+			return err
+		}
+	default:
+		return httperr.NewBadRequest("invalid_action", "Unsupported action.")
+	}
+
+	if err := flag.Review(r.ctx, s.db, admin.ID); err != nil {
+		return err
+	}
+	return w.writeJSON(flag)
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/discuitnet/discuit/core"
+	"github.com/discuitnet/discuit/internal/httperr"
+	"github.com/discuitnet/discuit/internal/uid"
+)
+
+// The time within which the second step of a login has to be completed.
+const twoFactorLoginTTL = time.Minute * 5
+
+// twoFactorSetupAllowed reports whether the API endpoint at path can be used
+// by a session that's yet to enable (required) two-factor authentication.
+func twoFactorSetupAllowed(path string) bool {
+	if !strings.HasPrefix(path, "/api/") {
+		return true
+	}
+	for _, p := range []string{"/api/_initial", "/api/_user", "/api/_2fa", "/api/_login", "/api/_logout"} {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// requireSecondFactor is called in place of loginUser when the user has
+// two-factor authentication enabled. The user is remembered in the session
+// until the login is completed (with loginSecondFactor) or it times out.
+func (s *Server) requireSecondFactor(w *responseWriter, r *request, user *core.User) error {
+	r.ses.Values["2fa_uid"] = user.ID.String()
+	r.ses.Values["2fa_at"] = time.Now().Unix()
+	if err := r.ses.Save(w, r.req); err != nil {
+		return err
+	}
+	return w.writeJSON(map[string]any{"twoFactorRequired": true})
+}
+
+// /api/_login/2fa [POST]
+func (s *Server) loginSecondFactor(w *responseWriter, r *request) error {
+	if r.loggedIn {
+		return httperr.NewBadRequest("already_logged_in", "You are already logged in")
+	}
+
+	errNoPendingLogin := httperr.NewBadRequest("no_pending_login", "No login is pending a second step.")
+	hex, _ := r.ses.Values["2fa_uid"].(string)
+	at, _ := r.ses.Values["2fa_at"].(float64)
+	if hex == "" || time.Since(time.Unix(int64(at), 0)) > twoFactorLoginTTL {
+		return errNoPendingLogin
+	}
+	userID, err := uid.FromString(hex)
+	if err != nil {
+		return errNoPendingLogin
+	}
+
+	if err := s.rateLimit(r, "login_2fa_"+hex, time.Minute*5, 10); err != nil {
+		return err
+	}
+
+	values, err := r.unmarshalJSONBodyToStringsMap(true)
+	if err != nil {
+		return err
+	}
+
+	user, err := core.GetUser(r.ctx, s.db, userID, nil)
+	if err != nil {
+		return err
+	}
+	if err := user.VerifyTwoFactor(r.ctx, values["code"]); err != nil {
+		return err
+	}
+
+	delete(r.ses.Values, "2fa_uid")
+	delete(r.ses.Values, "2fa_at")
+	if err := s.loginUser(user, r.ses, w, r.req); err != nil {
+		return err
+	}
+	return w.writeJSON(user)
+}
+
+// /api/_2fa [GET]
+func (s *Server) getTwoFactorStatus(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	user, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+	if err != nil {
+		return err
+	}
+
+	res := struct {
+		Enabled           bool `json:"enabled"`
+		Required          bool `json:"required"`
+		RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+	}{
+		Enabled: user.TwoFactorEnabled,
+	}
+	res.Required, _ = r.ses.Values["2fa_setup_required"].(bool)
+	if user.TwoFactorEnabled {
+		if res.RecoveryCodesLeft, err = user.RecoveryCodesLeft(r.ctx); err != nil {
+			return err
+		}
+	}
+	return w.writeJSON(res)
+}
+
+// /api/_2fa [POST]
+//
+// The action URL query parameter is one of:
+//   - enroll: starts enrolment, and returns the secret and its provisioning URI.
+//   - enable: completes enrolment with a code, and returns the recovery codes.
+//   - disable: turns off two-factor authentication (requires a code).
+//   - recovery_codes: replaces the recovery codes (requires a code).
+func (s *Server) updateTwoFactor(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	user, err := core.GetUser(r.ctx, s.db, *r.viewer, r.viewer)
+	if err != nil {
+		return err
+	}
+
+	if err := s.rateLimit(r, "2fa_"+user.ID.String(), time.Minute*5, 10); err != nil {
+		return err
+	}
+
+	action := r.urlQueryParamsValue("action")
+	values := map[string]string{}
+	if action != "enroll" {
+		if values, err = r.unmarshalJSONBodyToStringsMap(true); err != nil {
+			return err
+		}
+	}
+
+	switch action {
+	case "enroll":
+		issuer := s.config.SiteName
+		if issuer == "" {
+			issuer = "Discuit"
+		}
+		secret, uri, err := user.StartTwoFactorEnrolment(r.ctx, issuer)
+		if err != nil {
+			return err
+		}
+		return w.writeJSON(map[string]string{"secret": secret, "uri": uri})
+	case "enable":
+		codes, err := user.EnableTwoFactor(r.ctx, values["code"])
+		if err != nil {
+			return err
+		}
+		if _, ok := r.ses.Values["2fa_setup_required"]; ok {
+			delete(r.ses.Values, "2fa_setup_required")
+			if err := r.ses.Save(w, r.req); err != nil {
+				return err
+			}
+		}
+		return w.writeJSON(map[string]any{"recoveryCodes": codes})
+	case "disable":
+		if s.config.RequireTwoFactorForMods {
+			mod, err := core.UserModOfAny(r.ctx, s.db, user.ID)
+			if err != nil {
+				return err
+			}
+			if user.Admin || mod {
+				return httperr.NewForbidden("2fa_required", "Admins and mods cannot turn off two-factor authentication.")
+			}
+		}
+		if err := user.VerifyTwoFactor(r.ctx, values["code"]); err != nil {
+			return err
+		}
+		if err := user.DisableTwoFactor(r.ctx); err != nil {
+			return err
+		}
+		return w.writeJSON(map[string]any{"enabled": false})
+	case "recovery_codes":
+		if err := user.VerifyTwoFactor(r.ctx, values["code"]); err != nil {
+			return err
+		}
+		codes, err := user.RegenerateRecoveryCodes(r.ctx)
+		if err != nil {
+			return err
+		}
+		return w.writeJSON(map[string]any{"recoveryCodes": codes})
+	}
+	return httperr.NewBadRequest("invalid_action", "Unsupported action.")
+}
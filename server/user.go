@@ -19,7 +19,6 @@ import (
 	"github.com/discuitnet/discuit/internal/httperr"
 	"github.com/discuitnet/discuit/internal/httputil"
 	"github.com/discuitnet/discuit/internal/uid"
-	"github.com/gomodule/redigo/redis"
 	"github.com/gorilla/mux"
 )
 
@@ -30,6 +29,9 @@ func (s *Server) getUser(w *responseWriter, r *request) error {
 	if err != nil {
 		return err
 	}
+	if err := s.profileViewable(r, user); err != nil {
+		return err
+	}
 
 	if user.IsGhost() {
 		// For deleted accounts, expose the username for this API endpoint only.
@@ -123,7 +125,11 @@ func (s *Server) initial(w *responseWriter, r *request) error {
 		NoUsers        int                 `json:"noUsers"`
 		BannedFrom     []uid.ID            `json:"bannedFrom"`
 		VAPIDPublicKey string              `json:"vapidPublicKey"`
-		Mutes          struct {
+
+		// Site settings that the UI needs.
+		DisableImagePosts bool `json:"disableImagePosts"`
+
+		Mutes struct {
 			CommunityMutes []*core.Mute `json:"communityMutes"`
 			UserMutes      []*core.Mute `json:"userMutes"`
 		} `json:"mutes"`
@@ -181,6 +187,12 @@ func (s *Server) initial(w *responseWriter, r *request) error {
 		return err
 	}
 
+	settings, err := s.siteSettings(r.ctx)
+	if err != nil {
+		return err
+	}
+	response.DisableImagePosts = settings.DisableImagePosts
+
 	return w.writeJSON(response)
 }
 
@@ -231,6 +243,10 @@ func (s *Server) login(w *responseWriter, r *request) error {
 		return err
 	}
 
+	if user.TwoFactorEnabled {
+		return s.requireSecondFactor(w, r, user)
+	}
+
 	if err = s.loginUser(user, r.ses, w, r.req); err != nil {
 		return err
 	}
@@ -277,15 +293,9 @@ func (s *Server) requestOTP(w *responseWriter, r *request) error {
 	// Generate session ID
 	sessionId := uid.New()
 
-	// Save OTP in Redis
-	conn := s.redisPool.Get()
-	defer conn.Close()
-
-	// Create the key for OTP storage
-	key := "otp:" + email + ":" + sessionId.String()
-
-	// Set the OTP code with expiration
-	_, err = conn.Do("SETEX", key, s.config.OtpTTL, otp)
+	// Save OTP with expiration
+	key := email + ":" + sessionId.String()
+	err = s.otps.SaveOTP(key, otp, time.Duration(s.config.OtpTTL)*time.Second)
 	if err != nil {
 		return httperr.NewBadRequest("otp_save_fail", err.Error())
 	}
@@ -332,25 +342,17 @@ func (s *Server) verifyOTP(w *responseWriter, r *request) error {
 		return httperr.NewBadRequest("missing_data", "Missing data")
 	}
 
-	conn := s.redisPool.Get()
-	defer conn.Close()
-
 	// Create the key for OTP storage
-	key := "otp:" + email + ":" + sessionId
+	key := email + ":" + sessionId
 
-	// Retrieve the OTP code from Redis
-	storedOTP, err := redis.String(conn.Do("GET", key))
-	if err == redis.ErrNil {
-		// OTP does not exist or has expired
-		return httperr.NewBadRequest("invalid_or_expired_otp", "Invalid or expired OTP")
-	}
+	// Compare stored OTP with provided OTP
+	valid, err := s.otps.ValidateOTP(key, otp)
 	if err != nil {
 		return httperr.NewBadRequest("otp_retrieve_fail", err.Error())
 	}
-
-	// Compare stored OTP with provided OTP
-	if storedOTP != otp {
-		return httperr.NewBadRequest("invalid_otp", "Invalid OTP")
+	if !valid {
+		// OTP is wrong, does not exist, or has expired
+		return httperr.NewBadRequest("invalid_or_expired_otp", "Invalid or expired OTP")
 	}
 
 	// OTP is valid, proceed with your login or next step
@@ -361,15 +363,19 @@ func (s *Server) verifyOTP(w *responseWriter, r *request) error {
 		return httperr.NewBadRequest("user_not_found", "User not found")
 	}
 
-	// Try logging in user.
-	s.loginUser(user, r.ses, w, r.req)
-
-	// Delete OTP key from Redis
-	_, err = conn.Do("DEL", key)
-	if err != nil {
+	// Delete OTP so that it cannot be reused
+	if err := s.otps.DeleteOTP(key); err != nil {
 		return httperr.NewBadRequest("otp_delete_fail", err.Error())
 	}
 
+	// Try logging in user.
+	if user.TwoFactorEnabled {
+		return s.requireSecondFactor(w, r, user)
+	}
+	if err := s.loginUser(user, r.ses, w, r.req); err != nil {
+		return err
+	}
+
 	// Prepare success response
 	responseData := map[string]interface{}{
 		"message": "OTP verified successfully",
@@ -388,6 +394,9 @@ func (s *Server) signup(w *responseWriter, r *request) error {
 	if r.loggedIn {
 		return httperr.NewBadRequest("already_logged_in", "You are already logged in")
 	}
+	if err := s.signupsAllowed(r); err != nil {
+		return err
+	}
 
 	values, err := r.unmarshalJSONBodyToStringsMap(true)
 	if err != nil {
@@ -419,13 +428,26 @@ func (s *Server) signup(w *responseWriter, r *request) error {
 		return err
 	}
 
+	ipBan, err := s.checkIPBan(r.ctx, r.req, "signup", nil)
+	if err != nil {
+		return err
+	}
+
 	user, err := core.RegisterUser(r.ctx, s.db, username, email, password, phoneCode, phoneNumber, fullName)
 	if err != nil {
 		return err
 	}
+	if ipBan != nil {
+		// The signup is flagged.
+		if err := ipBan.RecordMatch(r.ctx, s.db, &user.ID, "signup", false); err != nil {
+			log.Printf("Error recording ip ban match: %v\n", err)
+		}
+	}
 
 	// Try logging in user.
-	s.loginUser(user, r.ses, w, r.req)
+	if err := s.loginUser(user, r.ses, w, r.req); err != nil {
+		return err
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	return w.writeJSON(user)
@@ -437,6 +459,9 @@ func (s *Server) signupVer2(w *responseWriter, r *request) error {
 	if r.loggedIn {
 		return httperr.NewBadRequest("already_logged_in", "You are already logged in")
 	}
+	if err := s.signupsAllowed(r); err != nil {
+		return err
+	}
 
 	values, err := r.unmarshalJSONBodyToStringsMap(true)
 	if err != nil {
@@ -468,10 +493,21 @@ func (s *Server) signupVer2(w *responseWriter, r *request) error {
 		return err
 	}
 
+	ipBan, err := s.checkIPBan(r.ctx, r.req, "signup", nil)
+	if err != nil {
+		return err
+	}
+
 	user, err := core.RegisterUser(r.ctx, s.db, username, email, password, phoneCode, phoneNumber, fullName)
 	if err != nil {
 		return err
 	}
+	if ipBan != nil {
+		// The signup is flagged.
+		if err := ipBan.RecordMatch(r.ctx, s.db, &user.ID, "signup", false); err != nil {
+			log.Printf("Error recording ip ban match: %v\n", err)
+		}
+	}
 
 	// Identify the user in Novu
 	err = s.IdentifyUser(r.ctx, user)
@@ -480,12 +516,26 @@ func (s *Server) signupVer2(w *responseWriter, r *request) error {
 	}
 
 	// Try logging in user.
-	s.loginUser(user, r.ses, w, r.req)
+	if err := s.loginUser(user, r.ses, w, r.req); err != nil {
+		return err
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	return w.writeJSON(user)
 }
 
+// signupsAllowed returns an error if signups are disabled in the site settings.
+func (s *Server) signupsAllowed(r *request) error {
+	settings, err := s.siteSettings(r.ctx)
+	if err != nil {
+		return err
+	}
+	if settings.DisableSignups {
+		return httperr.NewForbidden("signups_disabled", "Signups are currently disabled.")
+	}
+	return nil
+}
+
 func (s *Server) checkEmailDomain(ctx context.Context, email string) error {
 	// Extraxt the domain from the email
 	parts := strings.Split(email, "@")
@@ -675,6 +725,55 @@ func (s *Server) deleteNotification(w *responseWriter, r *request) error {
 	return w.writeJSON(notif)
 }
 
+// /api/notifications/subscriptions [GET, POST]
+//
+// Lists, and with POST, updates the threads (posts or comment subtrees) that
+// the user has followed or unfollowed.
+func (s *Server) handleThreadSubscriptions(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	if r.req.Method == "POST" {
+		reqBody := struct {
+			PostID     uid.ID  `json:"postId"`
+			CommentID  *uid.ID `json:"commentId"`
+			Subscribed *bool   `json:"subscribed"`
+		}{}
+		if err := r.unmarshalJSONBody(&reqBody); err != nil {
+			return err
+		}
+		if reqBody.Subscribed == nil {
+			return httperr.NewBadRequest("missing_subscribed", "Field subscribed is required.")
+		}
+		if err := core.SubscribeToThread(r.ctx, s.db, *r.viewer, reqBody.PostID, reqBody.CommentID, *reqBody.Subscribed); err != nil {
+			return err
+		}
+	}
+
+	subs, err := core.GetThreadSubscriptions(r.ctx, s.db, *r.viewer, true)
+	if err != nil {
+		return err
+	}
+	return w.writeJSON(subs)
+}
+
+// /api/notifications/subscriptions/{subscriptionID} [DELETE]
+func (s *Server) deleteThreadSubscription(w *responseWriter, r *request) error {
+	if !r.loggedIn {
+		return errNotLoggedIn
+	}
+
+	id, err := strconv.Atoi(r.muxVar("subscriptionID"))
+	if err != nil {
+		return httperr.NewBadRequest("invalid_id", "Invalid subscription ID.")
+	}
+	if err := core.DeleteThreadSubscription(r.ctx, s.db, *r.viewer, id); err != nil {
+		return err
+	}
+	return w.writeString(`{"success":true}`)
+}
+
 // /api/push_subscriptions [POST]
 func (s *Server) pushSubscriptions(w *responseWriter, r *request) error {
 	if !r.loggedIn {
@@ -721,6 +820,14 @@ func (s *Server) updateUserSettings(w *responseWriter, r *request) error {
 		if err = user.Update(r.ctx); err != nil {
 			return err
 		}
+	case "updateNotificationPreferences":
+		prefs := &core.NotificationPreferences{}
+		if err = r.unmarshalJSONBody(prefs); err != nil {
+			return err
+		}
+		if err = user.SetNotificationPreferences(r.ctx, prefs); err != nil {
+			return err
+		}
 	case "changePassword":
 		values, err := r.unmarshalJSONBodyToStringsMap(true)
 		if err != nil {
@@ -217,6 +217,59 @@ const getNotificationInfo = (notification, csrfToken) => {
       const { src } = badgeImage(notif.badgeType);
       setImage(src);
       break;
+    case 'thread_reply':
+      {
+        const where = notif.threadId ? 'a thread you follow' : 'a post you follow';
+        let to = `/${notif.post.communityName}/post/${notif.post.publicId}`;
+        if (notif.noComments === 1) {
+          ret.title = `@${notif.commentAuthor} commented in ${where}`;
+          to += `/${notif.commentId}`;
+        } else {
+          ret.title = `${notif.noComments} new comments in ${where}`;
+          if (notif.threadId) to += `/${notif.threadId}`;
+        }
+        ret.options.body = maxText(notif.post.title);
+        setToURL(to);
+      }
+      break;
+    case 'mention':
+      {
+        let to = `/${notif.post.communityName}/post/${notif.post.publicId}`;
+        if (notif.commentId) {
+          ret.title = `@${notif.authorUsername} mentioned you in a comment`;
+          to += `/${notif.commentId}`;
+        } else {
+          ret.title = `@${notif.authorUsername} mentioned you in a post`;
+        }
+        ret.options.body = maxText(notif.post.title);
+        setToURL(to);
+      }
+      break;
+    case 'community_request':
+      if (notif.request.status === 'approved') {
+        ret.title = `Your request for the community ${notif.request.communityName} has been approved`;
+        ret.options.body = 'You are its moderator.';
+        setToURL(notif.community ? `/${notif.community.name}` : '/communities');
+      } else {
+        ret.title = `Your request for the community ${notif.request.communityName} has been rejected`;
+        ret.options.body = maxText(notif.request.rejectReason || '');
+        setToURL('/communities');
+      }
+      break;
+    case 'data_export':
+      if (notif.export && notif.export.status === 'ready') {
+        ret.title = 'Your data export is ready';
+        ret.options.body = 'Download it before the link expires.';
+        setToURL(notif.export.downloadUrl);
+      } else if (notif.export && notif.export.status === 'failed') {
+        ret.title = 'Your data export failed';
+        ret.options.body = 'You can request a new one from your settings.';
+        setToURL('/settings');
+      } else {
+        ret.title = 'Your data export has expired';
+        setToURL('/settings');
+      }
+      break;
     default: {
       throw new Error('Unkown notification type');
     }
@@ -55,6 +55,7 @@ import Offline from './pages/Offline';
 import AppUpdate from './AppUpdate';
 import PushNotifications from './PushNotifications';
 import { List, Lists } from './pages/Lists';
+import CustomFeed from './pages/CustomFeed';
 import SaveToListModal from './components/SaveToListModal';
 import { getDevicePreference } from './pages/Settings/devicePrefs';
 
@@ -151,20 +152,33 @@ const App = () => {
     f();
   }, [isOnline]);
 
-  // Check if there're new notifications every 5 secs.
+  // Refetch the user when the count of new notifications changes (which the
+  // server pushes through /api/_events). Without EventSource support, poll
+  // every 5 secs instead.
   const user = useSelector((state) => state.main.user);
   const loggedIn = user !== null;
   useEffect(() => {
     if (loggedIn) {
-      const timerId = setInterval(async () => {
+      const refetchUser = async () => {
         try {
           const rUser = await mfetchjson(`/api/_user`);
           dispatch(userLoggedIn(rUser));
         } catch (error) {
           console.error(error);
         }
-      }, 5000);
-      return () => clearInterval(timerId);
+      };
+      if (!window.EventSource) {
+        const timerId = setInterval(refetchUser, 5000);
+        return () => clearInterval(timerId);
+      }
+      const events = new EventSource('/api/_events');
+      events.onmessage = (e) => {
+        const event = JSON.parse(e.data);
+        if (event.type === 'notifications_count') refetchUser();
+      };
+      events.addEventListener('overflow', refetchUser);
+      events.onopen = refetchUser; // catch up on what was missed while disconnected
+      return () => events.close();
     }
   }, [loggedIn]);
 
@@ -333,6 +347,9 @@ const AppSwitch = () => {
         <Route exact path="/@:username/lists/:listName">
           <List />
         </Route>
+        <Route exact path="/@:username/feeds/:feedName">
+          <CustomFeed />
+        </Route>
         <Route exact path="/:name">
           <Community />
         </Route>
@@ -114,6 +114,58 @@ const NotificationItem = ({ notification, ...rest }) => {
           </>
         );
       }
+      case 'thread_reply': {
+        const where = notif.threadId ? 'a thread you follow on post' : 'the post you follow';
+        if (notif.noComments === 1) {
+          return (
+            <>
+              <b>@{notif.commentAuthor}</b> commented in {where} <b>{notif.post.title}</b>.
+            </>
+          );
+        }
+        return (
+          <>
+            {notif.noComments} new comments in {where} <b>{notif.post.title}</b>.
+          </>
+        );
+      }
+      case 'mention': {
+        return (
+          <>
+            <b>@{notif.authorUsername}</b> mentioned you in{' '}
+            {notif.commentId ? 'a comment on post' : 'the post'} <b>{notif.post.title}</b>.
+          </>
+        );
+      }
+      case 'community_request': {
+        if (notif.request.status === 'approved') {
+          return (
+            <>
+              Your request for the community <b>{notif.request.communityName}</b> has been
+              approved. You are its moderator.
+            </>
+          );
+        }
+        return (
+          <>
+            Your request for the community <b>{notif.request.communityName}</b> has been rejected
+            {notif.request.rejectReason ? `: ${notif.request.rejectReason}` : '.'}
+          </>
+        );
+      }
+      case 'data_export': {
+        if (!notif.export) {
+          return <>Your data export has expired.</>;
+        }
+        if (notif.export.status === 'failed') {
+          return <>Your data export failed. You can request a new one.</>;
+        }
+        return (
+          <>
+            Your data export is ready. <b>Click to download it.</b>
+          </>
+        );
+      }
       default: {
         return null; // unknown notification type
       }
@@ -180,6 +232,32 @@ const NotificationItem = ({ notification, ...rest }) => {
       to = `/${notif.communityName}`;
       image = getNotifImage(notif);
       break;
+    case 'thread_reply':
+      to = `/${notif.post.communityName}/post/${notif.post.publicId}`;
+      if (notif.noComments === 1) to += `/${notif.commentId}`;
+      else if (notif.threadId) to += `/${notif.threadId}`;
+      image = getNotifImage(notif);
+      break;
+    case 'mention':
+      to = `/${notif.post.communityName}/post/${notif.post.publicId}`;
+      if (notif.commentId) to += `/${notif.commentId}`;
+      image = getNotifImage(notif);
+      break;
+    case 'community_request':
+      if (notif.community) {
+        to = `/${notif.community.name}`;
+      } else {
+        to = '/communities';
+      }
+      image = getNotifImage(notif);
+      break;
+    case 'data_export':
+      if (notif.export && notif.export.downloadUrl) {
+        to = notif.export.downloadUrl;
+      } else {
+        to = '/settings';
+      }
+      break;
     case 'new_badge':
       to = `/@${viewer.username}`;
       const { src } = badgeImage(notif.badgeType);
@@ -199,6 +277,11 @@ const NotificationItem = ({ notification, ...rest }) => {
       !document.querySelector('#modal-root').contains(e.target)
     ) {
       if (!seen) handleMarkAsSeen();
+      if (to.startsWith('/api/')) {
+        // A file download (of a data export), not a page of the app.
+        window.location.href = to;
+        return;
+      }
       history.push(to, {
         fromNotifications: true,
       });
@@ -1,6 +1,6 @@
-import React from 'react';
+import React, { useState } from 'react';
 import PropTypes from 'prop-types';
-import { toTitleCase, userGroupSingular } from '../../helper';
+import { mfetchjson, toTitleCase, userGroupSingular } from '../../helper';
 import CommunityLink from './CommunityLink';
 import TimeAgo from '../TimeAgo';
 import { useIsMobile, useMuteCommunity, useMuteUser } from '../../hooks';
@@ -9,7 +9,7 @@ import { ButtonMore } from '../Button';
 import { useDispatch, useSelector } from 'react-redux';
 import { UserLink } from '../UserProPic';
 import { userHasSupporterBadge } from '../../pages/User';
-import { saveToListModalOpened } from '../../slices/mainSlice';
+import { saveToListModalOpened, snackAlert, snackAlertError } from '../../slices/mainSlice';
 
 const PostCardHeadingDetails = ({
   post,
@@ -55,6 +55,25 @@ const PostCardHeadingDetails = ({
     communityName: post.communityName,
   });
 
+  const [isHidden, setIsHidden] = useState(Boolean(post.isHidden));
+  const handleHide = async () => {
+    try {
+      if (isHidden) {
+        await mfetchjson(`/api/hidden_posts/${post.id}`, { method: 'DELETE' });
+        dispatch(snackAlert('Đã bỏ ẩn bài viết.'));
+      } else {
+        await mfetchjson('/api/hidden_posts', {
+          method: 'POST',
+          body: JSON.stringify({ postId: post.id }),
+        });
+        dispatch(snackAlert('Đã ẩn bài viết khỏi bảng tin.'));
+      }
+      setIsHidden(!isHidden);
+    } catch (error) {
+      dispatch(snackAlertError(error));
+    }
+  };
+
   const isAuthorSupporter = userHasSupporterBadge(post.author);
   const isUsernameGhost = post.userDeleted && !viewerAdmin;
 
@@ -109,6 +128,9 @@ const PostCardHeadingDetails = ({
               >
                 Lưu vào danh sách
               </button>
+              <button className="button-clear dropdown-item" onClick={handleHide}>
+                {isHidden ? 'Bỏ ẩn bài viết' : 'Ẩn bài viết'}
+              </button>
               {onRemoveFromList && (
                 <button
                   className="button-clear dropdown-item"
@@ -0,0 +1,36 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+import { Link } from 'react-router-dom';
+import { Helmet } from 'react-helmet-async';
+import Sidebar from '../../components/Sidebar';
+
+const QuarantineWarning = ({ community, onContinue }) => {
+  return (
+    <div className="page-content page-notfound">
+      <Helmet>
+        <title>{`${community.name}`}</title>
+        <meta name="robots" content="noindex" />
+      </Helmet>
+      <Sidebar />
+      <h1>{`${community.name} is quarantined`}</h1>
+      <p>
+        This community has been quarantined by the admins. Its content may be offensive or
+        upsetting to some users.
+      </p>
+      {community.quarantineReason && <p>{`Reason: ${community.quarantineReason}`}</p>}
+      <p>
+        <button className="button-main" onClick={onContinue}>
+          Continue
+        </button>
+      </p>
+      <Link to="/">Go home.</Link>
+    </div>
+  );
+};
+
+QuarantineWarning.propTypes = {
+  community: PropTypes.object.isRequired,
+  onContinue: PropTypes.func.isRequired,
+};
+
+export default QuarantineWarning;
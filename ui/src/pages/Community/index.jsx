@@ -18,6 +18,7 @@ import PageLoading from '../../components/PageLoading';
 import NotFound from '../NotFound';
 import CommunityProPic from '../../components/CommunityProPic';
 import Banner from './Banner';
+import QuarantineWarning from './QuarantineWarning';
 import { useMuteCommunity } from '../../hooks';
 import Dropdown from '../../components/Dropdown';
 import { ButtonMore } from '../../components/Button';
@@ -71,6 +72,14 @@ const Community = () => {
     setTab('posts');
   }, [location]);
 
+  const quarantineKey = `quarantine_accepted_${name.toLowerCase()}`;
+  const [quarantineAccepted, setQuarantineAccepted] = useState(
+    sessionStorage.getItem(quarantineKey) === 'true'
+  );
+  useEffect(() => {
+    setQuarantineAccepted(sessionStorage.getItem(quarantineKey) === 'true');
+  }, [quarantineKey]);
+
   const { toggleMute: toggleCommunityMute, displayText: muteDisplayText } = useMuteCommunity(
     community ? { communityId: community.id, communityName: community.name } : {}
   );
@@ -82,6 +91,14 @@ const Community = () => {
     return <PageLoading />;
   }
 
+  if (community.quarantined && !community.userJoined && !quarantineAccepted) {
+    const handleContinue = () => {
+      sessionStorage.setItem(quarantineKey, 'true');
+      setQuarantineAccepted(true);
+    };
+    return <QuarantineWarning community={community} onContinue={handleContinue} />;
+  }
+
   const renderRules = () => {
     if (community.rules) {
       return <Rules rules={community.rules} communityName={community.name} />;
@@ -0,0 +1,71 @@
+import React, { useEffect, useState } from 'react';
+import { useDispatch } from 'react-redux';
+import { useParams } from 'react-router-dom';
+import { Helmet } from 'react-helmet-async';
+import Link from '../components/Link';
+import Sidebar from '../components/Sidebar';
+import PostsFeed from '../views/PostsFeed';
+import PageNotLoaded from './PageNotLoaded';
+import { mfetch } from '../helper';
+import { snackAlertError } from '../slices/mainSlice';
+
+const CustomFeed = () => {
+  const dispatch = useDispatch();
+  const { username, feedName } = useParams();
+
+  const [feed, setFeed] = useState(null);
+  const [loading, setLoading] = useState('loading');
+  useEffect(() => {
+    setLoading('loading');
+    (async () => {
+      try {
+        const res = await mfetch(`/api/users/${username}/feeds/${feedName}`);
+        if (!res.ok) {
+          if (res.status === 404) {
+            setLoading('notfound');
+            return;
+          }
+          throw new Error(await res.text());
+        }
+        setFeed(await res.json());
+        setLoading('loaded');
+      } catch (error) {
+        setLoading('error');
+        dispatch(snackAlertError(error));
+      }
+    })();
+  }, [username, feedName]);
+
+  if (loading !== 'loaded') {
+    return <PageNotLoaded loading={loading} />;
+  }
+
+  return (
+    <div className="page-content page-home page-custom-feed wrap page-grid">
+      <Helmet>
+        <title>{feed.displayName}</title>
+      </Helmet>
+      <Sidebar />
+      <main className="posts">
+        <div className="card card-padding custom-feed-head">
+          <h1>{feed.displayName}</h1>
+          <div className="custom-feed-by">
+            Bảng tin của <Link to={`/@${feed.username}`}>@{feed.username}</Link>
+            {!feed.public && ' (riêng tư)'}
+          </div>
+          {feed.description && <p>{feed.description}</p>}
+          <div className="custom-feed-communities">
+            {feed.communities.map((community) => (
+              <Link key={community.id} to={`/${community.name}`}>
+                {community.name}
+              </Link>
+            ))}
+          </div>
+        </div>
+        <PostsFeed feedType="custom" customFeedId={feed.id} />
+      </main>
+    </div>
+  );
+};
+
+export default CustomFeed;
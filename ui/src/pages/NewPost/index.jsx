@@ -39,6 +39,7 @@ const NewPost = () => {
   const [userGroup, setUserGroup] = useState('normal');
 
   const bannedFrom = useSelector((state) => state.main.bannedFrom);
+  const disableImagePosts = useSelector((state) => state.main.disableImagePosts);
   const [community, setCommunity] = useState(null);
   const [isBanned, setIsBanned] = useState(false);
   const [isMod, setIsMod] = useState(false);
@@ -327,7 +328,7 @@ const NewPost = () => {
     );
   }
 
-  const isImagePostsDisabled = CONFIG.disableImagePosts === true;
+  const isImagePostsDisabled = disableImagePosts === true;
 
   return (
     <div className="page-new">
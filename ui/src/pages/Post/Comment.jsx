@@ -63,6 +63,11 @@ const Comment = ({
     });
   };
 
+  useEffect(() => {
+    // The comment was updated in the store (an edit in a live thread).
+    _setComment((c) => ({ ...c, ...node.comment }));
+  }, [node.comment]);
+
   const commentShareURL = `/${community.name}/post/${postId}/${comment.id}`;
 
   const deleted = comment.deletedAt !== null;
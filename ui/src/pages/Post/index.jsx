@@ -32,7 +32,7 @@ import LinkImage from '../../components/PostCard/LinkImage';
 import CommunityCard from './CommunityCard';
 import Spinner from '../../components/Spinner';
 import { postAdded } from '../../slices/postsSlice';
-import { commentsAdded, newCommentAdded } from '../../slices/commentsSlice';
+import { commentUpdated, commentsAdded, newCommentAdded } from '../../slices/commentsSlice';
 import { communityAdded } from '../../slices/communitiesSlice';
 import { useLocation } from 'react-router-dom';
 import { getEmbedComponent } from '../../components/PostCard';
@@ -118,6 +118,44 @@ const Post = () => {
     dispatch(newCommentAdded(post.publicId, comment));
   };
 
+  // Stream the new and edited comments of live threads.
+  const isLive = Boolean(post && post.live && !post.locked);
+  useEffect(() => {
+    if (!isLive || !window.EventSource) return;
+    const postId = post.publicId;
+    const events = new EventSource(`/api/_events?post=${post.id}`);
+    events.onmessage = async (e) => {
+      const event = JSON.parse(e.data);
+      try {
+        if (event.type === 'new_comment' || event.type === 'comment_edited') {
+          const comment = await mfetchjson(`/api/comments/${event.data.commentId}`);
+          if (event.type === 'new_comment') {
+            dispatch(newCommentAdded(postId, comment));
+          } else {
+            dispatch(commentUpdated(postId, comment));
+          }
+        } else if (event.type === 'post_locked') {
+          dispatch(postAdded(await mfetchjson(`/api/posts/${postId}`)));
+        }
+      } catch (error) {
+        console.error(error);
+      }
+    };
+    return () => events.close();
+  }, [isLive, post && post.id]);
+
+  const handleLiveToggle = async () => {
+    try {
+      const rpost = await mfetchjson(
+        `/api/posts/${post.publicId}?action=${post.live ? 'unlive' : 'live'}`,
+        { method: 'PUT' }
+      );
+      dispatch(postAdded(rpost));
+    } catch (error) {
+      dispatch(snackAlertError(error));
+    }
+  };
+
   const [deleteAs, setDeleteAs] = useState('normal');
   const [deleteModalOpen, _setDeleteModalOpen] = useState(false);
   const [canDeletePostContent, setCanDeletePostContent] = useState(false);
@@ -375,8 +413,13 @@ const Post = () => {
                 <PostImageGallery post={post} isMobile={isMobile} keyboardControlsOn />
               )}
               {isEmbed && <Embed url={embedURL} />}
-              {(isLocked || post.deleted) && (
+              {(isLocked || post.deleted || isLive) && (
                 <div className="post-card-banners">
+                  {isLive && (
+                    <div className="post-card-banner is-live">
+                      Chủ đề trực tiếp: bình luận mới nhất hiển thị trước và tự động cập nhật.
+                    </div>
+                  )}
                   {isLocked && (
                     <div
                       className="post-card-banner is-locked"
@@ -445,6 +488,13 @@ const Post = () => {
                       >
                         {isLocked ? 'Mở' : 'Khóa'}
                       </button>
+                      <button
+                        className="button-clear dropdown-item"
+                        onClick={handleLiveToggle}
+                        disabled={post.deleted}
+                      >
+                        {post.live ? 'Tắt chế độ trực tiếp' : 'Bật chế độ trực tiếp'}
+                      </button>
                       <button
                         className="button-clear dropdown-item"
                         onClick={() => setDeleteModalOpen(true, 'mods')}
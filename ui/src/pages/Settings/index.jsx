@@ -54,7 +54,15 @@ const Settings = () => {
   };
   const [homeFeed, setHomeFeed] = useState(user.homeFeed);
 
+  const emailDigestOptions = {
+    off: 'Tắt',
+    daily: 'Hàng ngày',
+    weekly: 'Hàng tuần',
+  };
+  const [emailDigest, setEmailDigest] = useState(user.emailDigest || 'off');
+
   const [rememberFeedSort, setRememberFeedSort] = useState(user.rememberFeedSort);
+  const [hideViewedPosts, setHideViewedPosts] = useState(user.hideViewedPosts);
   const [enableEmbeds, setEnableEmbeds] = useState(!user.embedsOff);
   const [showUserProfilePictures, setShowUserProfilePictures] = useState(
     !user.hideUserProfilePictures
@@ -70,8 +78,10 @@ const Settings = () => {
   const [changed, resetChanged] = useIsChanged([
     aboutMe /*, email*/,
     notifsSettings,
+    emailDigest,
     homeFeed,
     rememberFeedSort,
+    hideViewedPosts,
     enableEmbeds,
     email,
     showUserProfilePictures,
@@ -130,8 +140,10 @@ const Settings = () => {
           aboutMe,
           upvoteNotificationsOff: !notifsSettings.upvoteNotifs,
           replyNotificationsOff: !notifsSettings.replyNotifs,
+          emailDigest,
           homeFeed,
           rememberFeedSort,
+          hideViewedPosts,
           embedsOff: !enableEmbeds,
           email,
           hideUserProfilePictures: !showUserProfilePictures,
@@ -346,6 +358,16 @@ const Settings = () => {
                 onChange={(e) => setRememberFeedSort(e.target.checked)}
               />
             </div>
+            <div className="checkbox is-check-last">
+              <label htmlFor="c6">Ẩn bài viết đã xem khỏi bảng tin</label>
+              <input
+                className="switch"
+                id="c6"
+                type="checkbox"
+                checked={hideViewedPosts}
+                onChange={(e) => setHideViewedPosts(e.target.checked)}
+              />
+            </div>
             <div className="checkbox is-check-last">
               <label htmlFor="c4">Chấp nhận nhúng</label>
               <input
@@ -415,6 +437,25 @@ const Settings = () => {
                 onChange={(e) => setNotifsSettings('replyNotifs', e.target.checked)}
               />
             </div>
+            <div>
+              <div>Email tóm tắt</div>
+              <Dropdown
+                aligned="right"
+                target={
+                  <button className="select-bar-dp-target">{emailDigestOptions[emailDigest]}</button>
+                }
+              >
+                <div className="dropdown-list">
+                  {Object.keys(emailDigestOptions)
+                    .filter((key) => key != emailDigest)
+                    .map((key) => (
+                      <div key={key} className="dropdown-item" onClick={() => setEmailDigest(key)}>
+                        {emailDigestOptions[key]}
+                      </div>
+                    ))}
+                </div>
+              </Dropdown>
+            </div>
             {/*notificationsPermissions === 'granted' && (
               <button onClick={handleDisablePushNotifications} style={{ alignSelf: 'flex-start' }}>
                 Disable push notifications
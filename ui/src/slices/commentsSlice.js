@@ -1,4 +1,4 @@
-import { addComment, commentsTree, searchTree } from './commentsTree';
+import { addComment, commentsTree, searchTree, updateComment } from './commentsTree';
 import { commentsCountIncremented } from './postsSlice';
 
 const initialState = {
@@ -20,6 +20,7 @@ export const defaultCommentZIndex = 100000;
 
 const typeCommentsAdded = 'comments/commentsAdded';
 const typeNewCommentAdded = 'comments/newCommentAdded';
+const typeCommentUpdated = 'comments/commentUpdated';
 const typeReplyCommentsAdded = 'comments/replyCommentsAdded';
 const typeMoreCommentsAdded = 'comments/moreCommentsAdded';
 
@@ -93,6 +94,21 @@ export default function commentsReducer(state = initialState, action) {
         },
       };
     }
+    case typeCommentUpdated: {
+      const { comment, postId } = action.payload;
+      const root = state.items[postId].comments;
+      updateComment(root, comment);
+      return {
+        ...state,
+        items: {
+          ...state.items,
+          [postId]: {
+            ...state.items[postId],
+            comments: { ...root },
+          },
+        },
+      };
+    }
     case typeReplyCommentsAdded: {
       const { postId, comments } = action.payload;
       const newComments = [];
@@ -128,11 +144,25 @@ export const commentsAdded = (postId, comments, next) => {
   };
 };
 
-export const newCommentAdded = (postId, comment) => (dispatch) => {
+export const newCommentAdded = (postId, comment) => (dispatch, getState) => {
+  // The comment might have already arrived through the live stream of the post
+  // (or the other way around). Replies to comments that aren't loaded are
+  // skipped.
+  const item = getState().comments.items[postId];
+  if (item && item.comments) {
+    if (searchTree(item.comments, comment.id) !== null) return;
+    if (comment.parentId !== null && searchTree(item.comments, comment.parentId) === null) return;
+  }
   dispatch({ type: typeNewCommentAdded, payload: { postId, comment } });
   dispatch(commentsCountIncremented(postId));
 };
 
+export const commentUpdated = (postId, comment) => (dispatch, getState) => {
+  const item = getState().comments.items[postId];
+  if (!(item && item.comments && searchTree(item.comments, comment.id) !== null)) return;
+  dispatch({ type: typeCommentUpdated, payload: { postId, comment } });
+};
+
 export const replyCommentsAdded = (postId, comments) => {
   return { type: typeReplyCommentsAdded, payload: { postId, comments } };
 };
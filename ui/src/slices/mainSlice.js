@@ -12,6 +12,7 @@ const initialNotifications = {
 const initialState = {
   user: null, // Meaning not logged in.
   vapidPublicKey: null, // applicationServerKey for the Web Push API.
+  disableImagePosts: false, // A site setting.
   appInstallButton: {
     show: false,
     deferredPrompt: undefined,
@@ -377,6 +378,7 @@ export default function mainReducer(state = initialState, action) {
 export const initialValuesAdded = (initial) => {
   const payload = {
     vapidPublicKey: initial.vapidPublicKey,
+    disableImagePosts: initial.disableImagePosts === true,
   };
   return { type: 'main/initialValuesAdded', payload };
 };
@@ -25,6 +25,8 @@ const sortOptions = [
   { text: 'Tin nóng', id: 'hot' },
   { text: 'Hoạt động', id: 'activity' },
   { text: 'Mới nhất', id: 'latest' },
+  { text: 'Đang lên', id: 'rising' },
+  { text: 'Gây tranh cãi', id: 'controversial' },
   { text: 'Ngày', id: 'day' },
   { text: 'Tuần', id: 'week' },
   { text: 'Tháng', id: 'month' },
@@ -94,7 +96,7 @@ function useFeedSort(rememberLastSort = false) {
   return [sort, setSort];
 }
 
-const PostsFeed = ({ feedType = 'all', communityId = null }) => {
+const PostsFeed = ({ feedType = 'all', communityId = null, customFeedId = null }) => {
   const dispatch = useDispatch();
   // const history = useHistory();
 
@@ -114,6 +116,7 @@ const PostsFeed = ({ feedType = 'all', communityId = null }) => {
     urlParams.set('feed', 'home');
   }
   if (communityId !== null) urlParams.set('communityId', communityId);
+  if (customFeedId !== null) urlParams.set('customFeedId', customFeedId);
   const endpoint = `${baseURL}?${urlParams.toString()}`; // api endpoint.
 
   // Only called on button clicks (not history API changes)
@@ -215,7 +218,8 @@ const PostsFeed = ({ feedType = 'all', communityId = null }) => {
 
 PostsFeed.propTypes = {
   communityId: PropTypes.string,
-  feedType: PropTypes.oneOf(['all', 'subscriptions', 'community']),
+  customFeedId: PropTypes.number,
+  feedType: PropTypes.oneOf(['all', 'subscriptions', 'community', 'custom']),
 };
 
 export default PostsFeed;
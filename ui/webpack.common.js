@@ -31,7 +31,6 @@ function readYamlConfigFile() {
     'discordURL',
     'githubURL',
     'substackURL',
-    'disableImagePosts',
     'disableForumCreation',
     'forumCreationReqPoints',
     'defaultFeedSort',